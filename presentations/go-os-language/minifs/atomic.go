@@ -0,0 +1,143 @@
+package minifs
+
+import (
+	"errors"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// tempAttempts es cuántos nombres prueban CreateTemp y MkdirTemp antes de
+// rendirse, como en el paquete os
+const tempAttempts = 10000
+
+// CreateExclusive crea path y lo abre, como open(2) con O_CREAT|O_EXCL: si
+// ya existe falla con un error que cumple errors.Is(err, os.ErrExist), en
+// lugar de sobrescribirlo como CreateFile. La comprobación y la creación
+// ocurren con el candado tomado, así que de dos llamadas con la misma
+// ruta sólo una gana.
+func (fs *FileSystem) CreateExclusive(path string, mode os.FileMode) (*File, error) {
+	if m, rel := fs.mountFor(path); m != nil {
+		if err := m.writable("create", path); err != nil {
+			return nil, err
+		}
+		c, ok := m.backend.(exclusiveCreator)
+		if !ok {
+			return nil, errors.New("el sistema montado no permite crear en exclusiva: " + path)
+		}
+		f, err := c.CreateExclusive(rel, mode)
+		if err != nil {
+			return nil, err
+		}
+		f.name = path
+		return f, nil
+	}
+
+	if err := fs.lock("create", path); err != nil {
+		return nil, err
+	}
+	defer fs.mu.Unlock()
+
+	parent, name, err := fs.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, &os.PathError{Op: "create", Path: path, Err: syscall.EEXIST}
+	}
+
+	parent.mu.Lock()
+	if _, _, exists := fs.child(parent, name); exists {
+		parent.mu.Unlock()
+		return nil, &os.PathError{Op: "create", Path: path, Err: syscall.EEXIST}
+	}
+	node := fs.addFile(parent, name, nil, mode)
+	parent.mu.Unlock()
+
+	return fs.open(node, path), nil
+}
+
+// CreateTemp crea y abre un archivo nuevo en dir, como os.CreateTemp: el
+// nombre sale de pattern cambiando el último "*" por una cadena al azar,
+// o agregándola al final si no hay "*". Un dir vacío es la raíz. Name del
+// File devuelto es la ruta elegida; quitarlo es cosa del llamador.
+func (fs *FileSystem) CreateTemp(dir, pattern string) (*File, error) {
+	var f *File
+	_, err := tempName("createtemp", dir, pattern, func(path string) (err error) {
+		f, err = fs.CreateExclusive(path, 0600)
+		return err
+	})
+	return f, err
+}
+
+// MkdirTemp crea un directorio nuevo en dir, con un nombre elegido como
+// en CreateTemp, y devuelve su ruta
+func (fs *FileSystem) MkdirTemp(dir, pattern string) (string, error) {
+	return tempName("mkdirtemp", dir, pattern, func(path string) error {
+		err := fs.CreateDir(path, 0700)
+		if err != nil && strings.Contains(err.Error(), "ya existe") {
+			return os.ErrExist
+		}
+		return err
+	})
+}
+
+// tempName prueba nombres al azar sacados de pattern hasta que create no
+// falle con os.ErrExist
+func tempName(op, dir, pattern string, create func(path string) error) (string, error) {
+	if strings.ContainsRune(pattern, '/') {
+		return "", &os.PathError{Op: op, Path: pattern, Err: errors.New("el patrón contiene un separador")}
+	}
+	if dir == "" {
+		dir = "/"
+	}
+	prefix, suffix := pattern, ""
+	if i := strings.LastIndexByte(pattern, '*'); i >= 0 {
+		prefix, suffix = pattern[:i], pattern[i+1:]
+	}
+
+	for try := 0; try < tempAttempts; try++ {
+		path := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10)+suffix)
+		err := create(path)
+		if err == nil {
+			return path, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return "", err
+		}
+	}
+	return "", &os.PathError{Op: op, Path: filepath.Join(dir, pattern), Err: syscall.EEXIST}
+}
+
+// WriteFileAtomic reemplaza el contenido de path sin que nadie pueda ver
+// un archivo a medio escribir: escribe data en un temporal del mismo
+// directorio y lo renombra sobre path. Si algo falla antes del Rename,
+// path queda como estaba y el temporal se elimina.
+func (fs *FileSystem) WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	path = filepath.Clean("/" + path)
+	dir, base := filepath.Split(path)
+
+	var tmp *File
+	tmpPath, err := tempName("writeatomic", dir, "."+base+".tmp*", func(p string) (err error) {
+		tmp, err = fs.CreateExclusive(p, perm)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = fs.Rename(tmpPath, path)
+	}
+	if err != nil {
+		fs.Remove(tmpPath)
+	}
+	return err
+}
@@ -0,0 +1,178 @@
+package minifs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCreateExclusive(t *testing.T) {
+	fs := newCheckedFS(t)
+
+	f, err := fs.CreateExclusive("/lock", 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("pid 1"))
+	f.Close()
+
+	if _, err := fs.CreateExclusive("/lock", 0600); !errors.Is(err, os.ErrExist) {
+		t.Errorf("Se esperaba ErrExist: %v", err)
+	}
+	if data, _ := fs.ReadFile("/lock"); string(data) != "pid 1" {
+		t.Errorf("CreateExclusive modificó el existente: %q", data)
+	}
+
+	// De muchas llamadas simultáneas con la misma ruta gana una sola
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	wins := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f, err := fs.CreateExclusive("/carrera", 0644)
+			if err == nil {
+				f.Close()
+				mu.Lock()
+				wins++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if wins != 1 {
+		t.Errorf("Ganaron %d creaciones", wins)
+	}
+
+	other := NewFileSystem()
+	fs.CreateDir("/mnt", 0755)
+	fs.Mount("/mnt", other, MountOptions{})
+	if f, err := fs.CreateExclusive("/mnt/a", 0644); err != nil || f.Name() != "/mnt/a" {
+		t.Errorf("CreateExclusive en un montaje: %v", err)
+	} else {
+		f.Close()
+	}
+	if _, err := fs.CreateExclusive("/mnt/a", 0644); !errors.Is(err, os.ErrExist) {
+		t.Errorf("CreateExclusive en un montaje con el archivo existente: %v", err)
+	}
+}
+
+func TestCreateTemp(t *testing.T) {
+	fs := newCheckedFS(t)
+	fs.CreateDir("/tmp", 0755)
+
+	seen := map[string]bool{}
+	for i := 0; i < 50; i++ {
+		f, err := fs.CreateTemp("/tmp", "subida-*.part")
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := f.Name()
+		f.Close()
+		if !strings.HasPrefix(name, "/tmp/subida-") || !strings.HasSuffix(name, ".part") {
+			t.Errorf("Nombre fuera del patrón: %s", name)
+		}
+		if seen[name] {
+			t.Errorf("Nombre repetido: %s", name)
+		}
+		seen[name] = true
+	}
+
+	dir, err := fs.MkdirTemp("", "trabajo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := fs.Stat(dir); err != nil || !info.IsDir || !strings.HasPrefix(dir, "/trabajo") {
+		t.Errorf("MkdirTemp: %s %v", dir, err)
+	}
+
+	if _, err := fs.CreateTemp("/tmp", "a/b*"); err == nil {
+		t.Error("Un patrón con separador debería fallar")
+	}
+	if _, err := fs.CreateTemp("/no/existe", "x*"); err == nil || errors.Is(err, os.ErrExist) {
+		t.Errorf("CreateTemp en un directorio inexistente: %v", err)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	fs := newCheckedFS(t)
+	fs.MkdirAll("/etc", 0755)
+	fs.WriteFile("/etc/app.conf", []byte("version=1"))
+
+	// Un lector con el archivo viejo abierto lo sigue viendo entero
+	old, _ := fs.Open("/etc/app.conf")
+	defer old.Close()
+
+	if err := fs.WriteFileAtomic("/etc/app.conf", []byte("version=2"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := fs.ReadFile("/etc/app.conf"); string(data) != "version=2" {
+		t.Errorf("Contenido nuevo: %q", data)
+	}
+	if info, _ := fs.Stat("/etc/app.conf"); info.Mode.Perm() != 0600 {
+		t.Errorf("Permisos: %v", info.Mode)
+	}
+	if data, _ := io.ReadAll(old); string(data) != "version=1" {
+		t.Errorf("El lector vio otro contenido: %q", data)
+	}
+
+	// Si el Rename falla el destino queda igual y no quedan temporales
+	fs.CreateDir("/etc/dir", 0755)
+	if err := fs.WriteFileAtomic("/etc/dir", []byte("x"), 0644); err == nil {
+		t.Error("WriteFileAtomic sobre un directorio debería fallar")
+	}
+	if infos, _ := fs.ListDir("/etc"); len(infos) != 2 {
+		t.Errorf("Quedaron temporales: %v", infos)
+	}
+}
+
+func TestWriteFileAtomicVersions(t *testing.T) {
+	fs := newCheckedFS(t)
+	fs.SetVersioning(VersioningOptions{MaxVersions: 5, Trash: true})
+	fs.WriteFile("/app.conf", []byte("v1"))
+	fs.WriteFile("/app.conf", []byte("v2"))
+
+	if err := fs.WriteFileAtomic("/app.conf", []byte("v3"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// El reemplazo guarda una revisión como WriteFile y no llena la papelera
+	versions, err := fs.Versions("/app.conf")
+	if err != nil || len(versions) != 2 {
+		t.Fatalf("Revisiones tras WriteFileAtomic: %v %v", versions, err)
+	}
+	for n, want := range []string{"v2", "v1"} {
+		if data, _ := fs.ReadVersion("/app.conf", n+1); string(data) != want {
+			t.Errorf("Revisión %d: %q, se esperaba %q", n+1, data, want)
+		}
+	}
+	if trash := fs.Trash(); len(trash) != 0 {
+		t.Errorf("El reemplazo fue a la papelera: %v", trash)
+	}
+
+	if err := fs.Restore("/app.conf", 1); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := fs.ReadFile("/app.conf"); string(data) != "v2" {
+		t.Errorf("Restore tras WriteFileAtomic: %q", data)
+	}
+}
+
+func TestRenameHardLink(t *testing.T) {
+	fs := newCheckedFS(t)
+	fs.WriteFile("/a", []byte("a"))
+	fs.Link("/a", "/b")
+
+	// Como rename(2), renombrar sobre otro enlace al mismo archivo no
+	// hace nada
+	if err := fs.Rename("/a", "/b"); err != nil {
+		t.Fatal(err)
+	}
+	if !fs.Exists("/a") || !fs.Exists("/b") {
+		t.Error("Rename entre dos enlaces al mismo archivo cambió el árbol")
+	}
+}
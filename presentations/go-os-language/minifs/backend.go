@@ -0,0 +1,20 @@
+package minifs
+
+import "os"
+
+// Backend es el conjunto completo de operaciones de un sistema de archivos
+// de minifs. *FileSystem lo implementa, y las envolturas como FaultFS
+// también, así que se pueden apilar y usar donde se espera un Volume.
+type Backend interface {
+	Volume
+	MkdirAll(path string, mode os.FileMode) error
+	WriteFile(path string, content []byte) error
+	AppendFile(path string, content []byte) error
+	Truncate(path string, size int64) error
+	Remove(path string) error
+	Rename(oldPath, newPath string) error
+	Walk(path string, walkFn func(path string, info FileInfo) error) error
+	Size(path string) (int64, error)
+}
+
+var _ Backend = (*FileSystem)(nil)
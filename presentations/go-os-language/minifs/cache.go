@@ -0,0 +1,417 @@
+package minifs
+
+import (
+	"container/list"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// CacheOptions convierte al FileSystem en una caché con un límite de
+// memoria para el contenido de los archivos. Cuando se pasa del límite se
+// desalojan los archivos usados hace más tiempo (LRU) y se recargan solos
+// la próxima vez que se leen o escriben.
+type CacheOptions struct {
+	// MaxBytes es la memoria máxima para contenido, contada en páginas
+	// reservadas; cero desactiva la caché
+	MaxBytes int64
+
+	// SpillDir es un directorio del anfitrión donde se guarda el contenido
+	// desalojado. Cada FileSystem crea ahí su propio subdirectorio.
+	SpillDir string
+
+	// Loader reproduce el contenido de un archivo a partir de la ruta que
+	// tenía al activar la caché, aunque después se haya renombrado. Los
+	// archivos que ya existían al activar la caché y los que se cargaron
+	// con Loader se descartan al desalojarlos en lugar de guardarse en
+	// SpillDir, mientras no se modifiquen.
+	Loader func(path string) ([]byte, error)
+}
+
+// CacheStats son los contadores de la caché
+type CacheStats struct {
+	Hits      uint64 // accesos a contenido que estaba en memoria
+	Misses    uint64 // accesos que tuvieron que recargar el contenido
+	Evictions uint64 // archivos desalojados
+	Spills    uint64 // desalojos que escribieron en SpillDir
+	Drops     uint64 // desalojos que descartaron el contenido
+	Errors    uint64 // desalojos que fallaron al escribir en SpillDir
+
+	Resident int64 // bytes en memoria
+	MaxBytes int64
+}
+
+// cacheState es dónde está el contenido de un archivo
+type cacheState int
+
+const (
+	cacheResident cacheState = iota
+	cacheSpilled
+	cacheDropped
+)
+
+// cacheEntry sigue un archivo dentro de la caché
+type cacheEntry struct {
+	node   *Node
+	elem   *list.Element
+	state  cacheState
+	charge int64  // bytes que ocupaba la última vez que se contó
+	pins   int    // operaciones en curso sobre su contenido
+	clean  bool   // Loader puede reproducir el contenido
+	source string // ruta con la que Loader lo reproduce
+}
+
+// cache lleva la cuenta de la memoria y el orden de uso de los archivos.
+// Su candado se toma antes que el de cualquier nodo.
+type cache struct {
+	mu       sync.Mutex
+	fs       *FileSystem
+	opts     CacheOptions
+	spillDir string
+
+	lru      *list.List // de *cacheEntry, el más reciente al frente
+	entries  map[*Node]*cacheEntry
+	resident int64
+	stats    CacheStats
+}
+
+// SetCache activa, cambia o desactiva (con MaxBytes cero) el modo caché.
+// Necesita SpillDir, Loader o ambos para poder desalojar. Al desactivarlo
+// se recarga todo el contenido desalojado.
+func (fs *FileSystem) SetCache(opts CacheOptions) error {
+	if err := fs.lock("setcache", "/"); err != nil {
+		return err
+	}
+	defer fs.mu.Unlock()
+
+	if opts.MaxBytes < 0 {
+		return errors.New("límite de memoria negativo")
+	}
+	if opts.MaxBytes > 0 && opts.SpillDir == "" && opts.Loader == nil {
+		return errors.New("la caché necesita SpillDir o Loader para desalojar")
+	}
+
+	old := fs.cache
+	if old != nil {
+		// Se recarga todo y se empieza de cero con las opciones nuevas
+		if err := old.close(); err != nil {
+			return err
+		}
+		fs.cache = nil
+	}
+	if opts.MaxBytes == 0 {
+		return nil
+	}
+
+	c := &cache{
+		fs:      fs,
+		opts:    opts,
+		lru:     list.New(),
+		entries: make(map[*Node]*cacheEntry),
+	}
+	if opts.SpillDir != "" {
+		dir, err := os.MkdirTemp(opts.SpillDir, "minifs-")
+		if err != nil {
+			return err
+		}
+		c.spillDir = dir
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, node := range fs.inodes {
+		if node.nodeType == FileNode {
+			c.add(node, true)
+		}
+	}
+	c.evict()
+
+	fs.cache = c
+	return nil
+}
+
+// CacheStats devuelve los contadores de la caché; todo en cero si no está
+// activa
+func (fs *FileSystem) CacheStats() CacheStats {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if fs.cache == nil {
+		return CacheStats{}
+	}
+
+	c := fs.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Resident = c.resident
+	stats.MaxBytes = c.opts.MaxBytes
+	return stats
+}
+
+// pin asegura que el contenido de node esté en memoria y evita que se
+// desaloje hasta llamar a la función que devuelve, que además vuelve a
+// contar su tamaño. write indica que la operación modifica el contenido.
+// Se asume que fs.mu está tomado y que el llamador no tiene node.mu.
+func (fs *FileSystem) pin(node *Node, write bool) (func(), error) {
+	if err := node.fill(); err != nil {
+		return nil, err
+	}
+
+	c := fs.cache
+	if c == nil || node.nodeType != FileNode {
+		return func() {}, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[node]
+	if !ok {
+		e = c.add(node, false)
+	}
+
+	if e.state == cacheResident {
+		c.stats.Hits++
+	} else {
+		c.stats.Misses++
+		if err := c.load(e); err != nil {
+			return nil, err
+		}
+	}
+
+	if write {
+		e.clean = false
+	}
+	e.pins++
+	c.lru.MoveToFront(e.elem)
+
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		e.pins--
+		c.recount(e)
+		c.evict()
+	}, nil
+}
+
+// cached registra un archivo nuevo. Se asume que fs.mu está tomado para
+// escritura y que el llamador no tiene node.mu.
+func (fs *FileSystem) cached(node *Node) {
+	if c := fs.cache; c != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.add(node, false)
+		c.evict()
+	}
+}
+
+// uncache olvida un archivo que ya no tiene enlaces
+func (fs *FileSystem) uncache(node *Node) {
+	c := fs.cache
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[node]
+	if !ok {
+		return
+	}
+	c.lru.Remove(e.elem)
+	delete(c.entries, node)
+	c.resident -= e.charge
+	if e.state == cacheSpilled {
+		os.Remove(c.spillPath(node))
+	}
+}
+
+// orphan marca un archivo que perdió su último enlace pero sigue abierto:
+// Loader ya no lo puede reproducir por su ruta, así que se recarga ahora
+// si hace falta y, si se vuelve a desalojar, va a SpillDir
+func (fs *FileSystem) orphan(node *Node) {
+	c := fs.cache
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[node]
+	if !ok {
+		return
+	}
+	if e.state == cacheDropped {
+		if err := c.load(e); err != nil {
+			c.stats.Errors++
+		}
+	}
+	e.clean = false
+	c.evict()
+}
+
+func (c *cache) add(node *Node, clean bool) *cacheEntry {
+	e := &cacheEntry{node: node, clean: clean && c.opts.Loader != nil}
+	if e.clean {
+		// Se guarda la ruta de ahora: si después se renombra, Loader
+		// sigue conociendo el contenido por la original
+		e.source = c.fs.pathOf(node)
+	}
+	e.elem = c.lru.PushFront(e)
+	c.entries[node] = e
+	c.recount(e)
+	return e
+}
+
+// recount actualiza lo que ocupa e en memoria
+func (c *cache) recount(e *cacheEntry) {
+	if e.state != cacheResident {
+		return
+	}
+	e.node.mu.RLock()
+	charge := e.node.data.allocated()
+	e.node.mu.RUnlock()
+
+	c.resident += charge - e.charge
+	e.charge = charge
+}
+
+// evict desaloja desde el final de la lista hasta quedar dentro del límite.
+// Los archivos en uso o que no se pueden reproducir ni guardar se saltan.
+func (c *cache) evict() {
+	for elem := c.lru.Back(); elem != nil && c.resident > c.opts.MaxBytes; {
+		e := elem.Value.(*cacheEntry)
+		elem = elem.Prev()
+
+		if e.pins > 0 || e.state != cacheResident || e.charge == 0 {
+			continue
+		}
+
+		switch {
+		case e.clean:
+			c.stats.Drops++
+			e.state = cacheDropped
+		case c.spillDir != "":
+			if err := c.spill(e); err != nil {
+				c.stats.Errors++
+				continue
+			}
+			c.stats.Spills++
+			e.state = cacheSpilled
+		default:
+			continue
+		}
+
+		e.node.mu.Lock()
+		e.node.data = sparseData{}
+		e.node.mu.Unlock()
+
+		c.stats.Evictions++
+		c.resident -= e.charge
+		e.charge = 0
+	}
+}
+
+func (c *cache) spillPath(node *Node) string {
+	return filepath.Join(c.spillDir, strconv.FormatUint(node.ino, 10))
+}
+
+// spill guarda las páginas de un archivo como una secuencia de número de
+// página y contenido, así los huecos siguen sin ocupar espacio
+func (c *cache) spill(e *cacheEntry) error {
+	f, err := os.Create(c.spillPath(e.node))
+	if err != nil {
+		return err
+	}
+
+	e.node.mu.RLock()
+	for _, page := range e.node.data.sortedPages() {
+		var header [8]byte
+		binary.BigEndian.PutUint64(header[:], uint64(page))
+		if _, err = f.Write(header[:]); err == nil {
+			_, err = f.Write(e.node.data.pages[page])
+		}
+		if err != nil {
+			break
+		}
+	}
+	e.node.mu.RUnlock()
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// load vuelve a poner en memoria el contenido desalojado
+func (c *cache) load(e *cacheEntry) error {
+	var data sparseData
+	size := int64(-1)
+
+	switch e.state {
+	case cacheSpilled:
+		path := c.spillPath(e.node)
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if len(raw)%(8+pageSize) != 0 {
+			return errors.New("contenido desalojado dañado: " + path)
+		}
+
+		data.pages = make(map[int64][]byte, len(raw)/(8+pageSize))
+		for len(raw) > 0 {
+			page := int64(binary.BigEndian.Uint64(raw[:8]))
+			data.pages[page] = raw[8 : 8+pageSize : 8+pageSize]
+			raw = raw[8+pageSize:]
+		}
+		os.Remove(path)
+
+	case cacheDropped:
+		content, err := c.opts.Loader(e.source)
+		if err != nil {
+			return err
+		}
+		data = newSparseData(content)
+		size = int64(len(content))
+	}
+
+	e.node.mu.Lock()
+	e.node.data = data
+	if size >= 0 {
+		e.node.size = size
+	}
+	e.node.mu.Unlock()
+
+	e.state = cacheResident
+	c.recount(e)
+	return nil
+}
+
+// close recarga todo lo desalojado y borra el directorio de SpillDir
+func (c *cache) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, e := range c.entries {
+		if e.state != cacheResident {
+			if err := c.load(e); err != nil {
+				return err
+			}
+		}
+	}
+	if c.spillDir != "" {
+		return os.RemoveAll(c.spillDir)
+	}
+	return nil
+}
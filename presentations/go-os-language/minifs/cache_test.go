@@ -0,0 +1,205 @@
+package minifs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+)
+
+// page devuelve una página completa llena con b
+func page(b byte) []byte {
+	return bytes.Repeat([]byte{b}, pageSize)
+}
+
+func spillFiles(t *testing.T, dir string) int {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Se esperaba un subdirectorio en SpillDir: %v %v", entries, err)
+	}
+	files, _ := os.ReadDir(dir + "/" + entries[0].Name())
+	return len(files)
+}
+
+func TestCacheSpill(t *testing.T) {
+	dir := t.TempDir()
+	fs := newCheckedFS(t)
+	if err := fs.SetCache(CacheOptions{MaxBytes: 2 * pageSize, SpillDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, name := range []string{"/a", "/b", "/c"} {
+		fs.WriteFile(name, page(byte('a'+i)))
+	}
+
+	stats := fs.CacheStats()
+	if stats.Resident != 2*pageSize || stats.Spills != 1 || spillFiles(t, dir) != 1 {
+		t.Fatalf("Tras escribir tres páginas con lugar para dos: %+v", stats)
+	}
+
+	// /a fue el menos usado; leerlo lo recarga y desaloja a /b
+	data, err := fs.ReadFile("/a")
+	if err != nil || !bytes.Equal(data, page('a')) {
+		t.Fatalf("Contenido recargado incorrecto: %v", err)
+	}
+	stats = fs.CacheStats()
+	if stats.Misses != 1 || stats.Evictions != 2 {
+		t.Errorf("Contadores tras recargar: %+v", stats)
+	}
+	if info := statT(t, fs, "/b"); info.Size != pageSize || info.Blocks != 0 {
+		t.Errorf("Un archivo desalojado conserva su tamaño pero no ocupa bloques: %+v", info)
+	}
+
+	fs.ReadFile("/a")
+	if stats := fs.CacheStats(); stats.Hits != 1 {
+		t.Errorf("Leer un archivo en memoria debería contar un acierto: %+v", stats)
+	}
+
+	// Escribir sobre uno desalojado lo recarga antes
+	f, _ := fs.Open("/b")
+	f.WriteAt([]byte("B"), 1)
+	f.Close()
+	if data, _ := fs.ReadFile("/b"); data[0] != 'b' || data[1] != 'B' {
+		t.Errorf("Escritura sobre un archivo desalojado: %q", data[:2])
+	}
+
+	// Eliminar un archivo desalojado borra lo que guardó en disco
+	for _, name := range []string{"/a", "/b", "/c"} {
+		fs.Remove(name)
+	}
+	if n := spillFiles(t, dir); n != 0 {
+		t.Errorf("Quedaron %d archivos en SpillDir", n)
+	}
+	if stats := fs.CacheStats(); stats.Resident != 0 {
+		t.Errorf("Memoria tras eliminar todo: %d", stats.Resident)
+	}
+}
+
+func TestCacheSparse(t *testing.T) {
+	fs := newCheckedFS(t)
+	fs.SetCache(CacheOptions{MaxBytes: pageSize, SpillDir: t.TempDir()})
+
+	fs.WriteFile("/disperso", nil)
+	fs.Truncate("/disperso", 100*pageSize)
+	f, _ := fs.Open("/disperso")
+	f.WriteAt([]byte("fin"), 100*pageSize-3)
+	f.Close()
+
+	fs.WriteFile("/otro", page('x'))
+
+	data, _ := fs.ReadFile("/disperso")
+	if !bytes.HasSuffix(data, []byte("fin")) || len(data) != 100*pageSize {
+		t.Fatal("El archivo disperso cambió al desalojarlo")
+	}
+	if info := statT(t, fs, "/disperso"); info.Blocks != pageSize/512 {
+		t.Errorf("Se perdieron los huecos al recargar: %d bloques", info.Blocks)
+	}
+}
+
+func TestCacheLoader(t *testing.T) {
+	fixtures := map[string][]byte{"/f1": page('1'), "/f2": page('2'), "/f3": page('3')}
+	loads := 0
+
+	fs := newCheckedFS(t)
+	for name, content := range fixtures {
+		fs.WriteFile(name, content)
+	}
+
+	err := fs.SetCache(CacheOptions{MaxBytes: pageSize, Loader: func(path string) ([]byte, error) {
+		loads++
+		if content, ok := fixtures[path]; ok {
+			return content, nil
+		}
+		return nil, fmt.Errorf("no existe: %s", path)
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := fs.CacheStats(); stats.Drops != 2 || stats.Resident != pageSize {
+		t.Fatalf("Los archivos previos se pueden reproducir: %+v", stats)
+	}
+	for name, content := range fixtures {
+		if data, _ := fs.ReadFile(name); !bytes.Equal(data, content) {
+			t.Errorf("Contenido de %s recargado con Loader incorrecto", name)
+		}
+	}
+	if stats := fs.CacheStats(); loads < 2 || uint64(loads) != stats.Misses {
+		t.Errorf("Loader se llamó %d veces con %d fallos", loads, stats.Misses)
+	}
+
+	// Un archivo nuevo no se puede reproducir ni guardar: se queda en memoria
+	fs.WriteFile("/nuevo", page('n'))
+	fs.ReadFile("/f1")
+	if data, _ := fs.ReadFile("/nuevo"); !bytes.Equal(data, page('n')) {
+		t.Error("Se perdió un archivo que no se puede reproducir")
+	}
+
+	if err := fs.SetCache(CacheOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if info := statT(t, fs, "/f2"); info.Blocks == 0 {
+		t.Error("Al desactivar la caché todo debería volver a memoria")
+	}
+}
+
+func TestCacheLoaderRename(t *testing.T) {
+	fixtures := map[string][]byte{"/a": page('a'), "/b": page('b')}
+
+	fs := newCheckedFS(t)
+	for name, content := range fixtures {
+		fs.WriteFile(name, content)
+	}
+	err := fs.SetCache(CacheOptions{MaxBytes: pageSize, Loader: func(path string) ([]byte, error) {
+		if content, ok := fixtures[path]; ok {
+			return content, nil
+		}
+		return nil, fmt.Errorf("no existe: %s", path)
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Intercambiar los nombres no cambia con qué ruta se recarga cada uno
+	fs.Rename("/a", "/tmp")
+	fs.Rename("/b", "/a")
+	fs.Rename("/tmp", "/b")
+	for range 2 {
+		if data, _ := fs.ReadFile("/a"); !bytes.Equal(data, page('b')) {
+			t.Errorf("/a recargó el contenido de otro archivo: %q", data[:1])
+		}
+		if data, _ := fs.ReadFile("/b"); !bytes.Equal(data, page('a')) {
+			t.Errorf("/b recargó el contenido de otro archivo: %q", data[:1])
+		}
+	}
+	if stats := fs.CacheStats(); stats.Drops < 3 {
+		t.Errorf("Los archivos deberían desalojarse y recargarse: %+v", stats)
+	}
+}
+
+func TestCacheConcurrentReads(t *testing.T) {
+	fs := newCheckedFS(t)
+	fs.SetCache(CacheOptions{MaxBytes: 2 * pageSize, SpillDir: t.TempDir()})
+	for i := 0; i < 8; i++ {
+		fs.WriteFile(fmt.Sprintf("/%d", i), page(byte('0'+i)))
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				name := fmt.Sprintf("/%d", (i+j)%8)
+				data, err := fs.ReadFile(name)
+				if err != nil || data[0] != byte('0'+(i+j)%8) {
+					t.Errorf("Lectura concurrente de %s: %v", name, err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
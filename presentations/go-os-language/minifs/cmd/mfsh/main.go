@@ -0,0 +1,79 @@
+// mfsh es un intérprete de comandos interactivo sobre un minifs.FileSystem.
+//
+// Uso:
+//
+//	mfsh [-image imagen.tar] [-script guion.txt]
+//
+// Con -image se carga una imagen guardada con el comando save; con -script
+// se ejecutan los comandos del archivo en lugar de abrir la sesión interactiva.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/hectorip/minifs"
+	"github.com/hectorip/minifs/shell"
+)
+
+func main() {
+	image := flag.String("image", "", "imagen tar para cargar al iniciar")
+	script := flag.String("script", "", "archivo con comandos a ejecutar")
+	flag.Parse()
+
+	fs := minifs.NewFileSystem()
+	if *image != "" {
+		f, err := os.Open(*image)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fs, err = minifs.LoadImage(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("Error cargando %s: %v", *image, err)
+		}
+	}
+
+	sh := shell.New(fs, os.Stdout)
+
+	if *script != "" {
+		f, err := os.Open(*script)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		if err := sh.Run(f, true); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	fmt.Println("mfsh - escribe 'help' para ver los comandos, Tab para completar")
+
+	lr := newLineReader(os.Stdin, os.Stdout, sh.Complete)
+	defer lr.Close()
+
+	for {
+		line, err := lr.ReadLine(sh.Prompt())
+		if err == errInterrupt {
+			continue
+		}
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			log.Print(err)
+			return
+		}
+
+		if err := sh.Exec(line); err != nil {
+			if err == io.EOF {
+				return
+			}
+			fmt.Println(err)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode/utf8"
+)
+
+// errInterrupt se devuelve cuando el usuario presiona Ctrl-C
+var errInterrupt = errors.New("interrumpido")
+
+// completer completa una línea y devuelve los candidatos si hay varios
+type completer func(line string) (string, []string)
+
+// lineReader lee líneas de la entrada. Si la entrada es una terminal usa
+// modo sin búfer (vía stty) para poder completar con Tab; si no, lee
+// líneas normales, lo que permite usar mfsh con tuberías.
+type lineReader struct {
+	in       *os.File
+	out      io.Writer
+	complete completer
+	buffered *bufio.Reader
+	state    string
+}
+
+func newLineReader(in *os.File, out io.Writer, complete completer) *lineReader {
+	lr := &lineReader{in: in, out: out, complete: complete, buffered: bufio.NewReader(in)}
+
+	if fi, err := in.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		if state, err := lr.stty("-g"); err == nil {
+			if _, err := lr.stty("-icanon", "-echo", "-isig", "min", "1"); err == nil {
+				lr.state = strings.TrimSpace(state)
+			}
+		}
+	}
+
+	return lr
+}
+
+func (lr *lineReader) stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = lr.in
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// Close restaura la configuración original de la terminal
+func (lr *lineReader) Close() {
+	if lr.state != "" {
+		lr.stty(lr.state)
+	}
+}
+
+// ReadLine muestra el prompt y devuelve la línea escrita sin el salto final
+func (lr *lineReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(lr.out, prompt)
+
+	if lr.state == "" {
+		line, err := lr.buffered.ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	var line []byte
+	for {
+		b, err := lr.buffered.ReadByte()
+		if err != nil {
+			return "", err
+		}
+
+		switch b {
+		case '\r', '\n':
+			fmt.Fprintln(lr.out)
+			return string(line), nil
+
+		case 3: // Ctrl-C
+			fmt.Fprintln(lr.out, "^C")
+			return "", errInterrupt
+
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Fprintln(lr.out)
+				return "", io.EOF
+			}
+
+		case 127, 8: // Retroceso
+			if len(line) > 0 {
+				_, size := utf8.DecodeLastRune(line)
+				line = line[:len(line)-size]
+				fmt.Fprint(lr.out, "\b \b")
+			}
+
+		case '\t':
+			completed, candidates := lr.complete(string(line))
+			if len(candidates) > 0 {
+				fmt.Fprintf(lr.out, "\n%s\n%s", strings.Join(candidates, "  "), prompt)
+				fmt.Fprint(lr.out, completed)
+			} else {
+				fmt.Fprint(lr.out, completed[len(line):])
+			}
+			line = []byte(completed)
+
+		case 27: // Secuencias de escape (flechas): se ignoran
+			if next, _ := lr.buffered.ReadByte(); next == '[' {
+				lr.buffered.ReadByte()
+			}
+
+		default:
+			if b >= 32 || b >= utf8.RuneSelf {
+				line = append(line, b)
+				lr.out.Write([]byte{b})
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// minifs-du muestra qué ocupa espacio dentro de una imagen guardada con
+// SaveImage o con el comando save de mfsh.
+//
+// Uso:
+//
+//	minifs-du [-n 10] [-h] [-tree] [-depth n] [-sort name|size|time] [-ascii] imagen.tar [ruta]
+//
+// Sin -tree lista los n subdirectorios más pesados bajo la ruta, a cualquier
+// profundidad, y al final el total; con -tree dibuja el árbol con el total
+// de cada directorio.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/hectorip/minifs"
+)
+
+var sorts = map[string]minifs.TreeSort{
+	"name": minifs.SortByName,
+	"size": minifs.SortBySize,
+	"time": minifs.SortByModTime,
+}
+
+func main() {
+	top := flag.Int("n", 10, "cantidad de directorios a listar; 0 lista todos")
+	human := flag.Bool("h", false, "tamaños en KiB, MiB, ...")
+	tree := flag.Bool("tree", false, "dibujar el árbol en lugar de la lista")
+	depth := flag.Int("depth", 0, "niveles a dibujar con -tree; 0 sin límite")
+	order := flag.String("sort", "size", "orden del árbol: name, size o time")
+	ascii := flag.Bool("ascii", false, "dibujar el árbol solo con caracteres ASCII")
+	flag.Parse()
+
+	log.SetFlags(0)
+	log.SetPrefix("minifs-du: ")
+
+	if flag.NArg() < 1 || flag.NArg() > 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	sort, ok := sorts[*order]
+	if !ok {
+		log.Fatalf("orden desconocido: %s", *order)
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fs, err := minifs.LoadImage(f)
+	f.Close()
+	if err != nil {
+		log.Fatalf("Error cargando %s: %v", flag.Arg(0), err)
+	}
+
+	path := "/"
+	if flag.NArg() == 2 {
+		path = flag.Arg(1)
+	}
+
+	t, err := fs.Tree(path, minifs.TreeOptions{
+		Sort:       sort,
+		MaxDepth:   *depth,
+		ASCII:      *ascii,
+		HumanSizes: *human,
+		DirSizes:   true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *tree {
+		if _, err := t.WriteTo(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	for _, node := range t.Top(*top) {
+		fmt.Printf("%s\t%s\n", size(node.Size, *human), node.Path)
+	}
+	fmt.Printf("%s\t%s\t(%d archivos, %d directorios)\n",
+		size(t.Root.Size, *human), t.Root.Path, t.Root.Files, t.Root.Dirs)
+}
+
+// size es el tamaño como lo muestra du: en bytes, sin unidad, salvo con -h
+func size(n int64, human bool) string {
+	if !human {
+		return fmt.Sprint(n)
+	}
+	return minifs.FormatSize(n, true)
+}
@@ -0,0 +1,95 @@
+package minifs
+
+import (
+	"context"
+	"os"
+)
+
+// WithContext devuelve una vista de fs que comparte el árbol, los montajes
+// y la configuración, pero cuyas operaciones respetan ctx:
+//
+//   - la espera por el candado del sistema de archivos termina cuando ctx
+//     se cancela, en lugar de bloquear hasta que se libere
+//   - Walk, Size y RemoveAll revisan ctx entre un nodo y el siguiente
+//   - los Files abiertos desde la vista hacen lo mismo en cada lectura,
+//     escritura, Seek o Truncate
+//
+// En esos casos el error es un *os.PathError que envuelve ctx.Err(), así
+// que errors.Is(err, context.Canceled) o context.DeadlineExceeded
+// funcionan. Una operación que ya tomó el candado termina normalmente.
+// Copy, SaveImage y Tree están hechas con las operaciones de arriba, así
+// que se cortan en el siguiente paso. Las que no devuelven error (Check,
+// Repair, Trash, PurgeTrash, SetVersioning, Versioning y CacheStats) y el
+// Close de un File esperan el candado sin mirar ctx. Los sistemas montados
+// reciben las llamadas sin el contexto.
+func (fs *FileSystem) WithContext(ctx context.Context) *FileSystem {
+	if ctx == nil {
+		panic("minifs: WithContext con un contexto nil")
+	}
+	return &FileSystem{core: fs.core, ctx: ctx}
+}
+
+// Context devuelve el contexto de la vista, o context.Background si no
+// se creó con WithContext
+func (fs *FileSystem) Context() context.Context {
+	if fs.ctx == nil {
+		return context.Background()
+	}
+	return fs.ctx
+}
+
+// ctxErr devuelve el error del contexto envuelto con la operación, o nil
+// si no hay contexto o sigue vigente
+func (fs *FileSystem) ctxErr(op, path string) error {
+	if fs.ctx == nil {
+		return nil
+	}
+	if err := fs.ctx.Err(); err != nil {
+		return &os.PathError{Op: op, Path: path, Err: err}
+	}
+	return nil
+}
+
+// lock toma fs.mu para escritura; ver acquire
+func (fs *FileSystem) lock(op, path string) error {
+	return fs.acquire(op, path, fs.mu.TryLock, fs.mu.Lock, fs.mu.Unlock)
+}
+
+// rlock toma fs.mu para lectura; ver acquire
+func (fs *FileSystem) rlock(op, path string) error {
+	return fs.acquire(op, path, fs.mu.TryRLock, fs.mu.RLock, fs.mu.RUnlock)
+}
+
+// acquire toma un candado dejando de esperar si el contexto se cancela.
+// sync.RWMutex no se puede abandonar a mitad de la espera, así que la
+// espera ocurre en otra goroutine que, si llega tarde, suelta el candado
+// apenas lo obtiene.
+func (fs *FileSystem) acquire(op, path string, try func() bool, lock, unlock func()) error {
+	if fs.ctx == nil {
+		lock()
+		return nil
+	}
+	if err := fs.ctxErr(op, path); err != nil {
+		return err
+	}
+	if try() {
+		return nil
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		lock()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		return nil
+	case <-fs.ctx.Done():
+		go func() {
+			<-acquired
+			unlock()
+		}()
+		return fs.ctxErr(op, path)
+	}
+}
@@ -0,0 +1,209 @@
+package minifs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+// countdownCtx se cancela sola después de n consultas a Err, para cortar
+// un recorrido en un punto exacto
+type countdownCtx struct {
+	context.Context
+	n int
+}
+
+func (c *countdownCtx) Err() error {
+	if c.n <= 0 {
+		return context.Canceled
+	}
+	c.n--
+	return nil
+}
+
+// newTree crea /t con dirs directorios de files archivos cada uno
+func newTree(t *testing.T, dirs, files int) *FileSystem {
+	t.Helper()
+	fs := newCheckedFS(t)
+	for d := 0; d < dirs; d++ {
+		for f := 0; f < files; f++ {
+			fs.MkdirAll(fmt.Sprintf("/t/d%d", d), 0755)
+			fs.WriteFile(fmt.Sprintf("/t/d%d/f%d", d, f), []byte("x"))
+		}
+	}
+	return fs
+}
+
+func TestWithContextCanceled(t *testing.T) {
+	fs := newCheckedFS(t)
+	fs.WriteFile("/a", []byte("a"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	view := fs.WithContext(ctx)
+
+	_, readErr := view.ReadFile("/a")
+	_, statErr := view.Stat("/a")
+	_, openErr := view.Open("/a")
+	errs := map[string]error{
+		"WriteFile": view.WriteFile("/b", nil),
+		"ReadFile":  readErr,
+		"Stat":      statErr,
+		"Open":      openErr,
+		"Remove":    view.Remove("/a"),
+		"Rename":    view.Rename("/a", "/c"),
+		"Walk":      view.Walk("/", func(string, FileInfo) error { return nil }),
+	}
+	for name, err := range errs {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("%s con un contexto cancelado: %v", name, err)
+		}
+	}
+	if view.Exists("/a") {
+		t.Error("Exists con un contexto cancelado debería dar false")
+	}
+
+	// El original no tiene contexto y ve el mismo árbol
+	if !fs.Exists("/a") || fs.Exists("/b") {
+		t.Error("La vista cancelada modificó el árbol")
+	}
+	if fs.Context() != context.Background() || view.Context() != ctx {
+		t.Error("Context devuelve otro contexto")
+	}
+}
+
+func TestWithContextSharesTree(t *testing.T) {
+	fs := newCheckedFS(t)
+	view := fs.WithContext(context.Background())
+
+	view.MkdirAll("/a/b", 0755)
+	if !fs.Exists("/a/b") {
+		t.Error("Lo creado por la vista no se ve en el original")
+	}
+	if err := CopyBetween(view, "/a", fs, "/a/b/c", CopyOptions{}); err == nil {
+		t.Error("La vista y el original deberían contar como el mismo árbol")
+	}
+	if err := fs.Mount("/a", view, MountOptions{}); err == nil {
+		t.Error("Se montó una vista sobre su propio sistema de archivos")
+	}
+}
+
+func TestWithContextFile(t *testing.T) {
+	fs := newCheckedFS(t)
+	fs.WriteFile("/a", []byte("hola"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	view := fs.WithContext(ctx)
+	f, err := view.Open("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	// Un File abierto desde la vista usa su contexto en cada operación
+	_, readErr := f.Read(make([]byte, 4))
+	_, writeErr := f.Write([]byte("x"))
+	_, statErr := f.Stat()
+	_, seekErr := f.Seek(0, io.SeekEnd)
+	errs := map[string]error{
+		"Read":      readErr,
+		"Write":     writeErr,
+		"Stat":      statErr,
+		"Seek":      seekErr,
+		"Truncate":  f.Truncate(0),
+		"Copy":      view.Copy("/a", "/b", CopyOptions{}),
+		"SaveImage": view.SaveImage(io.Discard),
+	}
+	for name, err := range errs {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("%s con un contexto cancelado: %v", name, err)
+		}
+	}
+
+	// Close siempre libera el archivo
+	if err := f.Close(); err != nil {
+		t.Errorf("Close con un contexto cancelado: %v", err)
+	}
+	if data, _ := fs.ReadFile("/a"); string(data) != "hola" {
+		t.Errorf("El archivo cambió: %q", data)
+	}
+}
+
+func TestWithContextLockTimeout(t *testing.T) {
+	fs := newCheckedFS(t)
+	fs.WriteFile("/a", []byte("a"))
+
+	// Alguien tiene el candado por mucho tiempo
+	fs.mu.Lock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := fs.WithContext(ctx).ReadFile("/a")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Se esperaba DeadlineExceeded: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("La espera no se cortó con el plazo: %v", elapsed)
+	}
+
+	// La goroutine que seguía esperando suelta el candado al obtenerlo
+	fs.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		fs.WriteFile("/b", []byte("b"))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("El candado quedó tomado tras la cancelación")
+	}
+}
+
+func TestWithContextWalk(t *testing.T) {
+	fs := newTree(t, 10, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	visited := 0
+	err := fs.WithContext(ctx).Walk("/t", func(string, FileInfo) error {
+		visited++
+		if visited == 5 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || visited != 5 {
+		t.Errorf("Walk siguió tras cancelar: %d nodos, %v", visited, err)
+	}
+
+	_, err = fs.WithContext(&countdownCtx{Context: context.Background(), n: 20}).Size("/t")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Size debería cortarse entre nodos: %v", err)
+	}
+}
+
+func TestWithContextRemoveAll(t *testing.T) {
+	fs := newTree(t, 10, 10)
+
+	// Se cancela a mitad de camino; lo que queda tiene que ser consistente,
+	// cosa que newCheckedFS verifica al terminar
+	ctx := &countdownCtx{Context: context.Background(), n: 50}
+	if err := fs.WithContext(ctx).RemoveAll("/t"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("RemoveAll debería cortarse entre nodos: %v", err)
+	}
+
+	size, _ := fs.Size("/t")
+	if !fs.Exists("/t") || size == 0 || size == 100 {
+		t.Errorf("Se esperaba un borrado parcial, quedan %d bytes", size)
+	}
+
+	if err := fs.WithContext(context.Background()).RemoveAll("/t"); err != nil || fs.Exists("/t") {
+		t.Errorf("RemoveAll sin cancelar: %v", err)
+	}
+}
@@ -0,0 +1,302 @@
+package minifs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OverwritePolicy indica qué hacer cuando el destino de una copia ya existe
+type OverwritePolicy int
+
+const (
+	// OverwriteNever falla si el destino ya existe
+	OverwriteNever OverwritePolicy = iota
+	// OverwriteAlways reemplaza siempre el destino
+	OverwriteAlways
+	// OverwriteIfNewer reemplaza el destino sólo si el origen es más reciente
+	OverwriteIfNewer
+	// SkipExisting deja el destino intacto sin reportar error
+	SkipExisting
+)
+
+// CopyOptions configura el comportamiento de Copy y CopyBetween
+type CopyOptions struct {
+	// PreserveMode copia los permisos del origen; si es falso se usan
+	// 0644 para archivos y 0755 para directorios
+	PreserveMode bool
+	// PreserveTimes copia la fecha de modificación del origen
+	PreserveTimes bool
+	// Overwrite decide qué pasa con los archivos que ya existen en el destino
+	Overwrite OverwritePolicy
+	// Dereference sigue los enlaces simbólicos del origen y copia su
+	// contenido. minifs no tiene enlaces, así que si es falso se omiten.
+	Dereference bool
+}
+
+// Volume es un árbol de archivos que puede ser origen o destino de una copia.
+// *FileSystem lo implementa, y HostDir adapta un directorio del sistema
+// operativo anfitrión.
+type Volume interface {
+	Exists(path string) bool
+	Stat(path string) (FileInfo, error)
+	ListDir(path string) ([]FileInfo, error)
+	ReadFile(path string) ([]byte, error)
+	CreateDir(path string, mode os.FileMode) error
+	CreateFile(path string, content []byte, mode os.FileMode) error
+	RemoveAll(path string) error
+	SetModTime(path string, modTime time.Time) error
+}
+
+// Copy copia un archivo o un directorio completo dentro del mismo sistema
+// de archivos. dst es la ruta final de la copia, no el directorio que la
+// contendrá.
+func (fs *FileSystem) Copy(src, dst string, opts CopyOptions) error {
+	return CopyBetween(fs, src, fs, dst, opts)
+}
+
+// CopyBetween copia src de srcFS a dst en dstFS de forma recursiva. Ambos
+// volúmenes pueden ser el mismo, dos FileSystem distintos o el sistema
+// operativo a través de HostDir.
+func CopyBetween(srcFS Volume, src string, dstFS Volume, dst string, opts CopyOptions) error {
+	info, err := srcFS.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if from, to, ok := sameTree(srcFS, src, dstFS, dst); ok {
+		if from == to {
+			return errors.New("origen y destino son el mismo: " + src)
+		}
+		if info.IsDir && isSubPath(from, to) {
+			return errors.New("no se puede copiar un directorio dentro de sí mismo: " + dst)
+		}
+	}
+
+	return copyNode(srcFS, src, info, dstFS, dst, opts)
+}
+
+// sameTree indica si ambas rutas viven en el mismo árbol y, de ser así,
+// devuelve las rutas comparables entre sí
+func sameTree(srcFS Volume, src string, dstFS Volume, dst string) (string, string, bool) {
+	srcHost, srcIsHost := srcFS.(hostDir)
+	dstHost, dstIsHost := dstFS.(hostDir)
+	if srcIsHost && dstIsHost {
+		return srcHost.real(src), dstHost.real(dst), true
+	}
+	if srcFS == dstFS || sameFS(srcFS, dstFS) {
+		return filepath.Clean("/" + src), filepath.Clean("/" + dst), true
+	}
+	return "", "", false
+}
+
+// sameFS indica si a y b son vistas del mismo FileSystem, por ejemplo una
+// creada con WithContext y la original
+func sameFS(a, b Volume) bool {
+	fa, ok := a.(*FileSystem)
+	if !ok {
+		return false
+	}
+	fb, ok := b.(*FileSystem)
+	return ok && fa.core == fb.core
+}
+
+// isSubPath indica si child está dentro de parent
+func isSubPath(parent, child string) bool {
+	if parent == "/" {
+		return true
+	}
+	return child == parent || strings.HasPrefix(child, parent+"/")
+}
+
+func copyNode(srcFS Volume, src string, info FileInfo, dstFS Volume, dst string, opts CopyOptions) error {
+	if info.Mode&os.ModeSymlink != 0 {
+		if !opts.Dereference {
+			return nil
+		}
+		// Stat sigue el enlace y nos da la información del destino
+		resolved, err := srcFS.Stat(src)
+		if err != nil {
+			return err
+		}
+		info = resolved
+	}
+
+	if info.IsDir {
+		return copyDir(srcFS, src, info, dstFS, dst, opts)
+	}
+	return copyFile(srcFS, src, info, dstFS, dst, opts)
+}
+
+func copyFile(srcFS Volume, src string, info FileInfo, dstFS Volume, dst string, opts CopyOptions) error {
+	if dstFS.Exists(dst) {
+		existing, err := dstFS.Stat(dst)
+		if err != nil {
+			return err
+		}
+		if existing.IsDir {
+			return errors.New("el destino es un directorio: " + dst)
+		}
+
+		switch opts.Overwrite {
+		case OverwriteNever:
+			return errors.New("destino ya existe: " + dst)
+		case SkipExisting:
+			return nil
+		case OverwriteIfNewer:
+			if !info.ModTime.After(existing.ModTime) {
+				return nil
+			}
+		}
+	}
+
+	// Se lee el origen antes de tocar el destino y después se sobrescribe
+	// con CreateFile, que también le pone el modo: si la lectura falla el
+	// destino queda intacto, y un FileSystem con revisiones guarda la
+	// anterior
+	content, err := srcFS.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0644)
+	if opts.PreserveMode {
+		mode = info.Mode.Perm()
+	}
+
+	if err := dstFS.CreateFile(dst, content, mode); err != nil {
+		return err
+	}
+
+	if opts.PreserveTimes {
+		return dstFS.SetModTime(dst, info.ModTime)
+	}
+	return nil
+}
+
+func copyDir(srcFS Volume, src string, info FileInfo, dstFS Volume, dst string, opts CopyOptions) error {
+	if dstFS.Exists(dst) {
+		existing, err := dstFS.Stat(dst)
+		if err != nil {
+			return err
+		}
+		if !existing.IsDir {
+			return errors.New("el destino no es un directorio: " + dst)
+		}
+		if opts.Overwrite == OverwriteNever {
+			return errors.New("destino ya existe: " + dst)
+		}
+	} else {
+		mode := os.FileMode(0755)
+		if opts.PreserveMode {
+			mode = info.Mode.Perm()
+		}
+		if err := dstFS.CreateDir(dst, mode); err != nil {
+			return err
+		}
+	}
+
+	entries, err := srcFS.ListDir(src)
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	for _, entry := range entries {
+		childSrc := filepath.Join(src, entry.Name)
+		childDst := filepath.Join(dst, entry.Name)
+		if err := copyNode(srcFS, childSrc, entry, dstFS, childDst, opts); err != nil {
+			return err
+		}
+	}
+
+	// Los tiempos del directorio se fijan al final porque crear hijos los modifica
+	if opts.PreserveTimes {
+		return dstFS.SetModTime(dst, info.ModTime)
+	}
+	return nil
+}
+
+// hostDir expone un directorio del sistema operativo como Volume
+type hostDir struct {
+	root string
+}
+
+// HostDir devuelve un Volume cuyas rutas son relativas a root en el sistema
+// operativo anfitrión, de modo que "/" corresponde a root
+func HostDir(root string) Volume {
+	return hostDir{root: filepath.Clean(root)}
+}
+
+func (h hostDir) real(path string) string {
+	return filepath.Join(h.root, filepath.FromSlash(filepath.Clean("/"+path)))
+}
+
+func hostInfo(name string, fi os.FileInfo) FileInfo {
+	return FileInfo{
+		Name:    name,
+		Size:    fi.Size(),
+		Mode:    fi.Mode().Perm() | fi.Mode()&os.ModeSymlink,
+		ModTime: fi.ModTime(),
+		IsDir:   fi.IsDir(),
+	}
+}
+
+func (h hostDir) Exists(path string) bool {
+	_, err := os.Lstat(h.real(path))
+	return err == nil
+}
+
+// Stat sigue los enlaces simbólicos
+func (h hostDir) Stat(path string) (FileInfo, error) {
+	fi, err := os.Stat(h.real(path))
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return hostInfo(fi.Name(), fi), nil
+}
+
+// ListDir no sigue los enlaces: se reportan con os.ModeSymlink en Mode
+func (h hostDir) ListDir(path string) ([]FileInfo, error) {
+	entries, err := os.ReadDir(h.real(path))
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		fi, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, hostInfo(entry.Name(), fi))
+	}
+	return files, nil
+}
+
+func (h hostDir) ReadFile(path string) ([]byte, error) {
+	return os.ReadFile(h.real(path))
+}
+
+func (h hostDir) CreateDir(path string, mode os.FileMode) error {
+	return os.Mkdir(h.real(path), mode)
+}
+
+func (h hostDir) CreateFile(path string, content []byte, mode os.FileMode) error {
+	if err := os.WriteFile(h.real(path), content, mode); err != nil {
+		return err
+	}
+	// WriteFile sólo aplica el modo al crear el archivo
+	return os.Chmod(h.real(path), mode)
+}
+
+func (h hostDir) RemoveAll(path string) error {
+	return os.RemoveAll(h.real(path))
+}
+
+func (h hostDir) SetModTime(path string, modTime time.Time) error {
+	return os.Chtimes(h.real(path), modTime, modTime)
+}
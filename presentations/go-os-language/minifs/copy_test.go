@@ -0,0 +1,198 @@
+package minifs
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCopy(t *testing.T) {
+	fs := NewFileSystem()
+	fs.MkdirAll("/src/sub", 0700)
+	fs.CreateFile("/src/a.txt", []byte("archivo a"), 0600)
+	fs.WriteFile("/src/sub/b.txt", []byte("archivo b"))
+
+	t.Run("File", func(t *testing.T) {
+		if err := fs.Copy("/src/a.txt", "/a-copia.txt", CopyOptions{}); err != nil {
+			t.Fatalf("Error copiando archivo: %v", err)
+		}
+
+		data, _ := fs.ReadFile("/a-copia.txt")
+		if string(data) != "archivo a" {
+			t.Errorf("Contenido incorrecto: %s", data)
+		}
+
+		info, _ := fs.Stat("/a-copia.txt")
+		if info.Mode != 0644 {
+			t.Errorf("Sin PreserveMode se esperaba 0644, got %v", info.Mode)
+		}
+	})
+
+	t.Run("Tree", func(t *testing.T) {
+		err := fs.Copy("/src", "/dst", CopyOptions{PreserveMode: true})
+		if err != nil {
+			t.Fatalf("Error copiando árbol: %v", err)
+		}
+
+		data, err := fs.ReadFile("/dst/sub/b.txt")
+		if err != nil || string(data) != "archivo b" {
+			t.Errorf("No se copió el archivo anidado: %v", err)
+		}
+
+		info, _ := fs.Stat("/dst/sub")
+		if info.Mode != 0700 {
+			t.Errorf("Permisos no preservados: got %v, want %v", info.Mode, os.FileMode(0700))
+		}
+
+		// La copia es independiente del original
+		fs.WriteFile("/src/sub/b.txt", []byte("cambiado"))
+		data, _ = fs.ReadFile("/dst/sub/b.txt")
+		if string(data) != "archivo b" {
+			t.Error("La copia comparte contenido con el original")
+		}
+	})
+
+	t.Run("IntoOwnSubtree", func(t *testing.T) {
+		if err := fs.Copy("/src", "/src/sub/loop", CopyOptions{}); err == nil {
+			t.Error("Se permitió copiar un directorio dentro de sí mismo")
+		}
+		if err := fs.Copy("/src/a.txt", "/src/a.txt", CopyOptions{Overwrite: OverwriteAlways}); err == nil {
+			t.Error("Se permitió copiar un archivo sobre sí mismo")
+		}
+	})
+
+	t.Run("OverwritePolicy", func(t *testing.T) {
+		fs.WriteFile("/o1.txt", []byte("origen"))
+		fs.WriteFile("/o2.txt", []byte("destino"))
+
+		if err := fs.Copy("/o1.txt", "/o2.txt", CopyOptions{}); err == nil {
+			t.Error("OverwriteNever debería fallar si el destino existe")
+		}
+
+		if err := fs.Copy("/o1.txt", "/o2.txt", CopyOptions{Overwrite: SkipExisting}); err != nil {
+			t.Fatalf("SkipExisting no debería fallar: %v", err)
+		}
+		if data, _ := fs.ReadFile("/o2.txt"); string(data) != "destino" {
+			t.Error("SkipExisting modificó el destino")
+		}
+
+		// El destino es más reciente, así que no se debe reemplazar
+		fs.SetModTime("/o1.txt", time.Now().Add(-time.Hour))
+		fs.Copy("/o1.txt", "/o2.txt", CopyOptions{Overwrite: OverwriteIfNewer})
+		if data, _ := fs.ReadFile("/o2.txt"); string(data) != "destino" {
+			t.Error("OverwriteIfNewer reemplazó un destino más reciente")
+		}
+
+		fs.Copy("/o1.txt", "/o2.txt", CopyOptions{Overwrite: OverwriteAlways})
+		if data, _ := fs.ReadFile("/o2.txt"); string(data) != "origen" {
+			t.Error("OverwriteAlways no reemplazó el destino")
+		}
+	})
+
+	t.Run("PreserveTimes", func(t *testing.T) {
+		old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		fs.SetModTime("/src/sub", old)
+
+		err := fs.Copy("/src/sub", "/times", CopyOptions{PreserveTimes: true})
+		if err != nil {
+			t.Fatalf("Error copiando: %v", err)
+		}
+
+		info, _ := fs.Stat("/times")
+		if !info.ModTime.Equal(old) {
+			t.Errorf("Tiempo no preservado: got %v, want %v", info.ModTime, old)
+		}
+	})
+}
+
+func TestCopyBetween(t *testing.T) {
+	src := NewFileSystem()
+	src.MkdirAll("/data/nested", 0755)
+	src.WriteFile("/data/uno.txt", []byte("uno"))
+	src.WriteFile("/data/nested/dos.txt", []byte("dos"))
+
+	t.Run("FileSystems", func(t *testing.T) {
+		dst := NewFileSystem()
+		if err := CopyBetween(src, "/data", dst, "/copia", CopyOptions{}); err != nil {
+			t.Fatalf("Error copiando entre sistemas: %v", err)
+		}
+
+		srcSize, _ := src.Size("/data")
+		dstSize, _ := dst.Size("/copia")
+		if srcSize != dstSize {
+			t.Errorf("Tamaños distintos: %d vs %d", srcSize, dstSize)
+		}
+	})
+
+	t.Run("RoundTripHost", func(t *testing.T) {
+		host := t.TempDir()
+		err := CopyBetween(src, "/data", HostDir(host), "/data", CopyOptions{PreserveMode: true})
+		if err != nil {
+			t.Fatalf("Error copiando al anfitrión: %v", err)
+		}
+
+		data, err := os.ReadFile(filepath.Join(host, "data", "nested", "dos.txt"))
+		if err != nil || string(data) != "dos" {
+			t.Fatalf("Archivo no copiado al anfitrión: %v", err)
+		}
+
+		back := NewFileSystem()
+		if err := CopyBetween(HostDir(host), "/data", back, "/data", CopyOptions{}); err != nil {
+			t.Fatalf("Error copiando desde el anfitrión: %v", err)
+		}
+
+		data, _ = back.ReadFile("/data/uno.txt")
+		if !bytes.Equal(data, []byte("uno")) {
+			t.Errorf("Contenido incorrecto tras ida y vuelta: %s", data)
+		}
+	})
+
+	t.Run("HostSymlinks", func(t *testing.T) {
+		host := t.TempDir()
+		os.WriteFile(filepath.Join(host, "real.txt"), []byte("real"), 0644)
+		if err := os.Symlink("real.txt", filepath.Join(host, "link.txt")); err != nil {
+			t.Skipf("Enlaces simbólicos no disponibles: %v", err)
+		}
+
+		skip := NewFileSystem()
+		CopyBetween(HostDir(host), "/", skip, "/", CopyOptions{Overwrite: OverwriteAlways})
+		if skip.Exists("/link.txt") {
+			t.Error("Sin Dereference el enlace debería omitirse")
+		}
+
+		follow := NewFileSystem()
+		CopyBetween(HostDir(host), "/", follow, "/", CopyOptions{Overwrite: OverwriteAlways, Dereference: true})
+		data, err := follow.ReadFile("/link.txt")
+		if err != nil || string(data) != "real" {
+			t.Errorf("Con Dereference se esperaba el contenido del destino: %v", err)
+		}
+	})
+
+	t.Run("ReadError", func(t *testing.T) {
+		faulty := NewFaultFS(src, 1)
+		faulty.AddRule(Rule{Op: "ReadFile", Err: syscall.EIO})
+		dst := NewFileSystem()
+		dst.WriteFile("/uno.txt", []byte("anterior"))
+
+		err := CopyBetween(faulty, "/data/uno.txt", dst, "/uno.txt", CopyOptions{Overwrite: OverwriteAlways})
+		if !errors.Is(err, syscall.EIO) {
+			t.Fatalf("Se esperaba EIO: %v", err)
+		}
+		if data, _ := dst.ReadFile("/uno.txt"); string(data) != "anterior" {
+			t.Errorf("Una lectura fallida borró el destino: %q", data)
+		}
+	})
+
+	t.Run("HostIntoOwnSubtree", func(t *testing.T) {
+		host := t.TempDir()
+		os.MkdirAll(filepath.Join(host, "a", "b"), 0755)
+		err := CopyBetween(HostDir(host), "/a", HostDir(filepath.Join(host, "a")), "/b/c", CopyOptions{})
+		if err == nil {
+			t.Error("Se permitió copiar un directorio del anfitrión dentro de sí mismo")
+		}
+	})
+}
@@ -0,0 +1,1113 @@
+package minifs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Formato en disco de DiskFS, una versión reducida de ext2:
+//
+//	bloque 0                 superbloque
+//	InodeBitmap...           un bit por inodo, 1 si está en uso
+//	BlockBitmap...           un bit por bloque del dispositivo
+//	InodeTable...            inodos de diskInodeSize bytes
+//	FirstData...             bloques de datos
+//
+// Los números de inodo empiezan en 1 (la raíz); el 0 marca una entrada de
+// directorio vacía. El número de bloque 0 en un puntero marca un hueco,
+// porque el bloque 0 siempre es el superbloque.
+const (
+	diskMagic     = 0x4d494e49 // "MINI"
+	diskBlockSize = 1024
+	diskInodeSize = 128
+	diskRootIno   = 1
+
+	directBlocks = 12
+	ptrsPerBlock = diskBlockSize / 4
+
+	// Índices en diskInode.Block, como i_block en ext2
+	indirectBlock       = 12
+	doubleIndirectBlock = 13
+)
+
+// BlockDevice es el dispositivo sobre el que vive un DiskFS, por ejemplo
+// un *os.File del anfitrión
+type BlockDevice interface {
+	io.ReaderAt
+	io.WriterAt
+}
+
+// superblock describe el dispositivo. Se guarda al principio del bloque 0.
+type superblock struct {
+	Magic       uint32
+	BlockSize   uint32
+	BlockCount  uint32
+	InodeCount  uint32
+	InodeBitmap uint32 // primer bloque de cada región
+	BlockBitmap uint32
+	InodeTable  uint32
+	FirstData   uint32
+	FreeBlocks  uint32
+	FreeInodes  uint32
+}
+
+// diskInode es un inodo tal como se guarda en la tabla
+type diskInode struct {
+	Mode   uint32 // tipo y permisos, como StatT.Mode
+	Nlink  uint32
+	Size   uint64
+	Mtime  int64  // nanosegundos desde 1970
+	Blocks uint32 // bloques reservados, incluidos los de punteros
+	Block  [15]uint32
+	Uid    uint32
+	Gid    uint32
+	_      [128 - 96]byte
+}
+
+func (in *diskInode) isDir() bool {
+	return in.Mode&typeDir != 0
+}
+
+// MkfsOptions configura Mkfs
+type MkfsOptions struct {
+	// Inodes es la cantidad de inodos; cero reserva uno cada 4 KiB
+	Inodes int
+}
+
+// Mkfs da formato a los primeros size bytes de dev: escribe el
+// superbloque, los mapas de bits vacíos, la tabla de inodos y el
+// directorio raíz
+func Mkfs(dev BlockDevice, size int64, opts MkfsOptions) error {
+	blocks := size / diskBlockSize
+	if blocks < 16 || blocks > 1<<32-1 {
+		return fmt.Errorf("tamaño de dispositivo no soportado: %d bytes", size)
+	}
+
+	inodes := int64(opts.Inodes)
+	if inodes == 0 {
+		inodes = max(blocks/4, 16)
+	}
+
+	bitsPerBlock := int64(diskBlockSize * 8)
+	sb := superblock{
+		Magic:       diskMagic,
+		BlockSize:   diskBlockSize,
+		BlockCount:  uint32(blocks),
+		InodeCount:  uint32(inodes),
+		InodeBitmap: 1,
+	}
+	sb.BlockBitmap = sb.InodeBitmap + uint32(ceilDiv(inodes, bitsPerBlock))
+	sb.InodeTable = sb.BlockBitmap + uint32(ceilDiv(blocks, bitsPerBlock))
+	sb.FirstData = sb.InodeTable + uint32(ceilDiv(inodes*diskInodeSize, diskBlockSize))
+	if int64(sb.FirstData)+8 > blocks {
+		return fmt.Errorf("dispositivo demasiado chico para %d inodos", inodes)
+	}
+	sb.FreeBlocks = sb.BlockCount - sb.FirstData
+	sb.FreeInodes = sb.InodeCount
+
+	// El dispositivo tiene tamaño fijo: escribir el último byte lo extiende
+	// si es un archivo
+	if _, err := dev.WriteAt([]byte{0}, blocks*diskBlockSize-1); err != nil {
+		return err
+	}
+
+	// Los metadatos se ponen en cero; el superbloque se escribe al reservar
+	// el inodo de la raíz
+	zero := make([]byte, diskBlockSize)
+	for b := uint32(0); b < sb.FirstData; b++ {
+		if _, err := dev.WriteAt(zero, int64(b)*diskBlockSize); err != nil {
+			return err
+		}
+	}
+
+	d := &DiskFS{dev: dev, sb: sb}
+	for b := uint32(0); b < sb.FirstData; b++ {
+		if err := d.setBit(sb.BlockBitmap, int64(b), true); err != nil {
+			return err
+		}
+	}
+
+	ino, err := d.allocInode()
+	if err != nil {
+		return err
+	}
+	if ino != diskRootIno {
+		return errors.New("la raíz no quedó en el inodo 1")
+	}
+	root := &diskInode{Mode: typeDir | 0755, Nlink: 2, Mtime: nowNano()}
+	if err := d.initDir(root, ino, ino); err != nil {
+		return err
+	}
+	return d.writeInode(ino, root)
+}
+
+// DiskFS es un sistema de archivos con formato de bloques, inodos y mapas
+// de bits sobre un BlockDevice. Implementa Backend, así que se puede usar
+// donde se usa un FileSystem; cada operación lee y escribe el dispositivo
+// directamente, sin caché.
+type DiskFS struct {
+	mu     sync.RWMutex
+	dev    BlockDevice
+	sb     superblock
+	policy PathPolicy
+	closer io.Closer
+}
+
+var _ Backend = (*DiskFS)(nil)
+
+// OpenDisk monta un dispositivo al que ya se le dio formato con Mkfs
+func OpenDisk(dev BlockDevice) (*DiskFS, error) {
+	buf := make([]byte, binary.Size(superblock{}))
+	if _, err := dev.ReadAt(buf, 0); err != nil {
+		return nil, err
+	}
+
+	d := &DiskFS{dev: dev, policy: PathPolicy{MaxNameLen: 255}}
+	if err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &d.sb); err != nil {
+		return nil, err
+	}
+	if d.sb.Magic != diskMagic {
+		return nil, errors.New("el dispositivo no tiene formato de minifs")
+	}
+	if d.sb.BlockSize != diskBlockSize {
+		return nil, fmt.Errorf("tamaño de bloque no soportado: %d", d.sb.BlockSize)
+	}
+	return d, nil
+}
+
+// CreateDiskImage crea un archivo de size bytes en el anfitrión, le da
+// formato y lo monta. Close cierra el archivo.
+func CreateDiskImage(path string, size int64, opts MkfsOptions) (*DiskFS, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := Mkfs(f, size, opts); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	d, err := OpenDisk(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	d.closer = f
+	return d, nil
+}
+
+// OpenDiskImage monta una imagen creada con CreateDiskImage
+func OpenDiskImage(path string) (*DiskFS, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := OpenDisk(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	d.closer = f
+	return d, nil
+}
+
+// Close cierra el archivo de la imagen si DiskFS lo abrió
+func (d *DiskFS) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closer == nil {
+		return nil
+	}
+	err := d.closer.Close()
+	d.closer = nil
+	return err
+}
+
+func ceilDiv(a, b int64) int64 {
+	return (a + b - 1) / b
+}
+
+func nowNano() int64 {
+	return time.Now().UnixNano()
+}
+
+// Bloques
+
+func (d *DiskFS) readBlock(n uint32) ([]byte, error) {
+	buf := make([]byte, diskBlockSize)
+	_, err := d.dev.ReadAt(buf, int64(n)*diskBlockSize)
+	return buf, err
+}
+
+func (d *DiskFS) writeBlock(n uint32, buf []byte) error {
+	_, err := d.dev.WriteAt(buf, int64(n)*diskBlockSize)
+	return err
+}
+
+func (d *DiskFS) writeSuperblock() error {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, &d.sb)
+	_, err := d.dev.WriteAt(buf.Bytes(), 0)
+	return err
+}
+
+// Mapas de bits
+
+func (d *DiskFS) getBit(start uint32, i int64) (bool, error) {
+	block, err := d.readBlock(start + uint32(i/(diskBlockSize*8)))
+	if err != nil {
+		return false, err
+	}
+	bit := i % (diskBlockSize * 8)
+	return block[bit/8]&(1<<(bit%8)) != 0, nil
+}
+
+func (d *DiskFS) setBit(start uint32, i int64, used bool) error {
+	n := start + uint32(i/(diskBlockSize*8))
+	block, err := d.readBlock(n)
+	if err != nil {
+		return err
+	}
+	bit := i % (diskBlockSize * 8)
+	if used {
+		block[bit/8] |= 1 << (bit % 8)
+	} else {
+		block[bit/8] &^= 1 << (bit % 8)
+	}
+	return d.writeBlock(n, block)
+}
+
+// findFree busca el primer bit en cero entre los primeros count bits
+func (d *DiskFS) findFree(start uint32, count int64) (int64, error) {
+	for n := int64(0); n*diskBlockSize*8 < count; n++ {
+		block, err := d.readBlock(start + uint32(n))
+		if err != nil {
+			return 0, err
+		}
+		for i, b := range block {
+			if b == 0xff {
+				continue
+			}
+			for bit := 0; bit < 8; bit++ {
+				idx := n*diskBlockSize*8 + int64(i*8+bit)
+				if b&(1<<bit) == 0 && idx < count {
+					return idx, nil
+				}
+			}
+		}
+	}
+	return 0, errors.New("no queda espacio en el dispositivo")
+}
+
+// allocBlock reserva un bloque y lo llena de ceros
+func (d *DiskFS) allocBlock() (uint32, error) {
+	idx, err := d.findFree(d.sb.BlockBitmap, int64(d.sb.BlockCount))
+	if err != nil {
+		return 0, err
+	}
+	if err := d.setBit(d.sb.BlockBitmap, idx, true); err != nil {
+		return 0, err
+	}
+	if err := d.writeBlock(uint32(idx), make([]byte, diskBlockSize)); err != nil {
+		return 0, err
+	}
+	d.sb.FreeBlocks--
+	return uint32(idx), d.writeSuperblock()
+}
+
+func (d *DiskFS) freeBlock(n uint32) error {
+	if err := d.setBit(d.sb.BlockBitmap, int64(n), false); err != nil {
+		return err
+	}
+	d.sb.FreeBlocks++
+	return d.writeSuperblock()
+}
+
+// allocInode reserva un inodo; el bit i corresponde al inodo i+1
+func (d *DiskFS) allocInode() (uint32, error) {
+	idx, err := d.findFree(d.sb.InodeBitmap, int64(d.sb.InodeCount))
+	if err != nil {
+		return 0, errors.New("no quedan inodos en el dispositivo")
+	}
+	if err := d.setBit(d.sb.InodeBitmap, idx, true); err != nil {
+		return 0, err
+	}
+	d.sb.FreeInodes--
+	return uint32(idx + 1), d.writeSuperblock()
+}
+
+func (d *DiskFS) freeInode(ino uint32) error {
+	if err := d.setBit(d.sb.InodeBitmap, int64(ino-1), false); err != nil {
+		return err
+	}
+	if err := d.writeInode(ino, &diskInode{}); err != nil {
+		return err
+	}
+	d.sb.FreeInodes++
+	return d.writeSuperblock()
+}
+
+// Inodos
+
+func (d *DiskFS) inodeOffset(ino uint32) int64 {
+	return int64(d.sb.InodeTable)*diskBlockSize + int64(ino-1)*diskInodeSize
+}
+
+func (d *DiskFS) readInode(ino uint32) (*diskInode, error) {
+	if ino == 0 || ino > d.sb.InodeCount {
+		return nil, fmt.Errorf("inodo fuera de rango: %d", ino)
+	}
+	buf := make([]byte, diskInodeSize)
+	if _, err := d.dev.ReadAt(buf, d.inodeOffset(ino)); err != nil {
+		return nil, err
+	}
+	in := &diskInode{}
+	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, in)
+	return in, err
+}
+
+func (d *DiskFS) writeInode(ino uint32, in *diskInode) error {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, in)
+	_, err := d.dev.WriteAt(buf.Bytes(), d.inodeOffset(ino))
+	return err
+}
+
+// Bloques de un inodo
+
+func (d *DiskFS) readPtrs(n uint32) ([]uint32, error) {
+	block, err := d.readBlock(n)
+	if err != nil {
+		return nil, err
+	}
+	ptrs := make([]uint32, ptrsPerBlock)
+	for i := range ptrs {
+		ptrs[i] = binary.LittleEndian.Uint32(block[i*4:])
+	}
+	return ptrs, nil
+}
+
+func (d *DiskFS) writePtrs(n uint32, ptrs []uint32) error {
+	block := make([]byte, diskBlockSize)
+	for i, p := range ptrs {
+		binary.LittleEndian.PutUint32(block[i*4:], p)
+	}
+	return d.writeBlock(n, block)
+}
+
+// span es cuántos bloques de datos cubre un puntero de nivel level: 1 para
+// un bloque de datos, ptrsPerBlock para un indirecto, etc.
+func span(level int) int64 {
+	s := int64(1)
+	for ; level > 0; level-- {
+		s *= ptrsPerBlock
+	}
+	return s
+}
+
+// bmap traduce el bloque lógico n de un archivo al bloque del dispositivo,
+// o 0 si es un hueco. Con alloc reserva los bloques que falten; el
+// llamador debe guardar el inodo después.
+func (d *DiskFS) bmap(in *diskInode, n int64, alloc bool) (uint32, error) {
+	if n < directBlocks {
+		return d.walkPtr(in, &in.Block[n], 0, 0, alloc)
+	}
+	n -= directBlocks
+	if n < span(1) {
+		return d.walkPtr(in, &in.Block[indirectBlock], 1, n, alloc)
+	}
+	n -= span(1)
+	if n < span(2) {
+		return d.walkPtr(in, &in.Block[doubleIndirectBlock], 2, n, alloc)
+	}
+	return 0, errors.New("archivo demasiado grande")
+}
+
+// walkPtr baja por el árbol de punteros *ptr de nivel level hasta el
+// bloque de datos n
+func (d *DiskFS) walkPtr(in *diskInode, ptr *uint32, level int, n int64, alloc bool) (uint32, error) {
+	if *ptr == 0 {
+		if !alloc {
+			return 0, nil
+		}
+		b, err := d.allocBlock()
+		if err != nil {
+			return 0, err
+		}
+		*ptr = b
+		in.Blocks++
+	}
+	if level == 0 {
+		return *ptr, nil
+	}
+
+	table, err := d.readPtrs(*ptr)
+	if err != nil {
+		return 0, err
+	}
+	child := span(level - 1)
+	i := n / child
+	before := table[i]
+	b, err := d.walkPtr(in, &table[i], level-1, n%child, alloc)
+	if table[i] != before {
+		if werr := d.writePtrs(*ptr, table); werr != nil {
+			return 0, werr
+		}
+	}
+	return b, err
+}
+
+// freeFrom libera los bloques de datos desde el bloque lógico keep en
+// adelante, y las tablas de punteros que queden vacías
+func (d *DiskFS) freeFrom(in *diskInode, keep int64) error {
+	for i := int64(0); i < directBlocks; i++ {
+		if err := d.freePtr(in, &in.Block[i], 0, keep-i); err != nil {
+			return err
+		}
+	}
+	if err := d.freePtr(in, &in.Block[indirectBlock], 1, keep-directBlocks); err != nil {
+		return err
+	}
+	return d.freePtr(in, &in.Block[doubleIndirectBlock], 2, keep-directBlocks-span(1))
+}
+
+// freePtr libera lo que cubre *ptr a partir del bloque relativo keep; con
+// keep <= 0 libera todo, incluido el propio bloque
+func (d *DiskFS) freePtr(in *diskInode, ptr *uint32, level int, keep int64) error {
+	if *ptr == 0 || keep >= span(level) {
+		return nil
+	}
+
+	if level > 0 {
+		table, err := d.readPtrs(*ptr)
+		if err != nil {
+			return err
+		}
+		child := span(level - 1)
+		for i := range table {
+			if err := d.freePtr(in, &table[i], level-1, keep-int64(i)*child); err != nil {
+				return err
+			}
+		}
+		if keep > 0 {
+			return d.writePtrs(*ptr, table)
+		}
+	}
+
+	if err := d.freeBlock(*ptr); err != nil {
+		return err
+	}
+	*ptr = 0
+	in.Blocks--
+	return nil
+}
+
+// readData lee desde off hasta llenar p o llegar al final del archivo
+func (d *DiskFS) readData(in *diskInode, p []byte, off int64) (int, error) {
+	size := int64(in.Size)
+	if off >= size {
+		return 0, nil
+	}
+	if rest := size - off; int64(len(p)) > rest {
+		p = p[:rest]
+	}
+
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		b, err := d.bmap(in, pos/diskBlockSize, false)
+		if err != nil {
+			return n, err
+		}
+
+		inBlock := pos % diskBlockSize
+		chunk := min(int64(len(p)-n), diskBlockSize-inBlock)
+		if b == 0 {
+			clear(p[n : n+int(chunk)])
+		} else if _, err := d.dev.ReadAt(p[n:n+int(chunk)], int64(b)*diskBlockSize+inBlock); err != nil {
+			return n, err
+		}
+		n += int(chunk)
+	}
+	return n, nil
+}
+
+// writeData escribe p en off reservando los bloques que hagan falta y
+// actualiza el tamaño; el llamador guarda el inodo
+func (d *DiskFS) writeData(in *diskInode, p []byte, off int64) error {
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		b, err := d.bmap(in, pos/diskBlockSize, true)
+		if err != nil {
+			return err
+		}
+
+		inBlock := pos % diskBlockSize
+		chunk := min(int64(len(p)-n), diskBlockSize-inBlock)
+		if _, err := d.dev.WriteAt(p[n:n+int(chunk)], int64(b)*diskBlockSize+inBlock); err != nil {
+			return err
+		}
+		n += int(chunk)
+	}
+
+	if end := uint64(off) + uint64(len(p)); end > in.Size {
+		in.Size = end
+	}
+	return nil
+}
+
+// truncate cambia el tamaño liberando los bloques que quedan afuera. La
+// cola del último bloque se pone en cero para que crecer después lea ceros.
+func (d *DiskFS) truncate(in *diskInode, size int64) error {
+	if size < int64(in.Size) {
+		if err := d.freeFrom(in, ceilDiv(size, diskBlockSize)); err != nil {
+			return err
+		}
+		if tail := size % diskBlockSize; tail != 0 {
+			b, err := d.bmap(in, size/diskBlockSize, false)
+			if err != nil {
+				return err
+			}
+			if b != 0 {
+				zero := make([]byte, diskBlockSize-tail)
+				if _, err := d.dev.WriteAt(zero, int64(b)*diskBlockSize+tail); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	in.Size = uint64(size)
+	return nil
+}
+
+// Directorios
+//
+// Un directorio es una lista de entradas de largo variable, como en ext2:
+// inodo (4 bytes), largo del registro (2), largo del nombre (1), tipo (1)
+// y el nombre. Cada registro ocupa hasta el siguiente, así que el último
+// de un bloque se estira hasta el final y borrar una entrada es sumar su
+// largo al de la anterior.
+
+const (
+	direntHeader = 8
+	direntFile   = 1
+	direntDir    = 2
+)
+
+type dirent struct {
+	ino  uint32
+	name string
+	typ  uint8
+}
+
+// direntSize es lo mínimo que ocupa una entrada, alineado a 4 bytes
+func direntSize(name string) int {
+	return (direntHeader + len(name) + 3) &^ 3
+}
+
+func putDirent(block []byte, off, recLen int, e dirent) {
+	binary.LittleEndian.PutUint32(block[off:], e.ino)
+	binary.LittleEndian.PutUint16(block[off+4:], uint16(recLen))
+	block[off+6] = uint8(len(e.name))
+	block[off+7] = e.typ
+	copy(block[off+direntHeader:], e.name)
+}
+
+// parseDirent lee la entrada en off y devuelve su largo de registro
+func parseDirent(block []byte, off int) (dirent, int) {
+	e := dirent{
+		ino: binary.LittleEndian.Uint32(block[off:]),
+		typ: block[off+7],
+	}
+	recLen := int(binary.LittleEndian.Uint16(block[off+4:]))
+	nameLen := int(block[off+6])
+	e.name = string(block[off+direntHeader : off+direntHeader+nameLen])
+	return e, recLen
+}
+
+// initDir escribe el primer bloque de un directorio nuevo con "." y ".."
+func (d *DiskFS) initDir(in *diskInode, ino, parent uint32) error {
+	b, err := d.bmap(in, 0, true)
+	if err != nil {
+		return err
+	}
+
+	block := make([]byte, diskBlockSize)
+	dot := dirent{ino: ino, name: ".", typ: direntDir}
+	putDirent(block, 0, direntSize("."), dot)
+	putDirent(block, direntSize("."), diskBlockSize-direntSize("."), dirent{ino: parent, name: "..", typ: direntDir})
+	in.Size = diskBlockSize
+	return d.writeBlock(b, block)
+}
+
+// dirBlocks recorre los bloques de un directorio; fn puede modificar el
+// bloque y devolver true para que se guarde, o errStop para terminar
+func (d *DiskFS) dirBlocks(in *diskInode, fn func(block []byte) (bool, error)) error {
+	for n := int64(0); n < int64(in.Size)/diskBlockSize; n++ {
+		b, err := d.bmap(in, n, false)
+		if err != nil {
+			return err
+		}
+		block, err := d.readBlock(b)
+		if err != nil {
+			return err
+		}
+
+		dirty, err := fn(block)
+		if dirty {
+			if werr := d.writeBlock(b, block); werr != nil {
+				return werr
+			}
+		}
+		if err == errStop {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var errStop = errors.New("stop")
+
+// readDir devuelve las entradas de un directorio, incluidas "." y ".."
+func (d *DiskFS) readDir(in *diskInode) ([]dirent, error) {
+	var entries []dirent
+	err := d.dirBlocks(in, func(block []byte) (bool, error) {
+		for off := 0; off < diskBlockSize; {
+			e, recLen := parseDirent(block, off)
+			if e.ino != 0 {
+				entries = append(entries, e)
+			}
+			off += recLen
+		}
+		return false, nil
+	})
+	return entries, err
+}
+
+// findEntry busca name en un directorio y devuelve su inodo, o 0
+func (d *DiskFS) findEntry(in *diskInode, name string) (dirent, error) {
+	var found dirent
+	err := d.dirBlocks(in, func(block []byte) (bool, error) {
+		for off := 0; off < diskBlockSize; {
+			e, recLen := parseDirent(block, off)
+			if e.ino != 0 && e.name == name {
+				found = e
+				return false, errStop
+			}
+			off += recLen
+		}
+		return false, nil
+	})
+	return found, err
+}
+
+// addEntry agrega una entrada usando el espacio libre al final de algún
+// registro o, si no hay, un bloque nuevo. El llamador guarda el inodo.
+func (d *DiskFS) addEntry(in *diskInode, e dirent) error {
+	need := direntSize(e.name)
+	added := false
+
+	err := d.dirBlocks(in, func(block []byte) (bool, error) {
+		for off := 0; off < diskBlockSize; {
+			cur, recLen := parseDirent(block, off)
+			used := 0
+			if cur.ino != 0 {
+				used = direntSize(cur.name)
+			}
+			if recLen-used >= need {
+				if used > 0 {
+					putDirent(block, off, used, cur)
+				}
+				putDirent(block, off+used, recLen-used, e)
+				added = true
+				return true, errStop
+			}
+			off += recLen
+		}
+		return false, nil
+	})
+	if err != nil || added {
+		return err
+	}
+
+	b, err := d.bmap(in, int64(in.Size)/diskBlockSize, true)
+	if err != nil {
+		return err
+	}
+	block := make([]byte, diskBlockSize)
+	putDirent(block, 0, diskBlockSize, e)
+	in.Size += diskBlockSize
+	return d.writeBlock(b, block)
+}
+
+// setEntry cambia el inodo de la entrada name, o la elimina si ino es 0
+func (d *DiskFS) setEntry(in *diskInode, name string, ino uint32) error {
+	return d.dirBlocks(in, func(block []byte) (bool, error) {
+		prev := -1
+		for off := 0; off < diskBlockSize; {
+			e, recLen := parseDirent(block, off)
+			if e.ino == 0 || e.name != name {
+				prev = off
+				off += recLen
+				continue
+			}
+
+			switch {
+			case ino != 0:
+				e.ino = ino
+				putDirent(block, off, recLen, e)
+			case prev >= 0:
+				// El registro anterior absorbe al eliminado
+				prevEntry, prevLen := parseDirent(block, prev)
+				putDirent(block, prev, prevLen+recLen, prevEntry)
+			default:
+				// La primera entrada de un bloque queda vacía
+				e.ino = 0
+				putDirent(block, off, recLen, e)
+			}
+			return true, errStop
+		}
+		return false, nil
+	})
+}
+
+// Dump escribe una descripción legible de las estructuras en disco: el
+// superbloque, el uso de los mapas de bits, cada inodo en uso con sus
+// bloques y las entradas de cada directorio
+func (d *DiskFS) Dump(w io.Writer) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	sb := d.sb
+	fmt.Fprintf(w, "superbloque: %d bloques de %d bytes, %d inodos\n", sb.BlockCount, sb.BlockSize, sb.InodeCount)
+	fmt.Fprintf(w, "  mapa de inodos: bloque %d\n", sb.InodeBitmap)
+	fmt.Fprintf(w, "  mapa de bloques: bloque %d\n", sb.BlockBitmap)
+	fmt.Fprintf(w, "  tabla de inodos: bloques %d-%d\n", sb.InodeTable, sb.FirstData-1)
+	fmt.Fprintf(w, "  datos: bloques %d-%d\n", sb.FirstData, sb.BlockCount-1)
+	fmt.Fprintf(w, "  libres: %d bloques, %d inodos\n", sb.FreeBlocks, sb.FreeInodes)
+
+	for ino := uint32(1); ino <= sb.InodeCount; ino++ {
+		used, err := d.getBit(sb.InodeBitmap, int64(ino-1))
+		if err != nil {
+			return err
+		}
+		if !used {
+			continue
+		}
+
+		in, err := d.readInode(ino)
+		if err != nil {
+			return err
+		}
+		kind := "archivo"
+		if in.isDir() {
+			kind = "dir"
+		}
+		fmt.Fprintf(w, "inodo %d: %s %04o nlink=%d tamaño=%d bloques=%d\n",
+			ino, kind, in.Mode&0777, in.Nlink, in.Size, in.Blocks)
+
+		var direct []uint32
+		for _, b := range in.Block[:directBlocks] {
+			if b != 0 {
+				direct = append(direct, b)
+			}
+		}
+		if len(direct) > 0 {
+			fmt.Fprintf(w, "  directos: %v\n", direct)
+		}
+		if b := in.Block[indirectBlock]; b != 0 {
+			fmt.Fprintf(w, "  indirecto: %d\n", b)
+		}
+		if b := in.Block[doubleIndirectBlock]; b != 0 {
+			fmt.Fprintf(w, "  doble indirecto: %d\n", b)
+		}
+
+		if in.isDir() {
+			entries, err := d.readDir(in)
+			if err != nil {
+				return err
+			}
+			for _, e := range entries {
+				fmt.Fprintf(w, "  %q -> %d\n", e.name, e.ino)
+			}
+		}
+	}
+	return nil
+}
+
+// inodeBlocks llama a fn con cada bloque de in. n es el bloque lógico de
+// los bloques de datos y -1 para las tablas de punteros.
+func (d *DiskFS) inodeBlocks(in *diskInode, fn func(b uint32, n int64)) error {
+	for i := int64(0); i < directBlocks; i++ {
+		if err := d.visitPtr(in.Block[i], 0, i, fn); err != nil {
+			return err
+		}
+	}
+	if err := d.visitPtr(in.Block[indirectBlock], 1, directBlocks, fn); err != nil {
+		return err
+	}
+	return d.visitPtr(in.Block[doubleIndirectBlock], 2, directBlocks+span(1), fn)
+}
+
+func (d *DiskFS) visitPtr(b uint32, level int, first int64, fn func(b uint32, n int64)) error {
+	if b == 0 {
+		return nil
+	}
+	if level == 0 {
+		fn(b, first)
+		return nil
+	}
+
+	fn(b, -1)
+	table, err := d.readPtrs(b)
+	if err != nil {
+		return err
+	}
+	for i, p := range table {
+		if err := d.visitPtr(p, level-1, first+int64(i)*span(level-1), fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readBitmap lee los primeros count bits de un mapa
+func (d *DiskFS) readBitmap(start uint32, count int64) ([]bool, error) {
+	bits := make([]bool, count)
+	for n := int64(0); n*diskBlockSize*8 < count; n++ {
+		block, err := d.readBlock(start + uint32(n))
+		if err != nil {
+			return nil, err
+		}
+		for i := int64(0); i < diskBlockSize*8 && n*diskBlockSize*8+i < count; i++ {
+			bits[n*diskBlockSize*8+i] = block[i/8]&(1<<(i%8)) != 0
+		}
+	}
+	return bits, nil
+}
+
+// diskChecker guarda el estado de un recorrido de Check sobre DiskFS
+type diskChecker struct {
+	d      *DiskFS
+	report *Report
+
+	dirs   map[uint32]string // directorios visitados y su ruta
+	links  map[uint32]int    // entradas que apuntan a cada archivo
+	paths  map[uint32]string // primera ruta de cada archivo
+	blocks map[uint32]string // bloques en uso y la ruta que los usa
+}
+
+func (c *diskChecker) problem(kind ProblemKind, path, format string, args ...any) {
+	c.report.Problems = append(c.report.Problems, Problem{Kind: kind, Path: path, Detail: fmt.Sprintf(format, args...)})
+}
+
+// Check hace con la imagen lo que fsck con un ext2: recorre el árbol desde
+// la raíz y verifica "." y "..", los contadores de enlaces, que ningún
+// bloque esté en dos archivos ni más allá del tamaño, y que los mapas de
+// bits y el superbloque coincidan con lo que se usa. No modifica nada.
+func (d *DiskFS) Check() *Report {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	c := &diskChecker{
+		d:      d,
+		report: &Report{},
+		dirs:   make(map[uint32]string),
+		links:  make(map[uint32]int),
+		paths:  make(map[uint32]string),
+		blocks: make(map[uint32]string),
+	}
+
+	if err := c.checkDir(diskRootIno, diskRootIno, "/"); err != nil {
+		c.problem(BadInode, "/", "%v", err)
+		return c.report
+	}
+	if err := c.checkFiles(); err != nil {
+		c.problem(BadInode, "/", "%v", err)
+	}
+	if err := c.checkBitmaps(); err != nil {
+		c.problem(BadBitmap, "/", "%v", err)
+	}
+
+	sort.SliceStable(c.report.Problems, func(i, j int) bool {
+		return c.report.Problems[i].Path < c.report.Problems[j].Path
+	})
+	return c.report
+}
+
+func (c *diskChecker) checkDir(ino, parent uint32, dirPath string) error {
+	if prev, seen := c.dirs[ino]; seen {
+		c.problem(Cycle, dirPath, "el directorio ya apareció en %s", prev)
+		return nil
+	}
+	c.dirs[ino] = dirPath
+	c.report.Dirs++
+
+	n, err := c.d.node(ino)
+	if err != nil {
+		return err
+	}
+	c.useBlocks(n.in, dirPath)
+
+	entries, err := c.d.readDir(n.in)
+	if err != nil {
+		return err
+	}
+
+	subdirs := 0
+	for _, e := range entries {
+		switch e.name {
+		case ".":
+			if e.ino != ino {
+				c.problem(BadParent, dirPath, `"." apunta al inodo %d`, e.ino)
+			}
+			continue
+		case "..":
+			if e.ino != parent {
+				c.problem(BadParent, dirPath, `".." apunta al inodo %d, se esperaba %d`, e.ino, parent)
+			}
+			continue
+		}
+
+		childPath := path.Join(dirPath, e.name)
+		child, err := c.d.node(e.ino)
+		if err != nil {
+			return err
+		}
+		if child.in.isDir() {
+			subdirs++
+			if err := c.checkDir(e.ino, ino, childPath); err != nil {
+				return err
+			}
+			continue
+		}
+
+		c.links[e.ino]++
+		if _, seen := c.paths[e.ino]; !seen {
+			c.paths[e.ino] = childPath
+		}
+	}
+
+	if want := uint32(2 + subdirs); n.in.Nlink != want {
+		c.problem(BadLinkCount, dirPath, "nlink es %d, se esperaba %d", n.in.Nlink, want)
+	}
+	return nil
+}
+
+// checkFiles verifica cada archivo una sola vez, aunque tenga varios enlaces
+func (c *diskChecker) checkFiles() error {
+	for ino, filePath := range c.paths {
+		n, err := c.d.node(ino)
+		if err != nil {
+			return err
+		}
+		c.report.Files++
+		c.report.Bytes += int64(n.in.Size)
+
+		if want := uint32(c.links[ino]); n.in.Nlink != want {
+			c.problem(BadLinkCount, filePath, "nlink es %d, se esperaba %d", n.in.Nlink, want)
+		}
+
+		last := ceilDiv(int64(n.in.Size), diskBlockSize)
+		if err := c.d.inodeBlocks(n.in, func(b uint32, logical int64) {
+			if logical >= last {
+				c.problem(BadSize, filePath, "bloque lógico %d más allá del tamaño %d", logical, n.in.Size)
+			}
+		}); err != nil {
+			return err
+		}
+		c.useBlocks(n.in, filePath)
+	}
+	return nil
+}
+
+// useBlocks anota los bloques de in y verifica su contador
+func (c *diskChecker) useBlocks(in *diskInode, owner string) {
+	count := uint32(0)
+	err := c.d.inodeBlocks(in, func(b uint32, _ int64) {
+		count++
+		if b < c.d.sb.FirstData || b >= c.d.sb.BlockCount {
+			c.problem(BadBitmap, owner, "bloque %d fuera de la zona de datos", b)
+			return
+		}
+		if prev, used := c.blocks[b]; used {
+			c.problem(BadBitmap, owner, "bloque %d usado también por %s", b, prev)
+			return
+		}
+		c.blocks[b] = owner
+	})
+	if err != nil {
+		c.problem(BadInode, owner, "%v", err)
+	}
+	if count != in.Blocks {
+		c.problem(BadInode, owner, "el inodo cuenta %d bloques y usa %d", in.Blocks, count)
+	}
+}
+
+// checkBitmaps compara los mapas de bits y los contadores del superbloque
+// con los inodos y bloques alcanzables desde la raíz
+func (c *diskChecker) checkBitmaps() error {
+	sb := c.d.sb
+
+	inodes, err := c.d.readBitmap(sb.InodeBitmap, int64(sb.InodeCount))
+	if err != nil {
+		return err
+	}
+	free := uint32(0)
+	for i, used := range inodes {
+		ino := uint32(i + 1)
+		_, isDir := c.dirs[ino]
+		_, isFile := c.paths[ino]
+		reachable := isDir || isFile
+		switch {
+		case used && !reachable:
+			c.problem(BadBitmap, "/", "inodo %d en uso pero inalcanzable", ino)
+		case !used && reachable:
+			c.problem(BadBitmap, "/", "inodo %d alcanzable pero libre en el mapa", ino)
+		}
+		if !used {
+			free++
+		}
+	}
+	if free != sb.FreeInodes {
+		c.problem(BadBitmap, "/", "el superbloque cuenta %d inodos libres y hay %d", sb.FreeInodes, free)
+	}
+
+	blocks, err := c.d.readBitmap(sb.BlockBitmap, int64(sb.BlockCount))
+	if err != nil {
+		return err
+	}
+	free = 0
+	for i, used := range blocks {
+		b := uint32(i)
+		_, inUse := c.blocks[b]
+		inUse = inUse || b < sb.FirstData
+		switch {
+		case used && !inUse:
+			c.problem(BadBitmap, "/", "bloque %d en uso pero sin dueño", b)
+		case !used && inUse:
+			c.problem(BadBitmap, c.blocks[b], "bloque %d en uso pero libre en el mapa", b)
+		}
+		if !used {
+			free++
+		}
+	}
+	if free != sb.FreeBlocks {
+		c.problem(BadBitmap, "/", "el superbloque cuenta %d bloques libres y hay %d", sb.FreeBlocks, free)
+	}
+	return nil
+}
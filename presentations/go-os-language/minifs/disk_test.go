@@ -0,0 +1,299 @@
+package minifs
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newCheckedDisk crea una imagen en un archivo temporal del anfitrión y
+// verifica al final del test que siga consistente
+func newCheckedDisk(t *testing.T, size int64) *DiskFS {
+	t.Helper()
+	d, err := CreateDiskImage(filepath.Join(t.TempDir(), "disco.img"), size, MkfsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if report := d.Check(); !report.OK() {
+			t.Errorf("La imagen quedó inconsistente: %v", report)
+		}
+		d.Close()
+	})
+	return d
+}
+
+// TestDiskFSSuite corre sobre DiskFS los mismos tests que minifs_test.go
+// corre sobre FileSystem
+func TestDiskFSSuite(t *testing.T) {
+	suite := []struct {
+		name string
+		test func(t *testing.T, fs Backend)
+	}{
+		{"Operations", testFileSystemOperations},
+		{"Walk", testWalk},
+		{"Concurrency", testConcurrency},
+		{"EdgeCases", testEdgeCases},
+		{"Metadata", testMetadata},
+	}
+	for _, s := range suite {
+		t.Run(s.name, func(t *testing.T) {
+			s.test(t, newCheckedDisk(t, 4<<20))
+		})
+	}
+}
+
+func TestDiskPersistence(t *testing.T) {
+	image := filepath.Join(t.TempDir(), "disco.img")
+	d, err := CreateDiskImage(image, 1<<20, MkfsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.MkdirAll("/etc/ssh", 0700)
+	d.WriteFile("/etc/hosts", []byte("127.0.0.1 localhost"))
+	d.Link("/etc/hosts", "/hosts")
+	d.Close()
+
+	d, err = OpenDiskImage(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if data, _ := d.ReadFile("/hosts"); string(data) != "127.0.0.1 localhost" {
+		t.Errorf("Contenido tras volver a montar: %q", data)
+	}
+	if info, err := d.Stat("/etc/ssh"); err != nil || info.Mode != 0700 {
+		t.Errorf("Directorio tras volver a montar: %+v %v", info, err)
+	}
+	if st := statT(t, d, "/etc/hosts"); st.Nlink != 2 {
+		t.Errorf("nlink tras volver a montar: %d", st.Nlink)
+	}
+	if report := d.Check(); !report.OK() {
+		t.Error(report)
+	}
+}
+
+func TestDiskLargeFile(t *testing.T) {
+	d := newCheckedDisk(t, 4<<20)
+
+	// Pasa por los bloques directos, el indirecto y el doble indirecto
+	size := (directBlocks + ptrsPerBlock + 10) * diskBlockSize
+	content := bytes.Repeat([]byte("0123456789abcdef"), size/16)
+	if err := d.WriteFile("/grande", content); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := d.ReadFile("/grande"); !bytes.Equal(data, content) {
+		t.Fatal("El contenido del archivo grande no coincide")
+	}
+
+	free := d.sb.FreeBlocks
+	if err := d.Truncate("/grande", 3*diskBlockSize+7); err != nil {
+		t.Fatal(err)
+	}
+	// Se liberan los bloques de datos y las tres tablas de punteros: la
+	// indirecta, la doble y la única que colgaba de ella
+	if freed := d.sb.FreeBlocks - free; freed != uint32(size/diskBlockSize-4+3) {
+		t.Errorf("Truncate liberó %d bloques", freed)
+	}
+
+	// Al crecer, lo que había más allá del tamaño no reaparece
+	d.Truncate("/grande", 5*diskBlockSize)
+	data, _ := d.ReadFile("/grande")
+	if !bytes.Equal(data[:3*diskBlockSize+7], content[:3*diskBlockSize+7]) ||
+		!bytes.Equal(data[3*diskBlockSize+7:], make([]byte, 2*diskBlockSize-7)) {
+		t.Error("Truncate no dejó ceros al volver a crecer")
+	}
+	if st := statT(t, d, "/grande"); st.Blocks != 4*diskBlockSize/512 {
+		t.Errorf("El hueco no debería ocupar bloques: %d", st.Blocks)
+	}
+
+	d.Remove("/grande")
+	if d.sb.FreeBlocks != free+uint32(size/diskBlockSize)+3 {
+		t.Errorf("Quedaron bloques sin liberar: %d libres", d.sb.FreeBlocks)
+	}
+}
+
+func TestDiskDirectoryEntries(t *testing.T) {
+	d := newCheckedDisk(t, 1<<20)
+	d.CreateDir("/d", 0755)
+
+	// Nombres largos para que el directorio ocupe varios bloques
+	var names []string
+	for i := 0; i < 40; i++ {
+		name := strings.Repeat(string(rune('a'+i%26)), 40+i)
+		names = append(names, name)
+		if err := d.WriteFile("/d/"+name, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if st := statT(t, d, "/d"); st.Size <= diskBlockSize {
+		t.Fatalf("El directorio debería ocupar varios bloques: %d bytes", st.Size)
+	}
+
+	// Borrar una de cada dos y volver a crear deja el hueco reutilizable
+	for i := 0; i < len(names); i += 2 {
+		if err := d.Remove("/d/" + names[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	size := statT(t, d, "/d").Size
+	for i := 0; i < len(names); i += 2 {
+		d.WriteFile("/d/"+names[i], nil)
+	}
+	if st := statT(t, d, "/d"); st.Size != size {
+		t.Errorf("No se reutilizaron las entradas libres: %d bytes, antes %d", st.Size, size)
+	}
+
+	files, _ := d.ListDir("/d")
+	if len(files) != len(names) {
+		t.Errorf("ListDir devolvió %d entradas, se esperaban %d", len(files), len(names))
+	}
+
+	// Mover un directorio actualiza su ".."
+	d.MkdirAll("/x/y", 0755)
+	if err := d.Rename("/d", "/x/y/d"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Rename("/x", "/x/y/d/x"); err == nil {
+		t.Error("Se movió un directorio dentro de sí mismo")
+	}
+}
+
+func TestDiskFull(t *testing.T) {
+	d := newCheckedDisk(t, 64<<10)
+
+	err := d.WriteFile("/grande", make([]byte, 64<<10))
+	if err == nil || !strings.Contains(err.Error(), "no queda espacio") {
+		t.Fatalf("Se esperaba un error de espacio: %v", err)
+	}
+	if d.Exists("/grande") {
+		t.Error("Un archivo que no entró no debería quedar creado")
+	}
+	if err := d.WriteFile("/chico", []byte("entra")); err != nil {
+		t.Errorf("Tras liberar lo reservado debería entrar un archivo chico: %v", err)
+	}
+}
+
+func TestDiskOverwriteFull(t *testing.T) {
+	image := filepath.Join(t.TempDir(), "disco.img")
+	d, err := CreateDiskImage(image, 64<<10, MkfsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := bytes.Repeat([]byte("a"), 2*diskBlockSize)
+	if err := d.WriteFile("/a", old); err != nil {
+		t.Fatal(err)
+	}
+	// Llenar el disco con archivos grandes y después con chicos, así se
+	// acaban los bloques antes que los inodos
+	i := 0
+	for _, blocks := range []int{directBlocks, 1} {
+		for d.WriteFile(fmt.Sprintf("/relleno%d", i), make([]byte, blocks*diskBlockSize)) == nil {
+			i++
+		}
+	}
+	if d.sb.FreeBlocks != 0 {
+		t.Fatalf("El disco debería estar lleno: %d bloques libres", d.sb.FreeBlocks)
+	}
+	free := d.sb.FreeBlocks
+
+	// Sobrescribir sin espacio falla sin tocar el archivo ni perder bloques
+	err = d.WriteFile("/a", bytes.Repeat([]byte("b"), 4*diskBlockSize))
+	if err == nil || !strings.Contains(err.Error(), "no queda espacio") {
+		t.Fatalf("Se esperaba un error de espacio: %v", err)
+	}
+	if data, _ := d.ReadFile("/a"); !bytes.Equal(data, old) {
+		t.Errorf("El archivo cambió tras una sobrescritura fallida: %d bytes", len(data))
+	}
+	if d.sb.FreeBlocks != free {
+		t.Errorf("Se perdieron bloques: %d libres, antes %d", d.sb.FreeBlocks, free)
+	}
+	d.Close()
+
+	d, err = OpenDiskImage(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if report := d.Check(); !report.OK() {
+		t.Errorf("La imagen quedó inconsistente: %v", report)
+	}
+	if data, _ := d.ReadFile("/a"); !bytes.Equal(data, old) {
+		t.Error("El archivo cambió tras volver a montar")
+	}
+}
+
+func TestDiskAppendFull(t *testing.T) {
+	d := newCheckedDisk(t, 64<<10)
+	old := bytes.Repeat([]byte("a"), diskBlockSize+diskBlockSize/2)
+	if err := d.WriteFile("/a", old); err != nil {
+		t.Fatal(err)
+	}
+	i := 0
+	for _, blocks := range []int{directBlocks, 1} {
+		for d.WriteFile(fmt.Sprintf("/relleno%d", i), make([]byte, blocks*diskBlockSize)) == nil {
+			i++
+		}
+	}
+	// Queda libre un solo bloque para un agregado que necesita cuatro: la
+	// escritura alcanza a usarlo antes de fallar
+	d.Remove(fmt.Sprintf("/relleno%d", i-1))
+	free := d.sb.FreeBlocks
+	if free != 1 {
+		t.Fatalf("Debería quedar un bloque libre: %d", free)
+	}
+
+	err := d.AppendFile("/a", bytes.Repeat([]byte("b"), 4*diskBlockSize))
+	if err == nil || !strings.Contains(err.Error(), "no queda espacio") {
+		t.Fatalf("Se esperaba un error de espacio: %v", err)
+	}
+	if data, _ := d.ReadFile("/a"); !bytes.Equal(data, old) {
+		t.Errorf("El archivo cambió tras un agregado fallido: %d bytes", len(data))
+	}
+	if d.sb.FreeBlocks != free {
+		t.Errorf("Se perdieron bloques: %d libres, antes %d", d.sb.FreeBlocks, free)
+	}
+
+	// La cola del último bloque vuelve a leerse como ceros
+	d.Truncate("/a", 2*diskBlockSize)
+	data, _ := d.ReadFile("/a")
+	if !bytes.Equal(data[len(old):], make([]byte, 2*diskBlockSize-len(old))) {
+		t.Error("Quedaron datos del agregado fallido después del final")
+	}
+}
+
+func TestDiskDump(t *testing.T) {
+	d := newCheckedDisk(t, 1<<20)
+	d.MkdirAll("/etc", 0755)
+	d.WriteFile("/etc/hosts", []byte("127.0.0.1 localhost"))
+
+	var b strings.Builder
+	if err := d.Dump(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"superbloque: 1024 bloques", "inodo 1: dir 0755 nlink=3", `"hosts" -> 3`, "tamaño=19"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("Falta %q en el volcado:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestDiskCheck(t *testing.T) {
+	d := newCheckedDisk(t, 1<<20)
+	d.WriteFile("/a", []byte("a"))
+
+	// Marcar libre un bloque en uso es lo que dejaría un corte de luz a
+	// mitad de una escritura
+	st := statT(t, d, "/a")
+	n, _ := d.node(uint32(st.Ino))
+	d.setBit(d.sb.BlockBitmap, int64(n.in.Block[0]), false)
+
+	report := d.Check()
+	if report.OK() || report.Problems[0].Kind != BadBitmap {
+		t.Errorf("Check no encontró el bloque libre en el mapa: %v", report)
+	}
+	d.setBit(d.sb.BlockBitmap, int64(n.in.Block[0]), true)
+}
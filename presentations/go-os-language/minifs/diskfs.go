@@ -0,0 +1,800 @@
+package minifs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Operaciones de Backend sobre DiskFS. Siguen la semántica y los mensajes
+// de error de FileSystem; la diferencia es que cada inodo se lee del
+// dispositivo, se modifica en memoria y se vuelve a escribir.
+
+// diskNode es un inodo leído del dispositivo junto con su número
+type diskNode struct {
+	ino uint32
+	in  *diskInode
+}
+
+func (d *DiskFS) node(ino uint32) (diskNode, error) {
+	in, err := d.readInode(ino)
+	return diskNode{ino: ino, in: in}, err
+}
+
+func (d *DiskFS) save(n diskNode) error {
+	return d.writeInode(n.ino, n.in)
+}
+
+// newNode reserva un inodo con el modo dado; el llamador lo guarda
+func (d *DiskFS) newNode(mode uint32, nlink uint32) (diskNode, error) {
+	ino, err := d.allocInode()
+	if err != nil {
+		return diskNode{}, err
+	}
+	return diskNode{ino: ino, in: &diskInode{Mode: mode, Nlink: nlink, Mtime: nowNano()}}, nil
+}
+
+// free libera los bloques y el inodo de n
+func (d *DiskFS) free(n diskNode) error {
+	if err := d.freeFrom(n.in, 0); err != nil {
+		return err
+	}
+	return d.freeInode(n.ino)
+}
+
+func (n diskNode) info(name string) FileInfo {
+	mtime := time.Unix(0, n.in.Mtime)
+	size := int64(n.in.Size)
+	st := &StatT{
+		Ino:     uint64(n.ino),
+		Nlink:   uint64(n.in.Nlink),
+		Mode:    n.in.Mode,
+		Uid:     n.in.Uid,
+		Gid:     n.in.Gid,
+		Size:    size,
+		Blksize: diskBlockSize,
+		Blocks:  int64(n.in.Blocks) * diskBlockSize / 512,
+		Mtim:    mtime,
+	}
+	if n.in.isDir() {
+		// Como en FileSystem, los directorios se ven con tamaño cero
+		size = 0
+	}
+
+	return FileInfo{
+		Name:    name,
+		Size:    size,
+		Mode:    os.FileMode(n.in.Mode & 0777),
+		ModTime: mtime,
+		IsDir:   n.in.isDir(),
+		sys:     st,
+	}
+}
+
+// walkParts baja desde la raíz por los componentes dados. path solo se usa
+// en los mensajes de error.
+func (d *DiskFS) walkParts(path string, parts []string) (diskNode, error) {
+	current, err := d.node(diskRootIno)
+	if err != nil {
+		return diskNode{}, err
+	}
+
+	for _, part := range parts {
+		e, err := d.findEntry(current.in, part)
+		if err != nil {
+			return diskNode{}, err
+		}
+		if e.ino == 0 {
+			return diskNode{}, errors.New("ruta no encontrada: " + path)
+		}
+
+		child, err := d.node(e.ino)
+		if err != nil {
+			return diskNode{}, err
+		}
+		if !child.in.isDir() {
+			return diskNode{}, errors.New("no es un directorio: " + part)
+		}
+		current = child
+	}
+	return current, nil
+}
+
+// resolve devuelve el directorio que contiene al último componente de path
+// y ese nombre; para la raíz el nombre es ""
+func (d *DiskFS) resolve(path string) (diskNode, string, error) {
+	parts, err := d.policy.split(path)
+	if err != nil {
+		return diskNode{}, "", err
+	}
+	if len(parts) == 0 {
+		root, err := d.node(diskRootIno)
+		return root, "", err
+	}
+
+	parent, err := d.walkParts(path, parts[:len(parts)-1])
+	if err != nil {
+		return diskNode{}, "", err
+	}
+	return parent, parts[len(parts)-1], nil
+}
+
+// child busca name en dir; el inodo es 0 si no existe
+func (d *DiskFS) child(dir diskNode, name string) (diskNode, bool, error) {
+	e, err := d.findEntry(dir.in, name)
+	if err != nil || e.ino == 0 {
+		return diskNode{}, false, err
+	}
+	n, err := d.node(e.ino)
+	return n, err == nil, err
+}
+
+// lookup localiza el nodo de path y el nombre con el que se ve
+func (d *DiskFS) lookup(path string) (diskNode, string, error) {
+	parent, name, err := d.resolve(path)
+	if err != nil {
+		return diskNode{}, "", err
+	}
+	if name == "" {
+		return parent, "/", nil
+	}
+
+	n, exists, err := d.child(parent, name)
+	if err != nil {
+		return diskNode{}, "", err
+	}
+	if !exists {
+		return diskNode{}, "", errors.New("no existe: " + path)
+	}
+	return n, name, nil
+}
+
+// CreateDir crea un nuevo directorio
+func (d *DiskFS) CreateDir(path string, mode os.FileMode) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	parent, name, err := d.resolve(path)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return errors.New("el directorio ya existe: /")
+	}
+	if _, exists, err := d.child(parent, name); err != nil {
+		return err
+	} else if exists {
+		return errors.New("el directorio ya existe: " + name)
+	}
+
+	dir, err := d.newNode(typeDir|uint32(mode.Perm()), 2)
+	if err != nil {
+		return err
+	}
+	if err := d.initDir(dir.in, dir.ino, parent.ino); err != nil {
+		d.free(dir)
+		return err
+	}
+	if err := d.addEntry(parent.in, dirent{ino: dir.ino, name: name, typ: direntDir}); err != nil {
+		d.free(dir)
+		return err
+	}
+	if err := d.save(dir); err != nil {
+		return err
+	}
+
+	parent.in.Nlink++ // el ".." del nuevo directorio
+	parent.in.Mtime = nowNano()
+	return d.save(parent)
+}
+
+// MkdirAll crea un directorio y todos sus padres si no existen
+func (d *DiskFS) MkdirAll(path string, mode os.FileMode) error {
+	parts, err := d.policy.split(path)
+	if err != nil {
+		return err
+	}
+	current := "/"
+
+	for _, part := range parts {
+		current = filepath.Join(current, part)
+		if err := d.CreateDir(current, mode); err != nil {
+			if !strings.Contains(err.Error(), "ya existe") {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// CreateFile crea un nuevo archivo con contenido, o reemplaza el contenido
+// y los permisos si ya existe
+func (d *DiskFS) CreateFile(path string, content []byte, mode os.FileMode) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.createFile(path, content, mode, true)
+}
+
+// createFile asume que el llamador tiene d.mu tomado para escritura. chmod
+// indica si un archivo existente toma los permisos mode.
+func (d *DiskFS) createFile(path string, content []byte, mode os.FileMode, chmod bool) error {
+	parent, name, err := d.resolve(path)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return errors.New("nombre de archivo vacío")
+	}
+
+	existing, exists, err := d.child(parent, name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		if existing.in.isDir() {
+			return errors.New("ya existe un directorio con ese nombre: " + name)
+		}
+		// El contenido nuevo va a bloques propios y recién después se
+		// sueltan los viejos: si no entra, el archivo queda como estaba
+		fresh := &diskInode{}
+		if err := d.writeData(fresh, content, 0); err != nil {
+			d.freeFrom(fresh, 0)
+			return err
+		}
+		if err := d.freeFrom(existing.in, 0); err != nil {
+			return err
+		}
+		existing.in.Block = fresh.Block
+		existing.in.Blocks = fresh.Blocks
+		existing.in.Size = fresh.Size
+		existing.in.Mtime = nowNano()
+		if chmod {
+			existing.in.Mode = typeReg | uint32(mode.Perm())
+		}
+		return d.save(existing)
+	}
+
+	file, err := d.newNode(typeReg|uint32(mode.Perm()), 1)
+	if err != nil {
+		return err
+	}
+	if err := d.writeData(file.in, content, 0); err != nil {
+		d.free(file)
+		return err
+	}
+	if err := d.addEntry(parent.in, dirent{ino: file.ino, name: name, typ: direntFile}); err != nil {
+		d.free(file)
+		return err
+	}
+	if err := d.save(file); err != nil {
+		return err
+	}
+
+	parent.in.Mtime = nowNano()
+	return d.save(parent)
+}
+
+// WriteFile escribe contenido en un archivo (lo crea si no existe); un
+// archivo existente conserva sus permisos
+func (d *DiskFS) WriteFile(path string, content []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.createFile(path, content, 0644, false)
+}
+
+// ReadFile lee el contenido de un archivo
+func (d *DiskFS) ReadFile(path string) ([]byte, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	parent, name, err := d.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+
+	n, exists, err := d.child(parent, name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists || name == "" {
+		return nil, errors.New("archivo no encontrado: " + path)
+	}
+	if n.in.isDir() {
+		return nil, errors.New("no es un archivo: " + path)
+	}
+
+	data := make([]byte, n.in.Size)
+	_, err = d.readData(n.in, data, 0)
+	return data, err
+}
+
+// ListDir lista el contenido de un directorio
+func (d *DiskFS) ListDir(path string) ([]FileInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	parts, err := d.policy.split(path)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := d.walkParts(path, parts)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := d.readDir(dir.in)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]FileInfo, 0, len(entries))
+	for _, e := range entries {
+		if e.name == "." || e.name == ".." {
+			continue
+		}
+		n, err := d.node(e.ino)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, n.info(e.name))
+	}
+	return files, nil
+}
+
+// Remove elimina un archivo o directorio vacío
+func (d *DiskFS) Remove(path string) error {
+	return d.remove(path, false)
+}
+
+// RemoveAll elimina un archivo o directorio y todo su contenido
+func (d *DiskFS) RemoveAll(path string) error {
+	return d.remove(path, true)
+}
+
+func (d *DiskFS) remove(path string, all bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	parent, name, err := d.resolve(path)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return errors.New("no se puede eliminar la raíz")
+	}
+
+	n, exists, err := d.child(parent, name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("no existe: " + path)
+	}
+
+	if n.in.isDir() && !all {
+		entries, err := d.readDir(n.in)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 2 {
+			return errors.New("directorio no vacío: " + path)
+		}
+	}
+
+	if err := d.setEntry(parent.in, name, 0); err != nil {
+		return err
+	}
+	if err := d.release(n, parent); err != nil {
+		return err
+	}
+	parent.in.Mtime = nowNano()
+	return d.save(parent)
+}
+
+// release descuenta el enlace de parent hacia n, que ya se quitó del
+// directorio. Los directorios liberan todo su subárbol; un archivo sólo se
+// libera cuando pierde su último enlace. El llamador guarda parent.
+func (d *DiskFS) release(n, parent diskNode) error {
+	if n.in.isDir() {
+		parent.in.Nlink--
+
+		entries, err := d.readDir(n.in)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if e.name == "." || e.name == ".." {
+				continue
+			}
+			child, err := d.node(e.ino)
+			if err != nil {
+				return err
+			}
+			if err := d.release(child, n); err != nil {
+				return err
+			}
+		}
+		return d.free(n)
+	}
+
+	n.in.Nlink--
+	if n.in.Nlink == 0 {
+		return d.free(n)
+	}
+	return d.save(n)
+}
+
+// Exists verifica si una ruta existe
+func (d *DiskFS) Exists(path string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	_, _, err := d.lookup(path)
+	return err == nil
+}
+
+// Stat obtiene información de un archivo/directorio
+func (d *DiskFS) Stat(path string) (FileInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	n, name, err := d.lookup(path)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return n.info(name), nil
+}
+
+// Walk recorre el árbol de archivos
+func (d *DiskFS) Walk(path string, walkFn func(path string, info FileInfo) error) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	n, name, err := d.lookup(path)
+	if err != nil {
+		return err
+	}
+	return d.walkRecursive(path, name, n, walkFn)
+}
+
+func (d *DiskFS) walkRecursive(path, name string, n diskNode, walkFn func(string, FileInfo) error) error {
+	if err := walkFn(path, n.info(name)); err != nil {
+		return err
+	}
+	if !n.in.isDir() {
+		return nil
+	}
+
+	entries, err := d.readDir(n.in)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.name == "." || e.name == ".." {
+			continue
+		}
+		child, err := d.node(e.ino)
+		if err != nil {
+			return err
+		}
+		if err := d.walkRecursive(filepath.Join(path, e.name), e.name, child, walkFn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AppendFile añade contenido al final de un archivo, o lo crea
+func (d *DiskFS) AppendFile(path string, content []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	parent, name, err := d.resolve(path)
+	if err != nil {
+		return err
+	}
+
+	n, exists, err := d.child(parent, name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return d.createFile(path, content, 0644, false)
+	}
+	if n.in.isDir() {
+		return errors.New("no es un archivo: " + path)
+	}
+
+	size := int64(n.in.Size)
+	if err := d.writeData(n.in, content, size); err != nil {
+		// Soltar los bloques que alcanzó a reservar y limpiar la cola del
+		// último: el archivo queda como estaba
+		n.in.Size = uint64(size) + uint64(len(content))
+		d.truncate(n.in, size)
+		d.save(n)
+		return err
+	}
+	n.in.Mtime = nowNano()
+	return d.save(n)
+}
+
+// Rename mueve o renombra un archivo o directorio, reemplazando el destino
+// si existe
+func (d *DiskFS) Rename(oldPath, newPath string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	oldParent, oldName, err := d.resolve(oldPath)
+	if err != nil {
+		return err
+	}
+	if oldName == "" {
+		return errors.New("no se puede mover la raíz")
+	}
+
+	n, exists, err := d.child(oldParent, oldName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("origen no existe: " + oldPath)
+	}
+
+	newParent, newName, err := d.resolve(newPath)
+	if err != nil {
+		return err
+	}
+	if newName == "" {
+		return errors.New("destino ya existe: " + newPath)
+	}
+
+	if newParent.ino == oldParent.ino {
+		// Un solo inodo en memoria, para no pisar un cambio con otro
+		newParent = oldParent
+		if oldName == newName {
+			return nil
+		}
+	}
+
+	// Un directorio no puede quedar dentro de sí mismo; se sube por ".."
+	// desde el destino hasta la raíz
+	if n.in.isDir() {
+		for dir := newParent; ; {
+			if dir.ino == n.ino {
+				return errors.New("no se puede mover un directorio dentro de sí mismo: " + oldPath)
+			}
+			if dir.ino == diskRootIno {
+				break
+			}
+			up, _, err := d.child(dir, "..")
+			if err != nil {
+				return err
+			}
+			dir = up
+		}
+	}
+
+	target, exists, err := d.child(newParent, newName)
+	if err != nil {
+		return err
+	}
+	if exists {
+		if target.ino == n.ino {
+			// Dos enlaces al mismo archivo: rename(2) no hace nada
+			return nil
+		}
+		if err := d.replaceable(n, target, newPath); err != nil {
+			return err
+		}
+	}
+
+	if err := d.setEntry(oldParent.in, oldName, 0); err != nil {
+		return err
+	}
+	if exists {
+		// La entrada del destino pasa a apuntar al nodo movido: el
+		// reemplazo es una sola escritura del bloque del directorio
+		if err := d.setEntry(newParent.in, newName, n.ino); err != nil {
+			return err
+		}
+		if err := d.release(target, newParent); err != nil {
+			return err
+		}
+	} else {
+		typ := uint8(direntFile)
+		if n.in.isDir() {
+			typ = direntDir
+		}
+		if err := d.addEntry(newParent.in, dirent{ino: n.ino, name: newName, typ: typ}); err != nil {
+			return err
+		}
+	}
+
+	now := nowNano()
+	if n.in.isDir() && newParent.ino != oldParent.ino {
+		if err := d.setEntry(n.in, "..", newParent.ino); err != nil {
+			return err
+		}
+		oldParent.in.Nlink--
+		newParent.in.Nlink++
+	}
+	oldParent.in.Mtime = now
+	newParent.in.Mtime = now
+
+	if err := d.save(oldParent); err != nil {
+		return err
+	}
+	return d.save(newParent)
+}
+
+// replaceable verifica que Rename pueda reemplazar target con n, con las
+// mismas reglas que FileSystem
+func (d *DiskFS) replaceable(n, target diskNode, path string) error {
+	if !n.in.isDir() && target.in.isDir() {
+		return errors.New("el destino es un directorio: " + path)
+	}
+	if n.in.isDir() && !target.in.isDir() {
+		return errors.New("el destino no es un directorio: " + path)
+	}
+	if target.in.isDir() {
+		entries, err := d.readDir(target.in)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 2 {
+			return errors.New("directorio no vacío: " + path)
+		}
+	}
+	return nil
+}
+
+// Link crea un enlace duro: newPath pasa a ser otro nombre del mismo
+// archivo que oldPath
+func (d *DiskFS) Link(oldPath, newPath string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	n, _, err := d.lookup(oldPath)
+	if err != nil {
+		return err
+	}
+	if n.in.isDir() {
+		return errors.New("no se permiten enlaces duros a directorios: " + oldPath)
+	}
+
+	parent, name, err := d.resolve(newPath)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return errors.New("destino ya existe: " + newPath)
+	}
+	if _, exists, err := d.child(parent, name); err != nil {
+		return err
+	} else if exists {
+		return errors.New("destino ya existe: " + newPath)
+	}
+
+	if err := d.addEntry(parent.in, dirent{ino: n.ino, name: name, typ: direntFile}); err != nil {
+		return err
+	}
+	n.in.Nlink++
+	if err := d.save(n); err != nil {
+		return err
+	}
+	parent.in.Mtime = nowNano()
+	return d.save(parent)
+}
+
+// Truncate cambia el tamaño de un archivo. Al crecer no se reservan
+// bloques: la parte nueva es un hueco que se lee como ceros.
+func (d *DiskFS) Truncate(path string, size int64) error {
+	if size < 0 {
+		return errors.New("tamaño negativo")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	n, _, err := d.lookup(path)
+	if err != nil {
+		return err
+	}
+	if n.in.isDir() {
+		return errors.New("no es un archivo: " + path)
+	}
+
+	if err := d.truncate(n.in, size); err != nil {
+		return err
+	}
+	n.in.Mtime = nowNano()
+	return d.save(n)
+}
+
+// SetModTime cambia la fecha de modificación de un archivo o directorio
+func (d *DiskFS) SetModTime(path string, modTime time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	n, _, err := d.lookup(path)
+	if err != nil {
+		return err
+	}
+	n.in.Mtime = modTime.UnixNano()
+	return d.save(n)
+}
+
+// Chmod cambia los permisos de un archivo o directorio
+func (d *DiskFS) Chmod(path string, mode os.FileMode) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	n, _, err := d.lookup(path)
+	if err != nil {
+		return err
+	}
+	n.in.Mode = n.in.Mode&^0777 | uint32(mode.Perm())
+	return d.save(n)
+}
+
+// Chown cambia el dueño y el grupo; un valor negativo no se cambia
+func (d *DiskFS) Chown(path string, uid, gid int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	n, _, err := d.lookup(path)
+	if err != nil {
+		return err
+	}
+	if uid >= 0 {
+		n.in.Uid = uint32(uid)
+	}
+	if gid >= 0 {
+		n.in.Gid = uint32(gid)
+	}
+	return d.save(n)
+}
+
+// Size calcula el tamaño total de un directorio o archivo
+func (d *DiskFS) Size(path string) (int64, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	n, _, err := d.lookup(path)
+	if err != nil {
+		return 0, err
+	}
+	return d.sizeRecursive(n)
+}
+
+func (d *DiskFS) sizeRecursive(n diskNode) (int64, error) {
+	if !n.in.isDir() {
+		return int64(n.in.Size), nil
+	}
+
+	entries, err := d.readDir(n.in)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, e := range entries {
+		if e.name == "." || e.name == ".." {
+			continue
+		}
+		child, err := d.node(e.ino)
+		if err != nil {
+			return 0, err
+		}
+		size, err := d.sizeRecursive(child)
+		if err != nil {
+			return 0, err
+		}
+		total += size
+	}
+	return total, nil
+}
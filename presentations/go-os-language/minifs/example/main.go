@@ -3,267 +3,130 @@ package main
 import (
 	"fmt"
 	"log"
+	"log/slog"
+	"os"
 	"strings"
-	"time"
 
 	"github.com/hectorip/minifs"
+	"github.com/hectorip/minifs/shell"
 )
 
+// session es una sesión de mfsh guionizada que recorre las operaciones
+// principales del sistema de archivos
+const session = `
+# 1. Crear estructura de directorios
+mkdir -p /home/user/documents /home/user/downloads /home/user/projects/golang
+mkdir -p /home/user/projects/python /var/log /var/cache /etc/config /tmp
+
+# 2. Crear archivos con contenido
+echo "Este es un documento de ejemplo" > /home/user/documents/readme.txt
+echo "# Notas" > /home/user/documents/notas.md
+echo "- Item 1" >> /home/user/documents/notas.md
+echo "- Item 2" >> /home/user/documents/notas.md
+echo "package main" > /home/user/projects/golang/main.go
+echo 'func main() { println("Hello, World!") }' >> /home/user/projects/golang/main.go
+echo "print('Hello, World!')" > /home/user/projects/python/app.py
+echo server.port=8080 > /etc/config/app.conf
+echo "export PATH=$PATH:/usr/local/bin" > /home/user/.bashrc
+echo "2024-01-01 10:00:00 Sistema iniciado" > /var/log/system.log
+
+# 3. Leer un archivo navegando con rutas relativas
+cd /home/user/projects
+cat golang/main.go
+
+# 4. Añadir entradas al log
+echo "2024-01-01 10:05:00 Usuario conectado" >> /var/log/system.log
+echo "2024-01-01 10:10:00 Proceso completado" >> /var/log/system.log
+cat /var/log/system.log
+
+# 5. Listar contenido de directorios
+ls -l /home/user /var
+
+# 6. Información de archivos
+stat /home/user/documents/readme.txt /var/log/system.log
+
+# 7. Tamaño de directorios
+du /home/user
+
+# 8. Renombrar y copiar
+mv /home/user/documents/readme.txt /home/user/documents/README.md
+mv python python3
+cp -r golang /home/user/documents
+
+# 9. Eliminar archivos y directorios
+rm /home/user/.bashrc
+rm -r /tmp /var/cache
+
+# 10. Buscar y mostrar el árbol completo
+find / -name *.md
+tree /
+`
+
 func main() {
-	fmt.Println("=== Mini Sistema de Archivos - Ejemplo de Uso ===\n")
+	fmt.Print("=== Mini Sistema de Archivos - Sesión de mfsh ===\n\n")
 
-	// Crear sistema de archivos
 	fs := minifs.NewFileSystem()
+	sh := shell.New(fs, os.Stdout)
 
-	// 1. Crear estructura de directorios
-	fmt.Println("1. Creando estructura de directorios...")
-	dirs := []string{
-		"/home/user/documents",
-		"/home/user/downloads",
-		"/home/user/projects/golang",
-		"/home/user/projects/python",
-		"/var/log",
-		"/var/cache",
-		"/etc/config",
-		"/tmp",
-	}
-
-	for _, dir := range dirs {
-		if err := fs.MkdirAll(dir, 0755); err != nil {
-			log.Printf("Error creando %s: %v", dir, err)
-		} else {
-			fmt.Printf("   ✓ Creado: %s\n", dir)
-		}
-	}
-
-	// 2. Crear archivos con contenido
-	fmt.Println("\n2. Creando archivos...")
-	files := map[string]string{
-		"/home/user/documents/readme.txt":      "Este es un documento de ejemplo",
-		"/home/user/documents/notas.md":        "# Notas\n\n- Item 1\n- Item 2\n- Item 3",
-		"/home/user/projects/golang/main.go":   "package main\n\nfunc main() {\n    println(\"Hello, World!\")\n}",
-		"/home/user/projects/python/app.py":    "#!/usr/bin/env python3\n\nprint('Hello, World!')",
-		"/etc/config/app.conf":                 "server.port=8080\nserver.host=localhost",
-		"/var/log/system.log":                  "2024-01-01 10:00:00 Sistema iniciado\n",
-		"/home/user/.bashrc":                   "export PATH=$PATH:/usr/local/bin",
-		"/home/user/.gitconfig":                "[user]\n    name = Usuario\n    email = usuario@example.com",
+	if err := sh.Run(strings.NewReader(session), true); err != nil {
+		log.Fatal(err)
 	}
 
-	for path, content := range files {
-		if err := fs.WriteFile(path, []byte(content)); err != nil {
-			log.Printf("Error creando %s: %v", path, err)
-		} else {
-			fmt.Printf("   ✓ Archivo creado: %s (%d bytes)\n", path, len(content))
-		}
-	}
-
-	// 3. Leer y mostrar contenido de un archivo
-	fmt.Println("\n3. Leyendo archivo /home/user/projects/golang/main.go:")
-	if content, err := fs.ReadFile("/home/user/projects/golang/main.go"); err != nil {
-		log.Printf("Error leyendo archivo: %v", err)
-	} else {
-		fmt.Println("   Contenido:")
-		fmt.Println("   " + strings.ReplaceAll(string(content), "\n", "\n   "))
-	}
-
-	// 4. Añadir contenido a un archivo (log)
-	fmt.Println("\n4. Añadiendo entradas al log...")
-	logEntries := []string{
-		"2024-01-01 10:05:00 Usuario conectado",
-		"2024-01-01 10:10:00 Proceso completado",
-		"2024-01-01 10:15:00 Sistema actualizado",
-	}
-
-	for _, entry := range logEntries {
-		if err := fs.AppendFile("/var/log/system.log", []byte(entry+"\n")); err != nil {
-			log.Printf("Error añadiendo al log: %v", err)
-		} else {
-			fmt.Printf("   ✓ Entrada añadida: %s\n", entry)
-		}
-	}
-
-	// 5. Listar contenido de directorios
-	fmt.Println("\n5. Listando contenido de directorios:")
-	dirsToList := []string{"/home/user", "/home/user/projects", "/var"}
-
-	for _, dir := range dirsToList {
-		fmt.Printf("\n   Contenido de %s:\n", dir)
-		if files, err := fs.ListDir(dir); err != nil {
-			log.Printf("   Error listando %s: %v", dir, err)
-		} else {
-			for _, file := range files {
-				if file.IsDir {
-					fmt.Printf("   📁 %-20s (dir)\n", file.Name+"/")
-				} else {
-					fmt.Printf("   📄 %-20s (%d bytes)\n", file.Name, file.Size)
-				}
-			}
-		}
-	}
-
-	// 6. Obtener información de archivos
-	fmt.Println("\n6. Información de archivos:")
-	pathsToStat := []string{
-		"/home/user/documents/readme.txt",
-		"/home/user/projects",
-		"/var/log/system.log",
-	}
-
-	for _, path := range pathsToStat {
-		if info, err := fs.Stat(path); err != nil {
-			log.Printf("Error obteniendo info de %s: %v", path, err)
-		} else {
-			fmt.Printf("\n   %s:\n", path)
-			fmt.Printf("   - Tipo: %s\n", map[bool]string{true: "Directorio", false: "Archivo"}[info.IsDir])
-			fmt.Printf("   - Tamaño: %d bytes\n", info.Size)
-			fmt.Printf("   - Permisos: %o\n", info.Mode)
-			fmt.Printf("   - Modificado: %s\n", info.ModTime.Format(time.RFC3339))
-		}
-	}
-
-	// 7. Calcular tamaño de directorios
-	fmt.Println("\n7. Tamaño de directorios:")
-	dirsToSize := []string{"/home/user/documents", "/home/user/projects", "/var", "/"}
-
-	for _, dir := range dirsToSize {
-		if size, err := fs.Size(dir); err != nil {
-			log.Printf("Error calculando tamaño de %s: %v", dir, err)
-		} else {
-			fmt.Printf("   %s: %d bytes\n", dir, size)
-		}
-	}
-
-	// 8. Renombrar archivos
-	fmt.Println("\n8. Renombrando archivos...")
-	renameOps := []struct {
-		old, new string
-	}{
-		{"/home/user/documents/readme.txt", "/home/user/documents/README.md"},
-		{"/home/user/projects/python", "/home/user/projects/python3"},
-	}
-
-	for _, op := range renameOps {
-		if err := fs.Rename(op.old, op.new); err != nil {
-			log.Printf("Error renombrando %s a %s: %v", op.old, op.new, err)
-		} else {
-			fmt.Printf("   ✓ Renombrado: %s → %s\n", op.old, op.new)
-		}
-	}
-
-	// 9. Eliminar archivos y directorios
-	fmt.Println("\n9. Eliminando archivos...")
-	toRemove := []string{
-		"/tmp",
-		"/home/user/.bashrc",
-		"/var/cache",
-	}
-
-	for _, path := range toRemove {
-		if err := fs.Remove(path); err != nil {
-			// Intentar con RemoveAll si es un directorio no vacío
-			if err := fs.RemoveAll(path); err != nil {
-				log.Printf("Error eliminando %s: %v", path, err)
-			} else {
-				fmt.Printf("   ✓ Eliminado (recursivo): %s\n", path)
-			}
-		} else {
-			fmt.Printf("   ✓ Eliminado: %s\n", path)
-		}
-	}
-
-	// 10. Recorrer todo el árbol de archivos
-	fmt.Println("\n10. Árbol completo del sistema de archivos:")
-	fmt.Println()
-	
-	var printTree func(string, int)
-	printTree = func(path string, level int) {
-		fs.Walk(path, func(p string, info minifs.FileInfo) error {
-			// Calcular nivel de indentación
-			depth := strings.Count(p, "/") - strings.Count(path, "/")
-			if depth != level {
-				return nil
-			}
-			
-			indent := strings.Repeat("  ", depth)
-			
-			// Obtener solo el nombre del archivo/directorio
-			name := info.Name
-			if name == "/" {
-				name = "/"
-			}
-			
-			// Imprimir con formato de árbol
-			if info.IsDir {
-				fmt.Printf("%s📁 %s/\n", indent, name)
-				// Recursivamente imprimir subdirectorios
-				if p != "/" {
-					printTree(p, level+1)
-				} else {
-					printTree(p, 1)
-				}
-			} else {
-				fmt.Printf("%s📄 %s (%d bytes)\n", indent, name, info.Size)
-			}
-			
-			return nil
-		})
-	}
-	
-	printTree("/", 0)
-
-	// 11. Estadísticas finales
-	fmt.Println("\n11. Estadísticas finales:")
-	
-	var totalFiles, totalDirs int
-	var totalSize int64
-	
-	fs.Walk("/", func(path string, info minifs.FileInfo) error {
-		if info.IsDir {
-			totalDirs++
-		} else {
-			totalFiles++
-			totalSize += info.Size
-		}
-		return nil
-	})
-	
-	fmt.Printf("   - Total de directorios: %d\n", totalDirs)
-	fmt.Printf("   - Total de archivos: %d\n", totalFiles)
-	fmt.Printf("   - Tamaño total: %d bytes\n", totalSize)
-	fmt.Printf("   - Tamaño promedio por archivo: %.2f bytes\n", float64(totalSize)/float64(totalFiles))
-
-	// 12. Prueba de concurrencia
-	fmt.Println("\n12. Prueba de operaciones concurrentes...")
+	// 11. Prueba de concurrencia: esto no se puede expresar en el guion
+	fmt.Println("\n11. Prueba de operaciones concurrentes...")
 	done := make(chan bool, 20)
-	
-	// Crear archivos concurrentemente
+
 	for i := 0; i < 10; i++ {
 		go func(n int) {
 			path := fmt.Sprintf("/home/user/downloads/file%d.txt", n)
-			content := fmt.Sprintf("Archivo concurrente #%d", n)
-			fs.WriteFile(path, []byte(content))
+			fs.WriteFile(path, []byte(fmt.Sprintf("Archivo concurrente #%d", n)))
 			done <- true
 		}(i)
 	}
-	
-	// Leer archivos concurrentemente
+
 	for i := 0; i < 10; i++ {
 		go func(n int) {
-			path := fmt.Sprintf("/home/user/downloads/file%d.txt", n)
-			fs.ReadFile(path)
+			fs.ReadFile(fmt.Sprintf("/home/user/downloads/file%d.txt", n))
 			done <- true
 		}(i)
 	}
-	
-	// Esperar a que terminen todas las operaciones
+
 	for i := 0; i < 20; i++ {
 		<-done
 	}
-	
-	// Verificar archivos creados
+
 	if files, err := fs.ListDir("/home/user/downloads"); err == nil {
 		fmt.Printf("   ✓ Archivos creados concurrentemente: %d\n", len(files))
 	}
 
+	// 12. Auditoría y métricas: cada operación pasa por los hooks
+	fmt.Println("\n12. Auditoría y métricas de un sistema instrumentado...")
+	metrics := minifs.NewMetrics()
+	audit := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		// Sin la hora para que la salida sea estable
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "duration" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	ifs := minifs.Instrument(fs, minifs.AuditLogger(audit), metrics.Observe)
+
+	ifs.WriteFile("/var/log/audit.log", []byte("inicio de sesión\n"))
+	ifs.AppendFile("/var/log/audit.log", []byte("cambio de permisos\n"))
+	ifs.ReadFile("/var/log/audit.log")
+	ifs.Rename("/var/log/audit.log", "/var/log/audit.log.1")
+	ifs.ReadFile("/var/log/audit.log")
+
+	// Los buckets del histograma dependen de la máquina; mostramos el resto
+	var prom strings.Builder
+	metrics.WritePrometheus(&prom)
+	for _, line := range strings.Split(prom.String(), "\n") {
+		if strings.HasPrefix(line, "minifs_") && !strings.Contains(line, "duration") {
+			fmt.Println("   " + line)
+		}
+	}
+
 	fmt.Println("\n=== Ejemplo completado exitosamente ===")
-}
\ No newline at end of file
+}
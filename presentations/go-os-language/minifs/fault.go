@@ -0,0 +1,327 @@
+package minifs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+// Rule describe cuándo y cómo falla una operación de FaultFS. Los campos
+// vacíos no filtran: una Rule{Err: syscall.EIO} hace fallar todo.
+type Rule struct {
+	Path string // patrón de path.Match sobre la ruta completa ("/logs/*")
+	Op   string // nombre del método ("CreateFile", "Rename", ...)
+
+	// Nth hace que la regla solo se dispare en la n-ésima llamada que
+	// coincida (empezando en 1). Con Probability la llamada se dispara al
+	// azar usando el generador con semilla de FaultFS.
+	Nth         int
+	Probability float64
+
+	// ShortWrite solo aplica a CreateFile, WriteFile y AppendFile: se
+	// escribe la mitad del contenido y se devuelve io.ErrShortWrite
+	ShortWrite bool
+
+	Err     error         // error a devolver, p.ej. syscall.ENOSPC
+	Latency time.Duration // espera antes de ejecutar la operación
+}
+
+// Fault registra una regla que se disparó
+type Fault struct {
+	Rule int // índice de la regla en el orden en que se agregó
+	Op   string
+	Path string
+	Call int // número de llamada que coincidió con la regla
+	Err  error
+}
+
+func (f Fault) String() string {
+	if f.Err == nil {
+		return fmt.Sprintf("regla %d: %s %s (llamada %d): latencia", f.Rule, f.Op, f.Path, f.Call)
+	}
+	return fmt.Sprintf("regla %d: %s %s (llamada %d): %v", f.Rule, f.Op, f.Path, f.Call, f.Err)
+}
+
+// FaultFS envuelve un Backend e inyecta errores, escrituras cortas y
+// latencia según sus reglas. Sirve para probar el manejo de errores del
+// código que usa minifs, que de otro modo solo falla con rutas inexistentes.
+//
+// Los errores se devuelven como *os.PathError, así que errors.Is(err,
+// syscall.ENOSPC) y errors.Is(err, fs.ErrPermission) funcionan igual que con
+// el sistema operativo.
+type FaultFS struct {
+	backend Backend
+
+	mu    sync.Mutex
+	rules []Rule
+	calls []int
+	rng   *rand.Rand
+	fired []Fault
+}
+
+var _ Backend = (*FaultFS)(nil)
+
+// NewFaultFS crea un FaultFS sin reglas. La semilla hace reproducibles las
+// reglas con Probability.
+func NewFaultFS(backend Backend, seed int64) *FaultFS {
+	return &FaultFS{
+		backend: backend,
+		rng:     rand.New(rand.NewSource(seed)),
+	}
+}
+
+// AddRule agrega una regla. Cuando varias coinciden se aplican todas las
+// latencias y gana el error de la primera que se dispare.
+func (f *FaultFS) AddRule(r Rule) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.rules = append(f.rules, r)
+	f.calls = append(f.calls, 0)
+}
+
+// Fired devuelve las fallas inyectadas hasta ahora, en orden
+func (f *FaultFS) Fired() []Fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]Fault(nil), f.fired...)
+}
+
+// Reset borra las reglas, los contadores y el registro de fallas
+func (f *FaultFS) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.rules, f.calls, f.fired = nil, nil, nil
+}
+
+// inject evalúa las reglas para una llamada. Devuelve si la escritura debe
+// quedar corta y el error a inyectar.
+func (f *FaultFS) inject(op string, paths ...string) (bool, error) {
+	f.mu.Lock()
+
+	var delay time.Duration
+	var err error
+	short := false
+	writes := op == "CreateFile" || op == "WriteFile" || op == "AppendFile"
+
+	for i, r := range f.rules {
+		if r.Op != "" && r.Op != op {
+			continue
+		}
+		if r.ShortWrite && !writes {
+			continue
+		}
+		p, ok := matchAny(r.Path, paths)
+		if !ok {
+			continue
+		}
+
+		f.calls[i]++
+		if r.Nth > 0 && f.calls[i] != r.Nth {
+			continue
+		}
+		if r.Probability > 0 && f.rng.Float64() >= r.Probability {
+			continue
+		}
+
+		fault := Fault{Rule: i, Op: op, Path: p, Call: f.calls[i]}
+		delay += r.Latency
+		if err == nil && !short {
+			switch {
+			case r.ShortWrite:
+				short = true
+				fault.Err = &os.PathError{Op: op, Path: p, Err: io.ErrShortWrite}
+			case r.Err != nil:
+				err = &os.PathError{Op: op, Path: p, Err: r.Err}
+				fault.Err = err
+			}
+		}
+		f.fired = append(f.fired, fault)
+	}
+
+	f.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	return short, err
+}
+
+func matchAny(pattern string, paths []string) (string, bool) {
+	for _, p := range paths {
+		if pattern == "" {
+			return p, true
+		}
+		if ok, _ := path.Match(pattern, p); ok {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// Exists no devuelve errores: con una falla inyectada reporta false
+func (f *FaultFS) Exists(path string) bool {
+	if _, err := f.inject("Exists", path); err != nil {
+		return false
+	}
+	return f.backend.Exists(path)
+}
+
+func (f *FaultFS) Stat(path string) (FileInfo, error) {
+	if _, err := f.inject("Stat", path); err != nil {
+		return FileInfo{}, err
+	}
+	return f.backend.Stat(path)
+}
+
+func (f *FaultFS) ListDir(path string) ([]FileInfo, error) {
+	if _, err := f.inject("ListDir", path); err != nil {
+		return nil, err
+	}
+	return f.backend.ListDir(path)
+}
+
+func (f *FaultFS) ReadFile(path string) ([]byte, error) {
+	if _, err := f.inject("ReadFile", path); err != nil {
+		return nil, err
+	}
+	return f.backend.ReadFile(path)
+}
+
+func (f *FaultFS) CreateDir(path string, mode os.FileMode) error {
+	if _, err := f.inject("CreateDir", path); err != nil {
+		return err
+	}
+	return f.backend.CreateDir(path, mode)
+}
+
+func (f *FaultFS) MkdirAll(path string, mode os.FileMode) error {
+	if _, err := f.inject("MkdirAll", path); err != nil {
+		return err
+	}
+	return f.backend.MkdirAll(path, mode)
+}
+
+// CreateFile con una escritura corta deja en el archivo solo la primera
+// mitad del contenido, como un disco que se llena a medio camino
+func (f *FaultFS) CreateFile(path string, content []byte, mode os.FileMode) error {
+	short, err := f.inject("CreateFile", path)
+	if err != nil {
+		return err
+	}
+	if short {
+		return f.shortWrite("CreateFile", path, f.backend.CreateFile(path, content[:len(content)/2], mode))
+	}
+	return f.backend.CreateFile(path, content, mode)
+}
+
+func (f *FaultFS) WriteFile(path string, content []byte) error {
+	short, err := f.inject("WriteFile", path)
+	if err != nil {
+		return err
+	}
+	if short {
+		return f.shortWrite("WriteFile", path, f.backend.WriteFile(path, content[:len(content)/2]))
+	}
+	return f.backend.WriteFile(path, content)
+}
+
+func (f *FaultFS) AppendFile(path string, content []byte) error {
+	short, err := f.inject("AppendFile", path)
+	if err != nil {
+		return err
+	}
+	if short {
+		return f.shortWrite("AppendFile", path, f.backend.AppendFile(path, content[:len(content)/2]))
+	}
+	return f.backend.AppendFile(path, content)
+}
+
+// shortWrite devuelve io.ErrShortWrite salvo que la escritura parcial
+// haya fallado por sí misma
+func (f *FaultFS) shortWrite(op, path string, err error) error {
+	if err != nil {
+		return err
+	}
+	return &os.PathError{Op: op, Path: path, Err: io.ErrShortWrite}
+}
+
+func (f *FaultFS) Truncate(path string, size int64) error {
+	if _, err := f.inject("Truncate", path); err != nil {
+		return err
+	}
+	return f.backend.Truncate(path, size)
+}
+
+func (f *FaultFS) Remove(path string) error {
+	if _, err := f.inject("Remove", path); err != nil {
+		return err
+	}
+	return f.backend.Remove(path)
+}
+
+func (f *FaultFS) RemoveAll(path string) error {
+	if _, err := f.inject("RemoveAll", path); err != nil {
+		return err
+	}
+	return f.backend.RemoveAll(path)
+}
+
+// Rename coincide con una regla si cualquiera de las dos rutas coincide
+func (f *FaultFS) Rename(oldPath, newPath string) error {
+	if _, err := f.inject("Rename", oldPath, newPath); err != nil {
+		return err
+	}
+	return f.backend.Rename(oldPath, newPath)
+}
+
+// Walk solo evalúa las reglas para la ruta inicial
+func (f *FaultFS) Walk(path string, walkFn func(path string, info FileInfo) error) error {
+	if _, err := f.inject("Walk", path); err != nil {
+		return err
+	}
+	return f.backend.Walk(path, walkFn)
+}
+
+func (f *FaultFS) Size(path string) (int64, error) {
+	if _, err := f.inject("Size", path); err != nil {
+		return 0, err
+	}
+	return f.backend.Size(path)
+}
+
+func (f *FaultFS) SetModTime(path string, modTime time.Time) error {
+	if _, err := f.inject("SetModTime", path); err != nil {
+		return err
+	}
+	return f.backend.SetModTime(path, modTime)
+}
+
+// Chmod solo funciona si el Backend de abajo sabe cambiar permisos
+func (f *FaultFS) Chmod(path string, mode os.FileMode) error {
+	if _, err := f.inject("Chmod", path); err != nil {
+		return err
+	}
+	if c, ok := f.backend.(chmoder); ok {
+		return c.Chmod(path, mode)
+	}
+	return errors.New("el sistema con fallas no permite cambiar permisos: " + path)
+}
+
+// Chown solo funciona si el Backend de abajo sabe cambiar el dueño
+func (f *FaultFS) Chown(path string, uid, gid int) error {
+	if _, err := f.inject("Chown", path); err != nil {
+		return err
+	}
+	if c, ok := f.backend.(chowner); ok {
+		return c.Chown(path, uid, gid)
+	}
+	return errors.New("el sistema con fallas no permite cambiar el dueño: " + path)
+}
@@ -0,0 +1,146 @@
+package minifs
+
+import (
+	"errors"
+	"io"
+	iofs "io/fs"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestFaultFS(t *testing.T) {
+	t.Run("PathAndOp", func(t *testing.T) {
+		ffs := NewFaultFS(NewFileSystem(), 1)
+		ffs.MkdirAll("/logs", 0755)
+		ffs.AddRule(Rule{Path: "/logs/*", Op: "CreateFile", Err: syscall.ENOSPC})
+
+		err := ffs.CreateFile("/logs/app.log", []byte("x"), 0644)
+		if !errors.Is(err, syscall.ENOSPC) {
+			t.Fatalf("Se esperaba ENOSPC, got %v", err)
+		}
+		var pe *iofs.PathError
+		if !errors.As(err, &pe) || pe.Path != "/logs/app.log" || pe.Op != "CreateFile" {
+			t.Errorf("El error no es un PathError con la ruta: %#v", err)
+		}
+
+		// Otras rutas y otras operaciones no fallan
+		if err := ffs.CreateFile("/otro.log", []byte("x"), 0644); err != nil {
+			t.Errorf("Falla fuera del patrón: %v", err)
+		}
+		if err := ffs.WriteFile("/logs/app.log", []byte("x")); err != nil {
+			t.Errorf("Falla en otra operación: %v", err)
+		}
+	})
+
+	t.Run("Nth", func(t *testing.T) {
+		ffs := NewFaultFS(NewFileSystem(), 1)
+		ffs.WriteFile("/a", []byte("a"))
+		ffs.AddRule(Rule{Op: "ReadFile", Nth: 3, Err: syscall.EIO})
+
+		for i := 1; i <= 4; i++ {
+			_, err := ffs.ReadFile("/a")
+			if (i == 3) != errors.Is(err, syscall.EIO) {
+				t.Errorf("Llamada %d: %v", i, err)
+			}
+		}
+
+		fired := ffs.Fired()
+		if len(fired) != 1 || fired[0].Call != 3 || fired[0].Op != "ReadFile" {
+			t.Errorf("Registro incorrecto: %v", fired)
+		}
+	})
+
+	t.Run("ProbabilityIsSeeded", func(t *testing.T) {
+		run := func() []int {
+			ffs := NewFaultFS(NewFileSystem(), 42)
+			ffs.AddRule(Rule{Op: "Stat", Probability: 0.3, Err: syscall.EIO})
+			for i := 0; i < 50; i++ {
+				ffs.Stat("/")
+			}
+			var calls []int
+			for _, f := range ffs.Fired() {
+				calls = append(calls, f.Call)
+			}
+			return calls
+		}
+
+		a, b := run(), run()
+		if len(a) == 0 || len(a) == 50 {
+			t.Fatalf("La probabilidad no se aplicó: %d de 50", len(a))
+		}
+		if len(a) != len(b) {
+			t.Fatalf("Misma semilla, resultados distintos: %v %v", a, b)
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				t.Fatalf("Misma semilla, resultados distintos: %v %v", a, b)
+			}
+		}
+	})
+
+	t.Run("ShortWrite", func(t *testing.T) {
+		fs := NewFileSystem()
+		ffs := NewFaultFS(fs, 1)
+		ffs.AddRule(Rule{ShortWrite: true, Nth: 1})
+
+		err := ffs.WriteFile("/datos", []byte("12345678"))
+		if !errors.Is(err, io.ErrShortWrite) {
+			t.Fatalf("Se esperaba io.ErrShortWrite, got %v", err)
+		}
+		if data, _ := fs.ReadFile("/datos"); string(data) != "1234" {
+			t.Errorf("La escritura corta dejó %q", data)
+		}
+	})
+
+	t.Run("RenameAndPermission", func(t *testing.T) {
+		ffs := NewFaultFS(NewFileSystem(), 1)
+		ffs.MkdirAll("/ro", 0755)
+		ffs.WriteFile("/a.txt", []byte("a"))
+		ffs.AddRule(Rule{Path: "/ro/*", Err: syscall.EACCES})
+
+		err := ffs.Rename("/a.txt", "/ro/a.txt")
+		if !errors.Is(err, iofs.ErrPermission) {
+			t.Errorf("Rename hacia /ro debería dar permiso denegado: %v", err)
+		}
+		if !ffs.Exists("/a.txt") {
+			t.Error("El Rename fallido no debería mover el archivo")
+		}
+	})
+
+	t.Run("Latency", func(t *testing.T) {
+		ffs := NewFaultFS(NewFileSystem(), 1)
+		ffs.AddRule(Rule{Op: "ListDir", Latency: 20 * time.Millisecond})
+
+		start := time.Now()
+		if _, err := ffs.ListDir("/"); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+			t.Errorf("No se aplicó la latencia: %v", elapsed)
+		}
+	})
+
+	t.Run("CopyErrorPath", func(t *testing.T) {
+		src := NewFileSystem()
+		src.MkdirAll("/proj/src", 0755)
+		src.WriteFile("/proj/src/main.go", []byte("package main"))
+		src.WriteFile("/proj/README", []byte("léeme"))
+
+		ffs := NewFaultFS(NewFileSystem(), 1)
+		ffs.AddRule(Rule{Path: "/copia/src/*", Op: "CreateFile", Err: syscall.ENOSPC})
+
+		err := CopyBetween(src, "/proj", ffs, "/copia", CopyOptions{})
+		if !errors.Is(err, syscall.ENOSPC) {
+			t.Errorf("CopyBetween no propagó ENOSPC: %v", err)
+		}
+		if len(ffs.Fired()) != 1 {
+			t.Errorf("Fallas registradas: %v", ffs.Fired())
+		}
+
+		ffs.Reset()
+		if err := CopyBetween(src, "/proj", ffs, "/copia2", CopyOptions{}); err != nil {
+			t.Errorf("Tras Reset no debería fallar: %v", err)
+		}
+	})
+}
@@ -0,0 +1,390 @@
+package minifs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// File es un archivo abierto. Guarda una referencia a su inodo, así que
+// sigue funcionando aunque el archivo se renombre o se mueva.
+type File struct {
+	fs   *FileSystem
+	node *Node
+	name string
+
+	mu     sync.Mutex
+	offset int64
+	closed bool
+
+	// readOnly viene de un montaje de solo lectura
+	readOnly bool
+
+	// track, si no es nil, se llama al empezar cada lectura o escritura
+	// con su nombre y los bytes pedidos, y devuelve la función que se llama
+	// al terminar. Lo pone InstrumentedFS.
+	track func(name string, size int) func(n int, err error)
+}
+
+// Open abre el archivo o directorio en path para lectura y escritura
+func (fs *FileSystem) Open(path string) (*File, error) {
+	if m, rel := fs.mountFor(path); m != nil {
+		o, ok := m.backend.(opener)
+		if !ok {
+			return nil, errors.New("el sistema montado no permite abrir archivos: " + path)
+		}
+		f, err := o.Open(rel)
+		if err != nil {
+			return nil, err
+		}
+		f.name = path
+		f.readOnly = f.readOnly || m.readOnly
+		return f, nil
+	}
+
+	if err := fs.rlock("open", path); err != nil {
+		return nil, err
+	}
+	defer fs.mu.RUnlock()
+
+	node, err := fs.lookup(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.open(node, path), nil
+}
+
+// OpenByID abre un archivo o directorio a partir de su número de inodo,
+// el que se obtiene con info.Sys().(*StatT).Ino
+func (fs *FileSystem) OpenByID(ino uint64) (*File, error) {
+	if err := fs.rlock("open", strconv.FormatUint(ino, 10)); err != nil {
+		return nil, err
+	}
+	defer fs.mu.RUnlock()
+
+	node, exists := fs.inodes[ino]
+	if !exists {
+		return nil, errors.New("inodo no encontrado: " + strconv.FormatUint(ino, 10))
+	}
+
+	return fs.open(node, fs.pathOf(node)), nil
+}
+
+// open crea el File y lo cuenta en el nodo. Se asume que fs.mu está
+// tomado al menos para lectura.
+func (fs *FileSystem) open(node *Node, name string) *File {
+	node.mu.Lock()
+	node.opens++
+	node.mu.Unlock()
+
+	return &File{fs: fs, node: node, name: name}
+}
+
+// pathOf reconstruye la ruta del enlace principal de un nodo subiendo por
+// sus padres. Se asume que fs.mu está tomado.
+func (fs *FileSystem) pathOf(node *Node) string {
+	if node == fs.root {
+		return "/"
+	}
+
+	path := node.name
+	for p := node.parent; p != fs.root; p = p.parent {
+		path = p.name + "/" + path
+	}
+	return "/" + path
+}
+
+// Name devuelve la ruta con la que se abrió el archivo
+func (f *File) Name() string {
+	return f.name
+}
+
+// Stat devuelve la información actual del archivo
+func (f *File) Stat() (FileInfo, error) {
+	if f.isClosed() {
+		return FileInfo{}, os.ErrClosed
+	}
+
+	if err := f.fs.rlock("stat", f.name); err != nil {
+		return FileInfo{}, err
+	}
+	defer f.fs.mu.RUnlock()
+
+	f.node.mu.RLock()
+	defer f.node.mu.RUnlock()
+
+	return f.node.info(filepath.Base(f.name)), nil
+}
+
+func (f *File) isClosed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+// Read lee desde la posición actual y la avanza
+func (f *File) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+
+	n, err := f.readAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+// ReadAt lee len(p) bytes desde off sin mover la posición actual
+func (f *File) ReadAt(p []byte, off int64) (int, error) {
+	if f.isClosed() {
+		return 0, os.ErrClosed
+	}
+	return f.readAt(p, off)
+}
+
+func (f *File) readAt(p []byte, off int64) (n int, err error) {
+	if f.track != nil {
+		done := f.track("Read", len(p))
+		defer func() { done(n, err) }()
+	}
+	if off < 0 {
+		return 0, errors.New("offset negativo")
+	}
+
+	if err := f.fs.rlock("read", f.name); err != nil {
+		return 0, err
+	}
+	defer f.fs.mu.RUnlock()
+
+	unpin, err := f.fs.pin(f.node, false)
+	if err != nil {
+		return 0, err
+	}
+	defer unpin()
+
+	f.node.mu.RLock()
+	defer f.node.mu.RUnlock()
+
+	if f.node.nodeType != FileNode {
+		return 0, errors.New("es un directorio: " + f.name)
+	}
+
+	if off >= f.node.size {
+		return 0, io.EOF
+	}
+
+	n = f.node.data.readAt(p, off, f.node.size)
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Write escribe en la posición actual y la avanza
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+
+	n, err := f.writeAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+// WriteAt escribe p a partir de off, extendiendo el archivo si hace falta
+func (f *File) WriteAt(p []byte, off int64) (int, error) {
+	if f.isClosed() {
+		return 0, os.ErrClosed
+	}
+	return f.writeAt(p, off)
+}
+
+func (f *File) writeAt(p []byte, off int64) (n int, err error) {
+	if f.track != nil {
+		done := f.track("Write", len(p))
+		defer func() { done(n, err) }()
+	}
+	if off < 0 {
+		return 0, errors.New("offset negativo")
+	}
+	if f.readOnly {
+		return 0, &os.PathError{Op: "write", Path: f.name, Err: syscall.EROFS}
+	}
+
+	if err := f.fs.lock("write", f.name); err != nil {
+		return 0, err
+	}
+	defer f.fs.mu.Unlock()
+
+	unpin, err := f.fs.pin(f.node, true)
+	if err != nil {
+		return 0, err
+	}
+	defer unpin()
+
+	f.node.mu.Lock()
+	defer f.node.mu.Unlock()
+
+	if f.node.nodeType != FileNode {
+		return 0, errors.New("es un directorio: " + f.name)
+	}
+
+	// Escribir más allá del final deja un hueco entre el final anterior y off
+	f.node.data.writeAt(p, off)
+	if end := off + int64(len(p)); end > f.node.size {
+		f.node.size = end
+	}
+	f.node.modTime = time.Now()
+
+	return len(p), nil
+}
+
+// Valores extra de whence para Seek, los mismos que SEEK_DATA y SEEK_HOLE
+// en Linux
+const (
+	SeekData = 3 // siguiente offset >= offset con datos
+	SeekHole = 4 // siguiente offset >= offset dentro de un hueco
+)
+
+// errNoData se devuelve cuando SeekData no encuentra datos (ENXIO en Linux)
+var errNoData = errors.New("no hay datos después del offset")
+
+// Truncate cambia el tamaño del archivo sin mover la posición actual
+func (f *File) Truncate(size int64) error {
+	if size < 0 {
+		return errors.New("tamaño negativo")
+	}
+	if f.isClosed() {
+		return os.ErrClosed
+	}
+	if f.readOnly {
+		return &os.PathError{Op: "truncate", Path: f.name, Err: syscall.EROFS}
+	}
+
+	if err := f.fs.lock("truncate", f.name); err != nil {
+		return err
+	}
+	defer f.fs.mu.Unlock()
+
+	unpin, err := f.fs.pin(f.node, true)
+	if err != nil {
+		return err
+	}
+	defer unpin()
+
+	f.node.mu.Lock()
+	defer f.node.mu.Unlock()
+
+	if f.node.nodeType != FileNode {
+		return errors.New("es un directorio: " + f.name)
+	}
+
+	f.node.truncate(size)
+	return nil
+}
+
+// Seek cambia la posición de la siguiente lectura o escritura. Además de
+// io.SeekStart, io.SeekCurrent e io.SeekEnd acepta SeekData y SeekHole
+// para recorrer un archivo disperso sin leer sus huecos.
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+
+	var base int64
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		base = f.offset
+	case io.SeekEnd:
+		if err := f.fs.rlock("seek", f.name); err != nil {
+			return 0, err
+		}
+		f.node.mu.RLock()
+		base = f.node.size
+		f.node.mu.RUnlock()
+		f.fs.mu.RUnlock()
+	case SeekData, SeekHole:
+		pos, err := f.seekSparse(offset, whence)
+		if err != nil {
+			return 0, err
+		}
+		f.offset = pos
+		return pos, nil
+	default:
+		return 0, errors.New("whence inválido")
+	}
+
+	if base+offset < 0 {
+		return 0, errors.New("posición negativa")
+	}
+
+	f.offset = base + offset
+	return f.offset, nil
+}
+
+// Close cierra el archivo; las operaciones posteriores devuelven
+// os.ErrClosed. Si el archivo ya no tenía enlaces, el último Close libera
+// su contenido.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return os.ErrClosed
+	}
+	f.closed = true
+
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	f.node.opens--
+	if f.node.opens == 0 && f.node.nlink == 0 {
+		f.fs.uncache(f.node)
+	}
+	return nil
+}
+
+func (f *File) seekSparse(offset int64, whence int) (int64, error) {
+	if err := f.fs.rlock("seek", f.name); err != nil {
+		return 0, err
+	}
+	defer f.fs.mu.RUnlock()
+
+	unpin, err := f.fs.pin(f.node, false)
+	if err != nil {
+		return 0, err
+	}
+	defer unpin()
+
+	f.node.mu.RLock()
+	defer f.node.mu.RUnlock()
+
+	if offset < 0 || offset >= f.node.size {
+		return 0, errNoData
+	}
+
+	if whence == SeekHole {
+		return f.node.data.seekHole(offset, f.node.size), nil
+	}
+
+	pos := f.node.data.seekData(offset, f.node.size)
+	if pos < 0 {
+		return 0, errNoData
+	}
+	return pos, nil
+}
@@ -0,0 +1,173 @@
+package minifs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+func statT(t *testing.T, fs Volume, path string) *StatT {
+	t.Helper()
+	info, err := fs.Stat(path)
+	if err != nil {
+		t.Fatalf("Error en Stat de %s: %v", path, err)
+	}
+	return info.Sys().(*StatT)
+}
+
+func TestInodes(t *testing.T) {
+	fs := NewFileSystem()
+	fs.MkdirAll("/a/b", 0755)
+	fs.WriteFile("/a/x.txt", []byte("x"))
+
+	if ino := statT(t, fs, "/").Ino; ino != 1 {
+		t.Errorf("La raíz debería ser el inodo 1, got %d", ino)
+	}
+
+	ino := statT(t, fs, "/a/x.txt").Ino
+	fs.WriteFile("/a/x.txt", []byte("sobrescrito"))
+	fs.Rename("/a/x.txt", "/a/b/y.txt")
+	if got := statT(t, fs, "/a/b/y.txt").Ino; got != ino {
+		t.Errorf("El inodo cambió tras sobrescribir y renombrar: %d, want %d", got, ino)
+	}
+
+	// Un directorio tiene dos enlaces más uno por cada subdirectorio
+	if n := statT(t, fs, "/a").Nlink; n != 3 {
+		t.Errorf("Nlink de /a incorrecto: got %d, want 3", n)
+	}
+	fs.Rename("/a/b", "/b")
+	if n := statT(t, fs, "/a").Nlink; n != 2 {
+		t.Errorf("Nlink de /a tras mover b: got %d, want 2", n)
+	}
+	if n := statT(t, fs, "/").Nlink; n != 4 {
+		t.Errorf("Nlink de la raíz: got %d, want 4", n)
+	}
+
+	entries, _ := fs.ListDir("/b")
+	if len(entries) != 1 || entries[0].Sys().(*StatT).Ino != ino {
+		t.Errorf("ListDir no reporta el inodo: %+v", entries)
+	}
+}
+
+func TestHardLinks(t *testing.T) {
+	fs := NewFileSystem()
+	fs.MkdirAll("/docs", 0755)
+	fs.WriteFile("/docs/original.txt", []byte("compartido"))
+
+	if err := fs.Link("/docs/original.txt", "/enlace.txt"); err != nil {
+		t.Fatalf("Error creando enlace: %v", err)
+	}
+
+	if err := fs.Link("/docs", "/otro"); err == nil {
+		t.Error("Se permitió un enlace duro a un directorio")
+	}
+	if err := fs.Link("/docs/original.txt", "/enlace.txt"); err == nil {
+		t.Error("Se permitió un enlace sobre un destino existente")
+	}
+
+	a, b := statT(t, fs, "/docs/original.txt"), statT(t, fs, "/enlace.txt")
+	if a.Ino != b.Ino || a.Nlink != 2 {
+		t.Errorf("Los enlaces no comparten inodo: %+v %+v", a, b)
+	}
+
+	// Escribir por un nombre se ve por el otro
+	fs.WriteFile("/enlace.txt", []byte("cambiado"))
+	if data, _ := fs.ReadFile("/docs/original.txt"); string(data) != "cambiado" {
+		t.Errorf("El contenido no es compartido: %q", data)
+	}
+
+	// Eliminar el enlace principal deja vivo el otro
+	if err := fs.RemoveAll("/docs"); err != nil {
+		t.Fatal(err)
+	}
+	if st := statT(t, fs, "/enlace.txt"); st.Nlink != 1 {
+		t.Errorf("Nlink tras eliminar un enlace: got %d, want 1", st.Nlink)
+	}
+
+	f, err := fs.OpenByID(a.Ino)
+	if err != nil {
+		t.Fatalf("El inodo debería seguir existiendo: %v", err)
+	}
+	if f.Name() != "/enlace.txt" {
+		t.Errorf("Ruta del inodo incorrecta: %s", f.Name())
+	}
+
+	fs.Remove("/enlace.txt")
+	if _, err := fs.OpenByID(a.Ino); err == nil {
+		t.Error("El inodo debería liberarse con el último enlace")
+	}
+}
+
+func TestFile(t *testing.T) {
+	fs := NewFileSystem()
+	fs.MkdirAll("/dir", 0755)
+	fs.WriteFile("/dir/datos.txt", []byte("hola mundo"))
+
+	f, err := fs.Open("/dir/datos.txt")
+	if err != nil {
+		t.Fatalf("Error abriendo archivo: %v", err)
+	}
+
+	t.Run("ReadSeek", func(t *testing.T) {
+		buf := make([]byte, 4)
+		if n, err := f.Read(buf); n != 4 || err != nil || string(buf) != "hola" {
+			t.Errorf("Read incorrecto: %d %v %q", n, err, buf)
+		}
+		if pos, _ := f.Seek(-5, io.SeekEnd); pos != 5 {
+			t.Errorf("Seek incorrecto: %d", pos)
+		}
+		data, _ := io.ReadAll(f)
+		if string(data) != "mundo" {
+			t.Errorf("Lectura tras Seek incorrecta: %q", data)
+		}
+	})
+
+	t.Run("WriteAt", func(t *testing.T) {
+		if _, err := f.WriteAt([]byte("MUNDO!"), 5); err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := fs.ReadFile("/dir/datos.txt"); string(data) != "hola MUNDO!" {
+			t.Errorf("WriteAt no extendió el archivo: %q", data)
+		}
+	})
+
+	t.Run("SurvivesRename", func(t *testing.T) {
+		info, _ := f.Stat()
+		ino := info.Sys().(*StatT).Ino
+
+		fs.Rename("/dir", "/movido")
+
+		byID, err := fs.OpenByID(ino)
+		if err != nil {
+			t.Fatalf("OpenByID tras Rename: %v", err)
+		}
+		defer byID.Close()
+
+		if byID.Name() != "/movido/datos.txt" {
+			t.Errorf("Ruta incorrecta tras Rename: %s", byID.Name())
+		}
+
+		f.Seek(0, io.SeekStart)
+		f.Write([]byte("HOLA"))
+		data := make([]byte, 11)
+		byID.ReadAt(data, 0)
+		if string(data) != "HOLA MUNDO!" {
+			t.Errorf("Los dos File no ven el mismo inodo: %q", data)
+		}
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		f.Close()
+		if _, err := f.Read(make([]byte, 1)); !errors.Is(err, os.ErrClosed) {
+			t.Errorf("Read tras Close: %v", err)
+		}
+		if err := f.Close(); !errors.Is(err, os.ErrClosed) {
+			t.Errorf("Doble Close: %v", err)
+		}
+	})
+
+	if _, err := fs.OpenByID(9999); err == nil {
+		t.Error("OpenByID con inodo inexistente debería fallar")
+	}
+}
@@ -0,0 +1,177 @@
+package minifs
+
+import (
+	"errors"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ImportOptions configura FromFS y CopyFromFS
+type ImportOptions struct {
+	// Lazy crea los archivos con el tamaño que informa el origen pero sin
+	// leerlos: el contenido se lee de src la primera vez que se usa (con
+	// ReadFile, Open o cualquier operación que lo necesite). Sirve para
+	// árboles grandes de los que se lee poco. src tiene que seguir
+	// disponible mientras queden archivos sin leer.
+	Lazy bool
+}
+
+// FromFS crea un FileSystem con el contenido del directorio root de src,
+// que puede ser un embed.FS, os.DirFS o cualquier fs.FS. root pasa a ser
+// la raíz; "." importa todo src. Se conservan los permisos y, si el origen
+// las tiene, las fechas de modificación. Lo que no es un archivo regular
+// ni un directorio, como los enlaces simbólicos, se omite.
+//
+//	//go:embed testdata
+//	var fixtures embed.FS
+//
+//	fs, err := minifs.FromFS(fixtures, "testdata", minifs.ImportOptions{})
+func FromFS(src iofs.FS, root string, opts ImportOptions) (*FileSystem, error) {
+	fs := NewFileSystem()
+	if err := fs.importFS(src, root, "/", opts); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+// CopyFromFS copia todo src dentro de dstPath, que se crea si no existe.
+// Los archivos que ya existen se sobrescriben; con Lazy, estos se leen en
+// el momento en lugar de esperar al primer uso.
+func (fs *FileSystem) CopyFromFS(dstPath string, src iofs.FS, opts ImportOptions) error {
+	return fs.importFS(src, ".", dstPath, opts)
+}
+
+// importFS copia root de src en dstPath
+func (fs *FileSystem) importFS(src iofs.FS, root, dstPath string, opts ImportOptions) error {
+	if !iofs.ValidPath(root) {
+		return &os.PathError{Op: "import", Path: root, Err: iofs.ErrInvalid}
+	}
+
+	// Crear un archivo cambia la fecha de su directorio, así que las de
+	// los directorios se aplican al final, de adentro hacia afuera
+	type dirTime struct {
+		path    string
+		modTime time.Time
+	}
+	var dirTimes []dirTime
+
+	err := iofs.WalkDir(src, root, func(name string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, name)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dstPath, rel)
+
+		switch {
+		case d.IsDir():
+			if err := fs.MkdirAll(path, info.Mode().Perm()); err != nil {
+				return err
+			}
+			if !info.ModTime().IsZero() {
+				dirTimes = append(dirTimes, dirTime{path, info.ModTime()})
+			}
+			return nil
+
+		case info.Mode().IsRegular():
+			load := func() ([]byte, error) { return iofs.ReadFile(src, name) }
+			if opts.Lazy {
+				err = fs.createLazy(path, info.Size(), info.Mode().Perm(), load)
+			} else {
+				var content []byte
+				if content, err = load(); err == nil {
+					err = fs.CreateFile(path, content, info.Mode().Perm())
+				}
+			}
+			if err == nil && !info.ModTime().IsZero() {
+				err = fs.SetModTime(path, info.ModTime())
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := len(dirTimes) - 1; i >= 0; i-- {
+		if err := fs.SetModTime(dirTimes[i].path, dirTimes[i].modTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createLazy crea un archivo de tamaño size cuyo contenido se obtiene con
+// load al usarlo por primera vez. Si el archivo ya existe o está en un
+// sistema montado, el contenido se lee ahora.
+func (fs *FileSystem) createLazy(path string, size int64, mode os.FileMode, load func() ([]byte, error)) error {
+	eager := func() error {
+		content, err := load()
+		if err != nil {
+			return err
+		}
+		return fs.CreateFile(path, content, mode)
+	}
+	if m, _ := fs.mountFor(path); m != nil {
+		return eager()
+	}
+
+	if err := fs.lock("create", path); err != nil {
+		return err
+	}
+
+	parent, name, err := fs.resolve(path)
+	if err != nil {
+		fs.mu.Unlock()
+		return err
+	}
+	if name == "" {
+		fs.mu.Unlock()
+		return errors.New("nombre de archivo vacío")
+	}
+
+	parent.mu.Lock()
+	if _, _, exists := fs.child(parent, name); exists {
+		parent.mu.Unlock()
+		fs.mu.Unlock()
+		return eager()
+	}
+	node := fs.addFile(parent, name, nil, mode)
+	parent.mu.Unlock()
+
+	// Nadie más ve el nodo mientras tengamos fs.mu para escritura
+	node.size = size
+	node.source = load
+	fs.mu.Unlock()
+	return nil
+}
+
+// fill lee el contenido de un archivo importado con Lazy si todavía no se
+// leyó. Si falla, el archivo sigue pendiente y se reintenta la próxima
+// vez. El llamador no debe tener node.mu.
+func (node *Node) fill() error {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+
+	if node.source == nil {
+		return nil
+	}
+	content, err := node.source()
+	if err != nil {
+		return err
+	}
+	node.data = newSparseData(content)
+	node.size = int64(len(content))
+	node.source = nil
+	return nil
+}
@@ -0,0 +1,145 @@
+package minifs
+
+import (
+	"errors"
+	iofs "io/fs"
+	"os"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var fixtureTime = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func fixtures() fstest.MapFS {
+	return fstest.MapFS{
+		"assets/index.html":     {Data: []byte("<h1>hola</h1>"), Mode: 0644, ModTime: fixtureTime},
+		"assets/secreto.key":    {Data: []byte("clave"), Mode: 0600},
+		"assets/css":            {Mode: iofs.ModeDir | 0700, ModTime: fixtureTime},
+		"assets/css/estilo.css": {Data: []byte("body{}"), Mode: 0644},
+		"assets/enlace":         {Data: []byte("index.html"), Mode: iofs.ModeSymlink | 0777},
+		"otros/leeme.txt":       {Data: []byte("fuera de assets")},
+	}
+}
+
+// countingFS cuenta las lecturas de contenido y puede fallar a pedido
+type countingFS struct {
+	iofs.FS
+	reads atomic.Int32
+	fail  atomic.Bool
+}
+
+func (c *countingFS) Open(name string) (iofs.File, error) {
+	f, err := c.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+		if c.fail.Load() {
+			f.Close()
+			return nil, errors.New("origen no disponible")
+		}
+		c.reads.Add(1)
+	}
+	return f, nil
+}
+
+func TestFromFS(t *testing.T) {
+	fs, err := FromFS(fixtures(), "assets", ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report := fs.Check(); !report.OK() {
+		t.Fatalf("Árbol inconsistente: %v", report)
+	}
+
+	if data, _ := fs.ReadFile("/css/estilo.css"); string(data) != "body{}" {
+		t.Errorf("Contenido importado: %q", data)
+	}
+	if fs.Exists("/otros") || fs.Exists("/enlace") {
+		t.Error("Se importó algo fuera de root o un enlace simbólico")
+	}
+
+	key, _ := fs.Stat("/secreto.key")
+	css, _ := fs.Stat("/css")
+	if key.Mode.Perm() != 0600 || css.Mode.Perm() != 0700 {
+		t.Errorf("Permisos: %v %v", key.Mode, css.Mode)
+	}
+	index, _ := fs.Stat("/index.html")
+	if !index.ModTime.Equal(fixtureTime) || !css.ModTime.Equal(fixtureTime) {
+		t.Errorf("Fechas: %v %v", index.ModTime, css.ModTime)
+	}
+
+	if _, err := FromFS(fixtures(), "no/existe", ImportOptions{}); err == nil {
+		t.Error("FromFS con un root inexistente debería fallar")
+	}
+	if _, err := FromFS(fixtures(), "/assets", ImportOptions{}); err == nil {
+		t.Error("FromFS con un root que no es válido para fs.FS debería fallar")
+	}
+}
+
+func TestCopyFromFSLazy(t *testing.T) {
+	fs := newCheckedFS(t)
+	src := &countingFS{FS: fixtures()}
+
+	if err := fs.CopyFromFS("/web", src, ImportOptions{Lazy: true}); err != nil {
+		t.Fatal(err)
+	}
+	if n := src.reads.Load(); n != 0 {
+		t.Errorf("Lazy leyó %d archivos al importar", n)
+	}
+	if info, _ := fs.Stat("/web/assets/index.html"); info.Size != 13 {
+		t.Errorf("El tamaño debería estar antes de leer: %d", info.Size)
+	}
+
+	// La primera lectura va al origen, la segunda no
+	fs.ReadFile("/web/assets/index.html")
+	if data, _ := fs.ReadFile("/web/assets/index.html"); string(data) != "<h1>hola</h1>" || src.reads.Load() != 1 {
+		t.Errorf("Lectura diferida: %q, %d lecturas", data, src.reads.Load())
+	}
+
+	// Si el origen falla, el archivo queda pendiente y se reintenta
+	src.fail.Store(true)
+	if _, err := fs.ReadFile("/web/otros/leeme.txt"); err == nil {
+		t.Error("Se esperaba el error del origen")
+	}
+	src.fail.Store(false)
+	if err := fs.AppendFile("/web/otros/leeme.txt", []byte("!")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := fs.ReadFile("/web/otros/leeme.txt"); string(data) != "fuera de assets!" {
+		t.Errorf("Escritura sobre un archivo diferido: %q", data)
+	}
+
+	// Lo que ya existe se sobrescribe en el momento
+	fs.WriteFile("/web/assets/css/estilo.css", []byte("viejo"))
+	before := src.reads.Load()
+	fs.CopyFromFS("/web", src, ImportOptions{Lazy: true})
+	if src.reads.Load() == before {
+		t.Error("Los archivos existentes deberían leerse al importar")
+	}
+	if data, _ := fs.ReadFile("/web/assets/css/estilo.css"); string(data) != "body{}" {
+		t.Errorf("Sobrescritura: %q", data)
+	}
+}
+
+func TestCopyFromFSDir(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(dir+"/sub", 0755)
+	os.WriteFile(dir+"/sub/a.txt", []byte("a"), 0640)
+
+	fs := newCheckedFS(t)
+	if err := fs.CopyFromFS("/", os.DirFS(dir), ImportOptions{Lazy: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Archivo pendiente que se elimina del origen antes de leerlo
+	os.Remove(dir + "/sub/a.txt")
+	if _, err := fs.ReadFile("/sub/a.txt"); !errors.Is(err, iofs.ErrNotExist) {
+		t.Errorf("Se esperaba el error del origen: %v", err)
+	}
+	if info, _ := fs.Stat("/sub/a.txt"); info.Mode.Perm() != 0640 {
+		t.Errorf("Permisos desde os.DirFS: %v", info.Mode)
+	}
+}
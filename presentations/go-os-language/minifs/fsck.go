@@ -0,0 +1,362 @@
+package minifs
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ProblemKind clasifica las inconsistencias que encuentra Check
+type ProblemKind int
+
+const (
+	// BadParent: el padre o el nombre guardados en el nodo no coinciden con
+	// la entrada que lo contiene
+	BadParent ProblemKind = iota
+	// BadKey: el índice de nombres sin mayúsculas o el índice ordenado no
+	// coinciden con los hijos
+	BadKey
+	// BadSize: hay contenido guardado más allá del tamaño del archivo
+	BadSize
+	// Cycle: un directorio es alcanzable por más de un camino
+	Cycle
+	// BadLinkCount: nlink no coincide con las entradas que apuntan al nodo
+	BadLinkCount
+	// BadInode: la tabla de inodos no coincide con los nodos del árbol
+	BadInode
+	// BadBitmap: un mapa de bits o un contador del superbloque de DiskFS
+	// no coincide con los bloques e inodos en uso
+	BadBitmap
+)
+
+func (k ProblemKind) String() string {
+	switch k {
+	case BadParent:
+		return "padre incorrecto"
+	case BadKey:
+		return "índice de nombres"
+	case BadSize:
+		return "tamaño"
+	case Cycle:
+		return "ciclo"
+	case BadLinkCount:
+		return "número de enlaces"
+	case BadInode:
+		return "tabla de inodos"
+	case BadBitmap:
+		return "mapa de bits"
+	}
+	return "desconocido"
+}
+
+// Problem es una inconsistencia encontrada en el árbol
+type Problem struct {
+	Kind   ProblemKind
+	Path   string
+	Detail string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s: %s", p.Path, p.Kind, p.Detail)
+}
+
+// Report es el resultado de Check o Repair
+type Report struct {
+	Dirs     int
+	Files    int
+	Bytes    int64 // tamaño aparente de todos los archivos
+	Problems []Problem
+}
+
+// OK indica si no se encontraron problemas
+func (r *Report) OK() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d directorios, %d archivos, %d bytes", r.Dirs, r.Files, r.Bytes)
+	if r.OK() {
+		b.WriteString(": sin problemas")
+		return b.String()
+	}
+	fmt.Fprintf(&b, ": %d problemas", len(r.Problems))
+	for _, p := range r.Problems {
+		b.WriteString("\n  " + p.String())
+	}
+	return b.String()
+}
+
+// Check recorre el árbol completo y verifica sus invariantes: que cada
+// nodo apunte a su padre real, que el índice de nombres coincida con los
+// hijos, que no haya contenido más allá del tamaño, que no haya ciclos y
+// que los contadores de enlaces y la tabla de inodos estén al día. No
+// modifica nada.
+func (fs *FileSystem) Check() *Report {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	return fs.fsck(false)
+}
+
+// Repair hace lo mismo que Check pero además corrige lo que encuentra. El
+// reporte lista los problemas que había antes de corregirlos.
+func (fs *FileSystem) Repair() *Report {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.fsck(true)
+}
+
+// checker guarda el estado de un recorrido de fsck
+type checker struct {
+	fs     *FileSystem
+	repair bool
+	report *Report
+
+	dirs  map[*Node]string // directorios visitados y su ruta
+	links map[*Node]int    // entradas que apuntan a cada archivo
+	paths map[*Node]string // primera ruta en que apareció cada archivo
+}
+
+func (c *checker) problem(kind ProblemKind, path, format string, args ...any) {
+	c.report.Problems = append(c.report.Problems, Problem{Kind: kind, Path: path, Detail: fmt.Sprintf(format, args...)})
+}
+
+// fsck asume que fs.mu está tomado: para escritura si repair es true
+func (fs *FileSystem) fsck(repair bool) *Report {
+	c := &checker{
+		fs:     fs,
+		repair: repair,
+		report: &Report{},
+		dirs:   make(map[*Node]string),
+		links:  make(map[*Node]int),
+		paths:  make(map[*Node]string),
+	}
+
+	c.checkDir(fs.root, "/")
+	c.checkFileLinks()
+	c.checkInodes()
+
+	// Los mapas se recorren en orden aleatorio; ordenamos para que dos
+	// reportes del mismo árbol sean iguales
+	sort.SliceStable(c.report.Problems, func(i, j int) bool {
+		return c.report.Problems[i].Path < c.report.Problems[j].Path
+	})
+	return c.report
+}
+
+func (c *checker) checkDir(dir *Node, dirPath string) {
+	c.dirs[dir] = dirPath
+	c.report.Dirs++
+	c.checkKeys(dir, dirPath)
+	c.checkIndex(dir, dirPath)
+
+	names := make([]string, 0, len(dir.children))
+	for name := range dir.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	subdirs := 0
+	for _, name := range names {
+		child := dir.children[name]
+		childPath := path.Join(dirPath, name)
+
+		if child.nodeType == FileNode {
+			c.links[child]++
+			if _, seen := c.paths[child]; !seen {
+				c.paths[child] = childPath
+				c.checkFile(child, childPath)
+			}
+			continue
+		}
+
+		if first, seen := c.dirs[child]; seen {
+			c.problem(Cycle, childPath, "el directorio ya apareció en %s", first)
+			if c.repair {
+				c.fs.removeChild(dir, name)
+			}
+			continue
+		}
+		subdirs++
+
+		if child.parent != dir || child.name != name {
+			c.problem(BadParent, childPath, "el nodo dice estar en %s como %q", c.nameOf(child.parent), child.name)
+			if c.repair {
+				child.parent, child.name = dir, name
+			}
+		}
+
+		c.checkDir(child, childPath)
+	}
+
+	// "." , la entrada en el padre y el ".." de cada subdirectorio
+	if want := uint32(2 + subdirs); dir.nlink != want {
+		c.problem(BadLinkCount, dirPath, "nlink es %d, debería ser %d", dir.nlink, want)
+		if c.repair {
+			dir.nlink = want
+		}
+	}
+}
+
+// nameOf describe un directorio para los mensajes
+func (c *checker) nameOf(dir *Node) string {
+	if dir == nil {
+		return "ningún directorio"
+	}
+	if p, ok := c.dirs[dir]; ok {
+		return p
+	}
+	return fmt.Sprintf("un directorio fuera del árbol (inodo %d)", dir.ino)
+}
+
+// checkKeys verifica que el índice de nombres tenga exactamente una clave
+// por hijo y que cada clave apunte al nombre correcto
+func (c *checker) checkKeys(dir *Node, dirPath string) {
+	if !c.fs.policy.CaseInsensitive {
+		if dir.keys != nil {
+			c.problem(BadKey, dirPath, "índice de nombres en un sistema que distingue mayúsculas")
+			if c.repair {
+				dir.keys = nil
+			}
+		}
+		return
+	}
+
+	ok := len(dir.keys) == len(dir.children)
+	for name := range dir.children {
+		if dir.keys[c.fs.policy.key(name)] != name {
+			ok = false
+		}
+	}
+	if ok {
+		return
+	}
+
+	c.problem(BadKey, dirPath, "el índice tiene %d claves para %d hijos", len(dir.keys), len(dir.children))
+	if c.repair {
+		dir.keys = make(map[string]string, len(dir.children))
+		for name := range dir.children {
+			dir.keys[c.fs.policy.key(name)] = name
+		}
+	}
+}
+
+// checkIndex verifica que el índice ordenado tenga cada hijo una sola vez,
+// con su clave y en orden
+func (c *checker) checkIndex(dir *Node, dirPath string) {
+	ok := dir.index != nil && dir.index.len == len(dir.children)
+	if ok {
+		var prev *indexEntry
+		for e := dir.index.first(); e != nil && ok; prev, e = e, e.next[0] {
+			ok = (prev == nil || prev.key < e.key) &&
+				e.key == c.fs.policy.key(e.name) && dir.children[e.name] == e.node
+		}
+	}
+	if ok {
+		return
+	}
+
+	n := 0
+	if dir.index != nil {
+		n = dir.index.len
+	}
+	c.problem(BadKey, dirPath, "el índice ordenado tiene %d entradas para %d hijos", n, len(dir.children))
+	if c.repair {
+		dir.index = newDirIndex()
+		for name, child := range dir.children {
+			dir.index.insert(c.fs.policy.key(name), name, child)
+		}
+	}
+}
+
+// checkFile verifica que no haya páginas ni bytes más allá del tamaño
+func (c *checker) checkFile(node *Node, filePath string) {
+	c.report.Files++
+	c.report.Bytes += node.size
+
+	for page, data := range node.data.pages {
+		start := page * pageSize
+		if start >= node.size {
+			c.problem(BadSize, filePath, "página %d más allá del tamaño %d", page, node.size)
+			if c.repair {
+				delete(node.data.pages, page)
+			}
+			continue
+		}
+
+		if tail := node.size - start; tail < pageSize {
+			for _, b := range data[tail:] {
+				if b != 0 {
+					c.problem(BadSize, filePath, "datos después del byte %d", node.size)
+					if c.repair {
+						clear(data[tail:])
+					}
+					break
+				}
+			}
+		}
+	}
+}
+
+// checkFileLinks compara nlink con las entradas encontradas y verifica que
+// el enlace principal de cada archivo exista
+func (c *checker) checkFileLinks() {
+	for node, count := range c.links {
+		filePath := c.paths[node]
+
+		if node.nlink != uint32(count) {
+			c.problem(BadLinkCount, filePath, "nlink es %d pero hay %d entradas", node.nlink, count)
+			if c.repair {
+				node.nlink = uint32(count)
+			}
+		}
+
+		if node.parent == nil || node.parent.children[node.name] != node {
+			c.problem(BadParent, filePath, "el enlace principal %q en %s no existe", node.name, c.nameOf(node.parent))
+			if c.repair {
+				c.fs.relink(c.fs.root, node)
+			}
+		}
+	}
+}
+
+// checkInodes compara la tabla de inodos con los nodos alcanzables
+func (c *checker) checkInodes() {
+	reachable := make(map[uint64]*Node, len(c.dirs)+len(c.links))
+	for dir, dirPath := range c.dirs {
+		c.checkIno(reachable, dir, dirPath)
+	}
+	for node := range c.links {
+		c.checkIno(reachable, node, c.paths[node])
+	}
+
+	for ino, node := range c.fs.inodes {
+		if _, ok := reachable[ino]; !ok {
+			c.problem(BadInode, fmt.Sprintf("inodo %d", ino), "el nodo %q no es alcanzable desde la raíz", node.name)
+			if c.repair {
+				delete(c.fs.inodes, ino)
+			}
+		}
+	}
+}
+
+func (c *checker) checkIno(reachable map[uint64]*Node, node *Node, nodePath string) {
+	if other, dup := reachable[node.ino]; dup && other != node {
+		c.problem(BadInode, nodePath, "inodo %d repetido", node.ino)
+		if c.repair {
+			node.ino = c.fs.nextIno
+			c.fs.nextIno++
+		}
+	}
+	reachable[node.ino] = node
+
+	if c.fs.inodes[node.ino] != node {
+		c.problem(BadInode, nodePath, "el inodo %d no está en la tabla", node.ino)
+		if c.repair {
+			c.fs.inodes[node.ino] = node
+		}
+	}
+}
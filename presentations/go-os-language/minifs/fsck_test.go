@@ -0,0 +1,137 @@
+package minifs
+
+import (
+	"testing"
+)
+
+// newCheckedFS crea un FileSystem que se verifica con Check al terminar la
+// prueba, para que cualquier operación que deje el árbol inconsistente falle
+func newCheckedFS(t *testing.T) *FileSystem {
+	t.Helper()
+	fs := NewFileSystem()
+	t.Cleanup(func() {
+		if report := fs.Check(); !report.OK() {
+			t.Errorf("El árbol quedó inconsistente: %v", report)
+		}
+	})
+	return fs
+}
+
+// corrupt arma un árbol sano y aplica una corrupción sobre sus nodos
+func corrupt(t *testing.T, damage func(fs *FileSystem)) *FileSystem {
+	t.Helper()
+	fs := NewFileSystem()
+	fs.MkdirAll("/a/b", 0755)
+	fs.WriteFile("/a/b/f.txt", []byte("contenido"))
+	fs.WriteFile("/g.txt", []byte("g"))
+
+	if report := fs.Check(); !report.OK() {
+		t.Fatalf("El árbol recién creado no está sano: %v", report)
+	}
+
+	fs.mu.Lock()
+	damage(fs)
+	fs.mu.Unlock()
+	return fs
+}
+
+func TestCheckAndRepair(t *testing.T) {
+	tests := []struct {
+		name   string
+		kind   ProblemKind
+		damage func(fs *FileSystem)
+	}{
+		{"Parent", BadParent, func(fs *FileSystem) {
+			a := fs.root.children["a"]
+			a.children["b"].parent = fs.root
+		}},
+		{"Name", BadParent, func(fs *FileSystem) {
+			fs.root.children["a"].name = "otro"
+		}},
+		{"Index", BadKey, func(fs *FileSystem) {
+			fs.root.index.delete("g.txt")
+		}},
+		{"Size", BadSize, func(fs *FileSystem) {
+			fs.root.children["g.txt"].size = 0
+		}},
+		{"TrailingBytes", BadSize, func(fs *FileSystem) {
+			f := fs.root.children["a"].children["b"].children["f.txt"]
+			f.size = 3
+		}},
+		{"Cycle", Cycle, func(fs *FileSystem) {
+			b := fs.root.children["a"].children["b"]
+			b.children["vuelta"] = fs.root.children["a"]
+		}},
+		{"DirNlink", BadLinkCount, func(fs *FileSystem) {
+			fs.root.children["a"].nlink = 7
+		}},
+		{"FileNlink", BadLinkCount, func(fs *FileSystem) {
+			fs.root.children["g.txt"].nlink = 2
+		}},
+		{"OrphanInode", BadInode, func(fs *FileSystem) {
+			fs.inodes[999] = &Node{name: "huérfano", ino: 999}
+		}},
+		{"MissingInode", BadInode, func(fs *FileSystem) {
+			delete(fs.inodes, fs.root.children["g.txt"].ino)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := corrupt(t, tt.damage)
+
+			report := fs.Check()
+			found := false
+			for _, p := range report.Problems {
+				found = found || p.Kind == tt.kind
+			}
+			if !found {
+				t.Fatalf("Check no detectó %v: %v", tt.kind, report)
+			}
+
+			if again := fs.Check(); again.String() != report.String() {
+				t.Error("Check no debería modificar el árbol")
+			}
+
+			if repaired := fs.Repair(); repaired.OK() {
+				t.Error("Repair debería reportar lo que corrigió")
+			}
+			if after := fs.Check(); !after.OK() {
+				t.Errorf("El árbol sigue inconsistente tras Repair: %v", after)
+			}
+		})
+	}
+}
+
+func TestCheckCaseInsensitiveKeys(t *testing.T) {
+	fs := NewFileSystemWithPolicy(MacOSPolicy())
+	fs.MkdirAll("/Docs", 0755)
+	fs.WriteFile("/Docs/A.txt", nil)
+
+	fs.mu.Lock()
+	delete(fs.root.children["Docs"].keys, "a.txt")
+	fs.mu.Unlock()
+
+	if report := fs.Check(); report.OK() || report.Problems[0].Kind != BadKey {
+		t.Fatalf("No se detectó el índice roto: %v", report)
+	}
+	fs.Repair()
+	if !fs.Exists("/docs/a.TXT") {
+		t.Error("Repair no reconstruyó el índice")
+	}
+}
+
+func TestRenameIntoItself(t *testing.T) {
+	fs := newCheckedFS(t)
+	fs.MkdirAll("/a/b/c", 0755)
+
+	if err := fs.Rename("/a", "/a/b/c/a"); err == nil {
+		t.Error("Se movió un directorio dentro de sí mismo")
+	}
+	if err := fs.Rename("/a/b", "/a/b/x"); err == nil {
+		t.Error("Se movió un directorio dentro de sí mismo")
+	}
+	if err := fs.Rename("/a/b/c", "/c"); err != nil {
+		t.Errorf("Mover hacia arriba debería funcionar: %v", err)
+	}
+}
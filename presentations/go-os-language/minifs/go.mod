@@ -1,6 +1,6 @@
 module github.com/hectorip/minifs
 
-go 1.21
+go 1.23
 
 require (
 )
\ No newline at end of file
@@ -0,0 +1,159 @@
+package minifs
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SaveImage guarda el sistema de archivos completo en w como un archivo tar.
+// Las entradas se escriben en orden alfabético para que dos imágenes del
+// mismo árbol sean idénticas. Los enlaces duros se guardan como entradas
+// TypeLink que apuntan al primer nombre escrito del mismo inodo.
+func (fs *FileSystem) SaveImage(w io.Writer) error {
+	tw := tar.NewWriter(w)
+
+	if err := fs.saveDir(tw, "/", make(map[uint64]string)); err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+func (fs *FileSystem) saveDir(tw *tar.Writer, dir string, links map[uint64]string) error {
+	entries, err := fs.ListDir(dir)
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name)
+		st := entry.Sys().(*StatT)
+		hdr := &tar.Header{
+			Name:    strings.TrimPrefix(path, "/"),
+			Mode:    int64(entry.Mode.Perm()),
+			Uid:     int(st.Uid),
+			Gid:     int(st.Gid),
+			ModTime: entry.ModTime,
+		}
+
+		if entry.IsDir {
+			hdr.Typeflag = tar.TypeDir
+			hdr.Name += "/"
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if err := fs.saveDir(tw, path, links); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if st.Nlink > 1 {
+			if first, seen := links[st.Ino]; seen {
+				hdr.Typeflag = tar.TypeLink
+				hdr.Linkname = first
+				if err := tw.WriteHeader(hdr); err != nil {
+					return err
+				}
+				continue
+			}
+			links[st.Ino] = hdr.Name
+		}
+
+		content, err := fs.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		hdr.Typeflag = tar.TypeReg
+		hdr.Size = int64(len(content))
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LoadImage crea un sistema de archivos nuevo a partir de una imagen
+// generada con SaveImage (o cualquier tar con archivos y directorios)
+func LoadImage(r io.Reader) (*FileSystem, error) {
+	fs := NewFileSystem()
+	tr := tar.NewReader(r)
+
+	// Los tiempos se aplican al final: crear hijos cambia el de los directorios
+	modTimes := make(map[string]time.Time)
+	var order []string
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		path := filepath.Clean("/" + hdr.Name)
+		if path == "/" {
+			continue
+		}
+		mode := os.FileMode(hdr.Mode).Perm()
+
+		if err := fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			// El directorio puede existir ya si alguna entrada anterior lo
+			// necesitó; Chmod le da los permisos de la imagen
+			if err := fs.MkdirAll(path, mode); err != nil {
+				return nil, err
+			}
+			if err := fs.Chmod(path, mode); err != nil {
+				return nil, err
+			}
+		case tar.TypeReg:
+			content, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			if err := fs.CreateFile(path, content, mode); err != nil {
+				return nil, err
+			}
+		case tar.TypeLink:
+			target := filepath.Clean("/" + hdr.Linkname)
+			if err := fs.Link(target, path); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errors.New("tipo de entrada no soportado en la imagen: " + hdr.Name)
+		}
+
+		if hdr.Typeflag != tar.TypeLink && (hdr.Uid != 0 || hdr.Gid != 0) {
+			if err := fs.Chown(path, hdr.Uid, hdr.Gid); err != nil {
+				return nil, err
+			}
+		}
+
+		modTimes[path] = hdr.ModTime
+		order = append(order, path)
+	}
+
+	for i := len(order) - 1; i >= 0; i-- {
+		fs.SetModTime(order[i], modTimes[order[i]])
+	}
+
+	return fs, nil
+}
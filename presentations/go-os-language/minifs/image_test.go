@@ -0,0 +1,66 @@
+package minifs
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestImageRoundTrip(t *testing.T) {
+	fs := NewFileSystem()
+	fs.MkdirAll("/etc/app", 0700)
+	fs.CreateFile("/etc/app/config", []byte("port=8080"), 0600)
+	fs.WriteFile("/readme.txt", []byte("hola"))
+	fs.CreateDir("/empty", 0755)
+	fs.Link("/readme.txt", "/etc/LEEME")
+	fs.Chown("/etc/app/config", 1000, 100)
+
+	stamp := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	fs.SetModTime("/etc/app", stamp)
+
+	var buf bytes.Buffer
+	if err := fs.SaveImage(&buf); err != nil {
+		t.Fatalf("Error guardando imagen: %v", err)
+	}
+
+	loaded, err := LoadImage(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Error cargando imagen: %v", err)
+	}
+
+	data, err := loaded.ReadFile("/etc/app/config")
+	if err != nil || string(data) != "port=8080" {
+		t.Errorf("Contenido no restaurado: %v", err)
+	}
+
+	info, _ := loaded.Stat("/etc/app/config")
+	if info.Mode != 0600 {
+		t.Errorf("Permisos no restaurados: got %v", info.Mode)
+	}
+
+	if st := info.Sys().(*StatT); st.Uid != 1000 || st.Gid != 100 {
+		t.Errorf("Dueño no restaurado: %d:%d", st.Uid, st.Gid)
+	}
+
+	info, _ = loaded.Stat("/etc/app")
+	if !info.ModTime.Equal(stamp) {
+		t.Errorf("Tiempo no restaurado: got %v, want %v", info.ModTime, stamp)
+	}
+
+	a, _ := loaded.Stat("/readme.txt")
+	b, _ := loaded.Stat("/etc/LEEME")
+	if a.Sys().(*StatT).Ino != b.Sys().(*StatT).Ino {
+		t.Error("El enlace duro no fue restaurado")
+	}
+
+	if !loaded.Exists("/empty") {
+		t.Error("El directorio vacío no fue restaurado")
+	}
+
+	// Guardar de nuevo debe producir exactamente la misma imagen
+	var again bytes.Buffer
+	loaded.SaveImage(&again)
+	if !bytes.Equal(buf.Bytes(), again.Bytes()) {
+		t.Error("La imagen no es determinista")
+	}
+}
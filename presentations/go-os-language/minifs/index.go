@@ -0,0 +1,219 @@
+package minifs
+
+import (
+	"math/bits"
+	"math/rand/v2"
+	"sort"
+	"strings"
+)
+
+// ReadDirPage lista hasta limit entradas de un directorio, en el mismo
+// orden que ListDir, empezando después del nombre after. Para leer la
+// página siguiente se pasa como after el Name de la última entrada: el
+// cursor es el nombre y no una posición, así que las entradas que se
+// agregan o eliminan entre una página y otra no hacen saltear ni repetir
+// las demás. Un after vacío empieza desde el principio y un limit menor o
+// igual que cero no pone límite.
+//
+//	after := ""
+//	for {
+//		page, err := fs.ReadDirPage("/logs", after, 100)
+//		if err != nil || len(page) == 0 {
+//			break
+//		}
+//		after = page[len(page)-1].Name
+//	}
+func (fs *FileSystem) ReadDirPage(path, after string, limit int) ([]FileInfo, error) {
+	if m, rel := fs.mountFor(path); m != nil {
+		infos, err := m.backend.ListDir(rel)
+		if err != nil {
+			return nil, err
+		}
+		return pageOf(infos, after, "", limit), nil
+	}
+
+	return fs.readIndex(path, "", limit, func(ix *dirIndex) *indexEntry {
+		if after == "" {
+			return ix.first()
+		}
+		return ix.after(fs.policy.cursor(after))
+	})
+}
+
+// ReadDirPrefix lista, en orden, hasta limit entradas del directorio cuyo
+// nombre empieza con prefix. Sin distinguir mayúsculas el prefijo tampoco
+// las distingue. Como usa el índice, el costo depende de cuántas entradas
+// coinciden y no del tamaño del directorio.
+func (fs *FileSystem) ReadDirPrefix(path, prefix string, limit int) ([]FileInfo, error) {
+	if m, rel := fs.mountFor(path); m != nil {
+		infos, err := m.backend.ListDir(rel)
+		if err != nil {
+			return nil, err
+		}
+		return pageOf(infos, "", prefix, limit), nil
+	}
+
+	key := fs.policy.cursor(prefix)
+	return fs.readIndex(path, key, limit, func(ix *dirIndex) *indexEntry {
+		return ix.seek(key)
+	})
+}
+
+// readIndex arma hasta limit FileInfo recorriendo el índice del directorio
+// path desde la entrada que devuelve start
+func (fs *FileSystem) readIndex(path, prefix string, limit int, start func(ix *dirIndex) *indexEntry) ([]FileInfo, error) {
+	if err := fs.rlock("readdir", path); err != nil {
+		return nil, err
+	}
+	defer fs.mu.RUnlock()
+
+	dir, err := fs.navigateTo(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dir.mu.RLock()
+	defer dir.mu.RUnlock()
+
+	var files []FileInfo
+	scan(start(dir.index), prefix, func(e *indexEntry) bool {
+		files = append(files, e.node.info(e.name))
+		return limit <= 0 || len(files) < limit
+	})
+	return files, nil
+}
+
+// pageOf aplica after, prefix y limit a un listado completo, para los
+// sistemas montados que no tienen índice
+func pageOf(infos []FileInfo, after, prefix string, limit int) []FileInfo {
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+
+	var page []FileInfo
+	for _, info := range infos {
+		if info.Name <= after || !strings.HasPrefix(info.Name, prefix) {
+			continue
+		}
+		if limit > 0 && len(page) == limit {
+			break
+		}
+		page = append(page, info)
+	}
+	return page
+}
+
+// indexMaxLevel alcanza para millones de entradas: con p = 1/4 cada nivel
+// tiene en promedio la cuarta parte de los nodos del anterior
+const indexMaxLevel = 16
+
+// dirIndex mantiene los hijos de un directorio ordenados por su clave de
+// comparación (la de PathPolicy.key), en una skip list. El mapa children
+// sigue resolviendo las búsquedas por nombre exacto; el índice sirve para
+// listar en orden, paginar desde un nombre y buscar por prefijo sin
+// recorrer ni ordenar todo el directorio. Se protege con el mu del
+// directorio, igual que children.
+type dirIndex struct {
+	head  indexEntry
+	level int
+	len   int
+}
+
+// indexEntry es un hijo en el índice
+type indexEntry struct {
+	key  string
+	name string
+	node *Node
+	next []*indexEntry
+}
+
+func newDirIndex() *dirIndex {
+	return &dirIndex{head: indexEntry{next: make([]*indexEntry, indexMaxLevel)}, level: 1}
+}
+
+// randomLevel sortea la altura de una entrada nueva
+func randomLevel() int {
+	level := 1 + bits.TrailingZeros64(rand.Uint64())/2
+	return min(level, indexMaxLevel)
+}
+
+// path llena update con la última entrada de cada nivel cuya clave es
+// menor que key, y devuelve la primera con clave mayor o igual
+func (ix *dirIndex) path(key string, update []*indexEntry) *indexEntry {
+	x := &ix.head
+	for i := ix.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && x.next[i].key < key {
+			x = x.next[i]
+		}
+		if update != nil {
+			update[i] = x
+		}
+	}
+	return x.next[0]
+}
+
+// insert agrega o reemplaza la entrada con clave key
+func (ix *dirIndex) insert(key, name string, node *Node) {
+	var update [indexMaxLevel]*indexEntry
+	if e := ix.path(key, update[:]); e != nil && e.key == key {
+		e.name, e.node = name, node
+		return
+	}
+
+	level := randomLevel()
+	for i := ix.level; i < level; i++ {
+		update[i] = &ix.head
+	}
+	ix.level = max(ix.level, level)
+
+	e := &indexEntry{key: key, name: name, node: node, next: make([]*indexEntry, level)}
+	for i := 0; i < level; i++ {
+		e.next[i] = update[i].next[i]
+		update[i].next[i] = e
+	}
+	ix.len++
+}
+
+// delete quita la entrada con clave key, si está
+func (ix *dirIndex) delete(key string) {
+	var update [indexMaxLevel]*indexEntry
+	e := ix.path(key, update[:])
+	if e == nil || e.key != key {
+		return
+	}
+
+	for i := 0; i < len(e.next); i++ {
+		update[i].next[i] = e.next[i]
+	}
+	for ix.level > 1 && ix.head.next[ix.level-1] == nil {
+		ix.level--
+	}
+	ix.len--
+}
+
+// first devuelve la primera entrada en orden, o nil
+func (ix *dirIndex) first() *indexEntry {
+	return ix.head.next[0]
+}
+
+// seek devuelve la primera entrada con clave mayor o igual que key
+func (ix *dirIndex) seek(key string) *indexEntry {
+	return ix.path(key, nil)
+}
+
+// after devuelve la primera entrada con clave estrictamente mayor que key
+func (ix *dirIndex) after(key string) *indexEntry {
+	e := ix.seek(key)
+	if e != nil && e.key == key {
+		e = e.next[0]
+	}
+	return e
+}
+
+// scan recorre en orden desde start mientras fn devuelva true. Con un
+// prefijo, termina en la primera clave que no lo tiene.
+func scan(start *indexEntry, prefix string, fn func(e *indexEntry) bool) {
+	for e := start; e != nil; e = e.next[0] {
+		if !strings.HasPrefix(e.key, prefix) || !fn(e) {
+			return
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package minifs
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestDirIndex(t *testing.T) {
+	ix := newDirIndex()
+	want := map[string]bool{}
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("k%04d", rand.IntN(1000))
+		if rand.IntN(3) == 0 {
+			ix.delete(key)
+			delete(want, key)
+		} else {
+			ix.insert(key, key, nil)
+			want[key] = true
+		}
+	}
+
+	var got []string
+	for e := ix.first(); e != nil; e = e.next[0] {
+		got = append(got, e.key)
+	}
+	keys := make([]string, 0, len(want))
+	for key := range want {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if !slices.Equal(got, keys) || ix.len != len(keys) {
+		t.Fatalf("El índice tiene %d claves, se esperaban %d", ix.len, len(keys))
+	}
+
+	if e := ix.seek("k0500"); e == nil || e.key < "k0500" {
+		t.Errorf("seek devolvió una clave menor: %v", e)
+	}
+	if e := ix.after(keys[0]); e == nil || e.key != keys[1] {
+		t.Errorf("after no devolvió la siguiente clave: %v", e)
+	}
+	if e := ix.after(keys[len(keys)-1]); e != nil {
+		t.Errorf("after de la última clave: %v", e.key)
+	}
+}
+
+func TestListDirSorted(t *testing.T) {
+	fs := newCheckedFS(t)
+	for _, name := range []string{"c", "a", "b10", "b2", "B"} {
+		fs.WriteFile("/"+name, nil)
+	}
+
+	var names []string
+	infos, _ := fs.ListDir("/")
+	for _, info := range infos {
+		names = append(names, info.Name)
+	}
+	if fmt.Sprint(names) != "[B a b10 b2 c]" {
+		t.Errorf("ListDir debería estar ordenado: %v", names)
+	}
+}
+
+func TestReadDirPage(t *testing.T) {
+	fs := newCheckedFS(t)
+	fs.CreateDir("/d", 0755)
+	for i := 0; i < 250; i++ {
+		fs.WriteFile(fmt.Sprintf("/d/f%03d", i), nil)
+	}
+
+	// Entre páginas se agregan y eliminan entradas antes y después del
+	// cursor; las originales que siguen vivas aparecen una vez y en orden
+	var names []string
+	after := ""
+	for page := 0; ; page++ {
+		infos, err := fs.ReadDirPage("/d", after, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(infos) == 0 {
+			break
+		}
+		if len(infos) > 100 {
+			t.Fatalf("Página de %d entradas", len(infos))
+		}
+		for _, info := range infos {
+			names = append(names, info.Name)
+		}
+		after = infos[len(infos)-1].Name
+
+		fs.WriteFile(fmt.Sprintf("/d/a%d", page), nil)
+		fs.Remove("/d/f000")
+		fs.Remove("/d/f249")
+	}
+
+	if !sort.StringsAreSorted(names) {
+		t.Error("Las páginas no salieron en orden")
+	}
+	if len(names) != 249 || names[0] != "f000" || names[len(names)-1] != "f248" {
+		t.Errorf("Se leyeron %d entradas, de %s a %s", len(names), names[0], names[len(names)-1])
+	}
+
+	if _, err := fs.ReadDirPage("/no/existe", "", 10); err == nil {
+		t.Error("ReadDirPage de un directorio inexistente debería fallar")
+	}
+	if infos, _ := fs.ReadDirPage("/d", "", 0); len(infos) != 251 {
+		t.Errorf("Sin límite se esperaban todas las entradas: %d", len(infos))
+	}
+}
+
+func TestReadDirPrefix(t *testing.T) {
+	fs := NewFileSystemWithPolicy(MacOSPolicy())
+	fs.MkdirAll("/fotos", 0755)
+	for _, name := range []string{"IMG_1.jpg", "img_2.jpg", "IMG_3.jpg", "imagen.png", "video.mp4"} {
+		fs.WriteFile("/fotos/"+name, nil)
+	}
+
+	infos, err := fs.ReadDirPrefix("/fotos", "img_", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name)
+	}
+	if fmt.Sprint(names) != "[IMG_1.jpg img_2.jpg IMG_3.jpg]" {
+		t.Errorf("Prefijo sin distinguir mayúsculas: %v", names)
+	}
+
+	if infos, _ := fs.ReadDirPrefix("/fotos", "im", 2); len(infos) != 2 || infos[0].Name != "imagen.png" {
+		t.Errorf("Prefijo con límite: %v", infos)
+	}
+	if infos, _ := fs.ReadDirPrefix("/fotos", "zzz", 0); len(infos) != 0 {
+		t.Errorf("Prefijo sin coincidencias: %v", infos)
+	}
+
+	// Un montaje no tiene índice pero responde igual
+	other := NewFileSystem()
+	other.WriteFile("/b1", nil)
+	other.WriteFile("/a", nil)
+	other.WriteFile("/b2", nil)
+	fs.MkdirAll("/mnt", 0755)
+	fs.Mount("/mnt", other, MountOptions{})
+	if infos, _ := fs.ReadDirPrefix("/mnt", "b", 1); len(infos) != 1 || infos[0].Name != "b1" {
+		t.Errorf("Prefijo en un montaje: %v", infos)
+	}
+	if infos, _ := fs.ReadDirPage("/mnt", "a", 0); len(infos) != 2 || infos[0].Name != "b1" {
+		t.Errorf("Página en un montaje: %v", infos)
+	}
+}
+
+var (
+	millionOnce sync.Once
+	million     *FileSystem
+)
+
+// millionFS devuelve un sistema con un millón de archivos en /big, creado
+// una sola vez para todos los benchmarks
+func millionFS() *FileSystem {
+	millionOnce.Do(func() {
+		million = NewFileSystem()
+		million.CreateDir("/big", 0755)
+		for i := 0; i < 1000000; i++ {
+			million.WriteFile(fmt.Sprintf("/big/f%07d", i), nil)
+		}
+	})
+	return million
+}
+
+func BenchmarkCreateFile1M(b *testing.B) {
+	fs := millionFS()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		path := fmt.Sprintf("/big/nuevo%d", i)
+		fs.WriteFile(path, nil)
+		b.StopTimer()
+		fs.Remove(path)
+		b.StartTimer()
+	}
+}
+
+func BenchmarkReadDirPage1M(b *testing.B) {
+	fs := millionFS()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fs.ReadDirPage("/big", fmt.Sprintf("f%07d", i%1000000), 100)
+	}
+}
+
+func BenchmarkReadDirPrefix1M(b *testing.B) {
+	fs := millionFS()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fs.ReadDirPrefix("/big", fmt.Sprintf("f%05d", i%10000), 0)
+	}
+}
+
+func BenchmarkListDir1M(b *testing.B) {
+	fs := millionFS()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fs.ListDir("/big")
+	}
+}
@@ -0,0 +1,333 @@
+package minifs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Op describe una operación sobre un Backend instrumentado. Los hooks de
+// Before la reciben sin Duration ni Err.
+type Op struct {
+	Name    string // nombre del método ("ReadFile", "Rename", ...)
+	Path    string
+	NewPath string // destino de Rename y Link; vacío en las demás
+
+	BytesRead    int64
+	BytesWritten int64
+
+	Start    time.Time
+	Duration time.Duration
+	Err      error
+}
+
+// Hook recibe cada operación cuando empieza o cuando termina. Se llama
+// desde la goroutine que hizo la operación, así que debe ser seguro para
+// uso concurrente.
+type Hook func(op Op)
+
+// InstrumentedFS envuelve un Backend y llama a sus hooks alrededor de cada
+// operación con el nombre, las rutas, los bytes, la duración y el error.
+// Cubre los métodos de Backend, los opcionales que el Backend de abajo
+// tenga (Open, Link, CreateExclusive, Chmod y Chown) y las lecturas y
+// escrituras de los File que devuelven Open y CreateExclusive, que se
+// reportan como "Read" y "Write". Lo que es propio de *FileSystem, como
+// Copy, Versions u OpenByID, no pasa por los hooks.
+type InstrumentedFS struct {
+	backend Backend
+	before  []Hook
+	hooks   []Hook
+}
+
+var _ Backend = (*InstrumentedFS)(nil)
+
+// Instrument envuelve b para que cada operación se reporte a los hooks
+// cuando termina
+func Instrument(b Backend, hooks ...Hook) *InstrumentedFS {
+	return &InstrumentedFS{backend: b, hooks: hooks}
+}
+
+// Before agrega hooks que se llaman al empezar cada operación, con Start
+// y los bytes pedidos ya puestos. Devuelve i para encadenarlo con
+// Instrument.
+func (i *InstrumentedFS) Before(hooks ...Hook) *InstrumentedFS {
+	i.before = append(i.before, hooks...)
+	return i
+}
+
+// done completa op con la duración y el error y la entrega a los hooks
+func (i *InstrumentedFS) done(op Op, err error) {
+	op.Duration = time.Since(op.Start)
+	op.Err = err
+	for _, hook := range i.hooks {
+		hook(op)
+	}
+}
+
+// begin marca el comienzo de op y la entrega a los hooks de Before
+func (i *InstrumentedFS) begin(op Op) Op {
+	op.Start = time.Now()
+	for _, hook := range i.before {
+		hook(op)
+	}
+	return op
+}
+
+func (i *InstrumentedFS) Exists(path string) bool {
+	op := i.begin(Op{Name: "Exists", Path: path})
+	exists := i.backend.Exists(path)
+	i.done(op, nil)
+	return exists
+}
+
+func (i *InstrumentedFS) Stat(path string) (FileInfo, error) {
+	op := i.begin(Op{Name: "Stat", Path: path})
+	info, err := i.backend.Stat(path)
+	i.done(op, err)
+	return info, err
+}
+
+func (i *InstrumentedFS) ListDir(path string) ([]FileInfo, error) {
+	op := i.begin(Op{Name: "ListDir", Path: path})
+	entries, err := i.backend.ListDir(path)
+	i.done(op, err)
+	return entries, err
+}
+
+func (i *InstrumentedFS) ReadFile(path string) ([]byte, error) {
+	op := i.begin(Op{Name: "ReadFile", Path: path})
+	content, err := i.backend.ReadFile(path)
+	op.BytesRead = int64(len(content))
+	i.done(op, err)
+	return content, err
+}
+
+func (i *InstrumentedFS) CreateDir(path string, mode os.FileMode) error {
+	op := i.begin(Op{Name: "CreateDir", Path: path})
+	err := i.backend.CreateDir(path, mode)
+	i.done(op, err)
+	return err
+}
+
+func (i *InstrumentedFS) MkdirAll(path string, mode os.FileMode) error {
+	op := i.begin(Op{Name: "MkdirAll", Path: path})
+	err := i.backend.MkdirAll(path, mode)
+	i.done(op, err)
+	return err
+}
+
+// CreateFile, como las demás escrituras, reporta los bytes pedidos aunque
+// falle; Err indica si llegaron al Backend
+func (i *InstrumentedFS) CreateFile(path string, content []byte, mode os.FileMode) error {
+	op := i.begin(Op{Name: "CreateFile", Path: path, BytesWritten: int64(len(content))})
+	err := i.backend.CreateFile(path, content, mode)
+	i.done(op, err)
+	return err
+}
+
+func (i *InstrumentedFS) WriteFile(path string, content []byte) error {
+	op := i.begin(Op{Name: "WriteFile", Path: path, BytesWritten: int64(len(content))})
+	err := i.backend.WriteFile(path, content)
+	i.done(op, err)
+	return err
+}
+
+func (i *InstrumentedFS) AppendFile(path string, content []byte) error {
+	op := i.begin(Op{Name: "AppendFile", Path: path, BytesWritten: int64(len(content))})
+	err := i.backend.AppendFile(path, content)
+	i.done(op, err)
+	return err
+}
+
+func (i *InstrumentedFS) Truncate(path string, size int64) error {
+	op := i.begin(Op{Name: "Truncate", Path: path})
+	err := i.backend.Truncate(path, size)
+	i.done(op, err)
+	return err
+}
+
+func (i *InstrumentedFS) Remove(path string) error {
+	op := i.begin(Op{Name: "Remove", Path: path})
+	err := i.backend.Remove(path)
+	i.done(op, err)
+	return err
+}
+
+func (i *InstrumentedFS) RemoveAll(path string) error {
+	op := i.begin(Op{Name: "RemoveAll", Path: path})
+	err := i.backend.RemoveAll(path)
+	i.done(op, err)
+	return err
+}
+
+func (i *InstrumentedFS) Rename(oldPath, newPath string) error {
+	op := i.begin(Op{Name: "Rename", Path: oldPath, NewPath: newPath})
+	err := i.backend.Rename(oldPath, newPath)
+	i.done(op, err)
+	return err
+}
+
+// Walk se reporta como una sola operación; la duración incluye el tiempo
+// de walkFn
+func (i *InstrumentedFS) Walk(path string, walkFn func(path string, info FileInfo) error) error {
+	op := i.begin(Op{Name: "Walk", Path: path})
+	err := i.backend.Walk(path, walkFn)
+	i.done(op, err)
+	return err
+}
+
+func (i *InstrumentedFS) Size(path string) (int64, error) {
+	op := i.begin(Op{Name: "Size", Path: path})
+	size, err := i.backend.Size(path)
+	i.done(op, err)
+	return size, err
+}
+
+func (i *InstrumentedFS) SetModTime(path string, modTime time.Time) error {
+	op := i.begin(Op{Name: "SetModTime", Path: path})
+	err := i.backend.SetModTime(path, modTime)
+	i.done(op, err)
+	return err
+}
+
+// Open solo funciona si el Backend de abajo sabe abrir archivos. Las
+// lecturas y escrituras del File se reportan aparte.
+func (i *InstrumentedFS) Open(path string) (*File, error) {
+	op := i.begin(Op{Name: "Open", Path: path})
+	o, ok := i.backend.(opener)
+	if !ok {
+		err := errors.New("el sistema instrumentado no permite abrir archivos: " + path)
+		i.done(op, err)
+		return nil, err
+	}
+	f, err := o.Open(path)
+	if err == nil {
+		i.track(f, path)
+	}
+	i.done(op, err)
+	return f, err
+}
+
+// CreateExclusive solo funciona si el Backend de abajo sabe crear en
+// exclusiva
+func (i *InstrumentedFS) CreateExclusive(path string, mode os.FileMode) (*File, error) {
+	op := i.begin(Op{Name: "CreateExclusive", Path: path})
+	c, ok := i.backend.(exclusiveCreator)
+	if !ok {
+		err := errors.New("el sistema instrumentado no permite crear en exclusiva: " + path)
+		i.done(op, err)
+		return nil, err
+	}
+	f, err := c.CreateExclusive(path, mode)
+	if err == nil {
+		i.track(f, path)
+	}
+	i.done(op, err)
+	return f, err
+}
+
+// Link solo funciona si el Backend de abajo sabe crear enlaces duros
+func (i *InstrumentedFS) Link(oldPath, newPath string) error {
+	op := i.begin(Op{Name: "Link", Path: oldPath, NewPath: newPath})
+	l, ok := i.backend.(linker)
+	if !ok {
+		err := errors.New("el sistema instrumentado no permite enlaces duros: " + oldPath)
+		i.done(op, err)
+		return err
+	}
+	err := l.Link(oldPath, newPath)
+	i.done(op, err)
+	return err
+}
+
+// Chmod solo funciona si el Backend de abajo sabe cambiar permisos
+func (i *InstrumentedFS) Chmod(path string, mode os.FileMode) error {
+	op := i.begin(Op{Name: "Chmod", Path: path})
+	c, ok := i.backend.(chmoder)
+	if !ok {
+		err := errors.New("el sistema instrumentado no permite cambiar permisos: " + path)
+		i.done(op, err)
+		return err
+	}
+	err := c.Chmod(path, mode)
+	i.done(op, err)
+	return err
+}
+
+// Chown solo funciona si el Backend de abajo sabe cambiar el dueño
+func (i *InstrumentedFS) Chown(path string, uid, gid int) error {
+	op := i.begin(Op{Name: "Chown", Path: path})
+	c, ok := i.backend.(chowner)
+	if !ok {
+		err := errors.New("el sistema instrumentado no permite cambiar el dueño: " + path)
+		i.done(op, err)
+		return err
+	}
+	err := c.Chown(path, uid, gid)
+	i.done(op, err)
+	return err
+}
+
+// track hace que cada lectura y escritura de f pase por los hooks. Si f ya
+// venía de otro InstrumentedFS, se reporta a los dos.
+func (i *InstrumentedFS) track(f *File, path string) {
+	inner := f.track
+	f.track = func(name string, size int) func(n int, err error) {
+		var innerDone func(int, error)
+		if inner != nil {
+			innerDone = inner(name, size)
+		}
+
+		op := Op{Name: name, Path: path}
+		if name == "Write" {
+			op.BytesWritten = int64(size)
+		}
+		op = i.begin(op)
+
+		return func(n int, err error) {
+			if innerDone != nil {
+				innerDone(n, err)
+			}
+			if name == "Read" {
+				op.BytesRead = int64(n)
+			}
+			// Llegar al final no es una falla
+			if err == io.EOF {
+				err = nil
+			}
+			i.done(op, err)
+		}
+	}
+}
+
+// AuditLogger devuelve un Hook que registra cada operación en logger. Las
+// operaciones fallidas se registran con nivel Warn y las demás con Info.
+func AuditLogger(logger *slog.Logger) Hook {
+	return func(op Op) {
+		attrs := []slog.Attr{
+			slog.String("op", op.Name),
+			slog.String("path", op.Path),
+		}
+		if op.NewPath != "" {
+			attrs = append(attrs, slog.String("new_path", op.NewPath))
+		}
+		if op.BytesRead > 0 {
+			attrs = append(attrs, slog.Int64("bytes_read", op.BytesRead))
+		}
+		if op.BytesWritten > 0 {
+			attrs = append(attrs, slog.Int64("bytes_written", op.BytesWritten))
+		}
+		attrs = append(attrs, slog.Duration("duration", op.Duration))
+
+		level := slog.LevelInfo
+		if op.Err != nil {
+			level = slog.LevelWarn
+			attrs = append(attrs, slog.String("error", op.Err.Error()))
+		}
+
+		logger.LogAttrs(context.Background(), level, "minifs", attrs...)
+	}
+}
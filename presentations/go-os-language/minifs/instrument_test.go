@@ -0,0 +1,146 @@
+package minifs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestInstrument(t *testing.T) {
+	var ops []Op
+	ifs := Instrument(NewFileSystem(), func(op Op) { ops = append(ops, op) })
+
+	ifs.WriteFile("/a.txt", []byte("hola"))
+	ifs.ReadFile("/a.txt")
+	ifs.Rename("/a.txt", "/b.txt")
+	ifs.ReadFile("/a.txt")
+
+	if len(ops) != 4 {
+		t.Fatalf("Se esperaban 4 operaciones, got %d", len(ops))
+	}
+	if ops[0].Name != "WriteFile" || ops[0].BytesWritten != 4 {
+		t.Errorf("Escritura mal reportada: %+v", ops[0])
+	}
+	if ops[1].BytesRead != 4 || ops[1].Err != nil {
+		t.Errorf("Lectura mal reportada: %+v", ops[1])
+	}
+	if ops[2].Path != "/a.txt" || ops[2].NewPath != "/b.txt" {
+		t.Errorf("Rename mal reportado: %+v", ops[2])
+	}
+	if ops[3].Err == nil {
+		t.Error("El error de la lectura fallida no se reportó")
+	}
+}
+
+func TestAuditLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	// Instrumentar un FaultFS registra también los errores inyectados
+	ffs := NewFaultFS(NewFileSystem(), 1)
+	ffs.AddRule(Rule{Op: "AppendFile", Err: syscall.ENOSPC})
+	ifs := Instrument(ffs, AuditLogger(logger))
+
+	ifs.CreateFile("/log", []byte("x"), 0644)
+	ifs.AppendFile("/log", []byte("yy"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Se esperaban 2 líneas de auditoría:\n%s", buf.String())
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["level"] != "WARN" || entry["op"] != "AppendFile" || entry["path"] != "/log" {
+		t.Errorf("Entrada de auditoría incorrecta: %v", entry)
+	}
+	if !strings.Contains(entry["error"].(string), "no space") {
+		t.Errorf("La entrada no incluye el error: %v", entry)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics()
+	ifs := Instrument(NewFileSystem(), m.Observe)
+
+	ifs.WriteFile("/a", []byte("12345"))
+	ifs.WriteFile("/b", []byte("123"))
+	ifs.ReadFile("/a")
+	ifs.ReadFile("/nada")
+
+	var buf bytes.Buffer
+	if err := m.WritePrometheus(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		`minifs_ops_total{op="WriteFile"} 2`,
+		`minifs_ops_total{op="ReadFile"} 2`,
+		`minifs_op_errors_total{op="ReadFile"} 1`,
+		`minifs_bytes_written_total{op="WriteFile"} 8`,
+		`minifs_bytes_read_total{op="ReadFile"} 5`,
+		`minifs_op_duration_seconds_bucket{op="ReadFile",le="+Inf"} 2`,
+		`minifs_op_duration_seconds_count{op="WriteFile"} 2`,
+		"# TYPE minifs_op_duration_seconds histogram",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Falta %q en:\n%s", want, out)
+		}
+	}
+
+	snap := m.Snapshot()
+	if s := snap["WriteFile"].(opSnapshot); s.Count != 2 || s.BytesWritten != 8 {
+		t.Errorf("Snapshot incorrecto: %+v", s)
+	}
+}
+
+func TestInstrumentFile(t *testing.T) {
+	var started, ops []string
+	ifs := Instrument(NewFileSystem(), func(op Op) {
+		ops = append(ops, fmt.Sprintf("%s %s r=%d w=%d err=%v", op.Name, op.Path, op.BytesRead, op.BytesWritten, op.Err))
+	}).Before(func(op Op) {
+		if op.Duration != 0 || op.Err != nil {
+			t.Errorf("Before recibió una operación terminada: %+v", op)
+		}
+		started = append(started, op.Name)
+	})
+
+	f, err := ifs.CreateExclusive("/a.txt", 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("hola"))
+	f.Close()
+
+	ifs.Link("/a.txt", "/b.txt")
+	f, _ = ifs.Open("/b.txt")
+	io.ReadAll(f)
+	f.Close()
+
+	want := []string{
+		"CreateExclusive /a.txt r=0 w=0 err=<nil>",
+		"Write /a.txt r=0 w=4 err=<nil>",
+		"Link /a.txt r=0 w=0 err=<nil>",
+		"Open /b.txt r=0 w=0 err=<nil>",
+		"Read /b.txt r=4 w=0 err=<nil>", // io.EOF no cuenta como error
+	}
+	if strings.Join(ops, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Operaciones reportadas:\n%s", strings.Join(ops, "\n"))
+	}
+	if len(started) != len(ops) {
+		t.Errorf("Before se llamó %d veces para %d operaciones", len(started), len(ops))
+	}
+
+	// Sin la capacidad en el Backend de abajo falla
+	if err := Instrument(NewFaultFS(NewFileSystem(), 1)).Link("/a", "/b"); err == nil {
+		t.Error("Link sobre un Backend sin enlaces debería fallar")
+	}
+}
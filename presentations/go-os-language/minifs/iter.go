@@ -0,0 +1,184 @@
+package minifs
+
+import (
+	"errors"
+	"iter"
+	"path/filepath"
+)
+
+// dirEntry es una entrada de directorio copiada para recorrerla sin el
+// candado
+type dirEntry struct {
+	name string
+	node *Node
+}
+
+// Entries recorre el contenido de un directorio sin armar el []FileInfo
+// completo de ListDir. Solo copia los nombres y nodos bajo el candado; la
+// información de cada entrada se arma cuando se pide, y el cuerpo del
+// range corre sin ningún candado tomado, así que puede llamar a fs. Si el
+// directorio no existe, produce un único par con el error.
+//
+//	for info, err := range fs.Entries("/") {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(info.Name)
+//	}
+func (fs *FileSystem) Entries(path string) iter.Seq2[FileInfo, error] {
+	return func(yield func(FileInfo, error) bool) {
+		if m, rel := fs.mountFor(path); m != nil {
+			infos, err := m.backend.ListDir(rel)
+			if err != nil {
+				yield(FileInfo{}, err)
+				return
+			}
+			for _, info := range infos {
+				if !yield(info, nil) {
+					return
+				}
+			}
+			return
+		}
+
+		entries, err := fs.readDirEntries(path)
+		if err != nil {
+			yield(FileInfo{}, err)
+			return
+		}
+		for _, e := range entries {
+			e.node.mu.RLock()
+			info := e.node.info(e.name)
+			e.node.mu.RUnlock()
+
+			if !yield(info, nil) {
+				return
+			}
+		}
+	}
+}
+
+// All recorre el árbol desde root como Walk, pero como iterador: cada
+// directorio se copia recién cuando se llega a él y un break termina el
+// recorrido. Igual que en Entries, el cuerpo del range corre sin
+// candados. Si root no existe, o el contexto de la vista se cancela, el
+// recorrido simplemente termina; para distinguir esos casos está Walk.
+func (fs *FileSystem) All(root string) iter.Seq2[string, FileInfo] {
+	return func(yield func(string, FileInfo) bool) {
+		if m, rel := fs.mountFor(root); m != nil {
+			walkMount(m, rel, yield)
+			return
+		}
+
+		node, name, err := fs.start(root)
+		if err == nil {
+			fs.all(root, name, node, yield)
+		}
+	}
+}
+
+// start busca el nodo donde empieza un recorrido y su nombre guardado
+func (fs *FileSystem) start(path string) (*Node, string, error) {
+	if err := fs.rlock("walk", path); err != nil {
+		return nil, "", err
+	}
+	defer fs.mu.RUnlock()
+
+	parent, name, err := fs.resolve(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if name == "" {
+		return fs.root, fs.root.name, nil
+	}
+
+	parent.mu.RLock()
+	node, name, exists := fs.child(parent, name)
+	parent.mu.RUnlock()
+
+	if !exists {
+		return nil, "", errors.New("no existe: " + path)
+	}
+	return node, name, nil
+}
+
+// all devuelve false si el consumidor cortó el recorrido
+func (fs *FileSystem) all(path, name string, node *Node, yield func(string, FileInfo) bool) bool {
+	if fs.ctxErr("walk", path) != nil {
+		return false
+	}
+
+	node.mu.RLock()
+	info := node.info(name)
+	node.mu.RUnlock()
+	if !yield(path, info) {
+		return false
+	}
+	if node.nodeType != DirNode {
+		return true
+	}
+
+	if fs.rlock("walk", path) != nil {
+		return false
+	}
+	node.mu.RLock()
+	children := snapshotChildren(node)
+	node.mu.RUnlock()
+	fs.mu.RUnlock()
+
+	for _, e := range children {
+		childPath := filepath.Join(path, e.name)
+		if m := fs.mountAt(filepath.Clean("/" + childPath)); m != nil {
+			if !walkMount(m, "/", yield) {
+				return false
+			}
+			continue
+		}
+		if !fs.all(childPath, e.name, e.node, yield) {
+			return false
+		}
+	}
+	return true
+}
+
+// walkMount adapta el Walk de un montaje a un iterador. Devuelve false si
+// el consumidor cortó el recorrido.
+func walkMount(m *mount, rel string, yield func(string, FileInfo) bool) bool {
+	stopped := false
+	m.walk(rel, func(p string, info FileInfo) error {
+		if !yield(p, info) {
+			stopped = true
+			return errStop
+		}
+		return nil
+	})
+	return !stopped
+}
+
+// readDirEntries copia las entradas del directorio path
+func (fs *FileSystem) readDirEntries(path string) ([]dirEntry, error) {
+	if err := fs.rlock("readdir", path); err != nil {
+		return nil, err
+	}
+	defer fs.mu.RUnlock()
+
+	dir, err := fs.navigateTo(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dir.mu.RLock()
+	defer dir.mu.RUnlock()
+
+	return snapshotChildren(dir), nil
+}
+
+// snapshotChildren copia los hijos de dir. El llamador debe tener dir.mu
+// tomado.
+func snapshotChildren(dir *Node) []dirEntry {
+	entries := make([]dirEntry, 0, dir.index.len)
+	for e := dir.index.first(); e != nil; e = e.next[0] {
+		entries = append(entries, dirEntry{name: e.name, node: e.node})
+	}
+	return entries
+}
@@ -0,0 +1,182 @@
+package minifs
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestEntries(t *testing.T) {
+	fs := newTree(t, 1, 5)
+
+	var names []string
+	for info, err := range fs.Entries("/t/d0") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, info.Name)
+	}
+	sort.Strings(names)
+	if fmt.Sprint(names) != "[f0 f1 f2 f3 f4]" {
+		t.Errorf("Entries: %v", names)
+	}
+
+	n := 0
+	for range fs.Entries("/t/d0") {
+		n++
+		if n == 2 {
+			break
+		}
+	}
+	if n != 2 {
+		t.Errorf("El break no cortó la iteración: %d", n)
+	}
+
+	calls := 0
+	for _, err := range fs.Entries("/no/existe") {
+		calls++
+		if err == nil {
+			t.Error("Entries de un directorio inexistente debería dar error")
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Se esperaba un único error, hubo %d", calls)
+	}
+}
+
+func TestEntriesUnlocked(t *testing.T) {
+	fs := newTree(t, 1, 5)
+
+	// El cuerpo del range puede escribir en el mismo sistema de archivos
+	for info, err := range fs.Entries("/t/d0") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.Remove("/t/d0/" + info.Name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if infos, _ := fs.ListDir("/t/d0"); len(infos) != 0 {
+		t.Errorf("Quedaron %d entradas", len(infos))
+	}
+}
+
+func TestAll(t *testing.T) {
+	fs := newTree(t, 3, 3)
+	other := NewFileSystem()
+	other.WriteFile("/m.txt", []byte("m"))
+	fs.CreateDir("/t/mnt", 0755)
+	if err := fs.Mount("/t/mnt", other, MountOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Lo mismo que Walk, montajes incluidos
+	want := map[string]bool{}
+	fs.Walk("/t", func(path string, info FileInfo) error {
+		want[path] = true
+		return nil
+	})
+	got := map[string]bool{}
+	for path, info := range fs.All("/t") {
+		if got[path] {
+			t.Errorf("%s apareció dos veces", path)
+		}
+		got[path] = true
+		if path == "/t/mnt" && (info.Name != "mnt" || !info.IsDir) {
+			t.Errorf("Raíz del montaje: %+v", info)
+		}
+	}
+	if len(got) != len(want) || !got["/t/mnt/m.txt"] {
+		t.Errorf("All recorrió %d rutas, Walk %d", len(got), len(want))
+	}
+	for path := range want {
+		if !got[path] {
+			t.Errorf("Falta %s", path)
+		}
+	}
+
+	n := 0
+	for range fs.All("/") {
+		n++
+		if n == 3 {
+			break
+		}
+	}
+	if n != 3 {
+		t.Errorf("El break no cortó el recorrido: %d", n)
+	}
+
+	for path := range fs.All("/no/existe") {
+		t.Errorf("Recorrido de una ruta inexistente: %s", path)
+	}
+}
+
+func TestAllWritesDuringWalk(t *testing.T) {
+	fs := newTree(t, 2, 2)
+
+	// Crear archivos mientras se recorre no bloquea; los directorios que
+	// todavía no se copiaron ven los cambios
+	for path, info := range fs.All("/t") {
+		if info.IsDir && path != "/t" {
+			fs.WriteFile(path+"/nuevo", []byte("n"))
+		}
+	}
+	if !fs.Exists("/t/d0/nuevo") || !fs.Exists("/t/d1/nuevo") {
+		t.Error("No se pudo escribir durante el recorrido")
+	}
+}
+
+var (
+	bigDirOnce sync.Once
+	bigDir     *FileSystem
+)
+
+// bigDirFS devuelve un sistema con 100000 archivos en /big, creado una
+// sola vez para todos los benchmarks
+func bigDirFS() *FileSystem {
+	bigDirOnce.Do(func() {
+		bigDir = NewFileSystem()
+		bigDir.CreateDir("/big", 0755)
+		for i := 0; i < 100000; i++ {
+			bigDir.WriteFile(fmt.Sprintf("/big/f%d", i), nil)
+		}
+	})
+	return bigDir
+}
+
+func BenchmarkListDir100k(b *testing.B) {
+	fs := bigDirFS()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		infos, _ := fs.ListDir("/big")
+		for range infos {
+		}
+	}
+}
+
+func BenchmarkEntries100k(b *testing.B) {
+	fs := bigDirFS()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for range fs.Entries("/big") {
+		}
+	}
+}
+
+func BenchmarkEntries100kFirst10(b *testing.B) {
+	fs := bigDirFS()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n := 0
+		for range fs.Entries("/big") {
+			n++
+			if n == 10 {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package minifs
+
+import (
+	"bufio"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// latencyBuckets son los límites superiores, en segundos, del histograma
+// de latencias. Una operación en memoria suele caer en los primeros.
+var latencyBuckets = []float64{1e-6, 1e-5, 1e-4, 1e-3, 1e-2, 1e-1, 1}
+
+// opStats acumula las métricas de un tipo de operación
+type opStats struct {
+	count        uint64
+	errors       uint64
+	bytesRead    int64
+	bytesWritten int64
+	sum          float64  // segundos
+	buckets      []uint64 // no acumulativos; el último es +Inf
+}
+
+// Metrics agrega las operaciones de un InstrumentedFS: número de
+// operaciones y errores, histograma de latencias y bytes leídos y escritos
+// por operación. Se conecta con Instrument(fs, m.Observe) y se exporta en
+// formato de texto de Prometheus o con expvar.
+type Metrics struct {
+	mu  sync.Mutex
+	ops map[string]*opStats
+}
+
+// NewMetrics crea un colector vacío
+func NewMetrics() *Metrics {
+	return &Metrics{ops: make(map[string]*opStats)}
+}
+
+// Observe registra una operación. Tiene la firma de Hook.
+func (m *Metrics) Observe(op Op) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st, ok := m.ops[op.Name]
+	if !ok {
+		st = &opStats{buckets: make([]uint64, len(latencyBuckets)+1)}
+		m.ops[op.Name] = st
+	}
+
+	st.count++
+	if op.Err != nil {
+		st.errors++
+	}
+	st.bytesRead += op.BytesRead
+	st.bytesWritten += op.BytesWritten
+
+	secs := op.Duration.Seconds()
+	st.sum += secs
+	i := sort.SearchFloat64s(latencyBuckets, secs)
+	st.buckets[i]++
+}
+
+// names devuelve los nombres de operación en orden. Se asume m.mu tomado.
+func (m *Metrics) names() []string {
+	names := make([]string, 0, len(m.ops))
+	for name := range m.ops {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WritePrometheus escribe las métricas en el formato de texto de Prometheus
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	names := m.names()
+
+	counter := func(metric, help string, value func(*opStats) string) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s counter\n", metric, help, metric)
+		for _, name := range names {
+			fmt.Fprintf(bw, "%s{op=%q} %s\n", metric, name, value(m.ops[name]))
+		}
+	}
+
+	counter("minifs_ops_total", "Operaciones realizadas.",
+		func(st *opStats) string { return strconv.FormatUint(st.count, 10) })
+	counter("minifs_op_errors_total", "Operaciones que devolvieron un error.",
+		func(st *opStats) string { return strconv.FormatUint(st.errors, 10) })
+	counter("minifs_bytes_read_total", "Bytes leídos.",
+		func(st *opStats) string { return strconv.FormatInt(st.bytesRead, 10) })
+	counter("minifs_bytes_written_total", "Bytes escritos.",
+		func(st *opStats) string { return strconv.FormatInt(st.bytesWritten, 10) })
+
+	const hist = "minifs_op_duration_seconds"
+	fmt.Fprintf(bw, "# HELP %s Duración de las operaciones.\n# TYPE %s histogram\n", hist, hist)
+	for _, name := range names {
+		st := m.ops[name]
+		var cumulative uint64
+		for i, le := range latencyBuckets {
+			cumulative += st.buckets[i]
+			fmt.Fprintf(bw, "%s_bucket{op=%q,le=%q} %d\n", hist, name, strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(bw, "%s_bucket{op=%q,le=\"+Inf\"} %d\n", hist, name, st.count)
+		fmt.Fprintf(bw, "%s_sum{op=%q} %s\n", hist, name, strconv.FormatFloat(st.sum, 'g', -1, 64))
+		fmt.Fprintf(bw, "%s_count{op=%q} %d\n", hist, name, st.count)
+	}
+
+	return bw.Flush()
+}
+
+// ServeHTTP sirve las métricas para que Prometheus las recolecte
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WritePrometheus(w)
+}
+
+// opSnapshot es la forma en que cada operación aparece en expvar
+type opSnapshot struct {
+	Count        uint64  `json:"count"`
+	Errors       uint64  `json:"errors"`
+	BytesRead    int64   `json:"bytes_read"`
+	BytesWritten int64   `json:"bytes_written"`
+	Seconds      float64 `json:"seconds"`
+}
+
+// Snapshot devuelve una copia de las métricas por operación, lista para
+// serializarse como JSON
+func (m *Metrics) Snapshot() map[string]any {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snap := make(map[string]any, len(m.ops))
+	for name, st := range m.ops {
+		snap[name] = opSnapshot{
+			Count:        st.count,
+			Errors:       st.errors,
+			BytesRead:    st.bytesRead,
+			BytesWritten: st.bytesWritten,
+			Seconds:      st.sum,
+		}
+	}
+	return snap
+}
+
+// Publish publica las métricas en expvar con el nombre dado, de modo que
+// aparecen en /debug/vars. Como expvar.Publish, entra en pánico si el
+// nombre ya existe.
+func (m *Metrics) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() any { return m.Snapshot() }))
+}
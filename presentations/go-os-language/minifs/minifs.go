@@ -1,6 +1,7 @@
 package minifs
 
 import (
+	"context"
 	"errors"
 	"os"
 	"path/filepath"
@@ -21,21 +22,73 @@ const (
 type Node struct {
 	name     string
 	nodeType NodeType
-	content  []byte
+	data     sparseData
 	children map[string]*Node
-	parent   *Node
+	// keys indexa los hijos por su forma comparable (p.ej. en minúsculas)
+	// cuando la política no distingue mayúsculas; si no, es nil
+	keys map[string]string
+	// index tiene los mismos hijos ordenados por clave; ver dirIndex
+	index *dirIndex
+	// parent y name corresponden al enlace principal del nodo. Un archivo
+	// con enlaces duros aparece además en otros directorios con otros nombres.
+	parent *Node
+
+	// versions son las revisiones anteriores, de la más antigua a la más
+	// reciente; ver keepVersion
+	versions []version
+
+	// source lee el contenido de un archivo importado con ImportOptions.Lazy
+	// la primera vez que se usa; es nil cuando el contenido está en data
+	source func() ([]byte, error)
+
+	// opens cuenta los File abiertos sobre el nodo. Un archivo sin enlaces
+	// pero abierto conserva su contenido hasta el último Close.
+	opens int
 
 	// Metadatos
+	ino     uint64
+	nlink   uint32
 	mode    os.FileMode
+	uid     uint32
+	gid     uint32
 	modTime time.Time
 	size    int64
 	mu      sync.RWMutex
 }
 
-// FileSystem representa nuestro sistema de archivos
+// FileSystem representa nuestro sistema de archivos. Es una vista sobre
+// el estado compartido en core; WithContext crea otras vistas del mismo
+// árbol con su propio contexto.
 type FileSystem struct {
-	root *Node
-	mu   sync.RWMutex
+	*core
+
+	// ctx es nil salvo en las vistas creadas con WithContext
+	ctx context.Context
+}
+
+// core es todo lo que comparten un FileSystem y sus vistas
+type core struct {
+	root   *Node
+	mu     sync.RWMutex
+	policy PathPolicy
+
+	// inodes indexa todos los nodos vivos por su número de inodo
+	inodes  map[uint64]*Node
+	nextIno uint64
+
+	// mounts es la tabla de montajes, del más profundo al menos profundo.
+	// Tiene su propio candado para poder consultarla antes de tomar fs.mu.
+	mountMu sync.RWMutex
+	mounts  []*mount
+
+	// versioning activa las revisiones y la papelera; trash guarda de dónde
+	// vino cada entrada de TrashDir
+	versioning VersioningOptions
+	trash      map[string]TrashEntry
+	trashSeq   uint64
+
+	// cache es nil salvo en modo caché; ver SetCache
+	cache *cache
 }
 
 // FileInfo representa información de un archivo/directorio
@@ -45,6 +98,34 @@ type FileInfo struct {
 	Mode    os.FileMode
 	ModTime time.Time
 	IsDir   bool
+
+	sys *StatT
+}
+
+// StatT imita a syscall.Stat_t con los campos que tienen sentido en minifs
+type StatT struct {
+	Ino     uint64
+	Nlink   uint64
+	Mode    uint32 // tipo de archivo y permisos, como st_mode
+	Uid     uint32
+	Gid     uint32
+	Size    int64
+	Blksize int64
+	Blocks  int64 // bloques de 512 bytes
+	Mtim    time.Time
+}
+
+// Bits de tipo de archivo en StatT.Mode (los mismos que syscall.S_IFDIR
+// y syscall.S_IFREG)
+const (
+	typeDir = 0040000
+	typeReg = 0100000
+)
+
+// Sys devuelve un *StatT con el inodo y el número de enlaces, igual que
+// os.FileInfo.Sys devuelve un *syscall.Stat_t en Unix
+func (fi FileInfo) Sys() any {
+	return fi.sys
 }
 
 // NewFileSystem crea un nuevo sistema de archivos con raíz
@@ -53,82 +134,117 @@ func NewFileSystem() *FileSystem {
 		name:     "/",
 		nodeType: DirNode,
 		children: make(map[string]*Node),
+		index:    newDirIndex(),
+		ino:      1,
+		nlink:    2,
 		mode:     0755,
 		modTime:  time.Now(),
 	}
 
-	return &FileSystem{
-		root: root,
+	return &FileSystem{core: &core{
+		root:    root,
+		inodes:  map[uint64]*Node{1: root},
+		nextIno: 2,
+	}}
+}
+
+// newNode crea un nodo con un número de inodo nuevo y lo registra en la
+// tabla de inodos. Se asume que el llamador tiene fs.mu tomado para escritura.
+func (fs *FileSystem) newNode(name string, nodeType NodeType, parent *Node, mode os.FileMode) *Node {
+	node := &Node{
+		name:     name,
+		nodeType: nodeType,
+		parent:   parent,
+		ino:      fs.nextIno,
+		nlink:    1,
+		mode:     mode.Perm(),
+		modTime:  time.Now(),
 	}
+	if nodeType == DirNode {
+		// "." y la entrada en el padre
+		node.nlink = 2
+		node.children = make(map[string]*Node)
+		node.index = newDirIndex()
+	}
+
+	fs.inodes[node.ino] = node
+	fs.nextIno++
+	return node
 }
 
-// parsePath divide una ruta en sus componentes
-func (fs *FileSystem) parsePath(path string) []string {
-	path = filepath.Clean(path)
-	if path == "/" || path == "." {
-		return []string{}
+// info construye el FileInfo de un nodo visto a través de la entrada name.
+// El llamador debe tener al menos node.mu tomado para lectura.
+func (node *Node) info(name string) FileInfo {
+	st := &StatT{
+		Ino:     node.ino,
+		Nlink:   uint64(node.nlink),
+		Mode:    uint32(node.mode.Perm()),
+		Uid:     node.uid,
+		Gid:     node.gid,
+		Size:    node.size,
+		Blksize: pageSize,
+		Blocks:  node.data.allocated() / 512,
+		Mtim:    node.modTime,
+	}
+	if node.nodeType == DirNode {
+		st.Mode |= typeDir
+	} else {
+		st.Mode |= typeReg
 	}
 
-	path = strings.TrimPrefix(path, "/")
-	return strings.Split(path, "/")
+	return FileInfo{
+		Name:    name,
+		Size:    node.size,
+		Mode:    node.mode,
+		ModTime: node.modTime,
+		IsDir:   node.nodeType == DirNode,
+		sys:     st,
+	}
 }
 
 // navigateTo navega hasta el directorio especificado
 func (fs *FileSystem) navigateTo(path string) (*Node, error) {
-	parts := fs.parsePath(path)
-	current := fs.root
-
-	for _, part := range parts {
-		current.mu.RLock()
-		child, exists := current.children[part]
-		current.mu.RUnlock()
-
-		if !exists {
-			return nil, errors.New("ruta no encontrada: " + path)
-		}
-
-		if child.nodeType != DirNode {
-			return nil, errors.New("no es un directorio: " + part)
-		}
-
-		current = child
+	parts, err := fs.policy.split(path)
+	if err != nil {
+		return nil, err
 	}
 
-	return current, nil
+	return fs.walkParts(path, parts)
 }
 
 // CreateDir crea un nuevo directorio
 func (fs *FileSystem) CreateDir(path string, mode os.FileMode) error {
-	fs.mu.Lock()
-	defer fs.mu.Unlock()
+	if m, rel := fs.mountFor(path); m != nil {
+		if err := m.writable("mkdir", path); err != nil {
+			return err
+		}
+		return m.backend.CreateDir(rel, mode)
+	}
 
-	dir, name := filepath.Split(path)
-	if name == "" {
-		return errors.New("nombre de directorio vacío")
+	if err := fs.lock("mkdir", path); err != nil {
+		return err
 	}
+	defer fs.mu.Unlock()
 
-	parent, err := fs.navigateTo(dir)
+	parent, name, err := fs.resolve(path)
 	if err != nil {
 		return err
 	}
+	if name == "" {
+		return errors.New("el directorio ya existe: /")
+	}
 
 	parent.mu.Lock()
 	defer parent.mu.Unlock()
 
-	if _, exists := parent.children[name]; exists {
+	if _, _, exists := fs.child(parent, name); exists {
 		return errors.New("el directorio ya existe: " + name)
 	}
 
-	newDir := &Node{
-		name:     name,
-		nodeType: DirNode,
-		children: make(map[string]*Node),
-		parent:   parent,
-		mode:     mode,
-		modTime:  time.Now(),
-	}
+	newDir := fs.newNode(name, DirNode, parent, mode)
 
-	parent.children[name] = newDir
+	fs.addChild(parent, name, newDir)
+	parent.nlink++ // el ".." del nuevo directorio
 	parent.modTime = time.Now()
 
 	return nil
@@ -136,8 +252,11 @@ func (fs *FileSystem) CreateDir(path string, mode os.FileMode) error {
 
 // MkdirAll crea un directorio y todos sus padres si no existen
 func (fs *FileSystem) MkdirAll(path string, mode os.FileMode) error {
-	parts := fs.parsePath(path)
-	current := ""
+	parts, err := fs.policy.split(path)
+	if err != nil {
+		return err
+	}
+	current := "/"
 
 	for _, part := range parts {
 		current = filepath.Join(current, part)
@@ -152,75 +271,122 @@ func (fs *FileSystem) MkdirAll(path string, mode os.FileMode) error {
 	return nil
 }
 
-// CreateFile crea un nuevo archivo con contenido
+// CreateFile crea un nuevo archivo con contenido. Si ya existe, reemplaza
+// el contenido y los permisos.
 func (fs *FileSystem) CreateFile(path string, content []byte, mode os.FileMode) error {
-	fs.mu.Lock()
-	defer fs.mu.Unlock()
+	if m, rel := fs.mountFor(path); m != nil {
+		if err := m.writable("create", path); err != nil {
+			return err
+		}
+		return m.backend.CreateFile(rel, content, mode)
+	}
 
-	dir, name := filepath.Split(path)
-	if name == "" {
-		return errors.New("nombre de archivo vacío")
+	if err := fs.lock("create", path); err != nil {
+		return err
 	}
+	defer fs.mu.Unlock()
+
+	return fs.createFile(path, content, mode, true)
+}
 
-	parent, err := fs.navigateTo(dir)
+// createFile asume que el llamador ya tiene fs.mu tomado para escritura.
+// chmod indica si un archivo existente toma los permisos mode.
+func (fs *FileSystem) createFile(path string, content []byte, mode os.FileMode, chmod bool) error {
+	parent, name, err := fs.resolve(path)
 	if err != nil {
 		return err
 	}
+	if name == "" {
+		return errors.New("nombre de archivo vacío")
+	}
 
 	parent.mu.Lock()
 	defer parent.mu.Unlock()
 
-	if existing, exists := parent.children[name]; exists {
+	if existing, _, exists := fs.child(parent, name); exists {
 		if existing.nodeType == DirNode {
 			return errors.New("ya existe un directorio con ese nombre: " + name)
 		}
-		// Sobrescribir archivo existente
+		// Sobrescribir archivo existente. Con revisiones el contenido
+		// anterior tiene que estar en memoria para guardarlo.
+		unpin, err := fs.pin(existing, true)
+		if err != nil {
+			return err
+		}
+		defer unpin()
+
 		existing.mu.Lock()
-		existing.content = content
+		fs.keepVersion(existing)
+		existing.data = newSparseData(content)
 		existing.size = int64(len(content))
 		existing.modTime = time.Now()
+		if chmod {
+			existing.mode = mode.Perm()
+		}
 		existing.mu.Unlock()
 		return nil
 	}
 
-	newFile := &Node{
-		name:     name,
-		nodeType: FileNode,
-		content:  content,
-		parent:   parent,
-		mode:     mode,
-		modTime:  time.Now(),
-		size:     int64(len(content)),
-	}
+	fs.addFile(parent, name, content, mode)
+	return nil
+}
 
-	parent.children[name] = newFile
+// addFile crea un archivo nuevo name en parent. Se asume que fs.mu y
+// parent.mu están tomados para escritura.
+func (fs *FileSystem) addFile(parent *Node, name string, content []byte, mode os.FileMode) *Node {
+	// newSparseData copia el contenido, así que el llamador no puede
+	// modificarlo por fuera
+	newFile := fs.newNode(name, FileNode, parent, mode)
+	newFile.data = newSparseData(content)
+	newFile.size = int64(len(content))
+
+	fs.addChild(parent, name, newFile)
 	parent.modTime = time.Now()
+	fs.cached(newFile)
 
-	return nil
+	return newFile
 }
 
-// WriteFile escribe contenido en un archivo (lo crea si no existe)
+// WriteFile escribe contenido en un archivo (lo crea con permisos 0644 si
+// no existe). Como os.WriteFile, un archivo existente conserva sus
+// permisos.
 func (fs *FileSystem) WriteFile(path string, content []byte) error {
-	return fs.CreateFile(path, content, 0644)
+	if m, rel := fs.mountFor(path); m != nil {
+		if err := m.writable("create", path); err != nil {
+			return err
+		}
+		return m.backend.WriteFile(rel, content)
+	}
+
+	if err := fs.lock("create", path); err != nil {
+		return err
+	}
+	defer fs.mu.Unlock()
+
+	return fs.createFile(path, content, 0644, false)
 }
 
 // ReadFile lee el contenido de un archivo
 func (fs *FileSystem) ReadFile(path string) ([]byte, error) {
-	fs.mu.RLock()
-	defer fs.mu.RUnlock()
+	if m, rel := fs.mountFor(path); m != nil {
+		return m.backend.ReadFile(rel)
+	}
 
-	dir, name := filepath.Split(path)
+	if err := fs.rlock("read", path); err != nil {
+		return nil, err
+	}
+	defer fs.mu.RUnlock()
 
-	parent, err := fs.navigateTo(dir)
+	parent, name, err := fs.resolve(path)
 	if err != nil {
 		return nil, err
 	}
 
 	parent.mu.RLock()
-	node, exists := parent.children[name]
+	node, _, exists := fs.child(parent, name)
 	parent.mu.RUnlock()
 
-	if !exists {
+	if !exists || name == "" {
 		return nil, errors.New("archivo no encontrado: " + path)
 	}
 
@@ -228,19 +394,28 @@ func (fs *FileSystem) ReadFile(path string) ([]byte, error) {
 		return nil, errors.New("no es un archivo: " + path)
 	}
 
+	unpin, err := fs.pin(node, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unpin()
+
 	node.mu.RLock()
 	defer node.mu.RUnlock()
 
 	// Retornar una copia del contenido
-	content := make([]byte, len(node.content))
-	copy(content, node.content)
-
-	return content, nil
+	return node.data.bytes(node.size), nil
 }
 
-// ListDir lista el contenido de un directorio
+// ListDir lista el contenido de un directorio, ordenado por nombre
 func (fs *FileSystem) ListDir(path string) ([]FileInfo, error) {
-	fs.mu.RLock()
+	if m, rel := fs.mountFor(path); m != nil {
+		return m.backend.ListDir(rel)
+	}
+
+	if err := fs.rlock("readdir", path); err != nil {
+		return nil, err
+	}
 	defer fs.mu.RUnlock()
 
 	dir, err := fs.navigateTo(path)
@@ -251,16 +426,9 @@ func (fs *FileSystem) ListDir(path string) ([]FileInfo, error) {
 	dir.mu.RLock()
 	defer dir.mu.RUnlock()
 
-	var files []FileInfo
-	for _, child := range dir.children {
-		info := FileInfo{
-			Name:    child.name,
-			Size:    child.size,
-			Mode:    child.mode,
-			ModTime: child.modTime,
-			IsDir:   child.nodeType == DirNode,
-		}
-		files = append(files, info)
+	files := make([]FileInfo, 0, dir.index.len)
+	for e := dir.index.first(); e != nil; e = e.next[0] {
+		files = append(files, e.node.info(e.name))
 	}
 
 	return files, nil
@@ -268,23 +436,33 @@ func (fs *FileSystem) ListDir(path string) ([]FileInfo, error) {
 
 // Remove elimina un archivo o directorio vacío
 func (fs *FileSystem) Remove(path string) error {
-	fs.mu.Lock()
+	if m, rel := fs.mountFor(path); m != nil {
+		if err := m.writable("remove", path); err != nil {
+			return err
+		}
+		return m.backend.Remove(rel)
+	}
+
+	if err := fs.lock("remove", path); err != nil {
+		return err
+	}
 	defer fs.mu.Unlock()
 
-	dir, name := filepath.Split(path)
+	parent, name, err := fs.resolve(path)
+	if err != nil {
+		return err
+	}
 	if name == "" {
 		return errors.New("no se puede eliminar la raíz")
 	}
-
-	parent, err := fs.navigateTo(dir)
-	if err != nil {
+	if err := fs.busy("remove", path); err != nil {
 		return err
 	}
 
 	parent.mu.Lock()
 	defer parent.mu.Unlock()
 
-	node, exists := parent.children[name]
+	node, name, exists := fs.child(parent, name)
 	if !exists {
 		return errors.New("no existe: " + path)
 	}
@@ -294,87 +472,195 @@ func (fs *FileSystem) Remove(path string) error {
 		return errors.New("directorio no vacío: " + path)
 	}
 
-	delete(parent.children, name)
-	parent.modTime = time.Now()
+	fs.discard(node, parent, name, path)
 
 	return nil
 }
 
 // RemoveAll elimina un archivo o directorio y todo su contenido
 func (fs *FileSystem) RemoveAll(path string) error {
-	fs.mu.Lock()
-	defer fs.mu.Unlock()
-
-	if path == "/" || path == "" {
-		return errors.New("no se puede eliminar la raíz")
+	if m, rel := fs.mountFor(path); m != nil {
+		if err := m.writable("remove", path); err != nil {
+			return err
+		}
+		return m.backend.RemoveAll(rel)
 	}
 
-	dir, name := filepath.Split(path)
+	if err := fs.lock("remove", path); err != nil {
+		return err
+	}
+	defer fs.mu.Unlock()
 
-	parent, err := fs.navigateTo(dir)
+	parent, name, err := fs.resolve(path)
 	if err != nil {
 		return err
 	}
+	if name == "" {
+		return errors.New("no se puede eliminar la raíz")
+	}
+	if err := fs.busy("remove", path); err != nil {
+		return err
+	}
 
 	parent.mu.Lock()
 	defer parent.mu.Unlock()
 
-	if _, exists := parent.children[name]; !exists {
+	node, name, exists := fs.child(parent, name)
+	if !exists {
 		return errors.New("no existe: " + path)
 	}
 
-	delete(parent.children, name)
-	parent.modTime = time.Now()
+	// Con un contexto se vacía de abajo hacia arriba para poder cortar
+	// entre nodos. Con papelera no hace falta: mover es una sola operación.
+	if fs.ctx != nil && node.nodeType == DirNode && !fs.versioning.Trash {
+		if err := fs.emptyDir(node, path); err != nil {
+			return err
+		}
+	}
+
+	fs.discard(node, parent, name, path)
 
 	return nil
 }
 
-// Exists verifica si una ruta existe
-func (fs *FileSystem) Exists(path string) bool {
-	fs.mu.RLock()
-	defer fs.mu.RUnlock()
+// emptyDir elimina el contenido de dir de abajo hacia arriba y revisa el
+// contexto antes de cada nodo, así una cancelación deja un árbol
+// consistente con parte del contenido ya eliminado, como os.RemoveAll.
+// Se asume que fs.mu está tomado para escritura.
+func (fs *FileSystem) emptyDir(dir *Node, dirPath string) error {
+	for name, child := range dir.children {
+		childPath := filepath.Join(dirPath, name)
+		if err := fs.ctxErr("remove", childPath); err != nil {
+			return err
+		}
+		if child.nodeType == DirNode {
+			if err := fs.emptyDir(child, childPath); err != nil {
+				return err
+			}
+		}
+
+		dir.mu.Lock()
+		fs.removeChild(dir, name)
+		dir.modTime = time.Now()
+		dir.mu.Unlock()
+		fs.release(child, dir, name)
+	}
+	return nil
+}
+
+// release descuenta el enlace name de parent hacia node, que ya se quitó
+// del árbol. Los directorios liberan todo su subárbol; un archivo sólo
+// desaparece de la tabla de inodos cuando pierde su último enlace.
+// Se asume que fs.mu está tomado para escritura, lo que basta para
+// recorrer nodos sin sus candados.
+func (fs *FileSystem) release(node, parent *Node, name string) {
+	if node.nodeType == DirNode {
+		parent.nlink--
+		for childName, child := range node.children {
+			fs.release(child, node, childName)
+		}
+		delete(fs.inodes, node.ino)
+		return
+	}
+
+	node.nlink--
+	if node.nlink == 0 {
+		delete(fs.inodes, node.ino)
+		if node.opens == 0 {
+			fs.uncache(node)
+		} else {
+			fs.orphan(node)
+		}
+		return
+	}
 
-	if path == "/" || path == "" {
-		return true
+	// Si se eliminó el enlace principal buscamos otro que siga vivo
+	if node.parent == parent && node.name == name {
+		fs.relink(fs.root, node)
 	}
+}
 
-	dir, name := filepath.Split(path)
+// relink busca desde dir una entrada que apunte a target y la convierte en
+// su enlace principal
+func (fs *FileSystem) relink(dir, target *Node) bool {
+	for name, child := range dir.children {
+		if child == target {
+			target.parent = dir
+			target.name = name
+			return true
+		}
+		if child.nodeType == DirNode && fs.relink(child, target) {
+			return true
+		}
+	}
+	return false
+}
 
-	parent, err := fs.navigateTo(dir)
+// lookup localiza un nodo (archivo o directorio) a partir de su ruta.
+// Se asume que el llamador ya tiene fs.mu tomado.
+func (fs *FileSystem) lookup(path string) (*Node, error) {
+	parent, name, err := fs.resolve(path)
 	if err != nil {
-		return false
+		return nil, err
+	}
+	if name == "" {
+		return fs.root, nil
 	}
 
 	parent.mu.RLock()
-	_, exists := parent.children[name]
+	node, _, exists := fs.child(parent, name)
 	parent.mu.RUnlock()
 
-	return exists
+	if !exists {
+		return nil, errors.New("no existe: " + path)
+	}
+
+	return node, nil
 }
 
-// Stat obtiene información de un archivo/directorio
-func (fs *FileSystem) Stat(path string) (FileInfo, error) {
-	fs.mu.RLock()
+// Exists verifica si una ruta existe
+func (fs *FileSystem) Exists(path string) bool {
+	if m, rel := fs.mountFor(path); m != nil {
+		return m.backend.Exists(rel)
+	}
+
+	if fs.rlock("stat", path) != nil {
+		return false
+	}
 	defer fs.mu.RUnlock()
 
-	if path == "/" || path == "" {
-		return FileInfo{
-			Name:    "/",
-			Mode:    fs.root.mode,
-			ModTime: fs.root.modTime,
-			IsDir:   true,
-		}, nil
+	_, err := fs.lookup(path)
+	return err == nil
+}
+
+// Stat obtiene información de un archivo/directorio
+func (fs *FileSystem) Stat(path string) (FileInfo, error) {
+	if m, rel := fs.mountFor(path); m != nil {
+		info, err := m.backend.Stat(rel)
+		if err == nil && rel == "/" {
+			// La raíz del montaje se ve con el nombre del punto de montaje
+			info.Name = filepath.Base(m.path)
+		}
+		return info, err
 	}
 
-	dir, name := filepath.Split(path)
+	if err := fs.rlock("stat", path); err != nil {
+		return FileInfo{}, err
+	}
+	defer fs.mu.RUnlock()
 
-	parent, err := fs.navigateTo(dir)
+	parent, name, err := fs.resolve(path)
 	if err != nil {
 		return FileInfo{}, err
 	}
+	if name == "" {
+		fs.root.mu.RLock()
+		defer fs.root.mu.RUnlock()
+		return fs.root.info("/"), nil
+	}
 
 	parent.mu.RLock()
-	node, exists := parent.children[name]
+	node, name, exists := fs.child(parent, name)
 	parent.mu.RUnlock()
 
 	if !exists {
@@ -384,56 +670,77 @@ func (fs *FileSystem) Stat(path string) (FileInfo, error) {
 	node.mu.RLock()
 	defer node.mu.RUnlock()
 
-	return FileInfo{
-		Name:    node.name,
-		Size:    node.size,
-		Mode:    node.mode,
-		ModTime: node.modTime,
-		IsDir:   node.nodeType == DirNode,
-	}, nil
+	return node.info(name), nil
 }
 
-// Walk recorre el árbol de archivos
+// Walk recorre el árbol de archivos. Los montajes que encuentra se
+// recorren al final, ya sin el candado de este sistema de archivos.
 func (fs *FileSystem) Walk(path string, walkFn func(path string, info FileInfo) error) error {
-	fs.mu.RLock()
-	defer fs.mu.RUnlock()
+	if m, rel := fs.mountFor(path); m != nil {
+		return m.walk(rel, walkFn)
+	}
 
-	startNode := fs.root
-	if path != "/" && path != "" {
-		dir, name := filepath.Split(path)
-		parent, err := fs.navigateTo(dir)
-		if err != nil {
+	var mounts []*mount
+	if err := fs.walkTree(path, walkFn, &mounts); err != nil {
+		return err
+	}
+
+	for _, m := range mounts {
+		if err := fs.ctxErr("walk", m.path); err != nil {
+			return err
+		}
+		if err := m.walk("/", walkFn); err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
+// walkTree recorre el árbol propio y junta en mounts los puntos de montaje
+// que encuentra en lugar de bajar por ellos
+func (fs *FileSystem) walkTree(path string, walkFn func(path string, info FileInfo) error, mounts *[]*mount) error {
+	if err := fs.rlock("walk", path); err != nil {
+		return err
+	}
+	defer fs.mu.RUnlock()
+
+	parent, name, err := fs.resolve(path)
+	if err != nil {
+		return err
+	}
+
+	startNode, startName := fs.root, fs.root.name
+	if name != "" {
 		parent.mu.RLock()
-		node, exists := parent.children[name]
+		node, name, exists := fs.child(parent, name)
 		parent.mu.RUnlock()
 
 		if !exists {
 			return errors.New("no existe: " + path)
 		}
-		startNode = node
+		startNode, startName = node, name
 	}
 
-	return fs.walkRecursive(path, startNode, walkFn)
+	return fs.walkRecursive(path, startName, startNode, walkFn, mounts)
 }
 
-func (fs *FileSystem) walkRecursive(path string, node *Node, walkFn func(string, FileInfo) error) error {
-	node.mu.RLock()
-	info := FileInfo{
-		Name:    node.name,
-		Size:    node.size,
-		Mode:    node.mode,
-		ModTime: node.modTime,
-		IsDir:   node.nodeType == DirNode,
+func (fs *FileSystem) walkRecursive(path, name string, node *Node, walkFn func(string, FileInfo) error, mounts *[]*mount) error {
+	if err := fs.ctxErr("walk", path); err != nil {
+		return err
 	}
 
-	children := make([]*Node, 0, len(node.children))
-	childNames := make([]string, 0, len(node.children))
-	for name, child := range node.children {
-		children = append(children, child)
-		childNames = append(childNames, name)
+	node.mu.RLock()
+	info := node.info(name)
+
+	var children []*Node
+	var childNames []string
+	if node.nodeType == DirNode {
+		children = make([]*Node, 0, node.index.len)
+		childNames = make([]string, 0, node.index.len)
+		for e := node.index.first(); e != nil; e = e.next[0] {
+			children = append(children, e.node)
+			childNames = append(childNames, e.name)
+		}
 	}
 	node.mu.RUnlock()
 
@@ -444,7 +751,11 @@ func (fs *FileSystem) walkRecursive(path string, node *Node, walkFn func(string,
 	if node.nodeType == DirNode {
 		for i, child := range children {
 			childPath := filepath.Join(path, childNames[i])
-			if err := fs.walkRecursive(childPath, child, walkFn); err != nil {
+			if m := fs.mountAt(filepath.Clean("/" + childPath)); m != nil {
+				*mounts = append(*mounts, m)
+				continue
+			}
+			if err := fs.walkRecursive(childPath, childNames[i], child, walkFn, mounts); err != nil {
 				return err
 			}
 		}
@@ -455,53 +766,87 @@ func (fs *FileSystem) walkRecursive(path string, node *Node, walkFn func(string,
 
 // AppendFile añade contenido al final de un archivo existente
 func (fs *FileSystem) AppendFile(path string, content []byte) error {
-	fs.mu.Lock()
-	defer fs.mu.Unlock()
+	if m, rel := fs.mountFor(path); m != nil {
+		if err := m.writable("append", path); err != nil {
+			return err
+		}
+		return m.backend.AppendFile(rel, content)
+	}
 
-	dir, name := filepath.Split(path)
+	if err := fs.lock("append", path); err != nil {
+		return err
+	}
+	defer fs.mu.Unlock()
 
-	parent, err := fs.navigateTo(dir)
+	parent, name, err := fs.resolve(path)
 	if err != nil {
 		return err
 	}
 
 	parent.mu.RLock()
-	node, exists := parent.children[name]
+	node, _, exists := fs.child(parent, name)
 	parent.mu.RUnlock()
 
 	if !exists {
 		// Si no existe, lo creamos
-		return fs.CreateFile(path, content, 0644)
+		return fs.createFile(path, content, 0644, false)
 	}
 
 	if node.nodeType != FileNode {
 		return errors.New("no es un archivo: " + path)
 	}
 
+	unpin, err := fs.pin(node, true)
+	if err != nil {
+		return err
+	}
+	defer unpin()
+
 	node.mu.Lock()
 	defer node.mu.Unlock()
 
-	node.content = append(node.content, content...)
-	node.size = int64(len(node.content))
+	node.data.writeAt(content, node.size)
+	node.size += int64(len(content))
 	node.modTime = time.Now()
 
 	return nil
 }
 
-// Rename mueve o renombra un archivo o directorio
+// Rename mueve o renombra un archivo o directorio. Un destino existente se
+// reemplaza en un solo paso, como rename(2): nunca hay un momento en que
+// newPath no exista.
 func (fs *FileSystem) Rename(oldPath, newPath string) error {
-	fs.mu.Lock()
+	oldMount, oldRel := fs.mountFor(oldPath)
+	newMount, newRel := fs.mountFor(newPath)
+	if oldMount != newMount {
+		return crossDevice("rename", oldPath, newPath)
+	}
+	if oldMount != nil {
+		if err := oldMount.writable("rename", oldPath); err != nil {
+			return err
+		}
+		return oldMount.backend.Rename(oldRel, newRel)
+	}
+
+	if err := fs.lock("rename", oldPath); err != nil {
+		return err
+	}
 	defer fs.mu.Unlock()
 
 	// Obtener el nodo origen
-	oldDir, oldName := filepath.Split(oldPath)
-	oldParent, err := fs.navigateTo(oldDir)
+	oldParent, oldName, err := fs.resolve(oldPath)
 	if err != nil {
 		return err
 	}
+	if oldName == "" {
+		return errors.New("no se puede mover la raíz")
+	}
+	if err := fs.busy("rename", oldPath); err != nil {
+		return err
+	}
 
 	oldParent.mu.Lock()
-	node, exists := oldParent.children[oldName]
+	node, oldName, exists := fs.child(oldParent, oldName)
 	if !exists {
 		oldParent.mu.Unlock()
 		return errors.New("origen no existe: " + oldPath)
@@ -509,77 +854,369 @@ func (fs *FileSystem) Rename(oldPath, newPath string) error {
 	oldParent.mu.Unlock()
 
 	// Obtener el directorio destino
-	newDir, newName := filepath.Split(newPath)
-	newParent, err := fs.navigateTo(newDir)
+	newParent, newName, err := fs.resolve(newPath)
 	if err != nil {
 		return err
 	}
+	if newName == "" {
+		return errors.New("destino ya existe: " + newPath)
+	}
 
 	// Si es el mismo directorio y mismo nombre, no hacer nada
 	if oldParent == newParent && oldName == newName {
 		return nil
 	}
 
-	// Verificar que el destino no exista
+	// Un directorio no puede quedar dentro de sí mismo: el árbol tendría un
+	// ciclo desconectado de la raíz
+	if node.nodeType == DirNode {
+		for dir := newParent; dir != nil; dir = dir.parent {
+			if dir == node {
+				return errors.New("no se puede mover un directorio dentro de sí mismo: " + oldPath)
+			}
+		}
+	}
+
+	// Si el destino existe se reemplaza, como rename(2). Sin distinguir
+	// mayúsculas el destino puede ser la misma entrada con otra forma
+	// ("a" -> "A"), y eso es sólo un cambio de nombre.
 	newParent.mu.Lock()
-	if _, exists := newParent.children[newName]; exists {
-		newParent.mu.Unlock()
-		return errors.New("destino ya existe: " + newPath)
+	if target, storedName, exists := fs.child(newParent, newName); exists &&
+		(newParent != oldParent || storedName != oldName) {
+		if target == node {
+			// Dos enlaces al mismo archivo: rename(2) no hace nada
+			newParent.mu.Unlock()
+			return nil
+		}
+		if err := fs.replaceable(node, target, newPath); err != nil {
+			newParent.mu.Unlock()
+			return err
+		}
+		if fs.versioning.MaxVersions > 0 && node.nodeType == FileNode &&
+			target.nodeType == FileNode && target.nlink == 1 {
+			// Con revisiones, reemplazar un archivo es sobrescribirlo: el
+			// contenido anterior se guarda como revisión y no va a la
+			// papelera
+			if err := fs.inherit(node, target); err != nil {
+				newParent.mu.Unlock()
+				return err
+			}
+			fs.removeChild(newParent, storedName)
+			fs.release(target, newParent, storedName)
+		} else {
+			fs.discard(target, newParent, storedName, newPath)
+		}
 	}
 
-	// Mover el nodo
-	oldParent.mu.Lock()
-	delete(oldParent.children, oldName)
+	// Lo que sale de la papelera deja de estar disponible para Undelete, y
+	// si se mueve la papelera entera pasa a ser un directorio cualquiera
+	if trash := fs.trashNode(); node == trash {
+		fs.trash = nil
+	} else if oldParent == trash {
+		delete(fs.trash, oldName)
+	}
+
+	// Mover el nodo (si el padre es el mismo ya tenemos su candado)
+	if oldParent != newParent {
+		oldParent.mu.Lock()
+	}
+	fs.removeChild(oldParent, oldName)
 	oldParent.modTime = time.Now()
-	oldParent.mu.Unlock()
+	if oldParent != newParent {
+		oldParent.mu.Unlock()
+	}
 
+	// Sólo se reasigna el nodo movido: sus hijos apuntan a él por puntero,
+	// así que mover un directorio no toca su subárbol
+	if node.nodeType == DirNode && oldParent != newParent {
+		oldParent.nlink--
+		newParent.nlink++
+	}
 	node.name = newName
 	node.parent = newParent
-	newParent.children[newName] = node
+	fs.addChild(newParent, newName, node)
 	newParent.modTime = time.Now()
 	newParent.mu.Unlock()
 
 	return nil
 }
 
-// Size calcula el tamaño total de un directorio o archivo
-func (fs *FileSystem) Size(path string) (int64, error) {
-	fs.mu.RLock()
-	defer fs.mu.RUnlock()
+// replaceable verifica que Rename pueda reemplazar target con node: un
+// archivo sólo reemplaza a otro archivo y un directorio sólo a un
+// directorio vacío que no sea punto de montaje
+func (fs *FileSystem) replaceable(node, target *Node, path string) error {
+	if node.nodeType != DirNode && target.nodeType == DirNode {
+		return errors.New("el destino es un directorio: " + path)
+	}
+	if node.nodeType == DirNode && target.nodeType != DirNode {
+		return errors.New("el destino no es un directorio: " + path)
+	}
+	if target.nodeType == DirNode && len(target.children) > 0 {
+		return errors.New("directorio no vacío: " + path)
+	}
+	return fs.busy("rename", path)
+}
 
-	if path == "/" || path == "" {
-		return fs.sizeRecursive(fs.root), nil
+// Size calcula el tamaño total de un directorio o archivo, incluyendo lo
+// que haya montado por debajo
+func (fs *FileSystem) Size(path string) (int64, error) {
+	if m, rel := fs.mountFor(path); m != nil {
+		return m.backend.Size(rel)
 	}
 
-	dir, name := filepath.Split(path)
-	parent, err := fs.navigateTo(dir)
+	total, err := fs.size(path)
 	if err != nil {
 		return 0, err
 	}
 
-	parent.mu.RLock()
-	node, exists := parent.children[name]
-	parent.mu.RUnlock()
+	for _, m := range fs.mountsUnder(path) {
+		size, err := m.backend.Size("/")
+		if err != nil {
+			return 0, err
+		}
+		total += size
+	}
+	return total, nil
+}
 
-	if !exists {
-		return 0, errors.New("no existe: " + path)
+func (fs *FileSystem) size(path string) (int64, error) {
+	if err := fs.rlock("size", path); err != nil {
+		return 0, err
+	}
+	defer fs.mu.RUnlock()
+
+	node, err := fs.lookup(path)
+	if err != nil {
+		return 0, err
 	}
 
-	return fs.sizeRecursive(node), nil
+	return fs.sizeRecursive(path, node)
 }
 
-func (fs *FileSystem) sizeRecursive(node *Node) int64 {
+func (fs *FileSystem) sizeRecursive(path string, node *Node) (int64, error) {
+	if err := fs.ctxErr("size", path); err != nil {
+		return 0, err
+	}
+
 	node.mu.RLock()
 	defer node.mu.RUnlock()
 
 	if node.nodeType == FileNode {
-		return node.size
+		return node.size, nil
 	}
 
 	var totalSize int64
-	for _, child := range node.children {
-		totalSize += fs.sizeRecursive(child)
+	for name, child := range node.children {
+		// Lo que tapa un montaje no se ve; el montaje lo suma Size
+		childPath := filepath.Join(path, name)
+		if fs.mountAt(filepath.Clean("/"+childPath)) != nil {
+			continue
+		}
+		size, err := fs.sizeRecursive(childPath, child)
+		if err != nil {
+			return 0, err
+		}
+		totalSize += size
+	}
+
+	return totalSize, nil
+}
+
+// SetModTime cambia la fecha de modificación de un archivo o directorio
+func (fs *FileSystem) SetModTime(path string, modTime time.Time) error {
+	if m, rel := fs.mountFor(path); m != nil {
+		if err := m.writable("chtimes", path); err != nil {
+			return err
+		}
+		return m.backend.SetModTime(rel, modTime)
 	}
 
-	return totalSize
-}
\ No newline at end of file
+	if err := fs.lock("chtimes", path); err != nil {
+		return err
+	}
+	defer fs.mu.Unlock()
+
+	node, err := fs.lookup(path)
+	if err != nil {
+		return err
+	}
+
+	node.mu.Lock()
+	node.modTime = modTime
+	node.mu.Unlock()
+
+	return nil
+}
+
+// Chmod cambia los permisos de un archivo o directorio sin tocar su
+// contenido ni su fecha de modificación
+func (fs *FileSystem) Chmod(path string, mode os.FileMode) error {
+	if m, rel := fs.mountFor(path); m != nil {
+		if err := m.writable("chmod", path); err != nil {
+			return err
+		}
+		c, ok := m.backend.(chmoder)
+		if !ok {
+			return errors.New("el sistema montado no permite cambiar permisos: " + path)
+		}
+		return c.Chmod(rel, mode)
+	}
+
+	if err := fs.lock("chmod", path); err != nil {
+		return err
+	}
+	defer fs.mu.Unlock()
+
+	node, err := fs.lookup(path)
+	if err != nil {
+		return err
+	}
+
+	node.mu.Lock()
+	node.mode = mode.Perm()
+	node.mu.Unlock()
+
+	return nil
+}
+
+// Chown cambia el dueño y el grupo de un archivo o directorio. Como en
+// os.Chown, un valor negativo deja ese campo como estaba. minifs no
+// verifica permisos: los ids sólo se guardan y se ven en StatT.
+func (fs *FileSystem) Chown(path string, uid, gid int) error {
+	if m, rel := fs.mountFor(path); m != nil {
+		if err := m.writable("chown", path); err != nil {
+			return err
+		}
+		c, ok := m.backend.(chowner)
+		if !ok {
+			return errors.New("el sistema montado no permite cambiar el dueño: " + path)
+		}
+		return c.Chown(rel, uid, gid)
+	}
+
+	if err := fs.lock("chown", path); err != nil {
+		return err
+	}
+	defer fs.mu.Unlock()
+
+	node, err := fs.lookup(path)
+	if err != nil {
+		return err
+	}
+
+	node.mu.Lock()
+	if uid >= 0 {
+		node.uid = uint32(uid)
+	}
+	if gid >= 0 {
+		node.gid = uint32(gid)
+	}
+	node.mu.Unlock()
+
+	return nil
+}
+
+// Link crea un enlace duro: newPath pasa a ser otro nombre del mismo
+// archivo que oldPath. Como en POSIX, no se permiten enlaces a directorios.
+func (fs *FileSystem) Link(oldPath, newPath string) error {
+	oldMount, oldRel := fs.mountFor(oldPath)
+	newMount, newRel := fs.mountFor(newPath)
+	if oldMount != newMount {
+		return crossDevice("link", oldPath, newPath)
+	}
+	if oldMount != nil {
+		if err := oldMount.writable("link", newPath); err != nil {
+			return err
+		}
+		target, ok := oldMount.backend.(linker)
+		if !ok {
+			return errors.New("el sistema montado no permite enlaces duros: " + newPath)
+		}
+		return target.Link(oldRel, newRel)
+	}
+
+	if err := fs.lock("link", newPath); err != nil {
+		return err
+	}
+	defer fs.mu.Unlock()
+
+	node, err := fs.lookup(oldPath)
+	if err != nil {
+		return err
+	}
+	if node.nodeType != FileNode {
+		return errors.New("no se permiten enlaces duros a directorios: " + oldPath)
+	}
+
+	parent, name, err := fs.resolve(newPath)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return errors.New("destino ya existe: " + newPath)
+	}
+
+	parent.mu.Lock()
+	defer parent.mu.Unlock()
+
+	if _, _, exists := fs.child(parent, name); exists {
+		return errors.New("destino ya existe: " + newPath)
+	}
+
+	node.mu.Lock()
+	node.nlink++
+	node.mu.Unlock()
+
+	fs.addChild(parent, name, node)
+	parent.modTime = time.Now()
+
+	return nil
+}
+
+// Truncate cambia el tamaño de un archivo. Al crecer no se reserva memoria:
+// la parte nueva es un hueco que se lee como ceros.
+func (fs *FileSystem) Truncate(path string, size int64) error {
+	if size < 0 {
+		return errors.New("tamaño negativo")
+	}
+	if m, rel := fs.mountFor(path); m != nil {
+		if err := m.writable("truncate", path); err != nil {
+			return err
+		}
+		return m.backend.Truncate(rel, size)
+	}
+
+	if err := fs.lock("truncate", path); err != nil {
+		return err
+	}
+	defer fs.mu.Unlock()
+
+	node, err := fs.lookup(path)
+	if err != nil {
+		return err
+	}
+	if node.nodeType != FileNode {
+		return errors.New("no es un archivo: " + path)
+	}
+
+	unpin, err := fs.pin(node, true)
+	if err != nil {
+		return err
+	}
+	defer unpin()
+
+	node.mu.Lock()
+	defer node.mu.Unlock()
+
+	node.truncate(size)
+	return nil
+}
+
+// truncate ajusta el tamaño del nodo. El llamador debe tener node.mu tomado.
+func (node *Node) truncate(size int64) {
+	if size < node.size {
+		node.data.truncate(size)
+	}
+	node.size = size
+	node.modTime = time.Now()
+}
@@ -3,13 +3,17 @@ package minifs
 import (
 	"bytes"
 	"fmt"
+	"os"
 	"sort"
 	"testing"
 	"time"
 )
 
 func TestFileSystemOperations(t *testing.T) {
-	fs := NewFileSystem()
+	testFileSystemOperations(t, newCheckedFS(t))
+}
+
+func testFileSystemOperations(t *testing.T, fs Backend) {
 
 	t.Run("CreateDir", func(t *testing.T) {
 		err := fs.CreateDir("/test", 0755)
@@ -179,6 +183,36 @@ func TestFileSystemOperations(t *testing.T) {
 		}
 	})
 
+	t.Run("RenameReplace", func(t *testing.T) {
+		fs.WriteFile("/test/nuevo.conf", []byte("nuevo"))
+		fs.WriteFile("/test/app.conf", []byte("viejo"))
+
+		if err := fs.Rename("/test/nuevo.conf", "/test/app.conf"); err != nil {
+			t.Fatalf("Rename debería reemplazar un archivo existente: %v", err)
+		}
+		if data, _ := fs.ReadFile("/test/app.conf"); string(data) != "nuevo" {
+			t.Errorf("Contenido tras reemplazar: %q", data)
+		}
+		if fs.Exists("/test/nuevo.conf") {
+			t.Error("El origen todavía existe")
+		}
+
+		fs.MkdirAll("/test/lleno/x", 0755)
+		fs.CreateDir("/test/vacio", 0755)
+		if err := fs.Rename("/test/app.conf", "/test/vacio"); err == nil {
+			t.Error("Un archivo no debería reemplazar a un directorio")
+		}
+		if err := fs.Rename("/test/vacio", "/test/app.conf"); err == nil {
+			t.Error("Un directorio no debería reemplazar a un archivo")
+		}
+		if err := fs.Rename("/test/vacio", "/test/lleno"); err == nil {
+			t.Error("Un directorio no debería reemplazar a uno con contenido")
+		}
+		if err := fs.Rename("/test/lleno", "/test/vacio"); err != nil || !fs.Exists("/test/vacio/x") {
+			t.Errorf("Un directorio debería reemplazar a uno vacío: %v", err)
+		}
+	})
+
 	t.Run("Size", func(t *testing.T) {
 		// Crear estructura de prueba
 		fs.MkdirAll("/size/test", 0755)
@@ -198,7 +232,10 @@ func TestFileSystemOperations(t *testing.T) {
 }
 
 func TestWalk(t *testing.T) {
-	fs := NewFileSystem()
+	testWalk(t, newCheckedFS(t))
+}
+
+func testWalk(t *testing.T, fs Backend) {
 
 	// Crear estructura de prueba
 	fs.MkdirAll("/walk/dir1/subdir", 0755)
@@ -237,7 +274,10 @@ func TestWalk(t *testing.T) {
 }
 
 func TestConcurrency(t *testing.T) {
-	fs := NewFileSystem()
+	testConcurrency(t, newCheckedFS(t))
+}
+
+func testConcurrency(t *testing.T, fs Backend) {
 	fs.MkdirAll("/concurrent/test", 0755)
 
 	// Crear múltiples archivos concurrentemente
@@ -280,7 +320,10 @@ func TestConcurrency(t *testing.T) {
 }
 
 func TestEdgeCases(t *testing.T) {
-	fs := NewFileSystem()
+	testEdgeCases(t, newCheckedFS(t))
+}
+
+func testEdgeCases(t *testing.T, fs Backend) {
 
 	t.Run("RootOperations", func(t *testing.T) {
 		// No se debe poder eliminar la raíz
@@ -349,7 +392,10 @@ func TestEdgeCases(t *testing.T) {
 }
 
 func TestMetadata(t *testing.T) {
-	fs := NewFileSystem()
+	testMetadata(t, newCheckedFS(t))
+}
+
+func testMetadata(t *testing.T, fs Backend) {
 
 	t.Run("ModificationTime", func(t *testing.T) {
 		// Crear archivo y verificar tiempo de modificación
@@ -406,6 +452,126 @@ func TestMetadata(t *testing.T) {
 			t.Errorf("Tamaño de directorio debería ser 0, got %d", info.Size)
 		}
 	})
+
+	t.Run("OverwriteMode", func(t *testing.T) {
+		fs.CreateFile("/modo.txt", []byte("uno"), 0644)
+
+		// CreateFile sobre un archivo existente aplica el modo nuevo
+		if err := fs.CreateFile("/modo.txt", []byte("dos"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		info, _ := fs.Stat("/modo.txt")
+		if info.Mode.Perm() != 0600 || info.Size != 3 {
+			t.Errorf("Sobrescritura con CreateFile: %v, %d bytes", info.Mode, info.Size)
+		}
+
+		// Sólo se guardan los permisos, nunca bits de tipo
+		fs.CreateFile("/modo.txt", []byte("dos"), os.ModeDir|os.ModeSymlink|0600)
+		info, _ = fs.Stat("/modo.txt")
+		if info.Mode != 0600 || info.IsDir {
+			t.Errorf("Sobrescritura con bits de tipo: %v", info.Mode)
+		}
+		fs.CreateFile("/tipo.txt", nil, os.ModeDir|0640)
+		if info, _ := fs.Stat("/tipo.txt"); info.Mode != 0640 || info.IsDir {
+			t.Errorf("Creación con bits de tipo: %v", info.Mode)
+		}
+
+		// WriteFile, como os.WriteFile, conserva el que tenía
+		fs.WriteFile("/modo.txt", []byte("tres!"))
+		info, _ = fs.Stat("/modo.txt")
+		if info.Mode.Perm() != 0600 || info.Size != 5 {
+			t.Errorf("Sobrescritura con WriteFile: %v, %d bytes", info.Mode, info.Size)
+		}
+	})
+
+	t.Run("Truncate", func(t *testing.T) {
+		fs.WriteFile("/trunc.txt", []byte("0123456789"))
+		old := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+		fs.SetModTime("/trunc.txt", old)
+
+		if err := fs.Truncate("/trunc.txt", 4); err != nil {
+			t.Fatal(err)
+		}
+		info, _ := fs.Stat("/trunc.txt")
+		if info.Size != 4 || !info.ModTime.After(old) {
+			t.Errorf("Truncate debería achicar y actualizar la fecha: %d %v", info.Size, info.ModTime)
+		}
+
+		fs.Truncate("/trunc.txt", 6)
+		if data, _ := fs.ReadFile("/trunc.txt"); string(data) != "0123\x00\x00" {
+			t.Errorf("Truncate al crecer debería rellenar con ceros: %q", data)
+		}
+		if err := fs.Truncate("/trunc.txt", -1); err == nil {
+			t.Error("Truncate con tamaño negativo debería fallar")
+		}
+	})
+
+	t.Run("ParentModTime", func(t *testing.T) {
+		fs.MkdirAll("/padre", 0755)
+		old := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+		touched := func(op string, change func(), want bool) {
+			t.Helper()
+			fs.SetModTime("/padre", old)
+			change()
+			info, _ := fs.Stat("/padre")
+			if got := !info.ModTime.Equal(old); got != want {
+				t.Errorf("%s: cambió la fecha del padre = %v, se esperaba %v", op, got, want)
+			}
+		}
+
+		// Cambian la fecha las operaciones que cambian las entradas del
+		// directorio, no las que sólo cambian el contenido de un archivo
+		touched("crear", func() { fs.WriteFile("/padre/a", []byte("a")) }, true)
+		touched("sobrescribir", func() { fs.WriteFile("/padre/a", []byte("b")) }, false)
+		touched("agregar", func() { fs.AppendFile("/padre/a", []byte("c")) }, false)
+		touched("truncar", func() { fs.Truncate("/padre/a", 0) }, false)
+		touched("renombrar", func() { fs.Rename("/padre/a", "/padre/b") }, true)
+		touched("mover afuera", func() { fs.Rename("/padre/b", "/b") }, true)
+		touched("mover adentro", func() { fs.Rename("/b", "/padre/b") }, true)
+		touched("crear directorio", func() { fs.CreateDir("/padre/d", 0755) }, true)
+		touched("eliminar", func() { fs.Remove("/padre/b") }, true)
+		touched("eliminar todo", func() { fs.RemoveAll("/padre/d") }, true)
+	})
+
+	t.Run("ChmodChown", func(t *testing.T) {
+		meta, ok := fs.(interface {
+			Chmod(path string, mode os.FileMode) error
+			Chown(path string, uid, gid int) error
+		})
+		if !ok {
+			t.Skip("el Backend no cambia permisos")
+		}
+
+		fs.WriteFile("/meta.txt", []byte("contenido"))
+		old := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+		fs.SetModTime("/meta.txt", old)
+
+		if err := meta.Chmod("/meta.txt", 0400); err != nil {
+			t.Fatal(err)
+		}
+		if err := meta.Chown("/meta.txt", 1000, 100); err != nil {
+			t.Fatal(err)
+		}
+		meta.Chown("/meta.txt", -1, 50)
+
+		info, _ := fs.Stat("/meta.txt")
+		st := info.Sys().(*StatT)
+		if info.Mode.Perm() != 0400 || st.Uid != 1000 || st.Gid != 50 {
+			t.Errorf("Metadatos: %v uid=%d gid=%d", info.Mode, st.Uid, st.Gid)
+		}
+		if !info.ModTime.Equal(old) || info.Size != 9 {
+			t.Errorf("Chmod y Chown no deberían tocar fecha ni contenido: %v %d", info.ModTime, info.Size)
+		}
+
+		fs.CreateDir("/metadir", 0755)
+		meta.Chmod("/metadir", 0700)
+		if info, _ := fs.Stat("/metadir"); info.Mode.Perm() != 0700 || !info.IsDir {
+			t.Errorf("Chmod de un directorio: %v", info.Mode)
+		}
+		if err := meta.Chmod("/no/existe", 0644); err == nil {
+			t.Error("Chmod de una ruta inexistente debería fallar")
+		}
+	})
 }
 
 func BenchmarkWriteFile(b *testing.B) {
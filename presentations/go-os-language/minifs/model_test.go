@@ -0,0 +1,366 @@
+package minifs
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"math/rand"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// modelSeed es fija para que go test sea reproducible; -model.seed=0 usa la
+// hora para explorar secuencias nuevas
+var modelSeed = flag.Int64("model.seed", 1, "semilla para TestModel; cero usa la hora")
+
+// modelOp es una operación generada al azar
+type modelOp struct {
+	name    string
+	path    string
+	newPath string
+	content []byte
+}
+
+func (op modelOp) String() string {
+	switch op.name {
+	case "Rename":
+		return fmt.Sprintf("Rename(%q, %q)", op.path, op.newPath)
+	case "CreateFile", "AppendFile":
+		return fmt.Sprintf("%s(%q, %q)", op.name, op.path, op.content)
+	}
+	return fmt.Sprintf("%s(%q)", op.name, op.path)
+}
+
+// model es la especificación de referencia: un mapa de rutas absolutas a
+// su contenido, con nil para los directorios
+type model map[string][]byte
+
+func newModel() model {
+	return model{"/": nil}
+}
+
+func (m model) isDir(p string) bool {
+	content, ok := m[p]
+	return ok && content == nil
+}
+
+// parentOK indica si el directorio que contendría p existe
+func (m model) parentOK(p string) bool {
+	return p != "/" && m.isDir(path.Dir(p))
+}
+
+// apply aplica op al modelo y devuelve si debería fallar
+func (m model) apply(op modelOp) bool {
+	p := op.path
+	switch op.name {
+	case "MkdirAll":
+		// Como MkdirAll: un componente que ya existe se salta, aunque sea
+		// un archivo, y fallan los que quedan debajo
+		parts := strings.Split(strings.TrimPrefix(p, "/"), "/")
+		current := "/"
+		for i, part := range parts {
+			current = path.Join(current, part)
+			if _, ok := m[current]; ok {
+				if !m.isDir(current) && i < len(parts)-1 {
+					return true
+				}
+				continue
+			}
+			m[current] = nil
+		}
+		return false
+
+	case "CreateFile":
+		if !m.parentOK(p) || m.isDir(p) {
+			return true
+		}
+		m[p] = append([]byte{}, op.content...)
+		return false
+
+	case "AppendFile":
+		if !m.parentOK(p) || m.isDir(p) {
+			return true
+		}
+		m[p] = append(append([]byte{}, m[p]...), op.content...)
+		return false
+
+	case "Remove", "RemoveAll":
+		if _, ok := m[p]; !ok || p == "/" {
+			return true
+		}
+		children := m.under(p)
+		if op.name == "Remove" && len(children) > 0 {
+			return true
+		}
+		for _, child := range children {
+			delete(m, child)
+		}
+		delete(m, p)
+		return false
+
+	case "Rename":
+		to := op.newPath
+		if _, ok := m[p]; !ok || p == "/" || !m.parentOK(to) {
+			return true
+		}
+		if p == to {
+			return false
+		}
+		if strings.HasPrefix(to, p+"/") {
+			return true
+		}
+		// Un destino existente se reemplaza si es del mismo tipo y, si es
+		// directorio, está vacío
+		if _, exists := m[to]; exists {
+			if m.isDir(p) != m.isDir(to) || len(m.under(to)) > 0 {
+				return true
+			}
+			delete(m, to)
+		}
+		for _, child := range m.under(p) {
+			m[to+strings.TrimPrefix(child, p)] = m[child]
+			delete(m, child)
+		}
+		m[to] = m[p]
+		delete(m, p)
+		return false
+	}
+	panic("operación desconocida: " + op.name)
+}
+
+// under devuelve las rutas que están por debajo de p
+func (m model) under(p string) []string {
+	var paths []string
+	for child := range m {
+		if child != p && isSubPath(p, child) {
+			paths = append(paths, child)
+		}
+	}
+	return paths
+}
+
+// run aplica op a fs con la misma semántica que apply
+func run(fs *FileSystem, op modelOp) error {
+	switch op.name {
+	case "MkdirAll":
+		return fs.MkdirAll(op.path, 0755)
+	case "CreateFile":
+		return fs.CreateFile(op.path, op.content, 0644)
+	case "AppendFile":
+		return fs.AppendFile(op.path, op.content)
+	case "Remove":
+		return fs.Remove(op.path)
+	case "RemoveAll":
+		return fs.RemoveAll(op.path)
+	case "Rename":
+		return fs.Rename(op.path, op.newPath)
+	}
+	panic("operación desconocida: " + op.name)
+}
+
+// snapshot lee el contenido completo de fs bajo root con el formato del
+// modelo
+func snapshot(fs *FileSystem, root string) (model, error) {
+	m := model{}
+	err := fs.Walk(root, func(p string, info FileInfo) error {
+		if info.IsDir {
+			m[p] = nil
+			return nil
+		}
+		content, err := fs.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		// El modelo usa nil para los directorios
+		m[p] = append([]byte{}, content...)
+		return nil
+	})
+	return m, err
+}
+
+func (m model) diff(other model) string {
+	var lines []string
+	for p, content := range m {
+		got, ok := other[p]
+		switch {
+		case !ok:
+			lines = append(lines, "falta "+p)
+		case (content == nil) != (got == nil) || !bytes.Equal(content, got):
+			lines = append(lines, fmt.Sprintf("%s: %q, el modelo dice %q", p, got, content))
+		}
+	}
+	for p := range other {
+		if _, ok := m[p]; !ok {
+			lines = append(lines, "sobra "+p)
+		}
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// genOps genera una secuencia de operaciones sobre rutas bajo root con
+// pocos nombres distintos, para que choquen a menudo
+func genOps(r *rand.Rand, root string, n int) []modelOp {
+	names := []string{"a", "b", "c"}
+	randPath := func() string {
+		p := root
+		for depth := 1 + r.Intn(3); depth > 0; depth-- {
+			p = path.Join(p, names[r.Intn(len(names))])
+		}
+		return p
+	}
+
+	kinds := []string{"MkdirAll", "MkdirAll", "CreateFile", "CreateFile", "AppendFile", "Rename", "Remove", "RemoveAll"}
+	ops := make([]modelOp, n)
+	for i := range ops {
+		op := modelOp{name: kinds[r.Intn(len(kinds))], path: randPath()}
+		switch op.name {
+		case "CreateFile", "AppendFile":
+			op.content = []byte(fmt.Sprint(r.Intn(100)))
+		case "Rename":
+			op.newPath = randPath()
+		}
+		ops[i] = op
+	}
+	return ops
+}
+
+// check ejecuta ops sobre un FileSystem nuevo y sobre el modelo, y
+// devuelve la primera discrepancia
+func check(ops []modelOp) string {
+	fs := NewFileSystem()
+	m := newModel()
+
+	for i, op := range ops {
+		wantErr := m.apply(op)
+		err := run(fs, op)
+		if (err != nil) != wantErr {
+			return fmt.Sprintf("paso %d, %v: error %v, el modelo esperaba error: %v", i, op, err, wantErr)
+		}
+	}
+
+	got, err := snapshot(fs, "/")
+	if err != nil {
+		return err.Error()
+	}
+	if diff := m.diff(got); diff != "" {
+		return "el árbol no coincide con el modelo:\n" + diff
+	}
+	if report := fs.Check(); !report.OK() {
+		return report.String()
+	}
+	return ""
+}
+
+// minimize quita operaciones de ops mientras la secuencia siga fallando,
+// primero en bloques grandes y luego de a una
+func minimize(ops []modelOp, fails func([]modelOp) bool) []modelOp {
+	for chunk := len(ops) / 2; chunk >= 1; chunk /= 2 {
+		for start := 0; start+chunk <= len(ops); {
+			candidate := append(append([]modelOp{}, ops[:start]...), ops[start+chunk:]...)
+			if fails(candidate) {
+				ops = candidate
+			} else {
+				start += chunk
+			}
+		}
+	}
+	return ops
+}
+
+func seed(t *testing.T) int64 {
+	s := *modelSeed
+	if s == 0 {
+		s = time.Now().UnixNano()
+	}
+	t.Logf("semilla %d (repetir con -model.seed=%d)", s, s)
+	return s
+}
+
+func TestModel(t *testing.T) {
+	r := rand.New(rand.NewSource(seed(t)))
+
+	sequences, length := 300, 60
+	if testing.Short() {
+		sequences = 50
+	}
+
+	for i := 0; i < sequences; i++ {
+		ops := genOps(r, "/", length)
+		if failure := check(ops); failure != "" {
+			ops = minimize(ops, func(ops []modelOp) bool { return check(ops) != "" })
+
+			var b strings.Builder
+			for _, op := range ops {
+				b.WriteString("\n  " + op.String())
+			}
+			t.Fatalf("Secuencia mínima que falla:%s\n%s", b.String(), check(ops))
+		}
+	}
+}
+
+func TestMinimize(t *testing.T) {
+	ops := genOps(rand.New(rand.NewSource(1)), "/", 40)
+	bad := modelOp{name: "Remove", path: "/x"}
+	ops = append(ops[:17], append([]modelOp{bad}, ops[17:]...)...)
+
+	got := minimize(ops, func(ops []modelOp) bool {
+		for _, op := range ops {
+			if op.String() == bad.String() {
+				return true
+			}
+		}
+		return false
+	})
+	if len(got) != 1 || got[0].String() != bad.String() {
+		t.Errorf("minimize dejó %v", got)
+	}
+}
+
+// TestModelConcurrent corre varias secuencias a la vez, cada una en su
+// propio subárbol, y compara cada subárbol con su modelo. Tiene sentido
+// sobre todo con -race.
+func TestModelConcurrent(t *testing.T) {
+	r := rand.New(rand.NewSource(seed(t)))
+	fs := newCheckedFS(t)
+
+	const workers = 8
+	sequences := make([][]modelOp, workers)
+	for w := range sequences {
+		root := fmt.Sprintf("/w%d", w)
+		fs.MkdirAll(root, 0755)
+		sequences[w] = genOps(r, root, 200)
+	}
+
+	var wg sync.WaitGroup
+	for w, ops := range sequences {
+		wg.Add(1)
+		go func(w int, ops []modelOp) {
+			defer wg.Done()
+
+			root := fmt.Sprintf("/w%d", w)
+			m := model{root: nil}
+			for i, op := range ops {
+				wantErr := m.apply(op)
+				if err := run(fs, op); (err != nil) != wantErr {
+					t.Errorf("%s, paso %d, %v: error %v, el modelo esperaba error: %v", root, i, op, err, wantErr)
+					return
+				}
+			}
+
+			got, err := snapshot(fs, root)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if diff := m.diff(got); diff != "" {
+				t.Errorf("%s no coincide con el modelo:\n%s", root, diff)
+			}
+		}(w, ops)
+	}
+	wg.Wait()
+}
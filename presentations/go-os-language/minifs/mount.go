@@ -0,0 +1,359 @@
+package minifs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// MountOptions configura un montaje
+type MountOptions struct {
+	// ReadOnly hace que toda escritura dentro del montaje falle con EROFS
+	ReadOnly bool
+}
+
+// mount es una entrada de la tabla de montajes
+type mount struct {
+	path     string
+	backend  Backend
+	readOnly bool
+}
+
+// MountInfo describe un montaje activo
+type MountInfo struct {
+	Path     string
+	Backend  Backend
+	ReadOnly bool
+}
+
+// Mount monta b en path, que debe ser un directorio existente. A partir de
+// ese momento toda operación sobre path o por debajo se resuelve en b, con
+// la ruta relativa al montaje; lo que había en el directorio queda oculto
+// hasta desmontar. Las rutas de los montajes se comparan tal cual, sin la
+// política de rutas.
+func (fs *FileSystem) Mount(path string, b Backend, opts MountOptions) error {
+	path = filepath.Clean("/" + path)
+	if path == "/" {
+		return errors.New("no se puede montar sobre la raíz")
+	}
+	if sameFS(b, fs) {
+		return errors.New("no se puede montar un sistema de archivos sobre sí mismo: " + path)
+	}
+
+	info, err := fs.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir {
+		return errors.New("no es un directorio: " + path)
+	}
+
+	fs.mountMu.Lock()
+	defer fs.mountMu.Unlock()
+
+	for _, m := range fs.mounts {
+		if m.path == path {
+			return errors.New("ya existe un montaje en " + path)
+		}
+	}
+
+	fs.mounts = append(fs.mounts, &mount{path: path, backend: b, readOnly: opts.ReadOnly})
+
+	// Los montajes más profundos primero, para que mountFor encuentre el
+	// más específico
+	sort.Slice(fs.mounts, func(i, j int) bool {
+		return len(fs.mounts[i].path) > len(fs.mounts[j].path)
+	})
+	return nil
+}
+
+// Bind monta el subárbol src del propio sistema de archivos también en
+// dst, como mount --bind. Ninguno de los dos puede estar dentro del otro:
+// llegar a src pasaría por el bind, que vuelve a llegar a src, sin fin.
+func (fs *FileSystem) Bind(src, dst string, opts MountOptions) error {
+	if !fs.Exists(src) {
+		return errors.New("no existe: " + src)
+	}
+	src, dst = filepath.Clean("/"+src), filepath.Clean("/"+dst)
+	if isSubPath(src, dst) || isSubPath(dst, src) {
+		return errors.New("no se puede montar un directorio dentro de sí mismo: " + dst)
+	}
+	return fs.Mount(dst, Sub(fs, src), opts)
+}
+
+// Unmount desmonta lo que esté montado en path. Falla si hay otros
+// montajes por debajo.
+func (fs *FileSystem) Unmount(path string) error {
+	path = filepath.Clean("/" + path)
+
+	fs.mountMu.Lock()
+	defer fs.mountMu.Unlock()
+
+	for i, m := range fs.mounts {
+		if m.path != path {
+			continue
+		}
+		for _, other := range fs.mounts {
+			if other != m && isSubPath(path, other.path) {
+				return &os.PathError{Op: "unmount", Path: path, Err: syscall.EBUSY}
+			}
+		}
+		fs.mounts = append(fs.mounts[:i], fs.mounts[i+1:]...)
+		return nil
+	}
+
+	return errors.New("no hay nada montado en " + path)
+}
+
+// Mounts devuelve los montajes activos ordenados por ruta
+func (fs *FileSystem) Mounts() []MountInfo {
+	fs.mountMu.RLock()
+	defer fs.mountMu.RUnlock()
+
+	infos := make([]MountInfo, 0, len(fs.mounts))
+	for _, m := range fs.mounts {
+		infos = append(infos, MountInfo{Path: m.path, Backend: m.backend, ReadOnly: m.readOnly})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Path < infos[j].Path })
+	return infos
+}
+
+// mountFor devuelve el montaje que contiene path y la ruta dentro de él,
+// o nil si path pertenece al propio sistema de archivos
+func (fs *FileSystem) mountFor(path string) (*mount, string) {
+	fs.mountMu.RLock()
+	defer fs.mountMu.RUnlock()
+
+	if len(fs.mounts) == 0 {
+		return nil, path
+	}
+
+	clean := filepath.Clean("/" + path)
+	for _, m := range fs.mounts {
+		if clean == m.path {
+			return m, "/"
+		}
+		if strings.HasPrefix(clean, m.path+"/") {
+			return m, clean[len(m.path):]
+		}
+	}
+	return nil, path
+}
+
+// mountAt devuelve el montaje cuyo punto es exactamente path
+func (fs *FileSystem) mountAt(path string) *mount {
+	fs.mountMu.RLock()
+	defer fs.mountMu.RUnlock()
+
+	for _, m := range fs.mounts {
+		if m.path == path {
+			return m
+		}
+	}
+	return nil
+}
+
+// mountsUnder devuelve los montajes cuyo punto está en path o por debajo
+func (fs *FileSystem) mountsUnder(path string) []*mount {
+	fs.mountMu.RLock()
+	defer fs.mountMu.RUnlock()
+
+	clean := filepath.Clean("/" + path)
+	var under []*mount
+	for _, m := range fs.mounts {
+		if isSubPath(clean, m.path) {
+			under = append(under, m)
+		}
+	}
+	return under
+}
+
+// busy devuelve EBUSY si path contiene un punto de montaje: eliminarlo o
+// moverlo dejaría el montaje colgando
+func (fs *FileSystem) busy(op, path string) error {
+	if len(fs.mountsUnder(path)) > 0 {
+		return &os.PathError{Op: op, Path: path, Err: syscall.EBUSY}
+	}
+	return nil
+}
+
+// writable devuelve EROFS si el montaje es de solo lectura
+func (m *mount) writable(op, path string) error {
+	if m.readOnly {
+		return &os.PathError{Op: op, Path: path, Err: syscall.EROFS}
+	}
+	return nil
+}
+
+// walk recorre rel dentro del montaje con las rutas vistas desde afuera
+func (m *mount) walk(rel string, walkFn func(path string, info FileInfo) error) error {
+	return m.backend.Walk(rel, func(p string, info FileInfo) error {
+		if filepath.Clean("/"+p) == "/" {
+			info.Name = filepath.Base(m.path)
+		}
+		return walkFn(filepath.Join(m.path, p), info)
+	})
+}
+
+// crossDevice es el error de Rename y Link entre montajes distintos
+func crossDevice(op, oldPath, newPath string) error {
+	return &os.LinkError{Op: op, Old: oldPath, New: newPath, Err: syscall.EXDEV}
+}
+
+// subFS expone el subárbol dir de un Backend como si fuera su raíz
+type subFS struct {
+	backend Backend
+	dir     string
+}
+
+var _ Backend = (*subFS)(nil)
+
+// Sub devuelve un Backend con la raíz en dir dentro de b. Es lo que usa
+// Bind, pero sirve también para montar el subárbol de otro sistema.
+func Sub(b Backend, dir string) Backend {
+	return &subFS{backend: b, dir: filepath.Clean("/" + dir)}
+}
+
+func (s *subFS) real(path string) string {
+	return filepath.Join(s.dir, filepath.Clean("/"+path))
+}
+
+func (s *subFS) Exists(path string) bool { return s.backend.Exists(s.real(path)) }
+
+func (s *subFS) Stat(path string) (FileInfo, error) { return s.backend.Stat(s.real(path)) }
+
+func (s *subFS) ListDir(path string) ([]FileInfo, error) { return s.backend.ListDir(s.real(path)) }
+
+func (s *subFS) ReadFile(path string) ([]byte, error) { return s.backend.ReadFile(s.real(path)) }
+
+func (s *subFS) CreateDir(path string, mode os.FileMode) error {
+	return s.backend.CreateDir(s.real(path), mode)
+}
+
+func (s *subFS) MkdirAll(path string, mode os.FileMode) error {
+	return s.backend.MkdirAll(s.real(path), mode)
+}
+
+func (s *subFS) CreateFile(path string, content []byte, mode os.FileMode) error {
+	return s.backend.CreateFile(s.real(path), content, mode)
+}
+
+func (s *subFS) WriteFile(path string, content []byte) error {
+	return s.backend.WriteFile(s.real(path), content)
+}
+
+func (s *subFS) AppendFile(path string, content []byte) error {
+	return s.backend.AppendFile(s.real(path), content)
+}
+
+func (s *subFS) Truncate(path string, size int64) error {
+	return s.backend.Truncate(s.real(path), size)
+}
+
+// Remove y RemoveAll no pueden borrar la raíz del subárbol, igual que no
+// se puede borrar "/"
+func (s *subFS) Remove(path string) error {
+	if filepath.Clean("/"+path) == "/" {
+		return errors.New("no se puede eliminar la raíz")
+	}
+	return s.backend.Remove(s.real(path))
+}
+
+func (s *subFS) RemoveAll(path string) error {
+	if filepath.Clean("/"+path) == "/" {
+		return errors.New("no se puede eliminar la raíz")
+	}
+	return s.backend.RemoveAll(s.real(path))
+}
+
+func (s *subFS) Rename(oldPath, newPath string) error {
+	return s.backend.Rename(s.real(oldPath), s.real(newPath))
+}
+
+// Walk traduce las rutas para que sean relativas al subárbol
+func (s *subFS) Walk(path string, walkFn func(path string, info FileInfo) error) error {
+	return s.backend.Walk(s.real(path), func(p string, info FileInfo) error {
+		rel := strings.TrimPrefix(p, s.dir)
+		if rel == "" || s.dir == "/" {
+			rel = filepath.Clean("/" + rel)
+		}
+		return walkFn(rel, info)
+	})
+}
+
+func (s *subFS) Size(path string) (int64, error) { return s.backend.Size(s.real(path)) }
+
+func (s *subFS) SetModTime(path string, modTime time.Time) error {
+	return s.backend.SetModTime(s.real(path), modTime)
+}
+
+// Link solo funciona si el Backend de abajo sabe crear enlaces duros
+func (s *subFS) Link(oldPath, newPath string) error {
+	if l, ok := s.backend.(linker); ok {
+		return l.Link(s.real(oldPath), s.real(newPath))
+	}
+	return errors.New("el sistema montado no permite enlaces duros: " + newPath)
+}
+
+// Open solo funciona si el Backend de abajo sabe abrir archivos
+func (s *subFS) Open(path string) (*File, error) {
+	if o, ok := s.backend.(opener); ok {
+		return o.Open(s.real(path))
+	}
+	return nil, errors.New("el sistema montado no permite abrir archivos: " + path)
+}
+
+// Chmod solo funciona si el Backend de abajo sabe cambiar permisos
+func (s *subFS) Chmod(path string, mode os.FileMode) error {
+	if c, ok := s.backend.(chmoder); ok {
+		return c.Chmod(s.real(path), mode)
+	}
+	return errors.New("el sistema montado no permite cambiar permisos: " + path)
+}
+
+// Chown solo funciona si el Backend de abajo sabe cambiar el dueño
+func (s *subFS) Chown(path string, uid, gid int) error {
+	if c, ok := s.backend.(chowner); ok {
+		return c.Chown(s.real(path), uid, gid)
+	}
+	return errors.New("el sistema montado no permite cambiar el dueño: " + path)
+}
+
+// CreateExclusive solo funciona si el Backend de abajo sabe crear en
+// exclusiva
+func (s *subFS) CreateExclusive(path string, mode os.FileMode) (*File, error) {
+	if c, ok := s.backend.(exclusiveCreator); ok {
+		return c.CreateExclusive(s.real(path), mode)
+	}
+	return nil, errors.New("el sistema montado no permite crear en exclusiva: " + path)
+}
+
+// opener es un Backend que además sabe abrir archivos, como *FileSystem
+type opener interface {
+	Open(path string) (*File, error)
+}
+
+// linker es un Backend que además sabe crear enlaces duros
+type linker interface {
+	Link(oldPath, newPath string) error
+}
+
+// chmoder es un Backend que además sabe cambiar permisos
+type chmoder interface {
+	Chmod(path string, mode os.FileMode) error
+}
+
+// chowner es un Backend que además sabe cambiar el dueño
+type chowner interface {
+	Chown(path string, uid, gid int) error
+}
+
+// exclusiveCreator es un Backend que además sabe crear archivos en
+// exclusiva, como *FileSystem
+type exclusiveCreator interface {
+	CreateExclusive(path string, mode os.FileMode) (*File, error)
+}
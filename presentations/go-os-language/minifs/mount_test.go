@@ -0,0 +1,242 @@
+package minifs
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"syscall"
+	"testing"
+)
+
+// newMountedFS arma una raíz con /tmp, /etc y /data montados
+func newMountedFS(t *testing.T) (root, tmp, etc *FileSystem) {
+	t.Helper()
+	root, tmp, etc = newCheckedFS(t), newCheckedFS(t), newCheckedFS(t)
+
+	root.MkdirAll("/tmp", 0755)
+	root.MkdirAll("/etc", 0755)
+	root.MkdirAll("/data/proyectos", 0755)
+	root.WriteFile("/data/proyectos/main.go", []byte("package main"))
+	etc.WriteFile("/hosts", []byte("127.0.0.1 localhost"))
+
+	if err := root.Mount("/tmp", tmp, MountOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.Mount("/etc", etc, MountOptions{ReadOnly: true}); err != nil {
+		t.Fatal(err)
+	}
+	return root, tmp, etc
+}
+
+func TestMount(t *testing.T) {
+	root, tmp, _ := newMountedFS(t)
+
+	if err := root.WriteFile("/tmp/a.txt", []byte("temporal")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := tmp.ReadFile("/a.txt"); string(data) != "temporal" {
+		t.Errorf("El archivo no llegó al sistema montado: %q", data)
+	}
+	if data, _ := root.ReadFile("/etc/hosts"); string(data) != "127.0.0.1 localhost" {
+		t.Errorf("Lectura a través del montaje: %q", data)
+	}
+
+	info, err := root.Stat("/tmp")
+	if err != nil || !info.IsDir || info.Name != "tmp" {
+		t.Errorf("Stat del punto de montaje: %+v %v", info, err)
+	}
+
+	if err := root.Mount("/tmp", NewFileSystem(), MountOptions{}); err == nil {
+		t.Error("Se montó dos veces en el mismo punto")
+	}
+	if err := root.Mount("/data/proyectos/main.go", NewFileSystem(), MountOptions{}); err == nil {
+		t.Error("Se montó sobre un archivo")
+	}
+
+	if err := root.Unmount("/tmp"); err != nil {
+		t.Fatal(err)
+	}
+	if root.Exists("/tmp/a.txt") {
+		t.Error("El contenido del montaje sigue visible tras desmontar")
+	}
+	if err := root.Unmount("/tmp"); err == nil {
+		t.Error("Se desmontó algo que no estaba montado")
+	}
+}
+
+func TestReadOnlyMount(t *testing.T) {
+	root, _, etc := newMountedFS(t)
+
+	writes := map[string]error{
+		"WriteFile":  root.WriteFile("/etc/passwd", nil),
+		"AppendFile": root.AppendFile("/etc/hosts", []byte("x")),
+		"CreateDir":  root.CreateDir("/etc/ssh", 0755),
+		"Remove":     root.Remove("/etc/hosts"),
+		"Truncate":   root.Truncate("/etc/hosts", 0),
+		"Rename":     root.Rename("/etc/hosts", "/etc/hosts.bak"),
+		"Chmod":      root.Chmod("/etc/hosts", 0600),
+		"Chown":      root.Chown("/etc/hosts", 1000, 1000),
+	}
+	for name, err := range writes {
+		if !errors.Is(err, syscall.EROFS) {
+			t.Errorf("%s en un montaje de solo lectura: %v", name, err)
+		}
+	}
+
+	f, err := root.Open("/etc/hosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if buf, err := io.ReadAll(f); err != nil || string(buf) != "127.0.0.1 localhost" {
+		t.Errorf("Lectura de un archivo abierto en solo lectura: %q %v", buf, err)
+	}
+	if _, err := f.Write([]byte("x")); !errors.Is(err, syscall.EROFS) {
+		t.Errorf("Write sobre un archivo abierto en solo lectura: %v", err)
+	}
+
+	if data, _ := etc.ReadFile("/hosts"); string(data) != "127.0.0.1 localhost" {
+		t.Errorf("El montaje de solo lectura cambió: %q", data)
+	}
+}
+
+func TestBindMount(t *testing.T) {
+	root, _, _ := newMountedFS(t)
+	root.MkdirAll("/home/ana/src", 0755)
+
+	if err := root.Bind("/data/proyectos", "/home/ana/src", MountOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := root.ReadFile("/home/ana/src/main.go"); string(data) != "package main" {
+		t.Errorf("Lectura a través del bind: %q", data)
+	}
+
+	// Las escrituras por un lado se ven por el otro
+	root.WriteFile("/home/ana/src/go.mod", []byte("module x"))
+	if !root.Exists("/data/proyectos/go.mod") {
+		t.Error("La escritura por el bind no llegó al original")
+	}
+
+	if err := root.Remove("/home"); !errors.Is(err, syscall.EBUSY) {
+		t.Errorf("Eliminar un directorio con un montaje debería dar EBUSY: %v", err)
+	}
+	if err := root.Rename("/home/ana", "/home/beto"); !errors.Is(err, syscall.EBUSY) {
+		t.Errorf("Mover un directorio con un montaje debería dar EBUSY: %v", err)
+	}
+	if err := root.Unmount("/home/ana/src"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Un bind dentro de su propio origen haría que Walk no termine
+	root.MkdirAll("/data/proyectos/a/b", 0755)
+	for _, dst := range []string{"/data/proyectos/a/b", "/data/proyectos", "/data"} {
+		if err := root.Bind("/data/proyectos", dst, MountOptions{}); err == nil {
+			t.Errorf("Bind de /data/proyectos en %s debería fallar", dst)
+		}
+	}
+	if _, err := root.Size("/"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRenameAcrossMounts(t *testing.T) {
+	root, _, _ := newMountedFS(t)
+	root.WriteFile("/tmp/a.txt", []byte("a"))
+
+	for _, paths := range [][2]string{
+		{"/tmp/a.txt", "/data/a.txt"},
+		{"/data/proyectos/main.go", "/tmp/main.go"},
+		{"/tmp/a.txt", "/etc/a.txt"},
+	} {
+		if err := root.Rename(paths[0], paths[1]); !errors.Is(err, syscall.EXDEV) {
+			t.Errorf("Rename(%s, %s) debería dar EXDEV: %v", paths[0], paths[1], err)
+		}
+		if err := root.Link(paths[0], paths[1]); !errors.Is(err, syscall.EXDEV) {
+			t.Errorf("Link(%s, %s) debería dar EXDEV: %v", paths[0], paths[1], err)
+		}
+	}
+
+	// Dentro de un mismo montaje funciona
+	if err := root.Rename("/tmp/a.txt", "/tmp/b.txt"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWalkThroughMounts(t *testing.T) {
+	root, tmp, _ := newMountedFS(t)
+	tmp.MkdirAll("/cache", 0755)
+	tmp.WriteFile("/cache/x", []byte("12345"))
+
+	var paths []string
+	root.Walk("/", func(path string, info FileInfo) error {
+		paths = append(paths, path)
+		return nil
+	})
+	sort.Strings(paths)
+
+	want := []string{"/", "/data", "/data/proyectos", "/data/proyectos/main.go",
+		"/etc", "/etc/hosts", "/tmp", "/tmp/cache", "/tmp/cache/x"}
+	if len(paths) != len(want) {
+		t.Fatalf("Walk visitó %v, se esperaba %v", paths, want)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Errorf("Walk visitó %s, se esperaba %s", paths[i], want[i])
+		}
+	}
+
+	size, _ := root.Size("/")
+	if want := int64(len("package main") + len("127.0.0.1 localhost") + 5); size != want {
+		t.Errorf("Size debería sumar los montajes: %d, se esperaba %d", size, want)
+	}
+
+	// Lo que queda tapado por un montaje no se cuenta
+	if err := root.Mount("/data/proyectos", newCheckedFS(t), MountOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	size, _ = root.Size("/")
+	if want := int64(len("127.0.0.1 localhost") + 5); size != want {
+		t.Errorf("Size contó lo que tapa un montaje: %d, se esperaba %d", size, want)
+	}
+}
+
+func TestMountChmod(t *testing.T) {
+	root, tmp, _ := newMountedFS(t)
+	tmp.WriteFile("/a.txt", nil)
+
+	if err := root.Chmod("/tmp/a.txt", 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.Chown("/tmp/a.txt", 1000, -1); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := tmp.Stat("/a.txt")
+	if st := info.Sys().(*StatT); info.Mode != 0600 || st.Uid != 1000 || st.Gid != 0 {
+		t.Errorf("Chmod y Chown no llegaron al sistema montado: %v %d:%d", info.Mode, st.Uid, st.Gid)
+	}
+
+	// Las envolturas los pasan al Backend de abajo
+	wrappers := map[string]func(Backend) Backend{
+		"FaultFS":        func(b Backend) Backend { return NewFaultFS(b, 1) },
+		"InstrumentedFS": func(b Backend) Backend { return Instrument(b) },
+		"ThrottledFS":    func(b Backend) Backend { return NewThrottledFS(b, ThrottleOptions{Clock: NewFakeClock(epoch)}) },
+	}
+	for name, wrap := range wrappers {
+		inner := newCheckedFS(t)
+		inner.WriteFile("/b.txt", nil)
+		root.MkdirAll("/"+name, 0755)
+		if err := root.Mount("/"+name, wrap(inner), MountOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := root.Chmod("/"+name+"/b.txt", 0400); err != nil {
+			t.Errorf("Chmod a través de %s: %v", name, err)
+		}
+		if err := root.Chown("/"+name+"/b.txt", 7, 8); err != nil {
+			t.Errorf("Chown a través de %s: %v", name, err)
+		}
+		info, _ := inner.Stat("/b.txt")
+		if st := info.Sys().(*StatT); info.Mode != 0400 || st.Uid != 7 || st.Gid != 8 {
+			t.Errorf("%s no pasó Chmod y Chown: %v %d:%d", name, info.Mode, st.Uid, st.Gid)
+		}
+	}
+}
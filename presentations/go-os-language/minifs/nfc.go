@@ -0,0 +1,293 @@
+package minifs
+
+// composeNFC compone las secuencias de letra base más marca combinante en
+// su carácter precompuesto, que es lo que hace la forma NFC de Unicode para
+// el alfabeto latino. La biblioteca estándar no incluye normalización, así
+// que nos limitamos a las letras de Latin-1 y Latin Extended-A/B (U+00C0 a
+// U+024F); otros alfabetos pasan sin cambios.
+func composeNFC(s string) string {
+	// La mayoría de los nombres ya están compuestos: evitamos copiar
+	if !hasCombining(s) {
+		return s
+	}
+
+	out := make([]rune, 0, len(s))
+	for _, r := range s {
+		if n := len(out); n > 0 {
+			if composed, ok := nfcPairs[[2]rune{out[n-1], r}]; ok {
+				out[n-1] = composed
+				continue
+			}
+		}
+		out = append(out, r)
+	}
+	return string(out)
+}
+
+// hasCombining indica si s tiene alguna marca combinante del rango U+0300
+// a U+036F
+func hasCombining(s string) bool {
+	for _, r := range s {
+		if r >= 0x300 && r <= 0x36F {
+			return true
+		}
+	}
+	return false
+}
+
+// nfcPairs mapea letra base y marca combinante al carácter precompuesto.
+// Generada a partir de las descomposiciones canónicas de UnicodeData.txt.
+var nfcPairs = map[[2]rune]rune{
+	{'A', 0x0300}: 'À', // latin capital letter a with grave
+	{'A', 0x0301}: 'Á', // latin capital letter a with acute
+	{'A', 0x0302}: 'Â', // latin capital letter a with circumflex
+	{'A', 0x0303}: 'Ã', // latin capital letter a with tilde
+	{'A', 0x0308}: 'Ä', // latin capital letter a with diaeresis
+	{'A', 0x030A}: 'Å', // latin capital letter a with ring above
+	{'C', 0x0327}: 'Ç', // latin capital letter c with cedilla
+	{'E', 0x0300}: 'È', // latin capital letter e with grave
+	{'E', 0x0301}: 'É', // latin capital letter e with acute
+	{'E', 0x0302}: 'Ê', // latin capital letter e with circumflex
+	{'E', 0x0308}: 'Ë', // latin capital letter e with diaeresis
+	{'I', 0x0300}: 'Ì', // latin capital letter i with grave
+	{'I', 0x0301}: 'Í', // latin capital letter i with acute
+	{'I', 0x0302}: 'Î', // latin capital letter i with circumflex
+	{'I', 0x0308}: 'Ï', // latin capital letter i with diaeresis
+	{'N', 0x0303}: 'Ñ', // latin capital letter n with tilde
+	{'O', 0x0300}: 'Ò', // latin capital letter o with grave
+	{'O', 0x0301}: 'Ó', // latin capital letter o with acute
+	{'O', 0x0302}: 'Ô', // latin capital letter o with circumflex
+	{'O', 0x0303}: 'Õ', // latin capital letter o with tilde
+	{'O', 0x0308}: 'Ö', // latin capital letter o with diaeresis
+	{'U', 0x0300}: 'Ù', // latin capital letter u with grave
+	{'U', 0x0301}: 'Ú', // latin capital letter u with acute
+	{'U', 0x0302}: 'Û', // latin capital letter u with circumflex
+	{'U', 0x0308}: 'Ü', // latin capital letter u with diaeresis
+	{'Y', 0x0301}: 'Ý', // latin capital letter y with acute
+	{'a', 0x0300}: 'à', // latin small letter a with grave
+	{'a', 0x0301}: 'á', // latin small letter a with acute
+	{'a', 0x0302}: 'â', // latin small letter a with circumflex
+	{'a', 0x0303}: 'ã', // latin small letter a with tilde
+	{'a', 0x0308}: 'ä', // latin small letter a with diaeresis
+	{'a', 0x030A}: 'å', // latin small letter a with ring above
+	{'c', 0x0327}: 'ç', // latin small letter c with cedilla
+	{'e', 0x0300}: 'è', // latin small letter e with grave
+	{'e', 0x0301}: 'é', // latin small letter e with acute
+	{'e', 0x0302}: 'ê', // latin small letter e with circumflex
+	{'e', 0x0308}: 'ë', // latin small letter e with diaeresis
+	{'i', 0x0300}: 'ì', // latin small letter i with grave
+	{'i', 0x0301}: 'í', // latin small letter i with acute
+	{'i', 0x0302}: 'î', // latin small letter i with circumflex
+	{'i', 0x0308}: 'ï', // latin small letter i with diaeresis
+	{'n', 0x0303}: 'ñ', // latin small letter n with tilde
+	{'o', 0x0300}: 'ò', // latin small letter o with grave
+	{'o', 0x0301}: 'ó', // latin small letter o with acute
+	{'o', 0x0302}: 'ô', // latin small letter o with circumflex
+	{'o', 0x0303}: 'õ', // latin small letter o with tilde
+	{'o', 0x0308}: 'ö', // latin small letter o with diaeresis
+	{'u', 0x0300}: 'ù', // latin small letter u with grave
+	{'u', 0x0301}: 'ú', // latin small letter u with acute
+	{'u', 0x0302}: 'û', // latin small letter u with circumflex
+	{'u', 0x0308}: 'ü', // latin small letter u with diaeresis
+	{'y', 0x0301}: 'ý', // latin small letter y with acute
+	{'y', 0x0308}: 'ÿ', // latin small letter y with diaeresis
+	{'A', 0x0304}: 'Ā', // latin capital letter a with macron
+	{'a', 0x0304}: 'ā', // latin small letter a with macron
+	{'A', 0x0306}: 'Ă', // latin capital letter a with breve
+	{'a', 0x0306}: 'ă', // latin small letter a with breve
+	{'A', 0x0328}: 'Ą', // latin capital letter a with ogonek
+	{'a', 0x0328}: 'ą', // latin small letter a with ogonek
+	{'C', 0x0301}: 'Ć', // latin capital letter c with acute
+	{'c', 0x0301}: 'ć', // latin small letter c with acute
+	{'C', 0x0302}: 'Ĉ', // latin capital letter c with circumflex
+	{'c', 0x0302}: 'ĉ', // latin small letter c with circumflex
+	{'C', 0x0307}: 'Ċ', // latin capital letter c with dot above
+	{'c', 0x0307}: 'ċ', // latin small letter c with dot above
+	{'C', 0x030C}: 'Č', // latin capital letter c with caron
+	{'c', 0x030C}: 'č', // latin small letter c with caron
+	{'D', 0x030C}: 'Ď', // latin capital letter d with caron
+	{'d', 0x030C}: 'ď', // latin small letter d with caron
+	{'E', 0x0304}: 'Ē', // latin capital letter e with macron
+	{'e', 0x0304}: 'ē', // latin small letter e with macron
+	{'E', 0x0306}: 'Ĕ', // latin capital letter e with breve
+	{'e', 0x0306}: 'ĕ', // latin small letter e with breve
+	{'E', 0x0307}: 'Ė', // latin capital letter e with dot above
+	{'e', 0x0307}: 'ė', // latin small letter e with dot above
+	{'E', 0x0328}: 'Ę', // latin capital letter e with ogonek
+	{'e', 0x0328}: 'ę', // latin small letter e with ogonek
+	{'E', 0x030C}: 'Ě', // latin capital letter e with caron
+	{'e', 0x030C}: 'ě', // latin small letter e with caron
+	{'G', 0x0302}: 'Ĝ', // latin capital letter g with circumflex
+	{'g', 0x0302}: 'ĝ', // latin small letter g with circumflex
+	{'G', 0x0306}: 'Ğ', // latin capital letter g with breve
+	{'g', 0x0306}: 'ğ', // latin small letter g with breve
+	{'G', 0x0307}: 'Ġ', // latin capital letter g with dot above
+	{'g', 0x0307}: 'ġ', // latin small letter g with dot above
+	{'G', 0x0327}: 'Ģ', // latin capital letter g with cedilla
+	{'g', 0x0327}: 'ģ', // latin small letter g with cedilla
+	{'H', 0x0302}: 'Ĥ', // latin capital letter h with circumflex
+	{'h', 0x0302}: 'ĥ', // latin small letter h with circumflex
+	{'I', 0x0303}: 'Ĩ', // latin capital letter i with tilde
+	{'i', 0x0303}: 'ĩ', // latin small letter i with tilde
+	{'I', 0x0304}: 'Ī', // latin capital letter i with macron
+	{'i', 0x0304}: 'ī', // latin small letter i with macron
+	{'I', 0x0306}: 'Ĭ', // latin capital letter i with breve
+	{'i', 0x0306}: 'ĭ', // latin small letter i with breve
+	{'I', 0x0328}: 'Į', // latin capital letter i with ogonek
+	{'i', 0x0328}: 'į', // latin small letter i with ogonek
+	{'I', 0x0307}: 'İ', // latin capital letter i with dot above
+	{'J', 0x0302}: 'Ĵ', // latin capital letter j with circumflex
+	{'j', 0x0302}: 'ĵ', // latin small letter j with circumflex
+	{'K', 0x0327}: 'Ķ', // latin capital letter k with cedilla
+	{'k', 0x0327}: 'ķ', // latin small letter k with cedilla
+	{'L', 0x0301}: 'Ĺ', // latin capital letter l with acute
+	{'l', 0x0301}: 'ĺ', // latin small letter l with acute
+	{'L', 0x0327}: 'Ļ', // latin capital letter l with cedilla
+	{'l', 0x0327}: 'ļ', // latin small letter l with cedilla
+	{'L', 0x030C}: 'Ľ', // latin capital letter l with caron
+	{'l', 0x030C}: 'ľ', // latin small letter l with caron
+	{'N', 0x0301}: 'Ń', // latin capital letter n with acute
+	{'n', 0x0301}: 'ń', // latin small letter n with acute
+	{'N', 0x0327}: 'Ņ', // latin capital letter n with cedilla
+	{'n', 0x0327}: 'ņ', // latin small letter n with cedilla
+	{'N', 0x030C}: 'Ň', // latin capital letter n with caron
+	{'n', 0x030C}: 'ň', // latin small letter n with caron
+	{'O', 0x0304}: 'Ō', // latin capital letter o with macron
+	{'o', 0x0304}: 'ō', // latin small letter o with macron
+	{'O', 0x0306}: 'Ŏ', // latin capital letter o with breve
+	{'o', 0x0306}: 'ŏ', // latin small letter o with breve
+	{'O', 0x030B}: 'Ő', // latin capital letter o with double acute
+	{'o', 0x030B}: 'ő', // latin small letter o with double acute
+	{'R', 0x0301}: 'Ŕ', // latin capital letter r with acute
+	{'r', 0x0301}: 'ŕ', // latin small letter r with acute
+	{'R', 0x0327}: 'Ŗ', // latin capital letter r with cedilla
+	{'r', 0x0327}: 'ŗ', // latin small letter r with cedilla
+	{'R', 0x030C}: 'Ř', // latin capital letter r with caron
+	{'r', 0x030C}: 'ř', // latin small letter r with caron
+	{'S', 0x0301}: 'Ś', // latin capital letter s with acute
+	{'s', 0x0301}: 'ś', // latin small letter s with acute
+	{'S', 0x0302}: 'Ŝ', // latin capital letter s with circumflex
+	{'s', 0x0302}: 'ŝ', // latin small letter s with circumflex
+	{'S', 0x0327}: 'Ş', // latin capital letter s with cedilla
+	{'s', 0x0327}: 'ş', // latin small letter s with cedilla
+	{'S', 0x030C}: 'Š', // latin capital letter s with caron
+	{'s', 0x030C}: 'š', // latin small letter s with caron
+	{'T', 0x0327}: 'Ţ', // latin capital letter t with cedilla
+	{'t', 0x0327}: 'ţ', // latin small letter t with cedilla
+	{'T', 0x030C}: 'Ť', // latin capital letter t with caron
+	{'t', 0x030C}: 'ť', // latin small letter t with caron
+	{'U', 0x0303}: 'Ũ', // latin capital letter u with tilde
+	{'u', 0x0303}: 'ũ', // latin small letter u with tilde
+	{'U', 0x0304}: 'Ū', // latin capital letter u with macron
+	{'u', 0x0304}: 'ū', // latin small letter u with macron
+	{'U', 0x0306}: 'Ŭ', // latin capital letter u with breve
+	{'u', 0x0306}: 'ŭ', // latin small letter u with breve
+	{'U', 0x030A}: 'Ů', // latin capital letter u with ring above
+	{'u', 0x030A}: 'ů', // latin small letter u with ring above
+	{'U', 0x030B}: 'Ű', // latin capital letter u with double acute
+	{'u', 0x030B}: 'ű', // latin small letter u with double acute
+	{'U', 0x0328}: 'Ų', // latin capital letter u with ogonek
+	{'u', 0x0328}: 'ų', // latin small letter u with ogonek
+	{'W', 0x0302}: 'Ŵ', // latin capital letter w with circumflex
+	{'w', 0x0302}: 'ŵ', // latin small letter w with circumflex
+	{'Y', 0x0302}: 'Ŷ', // latin capital letter y with circumflex
+	{'y', 0x0302}: 'ŷ', // latin small letter y with circumflex
+	{'Y', 0x0308}: 'Ÿ', // latin capital letter y with diaeresis
+	{'Z', 0x0301}: 'Ź', // latin capital letter z with acute
+	{'z', 0x0301}: 'ź', // latin small letter z with acute
+	{'Z', 0x0307}: 'Ż', // latin capital letter z with dot above
+	{'z', 0x0307}: 'ż', // latin small letter z with dot above
+	{'Z', 0x030C}: 'Ž', // latin capital letter z with caron
+	{'z', 0x030C}: 'ž', // latin small letter z with caron
+	{'O', 0x031B}: 'Ơ', // latin capital letter o with horn
+	{'o', 0x031B}: 'ơ', // latin small letter o with horn
+	{'U', 0x031B}: 'Ư', // latin capital letter u with horn
+	{'u', 0x031B}: 'ư', // latin small letter u with horn
+	{'A', 0x030C}: 'Ǎ', // latin capital letter a with caron
+	{'a', 0x030C}: 'ǎ', // latin small letter a with caron
+	{'I', 0x030C}: 'Ǐ', // latin capital letter i with caron
+	{'i', 0x030C}: 'ǐ', // latin small letter i with caron
+	{'O', 0x030C}: 'Ǒ', // latin capital letter o with caron
+	{'o', 0x030C}: 'ǒ', // latin small letter o with caron
+	{'U', 0x030C}: 'Ǔ', // latin capital letter u with caron
+	{'u', 0x030C}: 'ǔ', // latin small letter u with caron
+	{'Ü', 0x0304}: 'Ǖ', // latin capital letter u with diaeresis and macron
+	{'ü', 0x0304}: 'ǖ', // latin small letter u with diaeresis and macron
+	{'Ü', 0x0301}: 'Ǘ', // latin capital letter u with diaeresis and acute
+	{'ü', 0x0301}: 'ǘ', // latin small letter u with diaeresis and acute
+	{'Ü', 0x030C}: 'Ǚ', // latin capital letter u with diaeresis and caron
+	{'ü', 0x030C}: 'ǚ', // latin small letter u with diaeresis and caron
+	{'Ü', 0x0300}: 'Ǜ', // latin capital letter u with diaeresis and grave
+	{'ü', 0x0300}: 'ǜ', // latin small letter u with diaeresis and grave
+	{'Ä', 0x0304}: 'Ǟ', // latin capital letter a with diaeresis and macron
+	{'ä', 0x0304}: 'ǟ', // latin small letter a with diaeresis and macron
+	{'Ȧ', 0x0304}: 'Ǡ', // latin capital letter a with dot above and macron
+	{'ȧ', 0x0304}: 'ǡ', // latin small letter a with dot above and macron
+	{'Æ', 0x0304}: 'Ǣ', // latin capital letter ae with macron
+	{'æ', 0x0304}: 'ǣ', // latin small letter ae with macron
+	{'G', 0x030C}: 'Ǧ', // latin capital letter g with caron
+	{'g', 0x030C}: 'ǧ', // latin small letter g with caron
+	{'K', 0x030C}: 'Ǩ', // latin capital letter k with caron
+	{'k', 0x030C}: 'ǩ', // latin small letter k with caron
+	{'O', 0x0328}: 'Ǫ', // latin capital letter o with ogonek
+	{'o', 0x0328}: 'ǫ', // latin small letter o with ogonek
+	{'Ǫ', 0x0304}: 'Ǭ', // latin capital letter o with ogonek and macron
+	{'ǫ', 0x0304}: 'ǭ', // latin small letter o with ogonek and macron
+	{'Ʒ', 0x030C}: 'Ǯ', // latin capital letter ezh with caron
+	{'ʒ', 0x030C}: 'ǯ', // latin small letter ezh with caron
+	{'j', 0x030C}: 'ǰ', // latin small letter j with caron
+	{'G', 0x0301}: 'Ǵ', // latin capital letter g with acute
+	{'g', 0x0301}: 'ǵ', // latin small letter g with acute
+	{'N', 0x0300}: 'Ǹ', // latin capital letter n with grave
+	{'n', 0x0300}: 'ǹ', // latin small letter n with grave
+	{'Å', 0x0301}: 'Ǻ', // latin capital letter a with ring above and acute
+	{'å', 0x0301}: 'ǻ', // latin small letter a with ring above and acute
+	{'Æ', 0x0301}: 'Ǽ', // latin capital letter ae with acute
+	{'æ', 0x0301}: 'ǽ', // latin small letter ae with acute
+	{'Ø', 0x0301}: 'Ǿ', // latin capital letter o with stroke and acute
+	{'ø', 0x0301}: 'ǿ', // latin small letter o with stroke and acute
+	{'A', 0x030F}: 'Ȁ', // latin capital letter a with double grave
+	{'a', 0x030F}: 'ȁ', // latin small letter a with double grave
+	{'A', 0x0311}: 'Ȃ', // latin capital letter a with inverted breve
+	{'a', 0x0311}: 'ȃ', // latin small letter a with inverted breve
+	{'E', 0x030F}: 'Ȅ', // latin capital letter e with double grave
+	{'e', 0x030F}: 'ȅ', // latin small letter e with double grave
+	{'E', 0x0311}: 'Ȇ', // latin capital letter e with inverted breve
+	{'e', 0x0311}: 'ȇ', // latin small letter e with inverted breve
+	{'I', 0x030F}: 'Ȉ', // latin capital letter i with double grave
+	{'i', 0x030F}: 'ȉ', // latin small letter i with double grave
+	{'I', 0x0311}: 'Ȋ', // latin capital letter i with inverted breve
+	{'i', 0x0311}: 'ȋ', // latin small letter i with inverted breve
+	{'O', 0x030F}: 'Ȍ', // latin capital letter o with double grave
+	{'o', 0x030F}: 'ȍ', // latin small letter o with double grave
+	{'O', 0x0311}: 'Ȏ', // latin capital letter o with inverted breve
+	{'o', 0x0311}: 'ȏ', // latin small letter o with inverted breve
+	{'R', 0x030F}: 'Ȑ', // latin capital letter r with double grave
+	{'r', 0x030F}: 'ȑ', // latin small letter r with double grave
+	{'R', 0x0311}: 'Ȓ', // latin capital letter r with inverted breve
+	{'r', 0x0311}: 'ȓ', // latin small letter r with inverted breve
+	{'U', 0x030F}: 'Ȕ', // latin capital letter u with double grave
+	{'u', 0x030F}: 'ȕ', // latin small letter u with double grave
+	{'U', 0x0311}: 'Ȗ', // latin capital letter u with inverted breve
+	{'u', 0x0311}: 'ȗ', // latin small letter u with inverted breve
+	{'S', 0x0326}: 'Ș', // latin capital letter s with comma below
+	{'s', 0x0326}: 'ș', // latin small letter s with comma below
+	{'T', 0x0326}: 'Ț', // latin capital letter t with comma below
+	{'t', 0x0326}: 'ț', // latin small letter t with comma below
+	{'H', 0x030C}: 'Ȟ', // latin capital letter h with caron
+	{'h', 0x030C}: 'ȟ', // latin small letter h with caron
+	{'A', 0x0307}: 'Ȧ', // latin capital letter a with dot above
+	{'a', 0x0307}: 'ȧ', // latin small letter a with dot above
+	{'E', 0x0327}: 'Ȩ', // latin capital letter e with cedilla
+	{'e', 0x0327}: 'ȩ', // latin small letter e with cedilla
+	{'Ö', 0x0304}: 'Ȫ', // latin capital letter o with diaeresis and macron
+	{'ö', 0x0304}: 'ȫ', // latin small letter o with diaeresis and macron
+	{'Õ', 0x0304}: 'Ȭ', // latin capital letter o with tilde and macron
+	{'õ', 0x0304}: 'ȭ', // latin small letter o with tilde and macron
+	{'O', 0x0307}: 'Ȯ', // latin capital letter o with dot above
+	{'o', 0x0307}: 'ȯ', // latin small letter o with dot above
+	{'Ȯ', 0x0304}: 'Ȱ', // latin capital letter o with dot above and macron
+	{'ȯ', 0x0304}: 'ȱ', // latin small letter o with dot above and macron
+	{'Y', 0x0304}: 'Ȳ', // latin capital letter y with macron
+	{'y', 0x0304}: 'ȳ', // latin small letter y with macron
+}
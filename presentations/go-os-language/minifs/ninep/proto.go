@@ -0,0 +1,209 @@
+package ninep
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Tipos de mensaje de 9P2000.L que entiende el servidor. Los números
+// vienen de la especificación (include/net/9p/9p.h en Linux).
+const (
+	Tlerror   = 6
+	Rlerror   = 7
+	Tlopen    = 12
+	Rlopen    = 13
+	Tlcreate  = 14
+	Rlcreate  = 15
+	Tgetattr  = 24
+	Rgetattr  = 25
+	Treaddir  = 40
+	Rreaddir  = 41
+	Tfsync    = 50
+	Rfsync    = 51
+	Tmkdir    = 72
+	Rmkdir    = 73
+	Trenameat = 74
+	Rrenameat = 75
+	Tunlinkat = 76
+	Runlinkat = 77
+	Tversion  = 100
+	Rversion  = 101
+	Tauth     = 102
+	Rauth     = 103
+	Tattach   = 104
+	Rattach   = 105
+	Tflush    = 108
+	Rflush    = 109
+	Twalk     = 110
+	Rwalk     = 111
+	Tread     = 116
+	Rread     = 117
+	Twrite    = 118
+	Rwrite    = 119
+	Tclunk    = 120
+	Rclunk    = 121
+	Tremove   = 122
+	Rremove   = 123
+)
+
+// Constantes auxiliares del protocolo
+const (
+	Version = "9P2000.L"
+	NoTag   = 0xFFFF
+	NoFid   = 0xFFFFFFFF
+
+	QTDIR  = 0x80
+	QTFILE = 0x00
+
+	// Bits de flags de Tlopen/Tlcreate (los de open(2) en Linux)
+	OWRONLY = 0x1
+	ORDWR   = 0x2
+	OTRUNC  = 0x200
+
+	// Flag de Tunlinkat para eliminar directorios
+	ATRemoveDir = 0x200
+
+	// Tipos de archivo en el campo mode de Rgetattr
+	SIFDIR = 0040000
+	SIFREG = 0100000
+
+	// GetattrBasic es la máscara que devuelve Rgetattr en valid
+	GetattrBasic = 0x000007ff
+
+	headerSize = 7
+)
+
+// Qid identifica un archivo de forma única en el servidor
+type Qid struct {
+	Type    uint8
+	Version uint32
+	Path    uint64
+}
+
+// errShort se reporta cuando un mensaje no trae todos sus campos
+var errShort = errors.New("mensaje 9P incompleto")
+
+// encoder construye el cuerpo de un mensaje
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) u8(v uint8) {
+	e.buf = append(e.buf, v)
+}
+
+func (e *encoder) u16(v uint16) {
+	e.buf = binary.LittleEndian.AppendUint16(e.buf, v)
+}
+
+func (e *encoder) u32(v uint32) {
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, v)
+}
+
+func (e *encoder) u64(v uint64) {
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, v)
+}
+
+func (e *encoder) str(s string) {
+	e.u16(uint16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) qid(q Qid) {
+	e.u8(q.Type)
+	e.u32(q.Version)
+	e.u64(q.Path)
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.u32(uint32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// decoder lee los campos de un mensaje. El primer error se conserva y las
+// lecturas posteriores devuelven ceros.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+// zeros respalda las lecturas de números que fallan: u8 a u64 necesitan
+// hasta 8 bytes para decodificar el cero
+var zeros [8]byte
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil || n < 0 || len(d.buf) < n {
+		// n puede venir del cliente, así que no se reserva memoria con él
+		d.err = errShort
+		if n >= 0 && n <= len(zeros) {
+			return zeros[:n:n]
+		}
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) u8() uint8 {
+	return d.take(1)[0]
+}
+
+func (d *decoder) u16() uint16 {
+	return binary.LittleEndian.Uint16(d.take(2))
+}
+
+func (d *decoder) u32() uint32 {
+	return binary.LittleEndian.Uint32(d.take(4))
+}
+
+func (d *decoder) u64() uint64 {
+	return binary.LittleEndian.Uint64(d.take(8))
+}
+
+func (d *decoder) str() string {
+	n := d.u16()
+	return string(d.take(int(n)))
+}
+
+func (d *decoder) qid() Qid {
+	return Qid{Type: d.u8(), Version: d.u32(), Path: d.u64()}
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.u32()
+	return d.take(int(n))
+}
+
+// readMsg lee un mensaje completo: size[4] type[1] tag[2] cuerpo
+func readMsg(r io.Reader, msize uint32) (uint8, uint16, []byte, error) {
+	var hdr [headerSize]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, 0, nil, err
+	}
+
+	size := binary.LittleEndian.Uint32(hdr[0:4])
+	if size < headerSize || size > msize {
+		return 0, 0, nil, errors.New("tamaño de mensaje 9P inválido")
+	}
+
+	body := make([]byte, size-headerSize)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, 0, nil, err
+	}
+
+	return hdr[4], binary.LittleEndian.Uint16(hdr[5:7]), body, nil
+}
+
+// writeMsg escribe un mensaje con su cabecera
+func writeMsg(w io.Writer, typ uint8, tag uint16, body []byte) error {
+	msg := make([]byte, headerSize, headerSize+len(body))
+	binary.LittleEndian.PutUint32(msg[0:4], uint32(headerSize+len(body)))
+	msg[4] = typ
+	binary.LittleEndian.PutUint16(msg[5:7], tag)
+	msg = append(msg, body...)
+
+	_, err := w.Write(msg)
+	return err
+}
@@ -0,0 +1,691 @@
+// Package ninep sirve un minifs.FileSystem con el protocolo 9P2000.L, el
+// dialecto de 9P que usa el cliente v9fs de Linux. Cualquier cliente 9P
+// puede entonces recorrer, leer y modificar el árbol a través de un socket
+// TCP o Unix:
+//
+//	mount -t 9p -o trans=tcp,port=5640,version=9p2000.L 127.0.0.1 /mnt
+package ninep
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/hectorip/minifs"
+)
+
+// Server atiende conexiones 9P sobre un FileSystem
+type Server struct {
+	FS *minifs.FileSystem
+	// MaxMsize limita el tamaño de los mensajes que se negocian en Tversion.
+	// Nunca baja de MinMsize.
+	MaxMsize uint32
+}
+
+// MinMsize es el msize más chico que acepta Tversion: cabe cualquier
+// cabecera y queda lugar para datos en cada Tread y Twrite
+const MinMsize = 4096
+
+// NewServer crea un servidor para fs
+func NewServer(fs *minifs.FileSystem) *Server {
+	return &Server{FS: fs, MaxMsize: 64 * 1024}
+}
+
+// ListenAndServe escucha en network ("tcp" o "unix") y atiende conexiones
+func (s *Server) ListenAndServe(network, addr string) error {
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	return s.Serve(l)
+}
+
+// Serve acepta conexiones de l y atiende cada una en su propia goroutine
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
+
+// fid es una referencia del cliente a un archivo dentro de la conexión
+type fid struct {
+	path    string
+	open    bool
+	entries []minifs.FileInfo // instantánea para Treaddir
+}
+
+// conn guarda el estado de una conexión: la tabla de fids y el msize
+type conn struct {
+	srv   *Server
+	rw    io.ReadWriter
+	msize uint32
+	fids  map[uint32]*fid
+}
+
+// ServeConn atiende los mensajes de una conexión hasta que el cliente la
+// cierra. Los mensajes se procesan en orden, así que Tflush nunca tiene
+// nada pendiente que cancelar.
+func (s *Server) ServeConn(rw io.ReadWriteCloser) error {
+	defer rw.Close()
+
+	c := &conn{srv: s, rw: rw, msize: max(s.MaxMsize, MinMsize), fids: make(map[uint32]*fid)}
+	for {
+		typ, tag, body, err := readMsg(rw, c.msize)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		rtyp, reply := c.handle(typ, &decoder{buf: body})
+		if err := writeMsg(rw, rtyp, tag, reply); err != nil {
+			return err
+		}
+	}
+}
+
+// rerror construye un Rlerror con el errno dado
+func rerror(errno syscall.Errno) (uint8, []byte) {
+	var e encoder
+	e.u32(uint32(errno))
+	return Rlerror, e.buf
+}
+
+// errno traduce los errores de minifs, que sólo tienen mensaje, a códigos
+// de error POSIX para el cliente
+func errno(err error) syscall.Errno {
+	var en syscall.Errno
+	if errors.As(err, &en) {
+		return en
+	}
+
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "no existe"), strings.Contains(msg, "no encontrad"):
+		return syscall.ENOENT
+	case strings.Contains(msg, "ya existe"):
+		return syscall.EEXIST
+	case strings.Contains(msg, "no es un directorio"):
+		return syscall.ENOTDIR
+	case strings.Contains(msg, "no es un archivo"), strings.Contains(msg, "es un directorio"):
+		return syscall.EISDIR
+	case strings.Contains(msg, "no vacío"):
+		return syscall.ENOTEMPTY
+	case strings.Contains(msg, "demasiado larg"):
+		return syscall.ENAMETOOLONG
+	case strings.Contains(msg, "no queda"):
+		return syscall.ENOSPC
+	case strings.Contains(msg, "no permitid"), strings.Contains(msg, "reservado"),
+		strings.Contains(msg, "no canónica"), strings.Contains(msg, "sale de la raíz"):
+		return syscall.EINVAL
+	case strings.Contains(msg, "raíz"):
+		return syscall.EBUSY
+	}
+	return syscall.EIO
+}
+
+func (c *conn) handle(typ uint8, d *decoder) (uint8, []byte) {
+	var rtyp uint8
+	var reply []byte
+	var err error
+
+	switch typ {
+	case Tversion:
+		rtyp, reply, err = c.version(d)
+	case Tauth:
+		// No hay autenticación: el cliente debe usar afid = NoFid
+		return rerror(syscall.EOPNOTSUPP)
+	case Tattach:
+		rtyp, reply, err = c.attach(d)
+	case Tflush:
+		rtyp, reply = Rflush, nil
+	case Twalk:
+		rtyp, reply, err = c.walk(d)
+	case Tlopen:
+		rtyp, reply, err = c.lopen(d)
+	case Tlcreate:
+		rtyp, reply, err = c.lcreate(d)
+	case Tread:
+		rtyp, reply, err = c.read(d)
+	case Twrite:
+		rtyp, reply, err = c.write(d)
+	case Tclunk:
+		rtyp, reply, err = c.clunk(d)
+	case Tremove:
+		rtyp, reply, err = c.remove(d)
+	case Tgetattr:
+		rtyp, reply, err = c.getattr(d)
+	case Treaddir:
+		rtyp, reply, err = c.readdir(d)
+	case Tmkdir:
+		rtyp, reply, err = c.mkdir(d)
+	case Trenameat:
+		rtyp, reply, err = c.renameat(d)
+	case Tunlinkat:
+		rtyp, reply, err = c.unlinkat(d)
+	case Tfsync:
+		d.u32()
+		rtyp, reply = Rfsync, nil
+	default:
+		return rerror(syscall.EOPNOTSUPP)
+	}
+
+	if d.err != nil {
+		return rerror(syscall.EINVAL)
+	}
+	if err != nil {
+		return rerror(errno(err))
+	}
+	return rtyp, reply
+}
+
+// qidFor calcula el qid de un archivo. El identificador es su número de
+// inodo, así que no cambia al renombrarlo y es el mismo para todos sus
+// enlaces duros.
+func qidFor(info minifs.FileInfo) Qid {
+	var ino uint64
+	if st, ok := info.Sys().(*minifs.StatT); ok {
+		ino = st.Ino
+	}
+
+	q := Qid{Type: QTFILE, Version: uint32(info.ModTime.UnixNano()), Path: ino}
+	if info.IsDir {
+		q.Type = QTDIR
+	}
+	return q
+}
+
+func (c *conn) stat(p string) (Qid, minifs.FileInfo, error) {
+	info, err := c.srv.FS.Stat(p)
+	if err != nil {
+		return Qid{}, info, err
+	}
+	return qidFor(info), info, nil
+}
+
+func (c *conn) lookup(id uint32) (*fid, error) {
+	f, ok := c.fids[id]
+	if !ok {
+		return nil, syscall.EBADF
+	}
+	return f, nil
+}
+
+func (c *conn) version(d *decoder) (uint8, []byte, error) {
+	msize := d.u32()
+	version := d.str()
+	if msize < MinMsize {
+		return 0, nil, syscall.EINVAL
+	}
+
+	// Una nueva versión reinicia la sesión
+	c.fids = make(map[uint32]*fid)
+	if msize < c.msize {
+		c.msize = msize
+	}
+	if version != Version {
+		version = "unknown"
+	}
+
+	var e encoder
+	e.u32(c.msize)
+	e.str(version)
+	return Rversion, e.buf, nil
+}
+
+func (c *conn) attach(d *decoder) (uint8, []byte, error) {
+	id := d.u32()
+	d.u32() // afid
+	d.str() // uname
+	aname := d.str()
+	d.u32() // n_uname
+
+	if _, exists := c.fids[id]; exists {
+		return 0, nil, syscall.EBADF
+	}
+
+	root := path.Clean("/" + aname)
+	q, info, err := c.stat(root)
+	if err != nil {
+		return 0, nil, err
+	}
+	if !info.IsDir {
+		return 0, nil, syscall.ENOTDIR
+	}
+
+	c.fids[id] = &fid{path: root}
+
+	var e encoder
+	e.qid(q)
+	return Rattach, e.buf, nil
+}
+
+// validName indica si name es un solo componente de ruta. Los nombres
+// vacíos, con "/", "." y ".." se rechazan: path.Join los resolvería y el
+// cliente podría salir del árbol que montó con Tattach.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.Contains(name, "/")
+}
+
+func (c *conn) walk(d *decoder) (uint8, []byte, error) {
+	id := d.u32()
+	newid := d.u32()
+	n := int(d.u16())
+	names := make([]string, n)
+	for i := range names {
+		names[i] = d.str()
+	}
+
+	f, err := c.lookup(id)
+	if err != nil {
+		return 0, nil, err
+	}
+	if _, exists := c.fids[newid]; exists && newid != id {
+		return 0, nil, syscall.EBADF
+	}
+
+	current := f.path
+	var qids []Qid
+	for i, name := range names {
+		if !validName(name) {
+			return 0, nil, syscall.EINVAL
+		}
+		next := path.Join(current, name)
+
+		q, _, err := c.stat(next)
+		if err != nil {
+			// Sólo el primer elemento produce error; si no, se devuelven
+			// los qids recorridos y newfid no se crea
+			if i == 0 {
+				return 0, nil, err
+			}
+			break
+		}
+		qids = append(qids, q)
+		current = next
+	}
+
+	if len(qids) == len(names) {
+		c.fids[newid] = &fid{path: current}
+	}
+
+	var e encoder
+	e.u16(uint16(len(qids)))
+	for _, q := range qids {
+		e.qid(q)
+	}
+	return Rwalk, e.buf, nil
+}
+
+// iounit es la cantidad máxima de datos que cabe en un Rread o Twrite:
+// msize menos la cabecera más larga (la de Twrite, 23 bytes, redondeada a 24)
+func (c *conn) iounit() uint32 {
+	return c.msize - 24
+}
+
+func (c *conn) lopen(d *decoder) (uint8, []byte, error) {
+	id := d.u32()
+	flags := d.u32()
+
+	f, err := c.lookup(id)
+	if err != nil {
+		return 0, nil, err
+	}
+	if f.open {
+		return 0, nil, syscall.EBADF
+	}
+
+	q, info, err := c.stat(f.path)
+	if err != nil {
+		return 0, nil, err
+	}
+	if info.IsDir && flags&(OWRONLY|ORDWR) != 0 {
+		return 0, nil, syscall.EISDIR
+	}
+	if !info.IsDir && flags&OTRUNC != 0 {
+		if err := c.srv.FS.CreateFile(f.path, nil, info.Mode); err != nil {
+			return 0, nil, err
+		}
+	}
+	f.open = true
+
+	var e encoder
+	e.qid(q)
+	e.u32(c.iounit())
+	return Rlopen, e.buf, nil
+}
+
+func (c *conn) lcreate(d *decoder) (uint8, []byte, error) {
+	id := d.u32()
+	name := d.str()
+	d.u32() // flags
+	mode := d.u32()
+	d.u32() // gid
+
+	f, err := c.lookup(id)
+	if err != nil {
+		return 0, nil, err
+	}
+	if f.open {
+		return 0, nil, syscall.EBADF
+	}
+	if !validName(name) {
+		return 0, nil, syscall.EINVAL
+	}
+
+	p := path.Join(f.path, name)
+	if c.srv.FS.Exists(p) {
+		return 0, nil, syscall.EEXIST
+	}
+	if err := c.srv.FS.CreateFile(p, nil, fileMode(mode)); err != nil {
+		return 0, nil, err
+	}
+
+	q, _, err := c.stat(p)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// El fid pasa a referirse al archivo nuevo, ya abierto
+	f.path = p
+	f.open = true
+
+	var e encoder
+	e.qid(q)
+	e.u32(c.iounit())
+	return Rlcreate, e.buf, nil
+}
+
+func (c *conn) read(d *decoder) (uint8, []byte, error) {
+	id := d.u32()
+	offset := d.u64()
+	count := d.u32()
+
+	f, err := c.lookup(id)
+	if err != nil {
+		return 0, nil, err
+	}
+	if !f.open {
+		return 0, nil, syscall.EBADF
+	}
+
+	file, err := c.srv.FS.Open(f.path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.Close()
+
+	if count > c.iounit() {
+		count = c.iounit()
+	}
+	data := make([]byte, count)
+	n, err := file.ReadAt(data, int64(offset))
+	if err != nil && err != io.EOF {
+		return 0, nil, err
+	}
+
+	var e encoder
+	e.bytes(data[:n])
+	return Rread, e.buf, nil
+}
+
+func (c *conn) write(d *decoder) (uint8, []byte, error) {
+	id := d.u32()
+	offset := d.u64()
+	data := d.bytes()
+
+	f, err := c.lookup(id)
+	if err != nil {
+		return 0, nil, err
+	}
+	if !f.open {
+		return 0, nil, syscall.EBADF
+	}
+
+	info, err := c.srv.FS.Stat(f.path)
+	if err != nil {
+		return 0, nil, err
+	}
+	if info.IsDir {
+		return 0, nil, syscall.EISDIR
+	}
+
+	file, err := c.srv.FS.Open(f.path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.Close()
+
+	if _, err := file.WriteAt(data, int64(offset)); err != nil {
+		return 0, nil, err
+	}
+
+	var e encoder
+	e.u32(uint32(len(data)))
+	return Rwrite, e.buf, nil
+}
+
+func (c *conn) clunk(d *decoder) (uint8, []byte, error) {
+	id := d.u32()
+	if _, err := c.lookup(id); err != nil {
+		return 0, nil, err
+	}
+	delete(c.fids, id)
+	return Rclunk, nil, nil
+}
+
+func (c *conn) remove(d *decoder) (uint8, []byte, error) {
+	id := d.u32()
+	f, err := c.lookup(id)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Tremove libera el fid aunque la eliminación falle
+	delete(c.fids, id)
+	if err := c.srv.FS.Remove(f.path); err != nil {
+		return 0, nil, err
+	}
+	return Rremove, nil, nil
+}
+
+func (c *conn) getattr(d *decoder) (uint8, []byte, error) {
+	id := d.u32()
+	d.u64() // request_mask: siempre devolvemos los atributos básicos
+
+	f, err := c.lookup(id)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	q, info, err := c.stat(f.path)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	st := info.Sys().(*minifs.StatT)
+	secs := uint64(info.ModTime.Unix())
+	nsecs := uint64(info.ModTime.Nanosecond())
+
+	var e encoder
+	e.u64(GetattrBasic)
+	e.qid(q)
+	e.u32(st.Mode)
+	e.u32(st.Uid)
+	e.u32(st.Gid)
+	e.u64(st.Nlink)
+	e.u64(0) // rdev
+	e.u64(uint64(st.Size))
+	e.u64(uint64(st.Blksize))
+	e.u64(uint64(st.Blocks))
+	for i := 0; i < 3; i++ { // atime, mtime, ctime
+		e.u64(secs)
+		e.u64(nsecs)
+	}
+	e.u64(0) // btime
+	e.u64(0)
+	e.u64(0) // gen
+	e.u64(0) // data_version
+	return Rgetattr, e.buf, nil
+}
+
+func (c *conn) readdir(d *decoder) (uint8, []byte, error) {
+	id := d.u32()
+	offset := d.u64()
+	count := d.u32()
+
+	f, err := c.lookup(id)
+	if err != nil {
+		return 0, nil, err
+	}
+	if !f.open {
+		return 0, nil, syscall.EBADF
+	}
+
+	// Al empezar desde cero se toma una instantánea ordenada del directorio
+	// para que los offsets sean estables entre llamadas
+	if offset == 0 || f.entries == nil {
+		entries, err := c.srv.FS.ListDir(f.path)
+		if err != nil {
+			return 0, nil, err
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		f.entries = entries
+	}
+
+	if count > c.iounit() {
+		count = c.iounit()
+	}
+
+	var data encoder
+	for i := int(offset); i < len(f.entries); i++ {
+		entry := f.entries[i]
+		var rec encoder
+		rec.qid(qidFor(entry))
+		rec.u64(uint64(i + 1))
+		if entry.IsDir {
+			rec.u8(4) // DT_DIR
+		} else {
+			rec.u8(8) // DT_REG
+		}
+		rec.str(entry.Name)
+
+		if len(data.buf)+len(rec.buf) > int(count) {
+			break
+		}
+		data.buf = append(data.buf, rec.buf...)
+	}
+
+	var e encoder
+	e.bytes(data.buf)
+	return Rreaddir, e.buf, nil
+}
+
+func (c *conn) mkdir(d *decoder) (uint8, []byte, error) {
+	id := d.u32()
+	name := d.str()
+	mode := d.u32()
+	d.u32() // gid
+
+	f, err := c.lookup(id)
+	if err != nil {
+		return 0, nil, err
+	}
+	if !validName(name) {
+		return 0, nil, syscall.EINVAL
+	}
+
+	p := path.Join(f.path, name)
+	if err := c.srv.FS.CreateDir(p, fileMode(mode)); err != nil {
+		return 0, nil, err
+	}
+
+	q, _, err := c.stat(p)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var e encoder
+	e.qid(q)
+	return Rmkdir, e.buf, nil
+}
+
+func (c *conn) renameat(d *decoder) (uint8, []byte, error) {
+	oldDir := d.u32()
+	oldName := d.str()
+	newDir := d.u32()
+	newName := d.str()
+
+	from, err := c.lookup(oldDir)
+	if err != nil {
+		return 0, nil, err
+	}
+	to, err := c.lookup(newDir)
+	if err != nil {
+		return 0, nil, err
+	}
+	if !validName(oldName) || !validName(newName) {
+		return 0, nil, syscall.EINVAL
+	}
+
+	oldPath := path.Join(from.path, oldName)
+	newPath := path.Join(to.path, newName)
+	if err := c.srv.FS.Rename(oldPath, newPath); err != nil {
+		return 0, nil, err
+	}
+
+	// Actualizar los fids que apuntaban al árbol renombrado
+	for _, f := range c.fids {
+		if f.path == oldPath || strings.HasPrefix(f.path, oldPath+"/") {
+			f.path = newPath + strings.TrimPrefix(f.path, oldPath)
+		}
+	}
+	return Rrenameat, nil, nil
+}
+
+func (c *conn) unlinkat(d *decoder) (uint8, []byte, error) {
+	id := d.u32()
+	name := d.str()
+	flags := d.u32()
+
+	f, err := c.lookup(id)
+	if err != nil {
+		return 0, nil, err
+	}
+	if !validName(name) {
+		return 0, nil, syscall.EINVAL
+	}
+
+	p := path.Join(f.path, name)
+	info, err := c.srv.FS.Stat(p)
+	if err != nil {
+		return 0, nil, err
+	}
+	if info.IsDir && flags&ATRemoveDir == 0 {
+		return 0, nil, syscall.EISDIR
+	}
+	if !info.IsDir && flags&ATRemoveDir != 0 {
+		return 0, nil, syscall.ENOTDIR
+	}
+
+	if err := c.srv.FS.Remove(p); err != nil {
+		return 0, nil, err
+	}
+	return Runlinkat, nil, nil
+}
+
+// fileMode conserva sólo los bits de permisos del modo que envía el cliente
+func fileMode(mode uint32) os.FileMode {
+	return os.FileMode(mode).Perm()
+}
@@ -0,0 +1,463 @@
+package ninep
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"syscall"
+	"testing"
+
+	"github.com/hectorip/minifs"
+)
+
+// client es un cliente 9P mínimo para las pruebas
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	tag  uint16
+}
+
+// rpc envía un mensaje y devuelve la respuesta. Si el servidor contesta
+// con Rlerror devuelve el errno.
+func (c *client) rpc(typ uint8, e encoder) (*decoder, syscall.Errno) {
+	c.t.Helper()
+	c.tag++
+	if err := writeMsg(c.conn, typ, c.tag, e.buf); err != nil {
+		c.t.Fatalf("Error enviando mensaje %d: %v", typ, err)
+	}
+
+	rtyp, tag, body, err := readMsg(c.conn, 1<<20)
+	if err != nil {
+		c.t.Fatalf("Error leyendo respuesta a %d: %v", typ, err)
+	}
+	if tag != c.tag {
+		c.t.Fatalf("Tag incorrecto: got %d, want %d", tag, c.tag)
+	}
+
+	d := &decoder{buf: body}
+	if rtyp == Rlerror {
+		return d, syscall.Errno(d.u32())
+	}
+	if rtyp != typ+1 {
+		c.t.Fatalf("Respuesta %d inesperada para %d", rtyp, typ)
+	}
+	return d, 0
+}
+
+// must falla la prueba si la respuesta fue un error
+func (c *client) must(typ uint8, e encoder) *decoder {
+	c.t.Helper()
+	d, errno := c.rpc(typ, e)
+	if errno != 0 {
+		c.t.Fatalf("Mensaje %d falló: %v", typ, errno)
+	}
+	return d
+}
+
+func (c *client) walk(fid, newfid uint32, names ...string) (int, syscall.Errno) {
+	var e encoder
+	e.u32(fid)
+	e.u32(newfid)
+	e.u16(uint16(len(names)))
+	for _, n := range names {
+		e.str(n)
+	}
+	d, errno := c.rpc(Twalk, e)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(d.u16()), 0
+}
+
+func (c *client) open(fid, flags uint32) {
+	var e encoder
+	e.u32(fid)
+	e.u32(flags)
+	c.must(Tlopen, e)
+}
+
+func (c *client) write(fid uint32, offset uint64, data string) {
+	var e encoder
+	e.u32(fid)
+	e.u64(offset)
+	e.bytes([]byte(data))
+	if n := c.must(Twrite, e).u32(); n != uint32(len(data)) {
+		c.t.Fatalf("Escritura corta: %d de %d", n, len(data))
+	}
+}
+
+func (c *client) read(fid uint32, offset uint64, count uint32) string {
+	var e encoder
+	e.u32(fid)
+	e.u64(offset)
+	e.u32(count)
+	return string(c.must(Tread, e).bytes())
+}
+
+func (c *client) clunk(fid uint32) {
+	var e encoder
+	e.u32(fid)
+	c.must(Tclunk, e)
+}
+
+// readdir devuelve los nombres de un directorio ya abierto leyendo en
+// bloques pequeños para ejercitar los offsets
+func (c *client) readdir(fid uint32) []string {
+	var names []string
+	var offset uint64
+	for {
+		var e encoder
+		e.u32(fid)
+		e.u64(offset)
+		e.u32(64)
+		data := c.must(Treaddir, e).bytes()
+		if len(data) == 0 {
+			return names
+		}
+
+		d := &decoder{buf: data}
+		for len(d.buf) > 0 {
+			d.qid()
+			offset = d.u64()
+			d.u8()
+			names = append(names, d.str())
+		}
+	}
+}
+
+func newTestClient(t *testing.T, fs *minifs.FileSystem) *client {
+	server, conn := net.Pipe()
+	go NewServer(fs).ServeConn(server)
+	t.Cleanup(func() { conn.Close() })
+
+	c := &client{t: t, conn: conn}
+
+	var e encoder
+	e.u32(8192)
+	e.str(Version)
+	d := c.must(Tversion, e)
+	if msize, version := d.u32(), d.str(); msize != 8192 || version != Version {
+		t.Fatalf("Negociación incorrecta: %d %s", msize, version)
+	}
+
+	e = encoder{}
+	e.u32(0)     // fid de la raíz
+	e.u32(NoFid) // afid
+	e.str("tester")
+	e.str("")
+	e.u32(0)
+	if q := c.must(Tattach, e).qid(); q.Type != QTDIR {
+		t.Fatalf("La raíz no es un directorio: %+v", q)
+	}
+
+	return c
+}
+
+func TestServer(t *testing.T) {
+	fs := minifs.NewFileSystem()
+	fs.MkdirAll("/docs/sub", 0755)
+	fs.WriteFile("/docs/hola.txt", []byte("hola mundo"))
+	c := newTestClient(t, fs)
+
+	t.Run("WalkAndRead", func(t *testing.T) {
+		if n, errno := c.walk(0, 1, "docs", "hola.txt"); errno != 0 || n != 2 {
+			t.Fatalf("Walk falló: %d %v", n, errno)
+		}
+		c.open(1, 0)
+		if got := c.read(1, 5, 100); got != "mundo" {
+			t.Errorf("Lectura con offset incorrecta: %q", got)
+		}
+		if got := c.read(1, 100, 10); got != "" {
+			t.Errorf("Lectura más allá del final: %q", got)
+		}
+		c.clunk(1)
+	})
+
+	t.Run("WalkErrors", func(t *testing.T) {
+		if _, errno := c.walk(0, 2, "nada"); errno != syscall.ENOENT {
+			t.Errorf("Se esperaba ENOENT, got %v", errno)
+		}
+
+		// Un walk parcial devuelve los qids recorridos pero no crea el fid
+		if n, errno := c.walk(0, 2, "docs", "nada"); errno != 0 || n != 1 {
+			t.Errorf("Walk parcial incorrecto: %d %v", n, errno)
+		}
+		var e encoder
+		e.u32(2)
+		if _, errno := c.rpc(Tclunk, e); errno != syscall.EBADF {
+			t.Errorf("El fid del walk parcial no debería existir: %v", errno)
+		}
+	})
+
+	t.Run("CreateWrite", func(t *testing.T) {
+		c.walk(0, 3, "docs")
+		var e encoder
+		e.u32(3)
+		e.str("nuevo.txt")
+		e.u32(ORDWR)
+		e.u32(0600)
+		e.u32(0)
+		c.must(Tlcreate, e)
+
+		c.write(3, 0, "abcdef")
+		c.write(3, 3, "XYZW")
+		c.clunk(3)
+
+		data, err := fs.ReadFile("/docs/nuevo.txt")
+		if err != nil || string(data) != "abcXYZW" {
+			t.Errorf("Contenido incorrecto: %q %v", data, err)
+		}
+		if info, _ := fs.Stat("/docs/nuevo.txt"); info.Mode != 0600 {
+			t.Errorf("Modo incorrecto: %v", info.Mode)
+		}
+	})
+
+	t.Run("Getattr", func(t *testing.T) {
+		c.walk(0, 4, "docs", "nuevo.txt")
+		var e encoder
+		e.u32(4)
+		e.u64(GetattrBasic)
+		d := c.must(Tgetattr, e)
+
+		d.u64() // valid
+		d.qid()
+		mode := d.u32()
+		d.u32()
+		d.u32()
+		d.u64()
+		d.u64()
+		size := d.u64()
+
+		if mode != SIFREG|0600 || size != 7 {
+			t.Errorf("Atributos incorrectos: modo %o, tamaño %d", mode, size)
+		}
+		c.clunk(4)
+	})
+
+	t.Run("MkdirReaddir", func(t *testing.T) {
+		for _, name := range []string{"a", "b", "c", "d", "e"} {
+			var e encoder
+			e.u32(0)
+			e.str("dir-" + name)
+			e.u32(0755)
+			e.u32(0)
+			c.must(Tmkdir, e)
+		}
+
+		c.walk(0, 5)
+		c.open(5, 0)
+		names := c.readdir(5)
+		c.clunk(5)
+
+		want := []string{"dir-a", "dir-b", "dir-c", "dir-d", "dir-e", "docs"}
+		if !sort.StringsAreSorted(names) || len(names) != len(want) {
+			t.Fatalf("Readdir incorrecto: %v", names)
+		}
+		for i := range want {
+			if names[i] != want[i] {
+				t.Errorf("Readdir incorrecto: %v", names)
+			}
+		}
+	})
+
+	t.Run("RenameUnlink", func(t *testing.T) {
+		c.walk(0, 6, "docs")
+		var e encoder
+		e.u32(6)
+		e.str("nuevo.txt")
+		e.u32(0)
+		e.str("renombrado.txt")
+		c.must(Trenameat, e)
+
+		if !fs.Exists("/renombrado.txt") {
+			t.Error("Renameat no movió el archivo")
+		}
+
+		e = encoder{}
+		e.u32(6)
+		e.str("sub")
+		e.u32(0)
+		if _, errno := c.rpc(Tunlinkat, e); errno != syscall.EISDIR {
+			t.Errorf("Unlinkat de un directorio sin AT_REMOVEDIR: %v", errno)
+		}
+
+		e = encoder{}
+		e.u32(6)
+		e.str("sub")
+		e.u32(ATRemoveDir)
+		c.must(Tunlinkat, e)
+		if fs.Exists("/docs/sub") {
+			t.Error("Unlinkat no eliminó el directorio")
+		}
+		c.clunk(6)
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		c.walk(0, 7, "docs")
+		var e encoder
+		e.u32(7)
+		if _, errno := c.rpc(Tremove, e); errno != syscall.ENOTEMPTY {
+			t.Errorf("Se esperaba ENOTEMPTY, got %v", errno)
+		}
+
+		c.walk(0, 8, "renombrado.txt")
+		e = encoder{}
+		e.u32(8)
+		c.must(Tremove, e)
+		if fs.Exists("/renombrado.txt") {
+			t.Error("Tremove no eliminó el archivo")
+		}
+	})
+
+	t.Run("TruncateOnOpen", func(t *testing.T) {
+		c.walk(0, 9, "docs", "hola.txt")
+		c.open(9, OWRONLY|OTRUNC)
+		c.write(9, 0, "adiós")
+		c.clunk(9)
+
+		if data, _ := fs.ReadFile("/docs/hola.txt"); !bytes.Equal(data, []byte("adiós")) {
+			t.Errorf("O_TRUNC no truncó el archivo: %q", data)
+		}
+	})
+}
+
+func TestServeTCP(t *testing.T) {
+	fs := minifs.NewFileSystem()
+	fs.WriteFile("/motd", []byte("bienvenido"))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("No se puede escuchar en TCP: %v", err)
+	}
+	defer l.Close()
+	go NewServer(fs).Serve(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	c := &client{t: t, conn: conn}
+	var e encoder
+	e.u32(8192)
+	e.str("9P2000")
+	d := c.must(Tversion, e)
+	d.u32()
+	if version := d.str(); version != "unknown" {
+		t.Error("Una versión distinta de 9P2000.L debería responder unknown")
+	}
+
+	e = encoder{}
+	e.u32(8192)
+	e.str(Version)
+	c.must(Tversion, e)
+
+	e = encoder{}
+	e.u32(0)
+	e.u32(NoFid)
+	e.str("tester")
+	e.str("")
+	e.u32(0)
+	c.must(Tattach, e)
+
+	c.walk(0, 1, "motd")
+	c.open(1, 0)
+	if got := c.read(1, 0, 100); got != "bienvenido" {
+		t.Errorf("Lectura por TCP incorrecta: %q", got)
+	}
+}
+
+func TestAttachRoot(t *testing.T) {
+	fs := minifs.NewFileSystem()
+	fs.MkdirAll("/jail/dentro", 0755)
+	fs.WriteFile("/secreto", []byte("no"))
+	c := newTestClient(t, fs)
+
+	var e encoder
+	e.u32(1)
+	e.u32(NoFid)
+	e.str("tester")
+	e.str("jail")
+	e.u32(0)
+	c.must(Tattach, e)
+
+	// Ni "..", ni "." ni un nombre con "/" pueden salir de /jail
+	for _, names := range [][]string{{".."}, {"..", "secreto"}, {"dentro", "..", ".."}, {"."}, {"dentro/.."}} {
+		if n, errno := c.walk(1, 2, names...); errno == 0 && n == len(names) {
+			t.Errorf("Walk %q salió de la raíz del attach", names)
+			c.clunk(2)
+		}
+	}
+
+	e = encoder{}
+	e.u32(1)
+	e.str("../escapado")
+	e.u32(0755)
+	e.u32(0)
+	if _, errno := c.rpc(Tmkdir, e); errno != syscall.EINVAL {
+		t.Errorf("Tmkdir con ..: se esperaba EINVAL, got %v", errno)
+	}
+	if fs.Exists("/escapado") {
+		t.Error("Tmkdir creó un directorio fuera de la raíz del attach")
+	}
+
+	e = encoder{}
+	e.u32(1)
+	e.str("dentro")
+	e.u32(1)
+	e.str("../dentro")
+	if _, errno := c.rpc(Trenameat, e); errno != syscall.EINVAL {
+		t.Errorf("Trenameat con ..: se esperaba EINVAL, got %v", errno)
+	}
+
+	e = encoder{}
+	e.u32(1)
+	e.str("../secreto")
+	e.u32(0)
+	if _, errno := c.rpc(Tunlinkat, e); errno != syscall.EINVAL || !fs.Exists("/secreto") {
+		t.Errorf("Tunlinkat con ..: se esperaba EINVAL, got %v", errno)
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	fs := minifs.NewFileSystem()
+	fs.WriteFile("/datos", []byte("hola"))
+	c := newTestClient(t, fs)
+
+	// Un msize menor que MinMsize dejaría iounit sin lugar para datos
+	var e encoder
+	e.u32(16)
+	e.str(Version)
+	if _, errno := c.rpc(Tversion, e); errno != syscall.EINVAL {
+		t.Errorf("Tversion con msize 16: se esperaba EINVAL, got %v", errno)
+	}
+
+	// La sesión sigue igual: el fid de la raíz todavía existe
+	c.walk(0, 1, "datos")
+	c.open(1, ORDWR)
+
+	// Un Twrite que anuncia más datos de los que trae
+	e = encoder{}
+	e.u32(1)
+	e.u64(0)
+	e.u32(0xFFFFFFF0)
+	e.buf = append(e.buf, "xyz"...)
+	if _, errno := c.rpc(Twrite, e); errno != syscall.EINVAL {
+		t.Errorf("Twrite corto: se esperaba EINVAL, got %v", errno)
+	}
+	if data, _ := fs.ReadFile("/datos"); string(data) != "hola" {
+		t.Errorf("Twrite corto modificó el archivo: %q", data)
+	}
+
+	// El largo del cliente no se usa para reservar memoria
+	allocs := testing.AllocsPerRun(10, func() {
+		d := &decoder{buf: []byte{0xF0, 0xFF, 0xFF, 0xFF, 'x'}}
+		if b := d.bytes(); len(b) != 0 || d.err == nil || d.u64() != 0 {
+			t.Fatal("Lectura corta sin error")
+		}
+	})
+	if allocs > 1 {
+		t.Errorf("Una lectura corta reservó memoria: %v", allocs)
+	}
+}
@@ -0,0 +1,249 @@
+package minifs
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PathPolicy decide qué rutas acepta un FileSystem y cómo se comparan los
+// nombres. El valor cero es la política de NewFileSystem: acepta rutas
+// relativas (se resuelven desde la raíz) y limpia componentes vacíos, "." y
+// "..". En todas las políticas un nombre con NUL es inválido.
+type PathPolicy struct {
+	// Strict exige rutas absolutas y canónicas: "/a//b", "/a/./b",
+	// "/a/../b", "/a/" y "a/b" se rechazan en lugar de limpiarse
+	Strict bool
+
+	// Límites en bytes; cero significa sin límite
+	MaxNameLen int
+	MaxPathLen int
+
+	// ForbiddenChars son caracteres que no pueden aparecer en un nombre
+	ForbiddenChars string
+
+	// ReservedNames son nombres que no se pueden usar, con o sin extensión
+	// y sin distinguir mayúsculas, como CON o NUL en Windows
+	ReservedNames []string
+
+	// NormalizeNFC compone los acentos escritos como letra más marca
+	// combinante, así "é" y "é" son el mismo nombre
+	NormalizeNFC bool
+
+	// CaseInsensitive compara nombres sin distinguir mayúsculas pero
+	// conserva la forma con que se crearon, como macOS y Windows
+	CaseInsensitive bool
+}
+
+// StrictPolicy sigue los límites de Linux: rutas absolutas y canónicas,
+// nombres de hasta 255 bytes y rutas de hasta 4096
+func StrictPolicy() PathPolicy {
+	return PathPolicy{Strict: true, MaxNameLen: 255, MaxPathLen: 4096}
+}
+
+// MacOSPolicy emula APFS con su configuración por omisión: sin distinguir
+// mayúsculas y con nombres Unicode normalizados
+func MacOSPolicy() PathPolicy {
+	return PathPolicy{
+		MaxNameLen:      255,
+		MaxPathLen:      1024,
+		ForbiddenChars:  ":",
+		NormalizeNFC:    true,
+		CaseInsensitive: true,
+	}
+}
+
+// WindowsPolicy emula NTFS visto desde Win32: sin distinguir mayúsculas,
+// con los caracteres prohibidos de Windows, los nombres de dispositivo
+// reservados y el límite clásico de MAX_PATH
+func WindowsPolicy() PathPolicy {
+	reserved := []string{"CON", "PRN", "AUX", "NUL"}
+	for i := 1; i <= 9; i++ {
+		reserved = append(reserved, "COM"+strconv.Itoa(i), "LPT"+strconv.Itoa(i))
+	}
+
+	return PathPolicy{
+		MaxNameLen:      255,
+		MaxPathLen:      260,
+		ForbiddenChars:  `<>:"\|?*`,
+		ReservedNames:   reserved,
+		NormalizeNFC:    true,
+		CaseInsensitive: true,
+	}
+}
+
+// NewFileSystemWithPolicy crea un sistema de archivos que valida y
+// compara las rutas según policy
+func NewFileSystemWithPolicy(policy PathPolicy) *FileSystem {
+	fs := NewFileSystem()
+	fs.policy = policy
+	if policy.CaseInsensitive {
+		fs.root.keys = make(map[string]string)
+	}
+	return fs
+}
+
+// Policy devuelve la política de rutas del sistema de archivos
+func (fs *FileSystem) Policy() PathPolicy {
+	return fs.policy
+}
+
+// split valida una ruta y la divide en sus componentes ya normalizados.
+// La raíz no tiene componentes.
+func (p *PathPolicy) split(path string) ([]string, error) {
+	if strings.IndexByte(path, 0) >= 0 {
+		return nil, errors.New("carácter no permitido en la ruta: NUL")
+	}
+
+	clean := filepath.Clean(path)
+	if p.Strict {
+		if !strings.HasPrefix(path, "/") {
+			return nil, errors.New("ruta relativa no permitida: " + path)
+		}
+		if clean != path {
+			return nil, errors.New("ruta no canónica: " + path)
+		}
+	}
+
+	if p.NormalizeNFC {
+		clean = composeNFC(clean)
+	}
+	if p.MaxPathLen > 0 && len(clean) > p.MaxPathLen {
+		return nil, errors.New("ruta demasiado larga: " + path)
+	}
+
+	if clean == "/" || clean == "." {
+		return []string{}, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(clean, "/"), "/")
+	for _, name := range parts {
+		if err := p.checkName(name); err != nil {
+			return nil, err
+		}
+	}
+	return parts, nil
+}
+
+// checkName valida un componente de la ruta
+func (p *PathPolicy) checkName(name string) error {
+	if name == ".." {
+		// Clean solo deja ".." al principio de una ruta relativa
+		return errors.New("la ruta sale de la raíz: " + name)
+	}
+	if p.MaxNameLen > 0 && len(name) > p.MaxNameLen {
+		return errors.New("nombre demasiado largo: " + name)
+	}
+	if strings.ContainsAny(name, p.ForbiddenChars) {
+		return errors.New("carácter no permitido en el nombre: " + name)
+	}
+
+	base, _, _ := strings.Cut(name, ".")
+	for _, reserved := range p.ReservedNames {
+		if strings.EqualFold(base, reserved) {
+			return errors.New("nombre reservado: " + name)
+		}
+	}
+	return nil
+}
+
+// key es la forma de un nombre con la que se compara en un directorio
+func (p *PathPolicy) key(name string) string {
+	if p.CaseInsensitive {
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+// resolve valida path y devuelve el directorio que contiene al último
+// componente junto con ese nombre normalizado. Para la raíz devuelve la
+// raíz y un nombre vacío. Se asume que fs.mu está tomado.
+func (fs *FileSystem) resolve(path string) (*Node, string, error) {
+	parts, err := fs.policy.split(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(parts) == 0 {
+		return fs.root, "", nil
+	}
+
+	parent, err := fs.walkParts(path, parts[:len(parts)-1])
+	if err != nil {
+		return nil, "", err
+	}
+	return parent, parts[len(parts)-1], nil
+}
+
+// walkParts baja desde la raíz por los componentes dados, que ya pasaron
+// por la política. path solo se usa en los mensajes de error.
+func (fs *FileSystem) walkParts(path string, parts []string) (*Node, error) {
+	current := fs.root
+
+	for _, part := range parts {
+		current.mu.RLock()
+		child, _, exists := fs.child(current, part)
+		current.mu.RUnlock()
+
+		if !exists {
+			return nil, errors.New("ruta no encontrada: " + path)
+		}
+
+		if child.nodeType != DirNode {
+			return nil, errors.New("no es un directorio: " + part)
+		}
+
+		current = child
+	}
+
+	return current, nil
+}
+
+// child busca name en dir según la política y devuelve el nodo y el nombre
+// con el que está guardado, que en modo sin mayúsculas puede diferir de
+// name. El llamador debe tener dir.mu tomado.
+func (fs *FileSystem) child(dir *Node, name string) (*Node, string, bool) {
+	if dir.keys != nil {
+		stored, ok := dir.keys[fs.policy.key(name)]
+		if !ok {
+			return nil, "", false
+		}
+		name = stored
+	}
+
+	node, ok := dir.children[name]
+	return node, name, ok
+}
+
+// addChild agrega una entrada a dir manteniendo el índice de nombres.
+// El llamador debe tener dir.mu tomado para escritura.
+func (fs *FileSystem) addChild(dir *Node, name string, node *Node) {
+	key := fs.policy.key(name)
+	dir.children[name] = node
+	dir.index.insert(key, name, node)
+	if dir.keys != nil {
+		dir.keys[key] = name
+	}
+	if node.nodeType == DirNode && fs.policy.CaseInsensitive && node.keys == nil {
+		node.keys = make(map[string]string)
+	}
+}
+
+// removeChild quita la entrada con el nombre guardado name
+func (fs *FileSystem) removeChild(dir *Node, name string) {
+	key := fs.policy.key(name)
+	delete(dir.children, name)
+	dir.index.delete(key)
+	if dir.keys != nil {
+		delete(dir.keys, key)
+	}
+}
+
+// cursor lleva un nombre dado por el usuario, como el de ReadDirPage, a la
+// clave con la que se ordena el índice
+func (p *PathPolicy) cursor(name string) string {
+	if p.NormalizeNFC {
+		name = composeNFC(name)
+	}
+	return p.key(name)
+}
@@ -0,0 +1,213 @@
+package minifs
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultPolicy(t *testing.T) {
+	fs := NewFileSystem()
+
+	// Las rutas se limpian como antes
+	fs.MkdirAll("/a//b/./c/", 0755)
+	if !fs.Exists("/a/b/c") || !fs.Exists("a/b") {
+		t.Error("La política por omisión debería limpiar las rutas")
+	}
+
+	if err := fs.WriteFile("/a/con\x00nul", []byte("x")); err == nil {
+		t.Error("Se aceptó un nombre con NUL")
+	}
+	if _, err := fs.ReadFile("../fuera"); err == nil {
+		t.Error("Se aceptó una ruta que sale de la raíz")
+	}
+
+	// Sin normalizar, las dos formas de "é" son nombres distintos
+	fs.WriteFile("/café", []byte("compuesto"))
+	if fs.Exists("/cafe\u0301") {
+		t.Error("La política por omisión no debería normalizar")
+	}
+}
+
+func TestStrictPolicy(t *testing.T) {
+	fs := NewFileSystemWithPolicy(StrictPolicy())
+
+	for _, path := range []string{"relativo", "/a//b", "/a/./b", "/a/../b", "/a/"} {
+		if err := fs.CreateDir(path, 0755); err == nil {
+			t.Errorf("Se aceptó la ruta %q", path)
+		}
+	}
+
+	if err := fs.MkdirAll("/a/b", 0755); err != nil {
+		t.Fatalf("MkdirAll con ruta canónica: %v", err)
+	}
+
+	long := strings.Repeat("x", 256)
+	if err := fs.WriteFile("/a/"+long, nil); err == nil || !strings.Contains(err.Error(), "demasiado largo") {
+		t.Errorf("Se aceptó un nombre de 256 bytes: %v", err)
+	}
+	if err := fs.WriteFile("/a/"+long[:255], nil); err != nil {
+		t.Errorf("Se rechazó un nombre de 255 bytes: %v", err)
+	}
+}
+
+func TestCaseInsensitivePolicy(t *testing.T) {
+	fs := NewFileSystemWithPolicy(MacOSPolicy())
+	fs.MkdirAll("/Documentos/Fotos", 0755)
+	fs.WriteFile("/Documentos/Léeme.TXT", []byte("hola"))
+
+	t.Run("Lookup", func(t *testing.T) {
+		data, err := fs.ReadFile("/documentos/LÉEME.txt")
+		if err != nil || string(data) != "hola" {
+			t.Errorf("Lectura sin distinguir mayúsculas: %q %v", data, err)
+		}
+
+		// Conserva la forma con la que se creó
+		info, _ := fs.Stat("/DOCUMENTOS/léeme.txt")
+		if info.Name != "Léeme.TXT" {
+			t.Errorf("No se conservó el nombre original: %s", info.Name)
+		}
+
+		if err := fs.CreateDir("/documentos", 0755); err == nil {
+			t.Error("Se creó un duplicado que solo difiere en mayúsculas")
+		}
+	})
+
+	t.Run("NFC", func(t *testing.T) {
+		// "é" escrita como e + acento combinante
+		if !fs.Exists("/Documentos/Le\u0301eme.txt") {
+			t.Error("La forma descompuesta no encontró el archivo")
+		}
+	})
+
+	t.Run("RenameCaseOnly", func(t *testing.T) {
+		if err := fs.Rename("/Documentos/léeme.txt", "/Documentos/LEEME.md"); err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.Rename("/documentos/fotos", "/Documentos/FOTOS"); err != nil {
+			t.Fatalf("Cambiar solo las mayúsculas debería funcionar: %v", err)
+		}
+
+		entries, _ := fs.ListDir("/Documentos")
+		names := map[string]bool{}
+		for _, e := range entries {
+			names[e.Name] = true
+		}
+		if len(entries) != 2 || !names["FOTOS"] || !names["LEEME.md"] {
+			t.Errorf("Entradas tras renombrar: %v", names)
+		}
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		if err := fs.Remove("/documentos/leeme.MD"); err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.WriteFile("/documentos/leeme.md", nil); err != nil {
+			t.Fatal(err)
+		}
+		info, _ := fs.Stat("/Documentos/LEEME.MD")
+		if info.Name != "leeme.md" {
+			t.Errorf("El índice no se limpió al eliminar: %s", info.Name)
+		}
+	})
+}
+
+func TestWindowsPolicy(t *testing.T) {
+	fs := NewFileSystemWithPolicy(WindowsPolicy())
+
+	for _, name := range []string{"/con", "/Aux.txt", "/lpt1.log", "/a:b", "/que?", "/pipe|"} {
+		if err := fs.WriteFile(name, nil); err == nil {
+			t.Errorf("Se aceptó el nombre %q", name)
+		}
+	}
+	if err := fs.WriteFile("/console.txt", nil); err != nil {
+		t.Errorf("console.txt no es un nombre reservado: %v", err)
+	}
+
+	deep := strings.Repeat("/d", 131)
+	if err := fs.MkdirAll(deep, 0755); err == nil {
+		t.Error("Se aceptó una ruta de más de 260 bytes")
+	}
+}
+
+func TestComposeNFC(t *testing.T) {
+	tests := map[string]string{
+		"cafe\u0301":         "café",
+		"n\u0303andú":        "ñandú",
+		"u\u0308\u0304":      "ǖ", // composición en dos pasos
+		"sin acentos":        "sin acentos",
+		"\u0301al principio": "\u0301al principio",
+	}
+	for in, want := range tests {
+		if got := composeNFC(in); got != want {
+			t.Errorf("composeNFC(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// FuzzSplit verifica que cualquier ruta que acepta una política quede en
+// componentes válidos y que volver a unirlos dé los mismos componentes
+func FuzzSplit(f *testing.F) {
+	for _, seed := range []string{"/", "", ".", "/a/b", "a//b/./c/", "/a/../b", "../x",
+		"/con.txt", "/a:b", "/café", "/" + strings.Repeat("x", 300), "/a\x00b"} {
+		f.Add(seed)
+	}
+
+	policies := map[string]PathPolicy{
+		"default": {},
+		"strict":  StrictPolicy(),
+		"macos":   MacOSPolicy(),
+		"windows": WindowsPolicy(),
+	}
+
+	f.Fuzz(func(t *testing.T, path string) {
+		for name, policy := range policies {
+			parts, err := policy.split(path)
+			if err != nil {
+				continue
+			}
+
+			for _, part := range parts {
+				if part == "" || part == "." || part == ".." || strings.ContainsAny(part, "/\x00") {
+					t.Fatalf("%s: split(%q) devolvió el componente %q", name, path, part)
+				}
+				if err := policy.checkName(part); err != nil {
+					t.Fatalf("%s: split(%q) aceptó %q: %v", name, path, part, err)
+				}
+			}
+
+			canonical := "/" + strings.Join(parts, "/")
+			again, err := policy.split(canonical)
+			if err != nil || strings.Join(again, "/") != strings.Join(parts, "/") {
+				t.Fatalf("%s: split(%q) = %q pero split(%q) = %q, %v", name, path, parts, canonical, again, err)
+			}
+		}
+	})
+}
+
+// FuzzCreateFile crea un archivo en una ruta arbitraria y verifica que se
+// pueda leer por la misma ruta y que el árbol siga consistente
+func FuzzCreateFile(f *testing.F) {
+	f.Add("/a/b.txt", []byte("hola"))
+	f.Add("a//b/../c", []byte{})
+	f.Add("/Léeme.TXT", []byte("x"))
+
+	f.Fuzz(func(t *testing.T, path string, content []byte) {
+		for _, fs := range []*FileSystem{NewFileSystem(), NewFileSystemWithPolicy(MacOSPolicy())} {
+			dir, _ := filepath.Split(path)
+			fs.MkdirAll(dir, 0755)
+			if err := fs.CreateFile(path, content, 0644); err != nil {
+				continue
+			}
+
+			data, err := fs.ReadFile(path)
+			if err != nil || !bytes.Equal(data, content) {
+				t.Fatalf("ReadFile(%q) = %q, %v", path, data, err)
+			}
+			if report := fs.Check(); !report.OK() {
+				t.Fatalf("Árbol inconsistente tras crear %q: %v", path, report)
+			}
+		}
+	})
+}
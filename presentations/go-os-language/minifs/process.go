@@ -0,0 +1,325 @@
+package minifs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+// ProcessOptions configura un Process
+type ProcessOptions struct {
+	// Dir es el directorio de trabajo inicial; vacío es la raíz
+	Dir string
+
+	// MaxFiles es el límite de descriptores abiertos, como RLIMIT_NOFILE;
+	// cero usa DefaultMaxFiles
+	MaxFiles int
+}
+
+// DefaultMaxFiles es el límite de descriptores por omisión, el mismo que
+// usan muchas distribuciones de Linux
+const DefaultMaxFiles = 1024
+
+// Process simula lo que el núcleo guarda de un proceso para hablar con el
+// sistema de archivos: un directorio de trabajo contra el que se resuelven
+// las rutas relativas y una tabla de descriptores numerados. Como en POSIX,
+// Dup comparte la descripción de archivo abierto (y con ella la posición),
+// y un archivo eliminado mientras está abierto se puede seguir leyendo
+// hasta cerrar el último descriptor.
+type Process struct {
+	fs *FileSystem
+
+	mu       sync.Mutex
+	cwd      string
+	fds      []*openFile // indexado por descriptor; nil es un hueco libre
+	maxFiles int
+}
+
+// openFile es una descripción de archivo abierto, compartida entre los
+// descriptores que salen de Dup
+type openFile struct {
+	file *File
+	refs int
+}
+
+// NewProcess crea un proceso sobre fs
+func NewProcess(fs *FileSystem, opts ProcessOptions) (*Process, error) {
+	p := &Process{fs: fs, cwd: "/", maxFiles: opts.MaxFiles}
+	if p.maxFiles == 0 {
+		p.maxFiles = DefaultMaxFiles
+	}
+	if opts.Dir != "" {
+		if err := p.Chdir(opts.Dir); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Abs resuelve path contra el directorio de trabajo
+func (p *Process) Abs(path string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.abs(path)
+}
+
+func (p *Process) abs(path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(p.cwd, path)
+}
+
+// Getwd devuelve el directorio de trabajo
+func (p *Process) Getwd() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.cwd
+}
+
+// Chdir cambia el directorio de trabajo; path puede ser relativo al actual
+func (p *Process) Chdir(path string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	dir := p.abs(path)
+	info, err := p.fs.Stat(dir)
+	if err != nil {
+		return &os.PathError{Op: "chdir", Path: path, Err: syscall.ENOENT}
+	}
+	if !info.IsDir {
+		return &os.PathError{Op: "chdir", Path: path, Err: syscall.ENOTDIR}
+	}
+
+	p.cwd = dir
+	return nil
+}
+
+// Open abre un archivo existente y devuelve el menor descriptor libre
+func (p *Process) Open(path string) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.open(path, "open")
+}
+
+// Create crea un archivo vacío, o vacía uno existente, y lo abre como
+// creat(2)
+func (p *Process) Create(path string, mode os.FileMode) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	abs := p.abs(path)
+	if p.exhausted() {
+		return -1, &os.PathError{Op: "create", Path: path, Err: syscall.EMFILE}
+	}
+	if err := p.fs.CreateFile(abs, nil, mode); err != nil {
+		return -1, err
+	}
+	return p.open(path, "create")
+}
+
+// open asume que p.mu está tomado
+func (p *Process) open(path, op string) (int, error) {
+	if p.exhausted() {
+		return -1, &os.PathError{Op: op, Path: path, Err: syscall.EMFILE}
+	}
+
+	f, err := p.fs.Open(p.abs(path))
+	if err != nil {
+		return -1, err
+	}
+	return p.install(&openFile{file: f, refs: 1}), nil
+}
+
+// exhausted indica si se llegó al límite de descriptores
+func (p *Process) exhausted() bool {
+	return p.openFiles() >= p.maxFiles
+}
+
+// install pone of en el menor descriptor libre, como exige POSIX
+func (p *Process) install(of *openFile) int {
+	for fd, slot := range p.fds {
+		if slot == nil {
+			p.fds[fd] = of
+			return fd
+		}
+	}
+	p.fds = append(p.fds, of)
+	return len(p.fds) - 1
+}
+
+// file devuelve el archivo detrás de fd o EBADF
+func (p *Process) file(op string, fd int) (*File, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if fd < 0 || fd >= len(p.fds) || p.fds[fd] == nil {
+		return nil, badFD(op, fd)
+	}
+	return p.fds[fd].file, nil
+}
+
+func badFD(op string, fd int) error {
+	return &os.PathError{Op: op, Path: "fd " + strconv.Itoa(fd), Err: syscall.EBADF}
+}
+
+// Read lee desde la posición de fd y la avanza. Al final del archivo
+// devuelve 0 e io.EOF.
+func (p *Process) Read(fd int, b []byte) (int, error) {
+	f, err := p.file("read", fd)
+	if err != nil {
+		return 0, err
+	}
+	return f.Read(b)
+}
+
+// Write escribe en la posición de fd y la avanza
+func (p *Process) Write(fd int, b []byte) (int, error) {
+	f, err := p.file("write", fd)
+	if err != nil {
+		return 0, err
+	}
+	return f.Write(b)
+}
+
+// Seek mueve la posición de fd, que comparten sus duplicados
+func (p *Process) Seek(fd int, offset int64, whence int) (int64, error) {
+	f, err := p.file("seek", fd)
+	if err != nil {
+		return 0, err
+	}
+	return f.Seek(offset, whence)
+}
+
+// Fstat devuelve la información del archivo detrás de fd, que puede no
+// tener ya ningún nombre
+func (p *Process) Fstat(fd int) (FileInfo, error) {
+	f, err := p.file("fstat", fd)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return f.Stat()
+}
+
+// Dup devuelve un descriptor nuevo, el menor libre, que comparte la
+// descripción de archivo abierto con fd
+func (p *Process) Dup(fd int) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if fd < 0 || fd >= len(p.fds) || p.fds[fd] == nil {
+		return -1, badFD("dup", fd)
+	}
+	if p.exhausted() {
+		return -1, &os.PathError{Op: "dup", Path: "fd " + strconv.Itoa(fd), Err: syscall.EMFILE}
+	}
+
+	of := p.fds[fd]
+	of.refs++
+	return p.install(of), nil
+}
+
+// Close libera fd. El archivo se cierra con el último descriptor que lo
+// comparte.
+func (p *Process) Close(fd int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if fd < 0 || fd >= len(p.fds) || p.fds[fd] == nil {
+		return badFD("close", fd)
+	}
+	return p.release(fd)
+}
+
+// release asume que p.mu está tomado y que fd está en uso
+func (p *Process) release(fd int) error {
+	of := p.fds[fd]
+	p.fds[fd] = nil
+	for len(p.fds) > 0 && p.fds[len(p.fds)-1] == nil {
+		p.fds = p.fds[:len(p.fds)-1]
+	}
+
+	of.refs--
+	if of.refs > 0 {
+		return nil
+	}
+	return of.file.Close()
+}
+
+// Exit cierra todos los descriptores, como hace el núcleo al terminar un
+// proceso
+func (p *Process) Exit() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var errs []error
+	for fd := len(p.fds) - 1; fd >= 0; fd-- {
+		if p.fds[fd] != nil {
+			errs = append(errs, p.release(fd))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// OpenFiles devuelve cuántos descriptores hay abiertos
+func (p *Process) OpenFiles() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.openFiles()
+}
+
+func (p *Process) openFiles() int {
+	n := 0
+	for _, of := range p.fds {
+		if of != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Operaciones por ruta: resuelven path contra el directorio de trabajo y
+// delegan en el FileSystem
+
+// Stat obtiene información de path
+func (p *Process) Stat(path string) (FileInfo, error) {
+	return p.fs.Stat(p.Abs(path))
+}
+
+// ReadFile lee el contenido completo de path
+func (p *Process) ReadFile(path string) ([]byte, error) {
+	return p.fs.ReadFile(p.Abs(path))
+}
+
+// Mkdir crea un directorio
+func (p *Process) Mkdir(path string, mode os.FileMode) error {
+	return p.fs.CreateDir(p.Abs(path), mode)
+}
+
+// Remove elimina path como unlink(2): si algún descriptor lo tiene
+// abierto, el contenido sigue disponible a través de él
+func (p *Process) Remove(path string) error {
+	return p.fs.Remove(p.Abs(path))
+}
+
+// Rename mueve o renombra oldPath a newPath
+func (p *Process) Rename(oldPath, newPath string) error {
+	return p.fs.Rename(p.Abs(oldPath), p.Abs(newPath))
+}
+
+// ReadAll lee desde la posición de fd hasta el final
+func (p *Process) ReadAll(fd int) ([]byte, error) {
+	f, err := p.file("read", fd)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(f)
+}
@@ -0,0 +1,163 @@
+package minifs
+
+import (
+	"errors"
+	"io"
+	"syscall"
+	"testing"
+)
+
+func newProcess(t *testing.T, fs *FileSystem, opts ProcessOptions) *Process {
+	t.Helper()
+	p, err := NewProcess(fs, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Exit() })
+	return p
+}
+
+func TestProcessCwd(t *testing.T) {
+	fs := newCheckedFS(t)
+	fs.MkdirAll("/home/ana/src", 0755)
+	fs.WriteFile("/home/ana/notas.txt", []byte("hola"))
+
+	p := newProcess(t, fs, ProcessOptions{Dir: "/home/ana"})
+	if data, err := p.ReadFile("notas.txt"); err != nil || string(data) != "hola" {
+		t.Errorf("Lectura relativa al directorio de trabajo: %q %v", data, err)
+	}
+
+	if err := p.Chdir("src"); err != nil {
+		t.Fatal(err)
+	}
+	if p.Getwd() != "/home/ana/src" {
+		t.Errorf("Getwd tras Chdir relativo: %s", p.Getwd())
+	}
+	if data, _ := p.ReadFile("../notas.txt"); string(data) != "hola" {
+		t.Errorf("Lectura con ..: %q", data)
+	}
+
+	if err := p.Chdir("../notas.txt"); !errors.Is(err, syscall.ENOTDIR) {
+		t.Errorf("Chdir a un archivo: %v", err)
+	}
+	if err := p.Chdir("/no/existe"); !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("Chdir a un directorio inexistente: %v", err)
+	}
+	if p.Getwd() != "/home/ana/src" {
+		t.Errorf("Un Chdir fallido cambió el directorio: %s", p.Getwd())
+	}
+
+	// Dos procesos no comparten el directorio de trabajo
+	other := newProcess(t, fs, ProcessOptions{})
+	if other.Getwd() != "/" || other.Abs("x") != "/x" {
+		t.Errorf("Directorio de otro proceso: %s", other.Getwd())
+	}
+}
+
+func TestProcessDescriptors(t *testing.T) {
+	fs := newCheckedFS(t)
+	p := newProcess(t, fs, ProcessOptions{})
+
+	a, err := p.Create("a.txt", 0644)
+	if err != nil || a != 0 {
+		t.Fatalf("El primer descriptor debería ser 0: %d %v", a, err)
+	}
+	p.Write(a, []byte("hola mundo"))
+
+	b, _ := p.Open("a.txt")
+	c, _ := p.Dup(b)
+	if b != 1 || c != 2 {
+		t.Errorf("Descriptores: %d %d", b, c)
+	}
+
+	// Los duplicados comparten la posición; un Open nuevo tiene la suya
+	buf := make([]byte, 5)
+	p.Read(b, buf)
+	p.Read(c, buf)
+	if string(buf[:5]) != "mundo" {
+		t.Errorf("Dup no comparte la posición: %q", buf)
+	}
+	if n, err := p.Read(c, buf); n != 0 || err != io.EOF {
+		t.Errorf("Lectura al final: %d %v", n, err)
+	}
+
+	// Se reutiliza el menor descriptor libre
+	p.Close(b)
+	if d, _ := p.Open("a.txt"); d != 1 {
+		t.Errorf("Se esperaba reutilizar el 1, se obtuvo %d", d)
+	}
+	if data, _ := p.ReadAll(c); len(data) != 0 {
+		t.Errorf("Cerrar un duplicado no debería cerrar el otro: %q", data)
+	}
+
+	if err := p.Close(7); !errors.Is(err, syscall.EBADF) {
+		t.Errorf("Close de un descriptor inválido: %v", err)
+	}
+	if _, err := p.Read(-1, buf); !errors.Is(err, syscall.EBADF) {
+		t.Errorf("Read de un descriptor inválido: %v", err)
+	}
+
+	if err := p.Exit(); err != nil || p.OpenFiles() != 0 {
+		t.Errorf("Exit dejó %d descriptores: %v", p.OpenFiles(), err)
+	}
+}
+
+func TestProcessMaxFiles(t *testing.T) {
+	fs := newCheckedFS(t)
+	fs.WriteFile("/f", nil)
+	p := newProcess(t, fs, ProcessOptions{MaxFiles: 2})
+
+	fd, _ := p.Open("/f")
+	p.Dup(fd)
+	if _, err := p.Open("/f"); !errors.Is(err, syscall.EMFILE) {
+		t.Errorf("Open sobre el límite: %v", err)
+	}
+	if _, err := p.Dup(fd); !errors.Is(err, syscall.EMFILE) {
+		t.Errorf("Dup sobre el límite: %v", err)
+	}
+	if _, err := p.Create("/g", 0644); !errors.Is(err, syscall.EMFILE) || fs.Exists("/g") {
+		t.Errorf("Create sobre el límite: %v", err)
+	}
+
+	p.Close(fd)
+	if _, err := p.Open("/f"); err != nil {
+		t.Errorf("Tras cerrar uno debería haber lugar: %v", err)
+	}
+}
+
+func TestUnlinkWhileOpen(t *testing.T) {
+	fs := newCheckedFS(t)
+	dir := t.TempDir()
+	fs.SetCache(CacheOptions{MaxBytes: pageSize, SpillDir: dir})
+	fs.WriteFile("/tmp.dat", page('t'))
+
+	p := newProcess(t, fs, ProcessOptions{})
+	fd, _ := p.Open("/tmp.dat")
+	dup, _ := p.Dup(fd)
+
+	if err := p.Remove("/tmp.dat"); err != nil {
+		t.Fatal(err)
+	}
+	if fs.Exists("/tmp.dat") {
+		t.Fatal("El nombre debería desaparecer al eliminarlo")
+	}
+
+	// Otro archivo desaloja al abierto; tiene que poder recargarse
+	fs.WriteFile("/otro", page('o'))
+
+	info, err := p.Fstat(fd)
+	if err != nil || info.Sys().(*StatT).Nlink != 0 {
+		t.Errorf("Fstat de un archivo sin enlaces: %+v %v", info, err)
+	}
+	p.Close(fd)
+	if data, err := p.ReadAll(dup); err != nil || len(data) != pageSize || data[0] != 't' {
+		t.Errorf("El contenido debería seguir disponible hasta el último Close: %v", err)
+	}
+
+	p.Write(dup, []byte("más"))
+	p.Close(dup)
+	fs.ReadFile("/otro")
+	if stats := fs.CacheStats(); stats.Resident != pageSize || spillFiles(t, dir) != 0 {
+		t.Errorf("El último Close debería liberar el contenido: %+v", stats)
+	}
+}
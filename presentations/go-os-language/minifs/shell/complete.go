@@ -0,0 +1,90 @@
+package shell
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Complete completa la última palabra de line. La primera palabra se
+// completa con nombres de comandos y las demás con rutas del sistema de
+// archivos. Devuelve la línea completada y, si hay varias opciones, la
+// lista de candidatos.
+func (sh *Shell) Complete(line string) (string, []string) {
+	start := strings.LastIndexAny(line, " \t") + 1
+	word := line[start:]
+
+	var candidates []string
+	if strings.TrimSpace(line[:start]) == "" {
+		candidates = sh.completeCommand(word)
+	} else {
+		candidates = sh.completePath(word)
+	}
+
+	if len(candidates) == 0 {
+		return line, nil
+	}
+
+	if len(candidates) == 1 {
+		completed := candidates[0]
+		if !strings.HasSuffix(completed, "/") {
+			completed += " "
+		}
+		return line[:start] + completed, nil
+	}
+
+	return line[:start] + commonPrefix(candidates), candidates
+}
+
+func (sh *Shell) completeCommand(word string) []string {
+	var matches []string
+	for name := range commands {
+		if strings.HasPrefix(name, word) {
+			matches = append(matches, name)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+func (sh *Shell) completePath(word string) []string {
+	// Separar el directorio ya escrito del prefijo del nombre
+	dir, prefix := "", word
+	if i := strings.LastIndex(word, "/"); i >= 0 {
+		dir, prefix = word[:i+1], word[i+1:]
+	}
+
+	lookup := sh.cwd
+	if dir != "" {
+		lookup = sh.abs(dir)
+	}
+
+	entries, err := sh.FS.ListDir(lookup)
+	if err != nil {
+		return nil
+	}
+
+	var matches []string
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name, prefix) {
+			continue
+		}
+		name := dir + entry.Name
+		if entry.IsDir {
+			name += "/"
+		}
+		matches = append(matches, filepath.ToSlash(name))
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, w := range words[1:] {
+		for !strings.HasPrefix(w, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
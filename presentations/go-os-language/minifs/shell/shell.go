@@ -0,0 +1,612 @@
+// Package shell implementa un intérprete de comandos estilo Unix sobre un
+// minifs.FileSystem. Lo usan tanto el comando mfsh como el ejemplo.
+package shell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hectorip/minifs"
+)
+
+// errExit se devuelve cuando el usuario pide terminar la sesión
+var errExit = errors.New("exit")
+
+// command describe un comando del intérprete
+type command struct {
+	usage string
+	run   func(sh *Shell, args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"ls":    {"ls [-l] [ruta...]", (*Shell).ls},
+		"cd":    {"cd [ruta]", (*Shell).cd},
+		"pwd":   {"pwd", (*Shell).pwd},
+		"mkdir": {"mkdir [-p] ruta...", (*Shell).mkdir},
+		"cat":   {"cat archivo...", (*Shell).cat},
+		"echo":  {"echo texto... [> archivo | >> archivo]", (*Shell).echo},
+		"rm":    {"rm [-r] ruta...", (*Shell).rm},
+		"mv":    {"mv origen destino", (*Shell).mv},
+		"cp":    {"cp [-r] origen destino", (*Shell).cp},
+		"du":    {"du [-h] [ruta]", (*Shell).du},
+		"tree":  {"tree [-ahst] [ruta]", (*Shell).tree},
+		"stat":  {"stat ruta...", (*Shell).stat},
+		"find":  {"find [ruta] [-name patrón] [-type f|d]", (*Shell).find},
+		"load":  {"load imagen.tar", (*Shell).load},
+		"save":  {"save imagen.tar", (*Shell).save},
+		"help":  {"help", (*Shell).help},
+		"exit":  {"exit", func(*Shell, []string) error { return errExit }},
+	}
+}
+
+// Shell mantiene el estado de una sesión: el sistema de archivos y el
+// directorio de trabajo actual
+type Shell struct {
+	FS  *minifs.FileSystem
+	Out io.Writer
+	cwd string
+}
+
+// New crea un intérprete sobre fs que escribe su salida en out
+func New(fs *minifs.FileSystem, out io.Writer) *Shell {
+	return &Shell{FS: fs, Out: out, cwd: "/"}
+}
+
+// Cwd devuelve el directorio de trabajo actual
+func (sh *Shell) Cwd() string {
+	return sh.cwd
+}
+
+// Prompt devuelve el texto que se muestra antes de cada comando
+func (sh *Shell) Prompt() string {
+	return "mfsh:" + sh.cwd + "$ "
+}
+
+// Exec ejecuta una línea de comandos. Devuelve io.EOF si la línea pide
+// terminar la sesión.
+func (sh *Shell) Exec(line string) error {
+	args, err := splitArgs(line)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 || strings.HasPrefix(args[0], "#") {
+		return nil
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return errors.New("comando desconocido: " + args[0])
+	}
+
+	if err := cmd.run(sh, args[1:]); err != nil {
+		if err == errExit {
+			return io.EOF
+		}
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	return nil
+}
+
+// Run ejecuta un guion línea por línea. Si echo es verdadero cada línea se
+// imprime con el prompt antes de ejecutarse y los comentarios se muestran
+// como títulos. Los errores de los comandos se
+// reportan en la salida sin detener el guion.
+func (sh *Shell) Run(r io.Reader, echo bool) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if echo {
+			switch trimmed := strings.TrimSpace(line); {
+			case strings.HasPrefix(trimmed, "#"):
+				fmt.Fprintf(sh.Out, "\n%s\n", trimmed)
+			case trimmed != "":
+				fmt.Fprintf(sh.Out, "%s%s\n", sh.Prompt(), line)
+			}
+		}
+
+		if err := sh.Exec(line); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			fmt.Fprintln(sh.Out, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// abs convierte una ruta relativa al directorio de trabajo en absoluta
+func (sh *Shell) abs(path string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(sh.cwd, path)
+	}
+	return filepath.Clean(path)
+}
+
+// splitArgs separa una línea en argumentos respetando comillas simples y dobles
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, errors.New("comillas sin cerrar")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// flags separa las opciones de una letra (como -l o -rp) del resto de argumentos
+func flags(args []string, allowed string) (map[rune]bool, []string, error) {
+	set := make(map[rune]bool)
+	var rest []string
+	for _, arg := range args {
+		if len(arg) < 2 || arg[0] != '-' {
+			rest = append(rest, arg)
+			continue
+		}
+		for _, f := range arg[1:] {
+			if !strings.ContainsRune(allowed, f) {
+				return nil, nil, fmt.Errorf("opción desconocida: -%c", f)
+			}
+			set[f] = true
+		}
+	}
+	return set, rest, nil
+}
+
+func sortedEntries(fs *minifs.FileSystem, dir string) ([]minifs.FileInfo, error) {
+	entries, err := fs.ListDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+func formatLong(info minifs.FileInfo, name string) string {
+	mode := info.Mode.Perm().String()
+	if info.IsDir {
+		mode = "d" + mode[1:]
+		name += "/"
+	}
+	return fmt.Sprintf("%s %8d %s %s", mode, info.Size, info.ModTime.Format("2006-01-02 15:04"), name)
+}
+
+func (sh *Shell) ls(args []string) error {
+	opts, paths, err := flags(args, "l")
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	for i, p := range paths {
+		path := sh.abs(p)
+		info, err := sh.FS.Stat(path)
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir {
+			if opts['l'] {
+				fmt.Fprintln(sh.Out, formatLong(info, p))
+			} else {
+				fmt.Fprintln(sh.Out, p)
+			}
+			continue
+		}
+
+		if len(paths) > 1 {
+			if i > 0 {
+				fmt.Fprintln(sh.Out)
+			}
+			fmt.Fprintf(sh.Out, "%s:\n", p)
+		}
+
+		entries, err := sortedEntries(sh.FS, path)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			switch {
+			case opts['l']:
+				fmt.Fprintln(sh.Out, formatLong(entry, entry.Name))
+			case entry.IsDir:
+				fmt.Fprintln(sh.Out, entry.Name+"/")
+			default:
+				fmt.Fprintln(sh.Out, entry.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func (sh *Shell) cd(args []string) error {
+	target := "/"
+	if len(args) > 0 {
+		target = sh.abs(args[0])
+	}
+
+	info, err := sh.FS.Stat(target)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir {
+		return errors.New("no es un directorio: " + target)
+	}
+
+	sh.cwd = target
+	return nil
+}
+
+func (sh *Shell) pwd(args []string) error {
+	fmt.Fprintln(sh.Out, sh.cwd)
+	return nil
+}
+
+func (sh *Shell) mkdir(args []string) error {
+	opts, paths, err := flags(args, "p")
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return errors.New("uso: " + commands["mkdir"].usage)
+	}
+
+	for _, p := range paths {
+		if opts['p'] {
+			err = sh.FS.MkdirAll(sh.abs(p), 0755)
+		} else {
+			err = sh.FS.CreateDir(sh.abs(p), 0755)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sh *Shell) cat(args []string) error {
+	if len(args) == 0 {
+		return errors.New("uso: " + commands["cat"].usage)
+	}
+
+	for _, p := range args {
+		content, err := sh.FS.ReadFile(sh.abs(p))
+		if err != nil {
+			return err
+		}
+		sh.Out.Write(content)
+	}
+	return nil
+}
+
+func (sh *Shell) echo(args []string) error {
+	var words []string
+	target, appendMode := "", false
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == ">" || arg == ">>":
+			if i+1 >= len(args) {
+				return errors.New("falta el archivo de la redirección")
+			}
+			appendMode = arg == ">>"
+			target = args[i+1]
+			i++
+		case strings.HasPrefix(arg, ">>"):
+			target, appendMode = arg[2:], true
+		case strings.HasPrefix(arg, ">"):
+			target, appendMode = arg[1:], false
+		default:
+			words = append(words, arg)
+		}
+	}
+
+	text := strings.Join(words, " ") + "\n"
+	if target == "" {
+		fmt.Fprint(sh.Out, text)
+		return nil
+	}
+
+	if appendMode {
+		return sh.FS.AppendFile(sh.abs(target), []byte(text))
+	}
+	return sh.FS.WriteFile(sh.abs(target), []byte(text))
+}
+
+func (sh *Shell) rm(args []string) error {
+	opts, paths, err := flags(args, "rf")
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return errors.New("uso: " + commands["rm"].usage)
+	}
+
+	for _, p := range paths {
+		path := sh.abs(p)
+		if opts['r'] {
+			err = sh.FS.RemoveAll(path)
+		} else {
+			info, statErr := sh.FS.Stat(path)
+			if statErr != nil {
+				return statErr
+			}
+			if info.IsDir {
+				return errors.New("es un directorio (use -r): " + p)
+			}
+			err = sh.FS.Remove(path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// target resuelve el destino de mv y cp: si es un directorio existente, el
+// origen se coloca dentro de él
+func (sh *Shell) target(src, dst string) string {
+	dst = sh.abs(dst)
+	if info, err := sh.FS.Stat(dst); err == nil && info.IsDir {
+		return filepath.Join(dst, filepath.Base(src))
+	}
+	return dst
+}
+
+func (sh *Shell) mv(args []string) error {
+	if len(args) != 2 {
+		return errors.New("uso: " + commands["mv"].usage)
+	}
+	return sh.FS.Rename(sh.abs(args[0]), sh.target(sh.abs(args[0]), args[1]))
+}
+
+func (sh *Shell) cp(args []string) error {
+	opts, paths, err := flags(args, "r")
+	if err != nil {
+		return err
+	}
+	if len(paths) != 2 {
+		return errors.New("uso: " + commands["cp"].usage)
+	}
+
+	src := sh.abs(paths[0])
+	info, err := sh.FS.Stat(src)
+	if err != nil {
+		return err
+	}
+	if info.IsDir && !opts['r'] {
+		return errors.New("es un directorio (use -r): " + paths[0])
+	}
+
+	return sh.FS.Copy(src, sh.target(src, paths[1]), minifs.CopyOptions{
+		PreserveMode: true,
+		Overwrite:    minifs.OverwriteAlways,
+	})
+}
+
+func (sh *Shell) du(args []string) error {
+	opts, paths, err := flags(args, "h")
+	if err != nil {
+		return err
+	}
+	path := sh.cwd
+	if len(paths) > 0 {
+		path = sh.abs(paths[0])
+	}
+
+	tree, err := sh.FS.Tree(path, minifs.TreeOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, child := range tree.Root.Children {
+		if child.Info.IsDir {
+			fmt.Fprintf(sh.Out, "%s\t%s\n", duSize(child.Size, opts['h']), child.Path)
+		}
+	}
+	fmt.Fprintf(sh.Out, "%s\t%s\n", duSize(tree.Root.Size, opts['h']), path)
+	return nil
+}
+
+// duSize es el tamaño como lo muestra du: en bytes, sin unidad, salvo con -h
+func duSize(size int64, human bool) string {
+	if !human {
+		return strconv.FormatInt(size, 10)
+	}
+	return minifs.FormatSize(size, true)
+}
+
+func (sh *Shell) tree(args []string) error {
+	opts, paths, err := flags(args, "ahst")
+	if err != nil {
+		return err
+	}
+	path := sh.cwd
+	if len(paths) > 0 {
+		path = sh.abs(paths[0])
+	}
+
+	treeOpts := minifs.TreeOptions{ASCII: opts['a'], HumanSizes: opts['h']}
+	switch {
+	case opts['s']:
+		treeOpts.Sort, treeOpts.DirSizes = minifs.SortBySize, true
+	case opts['t']:
+		treeOpts.Sort = minifs.SortByModTime
+	}
+
+	tree, err := sh.FS.Tree(path, treeOpts)
+	if err != nil {
+		return err
+	}
+	_, err = tree.WriteTo(sh.Out)
+	return err
+}
+
+func (sh *Shell) stat(args []string) error {
+	if len(args) == 0 {
+		return errors.New("uso: " + commands["stat"].usage)
+	}
+
+	for _, p := range args {
+		info, err := sh.FS.Stat(sh.abs(p))
+		if err != nil {
+			return err
+		}
+
+		kind := "archivo"
+		if info.IsDir {
+			kind = "directorio"
+		}
+		fmt.Fprintf(sh.Out, "  Ruta: %s\n", sh.abs(p))
+		fmt.Fprintf(sh.Out, "  Tipo: %s\n", kind)
+		fmt.Fprintf(sh.Out, "Tamaño: %d bytes\n", info.Size)
+		fmt.Fprintf(sh.Out, "  Modo: %v (%o)\n", info.Mode, info.Mode.Perm())
+		fmt.Fprintf(sh.Out, "Modif.: %s\n", info.ModTime.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (sh *Shell) find(args []string) error {
+	root := sh.cwd
+	pattern, kind := "", ""
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-name", "-type":
+			if i+1 >= len(args) {
+				return errors.New("falta el valor de " + args[i])
+			}
+			if args[i] == "-name" {
+				pattern = args[i+1]
+			} else {
+				kind = args[i+1]
+			}
+			i++
+		default:
+			root = sh.abs(args[i])
+		}
+	}
+
+	if pattern != "" {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return err
+		}
+	}
+
+	var matches []string
+	err := sh.FS.Walk(root, func(path string, info minifs.FileInfo) error {
+		if kind == "f" && info.IsDir || kind == "d" && !info.IsDir {
+			return nil
+		}
+		if pattern != "" {
+			if ok, _ := filepath.Match(pattern, info.Name); !ok {
+				return nil
+			}
+		}
+		matches = append(matches, path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(matches)
+	for _, m := range matches {
+		fmt.Fprintln(sh.Out, m)
+	}
+	return nil
+}
+
+// load y save trabajan con archivos del sistema operativo anfitrión
+
+func (sh *Shell) load(args []string) error {
+	if len(args) != 1 {
+		return errors.New("uso: " + commands["load"].usage)
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fs, err := minifs.LoadImage(f)
+	if err != nil {
+		return err
+	}
+
+	sh.FS = fs
+	sh.cwd = "/"
+	return nil
+}
+
+func (sh *Shell) save(args []string) error {
+	if len(args) != 1 {
+		return errors.New("uso: " + commands["save"].usage)
+	}
+
+	f, err := os.Create(args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := sh.FS.SaveImage(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (sh *Shell) help(args []string) error {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(sh.Out, "  %s\n", commands[name].usage)
+	}
+	return nil
+}
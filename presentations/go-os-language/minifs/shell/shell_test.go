@@ -0,0 +1,184 @@
+package shell
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hectorip/minifs"
+)
+
+func newTestShell() (*Shell, *bytes.Buffer) {
+	var out bytes.Buffer
+	return New(minifs.NewFileSystem(), &out), &out
+}
+
+func run(t *testing.T, sh *Shell, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if err := sh.Exec(line); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+	}
+}
+
+func TestCommands(t *testing.T) {
+	sh, out := newTestShell()
+
+	t.Run("MkdirCdPwd", func(t *testing.T) {
+		run(t, sh, "mkdir -p /home/user/docs", "cd /home/user", "cd docs")
+		out.Reset()
+		run(t, sh, "pwd")
+		if got := out.String(); got != "/home/user/docs\n" {
+			t.Errorf("pwd incorrecto: %q", got)
+		}
+		run(t, sh, "cd ..")
+		if sh.Cwd() != "/home/user" {
+			t.Errorf("cd .. incorrecto: %s", sh.Cwd())
+		}
+	})
+
+	t.Run("EchoRedirect", func(t *testing.T) {
+		run(t, sh, `echo "hola mundo" > saludo.txt`, "echo adiós >> saludo.txt")
+		data, err := sh.FS.ReadFile("/home/user/saludo.txt")
+		if err != nil {
+			t.Fatalf("Archivo no creado: %v", err)
+		}
+		if string(data) != "hola mundo\nadiós\n" {
+			t.Errorf("Contenido incorrecto: %q", data)
+		}
+
+		out.Reset()
+		run(t, sh, "cat saludo.txt")
+		if out.String() != string(data) {
+			t.Errorf("cat incorrecto: %q", out.String())
+		}
+	})
+
+	t.Run("Ls", func(t *testing.T) {
+		out.Reset()
+		run(t, sh, "ls")
+		if got := out.String(); got != "docs/\nsaludo.txt\n" {
+			t.Errorf("ls incorrecto: %q", got)
+		}
+
+		out.Reset()
+		run(t, sh, "ls -l /home/user")
+		if !strings.Contains(out.String(), "-rw-r--r--") || !strings.Contains(out.String(), "drwxr-xr-x") {
+			t.Errorf("ls -l sin permisos: %q", out.String())
+		}
+	})
+
+	t.Run("CpMv", func(t *testing.T) {
+		run(t, sh, "cp saludo.txt docs", "mv docs/saludo.txt docs/copia.txt", "cp -r docs respaldo")
+		if !sh.FS.Exists("/home/user/respaldo/copia.txt") {
+			t.Error("cp -r no copió el directorio")
+		}
+		if err := sh.Exec("cp docs otro"); err == nil {
+			t.Error("cp de un directorio sin -r debería fallar")
+		}
+	})
+
+	t.Run("FindDuTree", func(t *testing.T) {
+		out.Reset()
+		run(t, sh, "find / -name *.txt -type f")
+		want := "/home/user/docs/copia.txt\n/home/user/respaldo/copia.txt\n/home/user/saludo.txt\n"
+		if out.String() != want {
+			t.Errorf("find incorrecto:\n%s", out.String())
+		}
+
+		out.Reset()
+		run(t, sh, "du /home")
+		if !strings.HasSuffix(out.String(), "54\t/home\n") {
+			t.Errorf("du incorrecto:\n%s", out.String())
+		}
+
+		out.Reset()
+		run(t, sh, "tree /home/user/docs")
+		if out.String() != "/home/user/docs\n└── copia.txt (18 bytes)\n" {
+			t.Errorf("tree incorrecto:\n%s", out.String())
+		}
+
+		out.Reset()
+		run(t, sh, "tree -as /home/user")
+		want = "/home/user (54 bytes)\n" +
+			"|-- docs/ (18 bytes)\n" +
+			"|   `-- copia.txt (18 bytes)\n" +
+			"|-- respaldo/ (18 bytes)\n" +
+			"|   `-- copia.txt (18 bytes)\n" +
+			"`-- saludo.txt (18 bytes)\n"
+		if out.String() != want {
+			t.Errorf("tree -as incorrecto:\n%s", out.String())
+		}
+	})
+
+	t.Run("Rm", func(t *testing.T) {
+		if err := sh.Exec("rm respaldo"); err == nil {
+			t.Error("rm de un directorio sin -r debería fallar")
+		}
+		run(t, sh, "rm -r respaldo", "rm saludo.txt")
+		if sh.FS.Exists("/home/user/respaldo") || sh.FS.Exists("/home/user/saludo.txt") {
+			t.Error("rm no eliminó las rutas")
+		}
+	})
+
+	t.Run("SaveLoad", func(t *testing.T) {
+		image := filepath.Join(t.TempDir(), "img.tar")
+		run(t, sh, "save "+image)
+
+		other, _ := newTestShell()
+		run(t, other, "load "+image)
+		if !other.FS.Exists("/home/user/docs/copia.txt") {
+			t.Error("La imagen cargada no contiene los archivos")
+		}
+	})
+}
+
+func TestRunScript(t *testing.T) {
+	sh, out := newTestShell()
+	script := "mkdir /a\n# comentario\ncat /noexiste\necho listo\nexit\necho nunca\n"
+
+	if err := sh.Run(strings.NewReader(script), false); err != nil {
+		t.Fatalf("Error ejecutando guion: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "cat: ") {
+		t.Error("El error de cat no se reportó")
+	}
+	if !strings.HasSuffix(out.String(), "listo\n") {
+		t.Errorf("exit no detuvo el guion: %q", out.String())
+	}
+}
+
+func TestComplete(t *testing.T) {
+	sh, _ := newTestShell()
+	run(t, sh, "mkdir -p /proyectos/go /proyectos/python", "echo x > /programa.txt")
+
+	tests := []struct {
+		line       string
+		want       string
+		candidates []string
+	}{
+		{"tr", "tree ", nil},
+		{"c", "c", []string{"cat", "cd", "cp"}},
+		{"ls /pro", "ls /pro", []string{"/programa.txt", "/proyectos/"}},
+		{"ls /proy", "ls /proyectos/", nil},
+		{"cd /proyectos/p", "cd /proyectos/python/", nil},
+		{"cat nada", "cat nada", nil},
+	}
+
+	for _, tt := range tests {
+		got, candidates := sh.Complete(tt.line)
+		if got != tt.want || !reflect.DeepEqual(candidates, tt.candidates) {
+			t.Errorf("Complete(%q) = %q %v, want %q %v", tt.line, got, candidates, tt.want, tt.candidates)
+		}
+	}
+
+	// Las rutas relativas se completan desde el directorio actual
+	run(t, sh, "cd /proyectos")
+	if got, _ := sh.Complete("cd g"); got != "cd go/" {
+		t.Errorf("Completado relativo incorrecto: %q", got)
+	}
+}
@@ -0,0 +1,154 @@
+package minifs
+
+import "sort"
+
+// pageSize es el tamaño de cada página de contenido y el Blksize que
+// reporta Stat
+const pageSize = 4096
+
+// sparseData guarda el contenido de un archivo en páginas de pageSize
+// bytes indexadas por su número. Las páginas que nunca se escribieron no
+// existen: son huecos que se leen como ceros y no ocupan memoria, así que
+// escribir un byte en el offset 10 GiB solo reserva una página.
+//
+// El tamaño aparente del archivo vive en Node.size; sparseData solo sabe
+// qué páginas tienen datos.
+type sparseData struct {
+	pages map[int64][]byte
+}
+
+// newSparseData crea el contenido a partir de un slice, copiándolo
+func newSparseData(content []byte) sparseData {
+	var d sparseData
+	d.writeAt(content, 0)
+	return d
+}
+
+// readAt copia en p los bytes desde off hasta como mucho size y devuelve
+// cuántos copió. Los huecos se leen como ceros.
+func (d *sparseData) readAt(p []byte, off, size int64) int {
+	if off >= size {
+		return 0
+	}
+	if rest := size - off; int64(len(p)) > rest {
+		p = p[:rest]
+	}
+
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		page, inPage := pos/pageSize, pos%pageSize
+		chunk := p[n:]
+		if max := pageSize - inPage; int64(len(chunk)) > max {
+			chunk = chunk[:max]
+		}
+
+		if data, ok := d.pages[page]; ok {
+			copy(chunk, data[inPage:])
+		} else {
+			clear(chunk)
+		}
+		n += len(chunk)
+	}
+
+	return n
+}
+
+// writeAt escribe p a partir de off reservando solo las páginas que toca
+func (d *sparseData) writeAt(p []byte, off int64) {
+	if len(p) > 0 && d.pages == nil {
+		d.pages = make(map[int64][]byte)
+	}
+
+	for n := 0; n < len(p); {
+		pos := off + int64(n)
+		page, inPage := pos/pageSize, pos%pageSize
+
+		data, ok := d.pages[page]
+		if !ok {
+			data = make([]byte, pageSize)
+			d.pages[page] = data
+		}
+		n += copy(data[inPage:], p[n:])
+	}
+}
+
+// truncate descarta los datos a partir de size. Las páginas enteras se
+// liberan y el final de la última se pone a cero para que, si el archivo
+// vuelve a crecer, esa zona se lea como ceros.
+func (d *sparseData) truncate(size int64) {
+	last := size / pageSize
+	for page := range d.pages {
+		if page > last || (page == last && size%pageSize == 0) {
+			delete(d.pages, page)
+		}
+	}
+
+	if data, ok := d.pages[last]; ok {
+		clear(data[size%pageSize:])
+	}
+}
+
+// bytes devuelve el contenido completo como un slice nuevo de size bytes
+func (d *sparseData) bytes(size int64) []byte {
+	content := make([]byte, size)
+	d.readAt(content, 0, size)
+	return content
+}
+
+// clone copia las páginas con datos, conservando los huecos
+func (d *sparseData) clone() sparseData {
+	c := sparseData{pages: make(map[int64][]byte, len(d.pages))}
+	for page, data := range d.pages {
+		c.pages[page] = append([]byte(nil), data...)
+	}
+	return c
+}
+
+// allocated devuelve los bytes realmente reservados
+func (d *sparseData) allocated() int64 {
+	return int64(len(d.pages)) * pageSize
+}
+
+// sortedPages devuelve los números de página con datos en orden
+func (d *sparseData) sortedPages() []int64 {
+	pages := make([]int64, 0, len(d.pages))
+	for page := range d.pages {
+		pages = append(pages, page)
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i] < pages[j] })
+	return pages
+}
+
+// seekData devuelve el primer offset >= off que tiene datos, o -1 si
+// desde off hasta size solo hay huecos
+func (d *sparseData) seekData(off, size int64) int64 {
+	for _, page := range d.sortedPages() {
+		start := page * pageSize
+		if start+pageSize <= off {
+			continue
+		}
+		if start < off {
+			start = off
+		}
+		if start >= size {
+			break
+		}
+		return start
+	}
+	return -1
+}
+
+// seekHole devuelve el primer offset >= off que está en un hueco. Como en
+// Linux, el final del archivo cuenta como un hueco implícito.
+func (d *sparseData) seekHole(off, size int64) int64 {
+	for page := off / pageSize; page*pageSize < size; page++ {
+		if _, ok := d.pages[page]; !ok {
+			if start := page * pageSize; start > off {
+				return start
+			}
+			return off
+		}
+	}
+	return size
+}
@@ -0,0 +1,128 @@
+package minifs
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+const gib = 1 << 30
+
+func TestSparseFiles(t *testing.T) {
+	fs := NewFileSystem()
+	fs.WriteFile("/disperso", nil)
+
+	f, _ := fs.Open("/disperso")
+	defer f.Close()
+
+	t.Run("WriteFarAway", func(t *testing.T) {
+		if _, err := f.WriteAt([]byte("fin"), 10*gib); err != nil {
+			t.Fatal(err)
+		}
+
+		st := statT(t, fs, "/disperso")
+		if st.Size != 10*gib+3 {
+			t.Errorf("Tamaño aparente incorrecto: %d", st.Size)
+		}
+		if st.Blocks != pageSize/512 {
+			t.Errorf("Se reservaron %d bloques, want %d", st.Blocks, pageSize/512)
+		}
+
+		buf := make([]byte, 5)
+		f.ReadAt(buf, 10*gib-2)
+		if !bytes.Equal(buf, []byte("\x00\x00fin")) {
+			t.Errorf("Lectura a través del hueco incorrecta: %q", buf)
+		}
+	})
+
+	t.Run("SeekDataHole", func(t *testing.T) {
+		if pos, err := f.Seek(0, SeekData); err != nil || pos != 10*gib {
+			t.Errorf("SeekData desde 0: %d %v", pos, err)
+		}
+		if pos, _ := f.Seek(0, SeekHole); pos != 0 {
+			t.Errorf("SeekHole desde 0: %d", pos)
+		}
+		if pos, _ := f.Seek(10*gib, SeekHole); pos != 10*gib+3 {
+			t.Errorf("El final del archivo debería contar como hueco: %d", pos)
+		}
+		if _, err := f.Seek(11*gib, SeekData); err == nil {
+			t.Error("SeekData más allá del final debería fallar")
+		}
+	})
+
+	t.Run("Truncate", func(t *testing.T) {
+		fs.WriteFile("/t.txt", []byte("hola mundo"))
+
+		fs.Truncate("/t.txt", 4)
+		if data, _ := fs.ReadFile("/t.txt"); string(data) != "hola" {
+			t.Errorf("Truncate al reducir: %q", data)
+		}
+
+		// Al crecer de nuevo los bytes descartados no reaparecen
+		if err := fs.Truncate("/t.txt", 8); err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := fs.ReadFile("/t.txt"); string(data) != "hola\x00\x00\x00\x00" {
+			t.Errorf("Truncate al crecer: %q", data)
+		}
+
+		if err := fs.Truncate("/t.txt", 5*gib); err != nil {
+			t.Fatal(err)
+		}
+		if st := statT(t, fs, "/t.txt"); st.Size != 5*gib || st.Blocks != pageSize/512 {
+			t.Errorf("Truncate no debería reservar memoria: %+v", st)
+		}
+
+		if err := fs.Truncate("/", 0); err == nil {
+			t.Error("Truncate de un directorio debería fallar")
+		}
+	})
+
+	t.Run("AppendAcrossPages", func(t *testing.T) {
+		content := bytes.Repeat([]byte("0123456789"), pageSize/5)
+		fs.WriteFile("/grande", content[:pageSize-3])
+		fs.AppendFile("/grande", content[pageSize-3:])
+
+		r, _ := fs.Open("/grande")
+		defer r.Close()
+		data, _ := io.ReadAll(r)
+		if !bytes.Equal(data, content) {
+			t.Error("El contenido que cruza páginas no coincide")
+		}
+	})
+}
+
+// Los huecos no ocupan memoria: escribir lejos cuesta lo mismo que escribir
+// al principio
+func BenchmarkSparseWrite(b *testing.B) {
+	fs := NewFileSystem()
+	fs.WriteFile("/disperso", nil)
+	f, _ := fs.Open("/disperso")
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		f.WriteAt([]byte("x"), int64(i)*gib)
+	}
+}
+
+func BenchmarkDenseWrite(b *testing.B) {
+	fs := NewFileSystem()
+	fs.WriteFile("/denso", nil)
+	f, _ := fs.Open("/denso")
+	page := make([]byte, pageSize)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		f.WriteAt(page, int64(i)*pageSize)
+	}
+}
+
+func BenchmarkTruncateGrow(b *testing.B) {
+	fs := NewFileSystem()
+	fs.WriteFile("/t", nil)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		fs.Truncate("/t", int64(i+1)*gib)
+	}
+}
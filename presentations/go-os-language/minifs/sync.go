@@ -0,0 +1,254 @@
+package minifs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// SyncCompare decide cómo Sync detecta que un archivo cambió
+type SyncCompare int
+
+const (
+	// CompareSizeTime compara tamaño y fecha de modificación, como la
+	// verificación rápida de rsync
+	CompareSizeTime SyncCompare = iota
+	// CompareChecksum compara el SHA-256 del contenido
+	CompareChecksum
+)
+
+// SyncOptions configura Sync
+type SyncOptions struct {
+	Compare SyncCompare
+	// Delete elimina del destino lo que no está en el origen
+	Delete bool
+	// DryRun solo calcula las operaciones, sin tocar el destino
+	DryRun bool
+}
+
+// SyncAction es el tipo de una operación de Sync
+type SyncAction int
+
+const (
+	SyncCreateDir SyncAction = iota
+	SyncCopy
+	SyncUpdate
+	SyncDelete
+)
+
+func (a SyncAction) String() string {
+	switch a {
+	case SyncCreateDir:
+		return "crear"
+	case SyncCopy:
+		return "copiar"
+	case SyncUpdate:
+		return "actualizar"
+	case SyncDelete:
+		return "eliminar"
+	}
+	return "desconocida"
+}
+
+// SyncOp es una operación que Sync hizo, o haría con DryRun, sobre el
+// destino. Path es la ruta en el volumen de destino.
+type SyncOp struct {
+	Action SyncAction
+	Path   string
+	IsDir  bool
+	Size   int64 // bytes copiados en SyncCopy y SyncUpdate
+}
+
+func (op SyncOp) String() string {
+	path := op.Path
+	if op.IsDir {
+		path += "/"
+	}
+	if op.Action == SyncCopy || op.Action == SyncUpdate {
+		return fmt.Sprintf("%-10s %s (%d bytes)", op.Action, path, op.Size)
+	}
+	return fmt.Sprintf("%-10s %s", op.Action, path)
+}
+
+// Sync hace que dst en dstFS quede igual que src en srcFS copiando solo
+// los archivos que cambiaron, como rsync -a. Funciona en cualquier
+// dirección entre FileSystem y el sistema operativo a través de HostDir.
+// Devuelve las operaciones en el orden en que se aplicaron; con DryRun son
+// las que se aplicarían. Los enlaces simbólicos del origen se omiten.
+func Sync(srcFS Volume, src string, dstFS Volume, dst string, opts SyncOptions) ([]SyncOp, error) {
+	info, err := srcFS.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if from, to, ok := sameTree(srcFS, src, dstFS, dst); ok {
+		if from == to {
+			return nil, errors.New("origen y destino son el mismo: " + src)
+		}
+		if info.IsDir && isSubPath(from, to) {
+			return nil, errors.New("no se puede sincronizar un directorio dentro de sí mismo: " + dst)
+		}
+	}
+
+	s := &syncer{srcFS: srcFS, dstFS: dstFS, opts: opts}
+	err = s.syncNode(src, dst, info)
+	return s.ops, err
+}
+
+// syncer guarda el estado de una sincronización
+type syncer struct {
+	srcFS Volume
+	dstFS Volume
+	opts  SyncOptions
+	ops   []SyncOp
+}
+
+// apply registra op y, si no es una prueba, la ejecuta
+func (s *syncer) apply(op SyncOp, do func() error) error {
+	s.ops = append(s.ops, op)
+	if s.opts.DryRun {
+		return nil
+	}
+	return do()
+}
+
+func (s *syncer) syncNode(src, dst string, info FileInfo) error {
+	if info.Mode&os.ModeSymlink != 0 {
+		return nil
+	}
+
+	var existing FileInfo
+	exists := s.dstFS.Exists(dst)
+	if exists {
+		var err error
+		if existing, err = s.dstFS.Stat(dst); err != nil {
+			return err
+		}
+	}
+
+	// Un archivo que pasó a ser directorio, o al revés, se reemplaza
+	if exists && existing.IsDir != info.IsDir {
+		op := SyncOp{Action: SyncDelete, Path: dst, IsDir: existing.IsDir}
+		if err := s.apply(op, func() error { return s.dstFS.RemoveAll(dst) }); err != nil {
+			return err
+		}
+		exists = false
+	}
+
+	if info.IsDir {
+		return s.syncDir(src, dst, info, exists)
+	}
+	return s.syncFile(src, dst, info, existing, exists)
+}
+
+func (s *syncer) syncFile(src, dst string, info, existing FileInfo, exists bool) error {
+	action := SyncCopy
+	if exists {
+		changed, err := s.changed(src, info, dst, existing)
+		if err != nil || !changed {
+			return err
+		}
+		action = SyncUpdate
+	}
+
+	op := SyncOp{Action: action, Path: dst, Size: info.Size}
+	return s.apply(op, func() error {
+		content, err := s.srcFS.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		// Se sobrescribe en lugar de eliminar y crear, para que un
+		// FileSystem con revisiones guarde la anterior
+		if err := s.dstFS.CreateFile(dst, content, info.Mode.Perm()); err != nil {
+			return err
+		}
+		return s.dstFS.SetModTime(dst, info.ModTime)
+	})
+}
+
+// changed compara un archivo del origen con el del destino según opts
+func (s *syncer) changed(src string, info FileInfo, dst string, existing FileInfo) (bool, error) {
+	if info.Size != existing.Size {
+		return true, nil
+	}
+	if s.opts.Compare == CompareSizeTime {
+		return !info.ModTime.Equal(existing.ModTime), nil
+	}
+
+	srcSum, err := checksum(s.srcFS, src)
+	if err != nil {
+		return false, err
+	}
+	dstSum, err := checksum(s.dstFS, dst)
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(srcSum, dstSum), nil
+}
+
+func checksum(v Volume, path string) ([]byte, error) {
+	content, err := v.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(content)
+	return sum[:], nil
+}
+
+func (s *syncer) syncDir(src, dst string, info FileInfo, exists bool) error {
+	if !exists {
+		op := SyncOp{Action: SyncCreateDir, Path: dst, IsDir: true}
+		if err := s.apply(op, func() error { return s.dstFS.CreateDir(dst, info.Mode.Perm()) }); err != nil {
+			return err
+		}
+	}
+
+	entries, err := s.srcFS.ListDir(src)
+	if err != nil {
+		return err
+	}
+	sortByName(entries)
+
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		seen[entry.Name] = true
+		if err := s.syncNode(filepath.Join(src, entry.Name), filepath.Join(dst, entry.Name), entry); err != nil {
+			return err
+		}
+	}
+
+	// Con DryRun el directorio puede no existir todavía: no hay nada que
+	// eliminar
+	if s.opts.Delete && exists {
+		extra, err := s.dstFS.ListDir(dst)
+		if err != nil {
+			return err
+		}
+		sortByName(extra)
+
+		for _, entry := range extra {
+			if seen[entry.Name] {
+				continue
+			}
+			path := filepath.Join(dst, entry.Name)
+			op := SyncOp{Action: SyncDelete, Path: path, IsDir: entry.IsDir}
+			if err := s.apply(op, func() error { return s.dstFS.RemoveAll(path) }); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Crear y eliminar hijos cambia la fecha del directorio; se fija al final
+	if s.opts.DryRun {
+		return nil
+	}
+	return s.dstFS.SetModTime(dst, info.ModTime)
+}
+
+func sortByName(entries []FileInfo) {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+}
@@ -0,0 +1,136 @@
+package minifs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// syncPlan resume las operaciones para compararlas en los tests
+func syncPlan(ops []SyncOp) []string {
+	plan := make([]string, 0, len(ops))
+	for _, op := range ops {
+		path := op.Path
+		if op.IsDir {
+			path += "/"
+		}
+		plan = append(plan, op.Action.String()+" "+path)
+	}
+	return plan
+}
+
+func checkPlan(t *testing.T, ops []SyncOp, want ...string) {
+	t.Helper()
+	got := syncPlan(ops)
+	if len(got) != len(want) {
+		t.Fatalf("Operaciones %q, se esperaba %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Operación %d: %q, se esperaba %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSync(t *testing.T) {
+	dir := t.TempDir()
+	host := HostDir(dir)
+
+	fs := newCheckedFS(t)
+	fs.MkdirAll("/proyecto/src", 0755)
+	fs.WriteFile("/proyecto/README", []byte("léeme"))
+	fs.WriteFile("/proyecto/src/main.go", []byte("package main"))
+
+	t.Run("DryRun", func(t *testing.T) {
+		ops, err := Sync(fs, "/proyecto", host, "/out", SyncOptions{DryRun: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPlan(t, ops, "crear /out/", "copiar /out/README", "crear /out/src/", "copiar /out/src/main.go")
+		if _, err := os.Stat(filepath.Join(dir, "out")); !os.IsNotExist(err) {
+			t.Error("DryRun modificó el destino")
+		}
+	})
+
+	t.Run("ToHost", func(t *testing.T) {
+		if _, err := Sync(fs, "/proyecto", host, "/out", SyncOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "out", "src", "main.go"))
+		if err != nil || string(data) != "package main" {
+			t.Fatalf("Contenido en el anfitrión: %q %v", data, err)
+		}
+
+		// Sin cambios no hay nada que hacer
+		ops, _ := Sync(fs, "/proyecto", host, "/out", SyncOptions{})
+		checkPlan(t, ops)
+	})
+
+	t.Run("Changes", func(t *testing.T) {
+		fs.WriteFile("/proyecto/README", []byte("léeme, versión 2"))
+		fs.WriteFile("/proyecto/src/util.go", []byte("package main"))
+		os.WriteFile(filepath.Join(dir, "out", "sobra.txt"), []byte("x"), 0644)
+
+		ops, err := Sync(fs, "/proyecto", host, "/out", SyncOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPlan(t, ops, "actualizar /out/README", "copiar /out/src/util.go")
+
+		ops, _ = Sync(fs, "/proyecto", host, "/out", SyncOptions{Delete: true})
+		checkPlan(t, ops, "eliminar /out/sobra.txt")
+		if _, err := os.Stat(filepath.Join(dir, "out", "sobra.txt")); !os.IsNotExist(err) {
+			t.Error("Delete no eliminó lo que sobraba")
+		}
+	})
+
+	t.Run("FromHost", func(t *testing.T) {
+		copia := newCheckedFS(t)
+		if _, err := Sync(host, "/out", copia, "/", SyncOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := copia.ReadFile("/src/util.go"); string(data) != "package main" {
+			t.Errorf("Contenido traído del anfitrión: %q", data)
+		}
+		ops, _ := Sync(host, "/out", copia, "/", SyncOptions{})
+		checkPlan(t, ops)
+	})
+
+	t.Run("TypeChange", func(t *testing.T) {
+		fs.RemoveAll("/proyecto/src")
+		fs.WriteFile("/proyecto/src", []byte("ahora es un archivo"))
+
+		ops, err := Sync(fs, "/proyecto", host, "/out", SyncOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPlan(t, ops, "eliminar /out/src/", "copiar /out/src")
+	})
+}
+
+func TestSyncChecksum(t *testing.T) {
+	src, dst := newCheckedFS(t), newCheckedFS(t)
+	src.WriteFile("/a.txt", []byte("hola"))
+	Sync(src, "/", dst, "/", SyncOptions{})
+
+	// Mismo tamaño y misma fecha: solo la suma detecta el cambio
+	info, _ := src.Stat("/a.txt")
+	src.WriteFile("/a.txt", []byte("HOLA"))
+	src.SetModTime("/a.txt", info.ModTime)
+
+	ops, _ := Sync(src, "/", dst, "/", SyncOptions{})
+	checkPlan(t, ops)
+
+	ops, err := Sync(src, "/", dst, "/", SyncOptions{Compare: CompareChecksum})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPlan(t, ops, "actualizar /a.txt")
+	if data, _ := dst.ReadFile("/a.txt"); string(data) != "HOLA" {
+		t.Errorf("Contenido tras sincronizar: %q", data)
+	}
+
+	if _, err := Sync(src, "/", src, "/x", SyncOptions{}); err == nil {
+		t.Error("Se sincronizó un directorio dentro de sí mismo")
+	}
+}
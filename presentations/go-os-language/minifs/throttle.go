@@ -0,0 +1,395 @@
+package minifs
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+// Clock es el reloj que usa ThrottledFS para medir y esperar. Con un
+// FakeClock los tests no esperan de verdad y dan siempre lo mismo.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// SystemClock es el reloj del sistema operativo
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time        { return time.Now() }
+func (systemClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// FakeClock es un reloj simulado: Sleep no bloquea, sólo adelanta la hora.
+// Así un test puede verificar cuánto habría tardado una operación sin
+// esperarla.
+type FakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	slept time.Duration
+}
+
+// NewFakeClock crea un reloj simulado que empieza en start
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Sleep adelanta el reloj d y lo suma a Slept
+func (c *FakeClock) Sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	c.slept += d
+}
+
+// Advance adelanta el reloj sin contarlo como espera, como el tiempo que
+// pasa entre operaciones
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// Slept devuelve el total de las esperas
+func (c *FakeClock) Slept() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.slept
+}
+
+// Latency sortea la latencia de una operación
+type Latency func(r *rand.Rand) time.Duration
+
+// FixedLatency tarda siempre d
+func FixedLatency(d time.Duration) Latency {
+	return func(*rand.Rand) time.Duration { return d }
+}
+
+// UniformLatency tarda entre lo y hi con la misma probabilidad
+func UniformLatency(lo, hi time.Duration) Latency {
+	return func(r *rand.Rand) time.Duration {
+		if hi <= lo {
+			return lo
+		}
+		return lo + time.Duration(r.Int63n(int64(hi-lo)+1))
+	}
+}
+
+// NormalLatency sigue una distribución normal recortada en cero
+func NormalLatency(mean, stddev time.Duration) Latency {
+	return func(r *rand.Rand) time.Duration {
+		return max(0, mean+time.Duration(r.NormFloat64()*float64(stddev)))
+	}
+}
+
+// ExponentialLatency tarda en promedio mean, con una cola larga de
+// operaciones lentas como la de un disco compartido
+func ExponentialLatency(mean time.Duration) Latency {
+	return func(r *rand.Rand) time.Duration {
+		return time.Duration(r.ExpFloat64() * float64(mean))
+	}
+}
+
+// ThrottleOptions configura un ThrottledFS. Los límites en cero no
+// limitan.
+type ThrottleOptions struct {
+	// ReadBytesPerSec y WriteBytesPerSec son el ancho de banda de lectura
+	// (ReadFile) y de escritura (CreateFile, WriteFile y AppendFile)
+	ReadBytesPerSec  int64
+	WriteBytesPerSec int64
+
+	// Burst es cuántos bytes se pueden transferir de golpe con el balde
+	// lleno, después de un rato sin actividad; cero equivale a un segundo
+	// de ancho de banda
+	Burst int64
+
+	// IOPS limita las operaciones por segundo, de cualquier tipo
+	IOPS float64
+
+	// Latency se suma a todas las operaciones; OpLatency la reemplaza para
+	// los métodos que nombra ("ReadFile", "Rename", ...)
+	Latency   Latency
+	OpLatency map[string]Latency
+
+	// Clock mide y espera; nil usa SystemClock
+	Clock Clock
+
+	// Seed hace reproducibles las latencias al azar
+	Seed int64
+}
+
+// ThrottleStats son los contadores de un ThrottledFS
+type ThrottleStats struct {
+	Ops          uint64
+	BytesRead    int64
+	BytesWritten int64
+	Waited       time.Duration // suma de las esperas impuestas
+}
+
+// ThrottledFS envuelve un Backend y hace que cada operación tarde lo que
+// tardaría en un disco lento: una latencia por operación, un tope de
+// operaciones por segundo y un ancho de banda de lectura y escritura. Los
+// topes son baldes de fichas, así que después de un rato sin uso se
+// permite una ráfaga. Las esperas se piden al Clock después de hacer la
+// operación, de modo que con un FakeClock no se espera de verdad.
+type ThrottledFS struct {
+	backend Backend
+	opts    ThrottleOptions
+	clock   Clock
+
+	mu    sync.Mutex
+	rng   *rand.Rand
+	iops  *bucket
+	read  *bucket
+	write *bucket
+	stats ThrottleStats
+}
+
+var _ Backend = (*ThrottledFS)(nil)
+
+// NewThrottledFS envuelve b con los límites de opts
+func NewThrottledFS(b Backend, opts ThrottleOptions) *ThrottledFS {
+	t := &ThrottledFS{
+		backend: b,
+		opts:    opts,
+		clock:   opts.Clock,
+		rng:     rand.New(rand.NewSource(opts.Seed)),
+	}
+	if t.clock == nil {
+		t.clock = SystemClock
+	}
+
+	now := t.clock.Now()
+	if opts.IOPS > 0 {
+		t.iops = newBucket(opts.IOPS, 1, now)
+	}
+	if opts.ReadBytesPerSec > 0 {
+		t.read = newBucket(float64(opts.ReadBytesPerSec), float64(burst(opts.Burst, opts.ReadBytesPerSec)), now)
+	}
+	if opts.WriteBytesPerSec > 0 {
+		t.write = newBucket(float64(opts.WriteBytesPerSec), float64(burst(opts.Burst, opts.WriteBytesPerSec)), now)
+	}
+	return t
+}
+
+func burst(b, rate int64) int64 {
+	if b > 0 {
+		return b
+	}
+	return rate
+}
+
+// Stats devuelve los contadores
+func (t *ThrottledFS) Stats() ThrottleStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.stats
+}
+
+// bucket es un balde de fichas que admite deuda: quien pide más fichas
+// de las que hay las obtiene igual y espera lo que tarda en reponerlas.
+// Así los que llegan después esperan detrás de él, como en una cola.
+type bucket struct {
+	rate   float64 // fichas por segundo
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate, burst float64, now time.Time) *bucket {
+	return &bucket{rate: rate, burst: burst, tokens: burst, last: now}
+}
+
+// take reserva n fichas y devuelve cuánto hay que esperar para usarlas
+func (b *bucket) take(n float64, now time.Time) time.Duration {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(-b.tokens / b.rate * float64(time.Second)))
+}
+
+// wait calcula la espera de una operación que leyó o escribió esa
+// cantidad de bytes y la cumple. Los baldes se reservan juntos, así que
+// gana el más lento, y la latencia se suma.
+func (t *ThrottledFS) wait(op string, read, written int64) {
+	t.mu.Lock()
+	now := t.clock.Now()
+
+	var delay time.Duration
+	if t.iops != nil {
+		delay = t.iops.take(1, now)
+	}
+	if t.read != nil && read > 0 {
+		delay = max(delay, t.read.take(float64(read), now))
+	}
+	if t.write != nil && written > 0 {
+		delay = max(delay, t.write.take(float64(written), now))
+	}
+
+	latency := t.opts.Latency
+	if l, ok := t.opts.OpLatency[op]; ok {
+		latency = l
+	}
+	if latency != nil {
+		delay += latency(t.rng)
+	}
+
+	t.stats.Ops++
+	t.stats.BytesRead += read
+	t.stats.BytesWritten += written
+	t.stats.Waited += delay
+	t.mu.Unlock()
+
+	t.clock.Sleep(delay)
+}
+
+func (t *ThrottledFS) Exists(path string) bool {
+	exists := t.backend.Exists(path)
+	t.wait("Exists", 0, 0)
+	return exists
+}
+
+func (t *ThrottledFS) Stat(path string) (FileInfo, error) {
+	info, err := t.backend.Stat(path)
+	t.wait("Stat", 0, 0)
+	return info, err
+}
+
+func (t *ThrottledFS) ListDir(path string) ([]FileInfo, error) {
+	entries, err := t.backend.ListDir(path)
+	t.wait("ListDir", 0, 0)
+	return entries, err
+}
+
+// ReadFile espera según los bytes que efectivamente leyó
+func (t *ThrottledFS) ReadFile(path string) ([]byte, error) {
+	content, err := t.backend.ReadFile(path)
+	t.wait("ReadFile", int64(len(content)), 0)
+	return content, err
+}
+
+func (t *ThrottledFS) CreateDir(path string, mode os.FileMode) error {
+	err := t.backend.CreateDir(path, mode)
+	t.wait("CreateDir", 0, 0)
+	return err
+}
+
+func (t *ThrottledFS) MkdirAll(path string, mode os.FileMode) error {
+	err := t.backend.MkdirAll(path, mode)
+	t.wait("MkdirAll", 0, 0)
+	return err
+}
+
+func (t *ThrottledFS) CreateFile(path string, content []byte, mode os.FileMode) error {
+	err := t.backend.CreateFile(path, content, mode)
+	t.wait("CreateFile", 0, written(content, err))
+	return err
+}
+
+func (t *ThrottledFS) WriteFile(path string, content []byte) error {
+	err := t.backend.WriteFile(path, content)
+	t.wait("WriteFile", 0, written(content, err))
+	return err
+}
+
+func (t *ThrottledFS) AppendFile(path string, content []byte) error {
+	err := t.backend.AppendFile(path, content)
+	t.wait("AppendFile", 0, written(content, err))
+	return err
+}
+
+// written cuenta como escritos los bytes de una escritura exitosa
+func written(content []byte, err error) int64 {
+	if err != nil {
+		return 0
+	}
+	return int64(len(content))
+}
+
+func (t *ThrottledFS) Truncate(path string, size int64) error {
+	err := t.backend.Truncate(path, size)
+	t.wait("Truncate", 0, 0)
+	return err
+}
+
+func (t *ThrottledFS) Remove(path string) error {
+	err := t.backend.Remove(path)
+	t.wait("Remove", 0, 0)
+	return err
+}
+
+func (t *ThrottledFS) RemoveAll(path string) error {
+	err := t.backend.RemoveAll(path)
+	t.wait("RemoveAll", 0, 0)
+	return err
+}
+
+func (t *ThrottledFS) Rename(oldPath, newPath string) error {
+	err := t.backend.Rename(oldPath, newPath)
+	t.wait("Rename", 0, 0)
+	return err
+}
+
+// Walk cuenta como una sola operación
+func (t *ThrottledFS) Walk(path string, walkFn func(path string, info FileInfo) error) error {
+	err := t.backend.Walk(path, walkFn)
+	t.wait("Walk", 0, 0)
+	return err
+}
+
+func (t *ThrottledFS) Size(path string) (int64, error) {
+	size, err := t.backend.Size(path)
+	t.wait("Size", 0, 0)
+	return size, err
+}
+
+func (t *ThrottledFS) SetModTime(path string, modTime time.Time) error {
+	err := t.backend.SetModTime(path, modTime)
+	t.wait("SetModTime", 0, 0)
+	return err
+}
+
+// Chmod solo funciona si el Backend de abajo sabe cambiar permisos
+func (t *ThrottledFS) Chmod(path string, mode os.FileMode) error {
+	c, ok := t.backend.(chmoder)
+	if !ok {
+		return errors.New("el sistema limitado no permite cambiar permisos: " + path)
+	}
+	err := c.Chmod(path, mode)
+	t.wait("Chmod", 0, 0)
+	return err
+}
+
+// Chown solo funciona si el Backend de abajo sabe cambiar el dueño
+func (t *ThrottledFS) Chown(path string, uid, gid int) error {
+	c, ok := t.backend.(chowner)
+	if !ok {
+		return errors.New("el sistema limitado no permite cambiar el dueño: " + path)
+	}
+	err := c.Chown(path, uid, gid)
+	t.wait("Chown", 0, 0)
+	return err
+}
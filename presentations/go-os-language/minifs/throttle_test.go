@@ -0,0 +1,159 @@
+package minifs
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// near compara duraciones tolerando el redondeo de los baldes
+func near(got, want time.Duration) bool {
+	diff := got - want
+	return diff > -time.Millisecond && diff < time.Millisecond
+}
+
+func TestThrottleBandwidth(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	tfs := NewThrottledFS(newCheckedFS(t), ThrottleOptions{
+		ReadBytesPerSec:  2 << 20,
+		WriteBytesPerSec: 1 << 20,
+		Clock:            clock,
+	})
+	mb := bytes.Repeat([]byte("x"), 1<<20)
+
+	// El balde empieza lleno con un segundo de ancho de banda: el primer
+	// mega sale de la ráfaga y cada uno de los siguientes tarda un segundo
+	for i := 0; i < 3; i++ {
+		tfs.WriteFile("/a", mb)
+	}
+	if got := clock.Slept(); !near(got, 2*time.Second) {
+		t.Errorf("3 MB a 1 MB/s con ráfaga de 1 MB: %v", got)
+	}
+
+	// La lectura tiene su propio balde
+	for i := 0; i < 3; i++ {
+		tfs.ReadFile("/a")
+	}
+	if got := clock.Slept(); !near(got, 2500*time.Millisecond) {
+		t.Errorf("3 MB a 2 MB/s tras las escrituras: %v", got)
+	}
+
+	// Sin actividad el balde se vuelve a llenar
+	clock.Advance(time.Minute)
+	before := clock.Slept()
+	tfs.WriteFile("/a", mb)
+	if got := clock.Slept() - before; got != 0 {
+		t.Errorf("Tras un rato sin uso debería haber ráfaga: %v", got)
+	}
+
+	stats := tfs.Stats()
+	if stats.Ops != 7 || stats.BytesWritten != 4<<20 || stats.BytesRead != 3<<20 || stats.Waited != clock.Slept() {
+		t.Errorf("Contadores: %+v", stats)
+	}
+}
+
+func TestThrottleIOPS(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	tfs := NewThrottledFS(newCheckedFS(t), ThrottleOptions{IOPS: 10, Clock: clock})
+
+	for i := 0; i < 21; i++ {
+		tfs.Exists("/")
+	}
+	if got := clock.Slept(); !near(got, 2*time.Second) {
+		t.Errorf("21 operaciones a 10 IOPS: %v", got)
+	}
+
+	// Una operación fallida también cuenta
+	tfs.Remove("/no/existe")
+	if got := clock.Slept(); !near(got, 2100*time.Millisecond) {
+		t.Errorf("Operación fallida: %v", got)
+	}
+}
+
+func TestThrottleLatency(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	tfs := NewThrottledFS(newCheckedFS(t), ThrottleOptions{
+		Latency:   FixedLatency(5 * time.Millisecond),
+		OpLatency: map[string]Latency{"Rename": FixedLatency(time.Second)},
+		Clock:     clock,
+	})
+
+	tfs.WriteFile("/a", []byte("a"))
+	tfs.Rename("/a", "/b")
+	if got := clock.Slept(); got != time.Second+5*time.Millisecond {
+		t.Errorf("Latencias: %v", got)
+	}
+
+	// La misma semilla da las mismas latencias
+	run := func(l Latency) time.Duration {
+		clock := NewFakeClock(epoch)
+		tfs := NewThrottledFS(NewFileSystem(), ThrottleOptions{Latency: l, Clock: clock, Seed: 7})
+		for i := 0; i < 1000; i++ {
+			tfs.Stat("/")
+		}
+		return clock.Slept()
+	}
+	for name, l := range map[string]Latency{
+		"Uniform":     UniformLatency(time.Millisecond, 3*time.Millisecond),
+		"Normal":      NormalLatency(2*time.Millisecond, time.Millisecond),
+		"Exponential": ExponentialLatency(2 * time.Millisecond),
+	} {
+		a, b := run(l), run(l)
+		if a != b {
+			t.Errorf("%s no es reproducible: %v y %v", name, a, b)
+		}
+		// 1000 operaciones de 2 ms en promedio
+		if a < 1700*time.Millisecond || a > 2300*time.Millisecond {
+			t.Errorf("%s: promedio fuera de rango: %v", name, a/1000)
+		}
+	}
+}
+
+func TestThrottleConcurrent(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	tfs := NewThrottledFS(newCheckedFS(t), ThrottleOptions{
+		WriteBytesPerSec: 1000,
+		Burst:            1,
+		Clock:            clock,
+	})
+
+	// Varias goroutines comparten los baldes y los contadores
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tfs.WriteFile("/f", make([]byte, 100))
+		}()
+	}
+	wg.Wait()
+	if got := tfs.Stats().BytesWritten; got != 1000 {
+		t.Errorf("Bytes escritos: %d", got)
+	}
+}
+
+// TestThrottledFSSuite corre los tests generales a través del envoltorio,
+// que no debe cambiar el comportamiento
+func TestThrottledFSSuite(t *testing.T) {
+	suite := []struct {
+		name string
+		test func(t *testing.T, fs Backend)
+	}{
+		{"Operations", testFileSystemOperations},
+		{"Walk", testWalk},
+		{"Concurrency", testConcurrency},
+	}
+	for _, s := range suite {
+		t.Run(s.name, func(t *testing.T) {
+			s.test(t, NewThrottledFS(newCheckedFS(t), ThrottleOptions{
+				IOPS:             1000,
+				WriteBytesPerSec: 1 << 20,
+				Latency:          ExponentialLatency(time.Millisecond),
+				Clock:            NewFakeClock(epoch),
+			}))
+		})
+	}
+}
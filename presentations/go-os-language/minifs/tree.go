@@ -0,0 +1,278 @@
+package minifs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// TreeSort es el orden de los hijos de cada directorio en un Tree
+type TreeSort int
+
+const (
+	SortByName    TreeSort = iota // alfabético
+	SortBySize                    // los más pesados primero
+	SortByModTime                 // los modificados más recientemente primero
+)
+
+// TreeOptions controla cómo se arma y se dibuja un Tree
+type TreeOptions struct {
+	// Sort es el orden de los hijos; Reverse lo invierte
+	Sort    TreeSort
+	Reverse bool
+
+	// MaxDepth limita cuántos niveles se dibujan; cero no tiene límite.
+	// Los totales de los directorios siempre cuentan todo lo que tienen
+	// debajo.
+	MaxDepth int
+
+	// ASCII dibuja las ramas con |-- y `-- en lugar de caracteres Unicode
+	ASCII bool
+
+	// HumanSizes escribe los tamaños como 1.5 KiB en lugar de en bytes
+	HumanSizes bool
+
+	// DirSizes muestra junto a cada directorio el total de su contenido
+	DirSizes bool
+}
+
+// TreeNode es un archivo o directorio dentro de un Tree
+type TreeNode struct {
+	Path string
+	Info FileInfo
+
+	// Size es el tamaño del archivo o, para un directorio, la suma de todo
+	// lo que cuelga de él. Como en du, un archivo con varios enlaces duros
+	// suma una sola vez, en el primer enlace que encuentra el recorrido, así
+	// que puede dar menos que Size del FileSystem.
+	Size int64
+
+	// Files y Dirs cuentan lo que hay debajo de un directorio, sin contarlo
+	// a él
+	Files int
+	Dirs  int
+
+	Children []*TreeNode
+
+	// charge es lo que suma al directorio padre: cero para los enlaces de
+	// un archivo que ya se contó
+	charge int64
+}
+
+// treeInode identifica un archivo entre montajes, que numeran sus inodos
+// cada uno por su lado
+type treeInode struct {
+	mount *mount
+	ino   uint64
+}
+
+// Tree es una foto del árbol bajo una ruta con los totales de cada
+// directorio ya calculados. Se dibuja con WriteTo o String y sirve también
+// para reportes al estilo du con Top.
+type Tree struct {
+	Root *TreeNode
+	opts TreeOptions
+}
+
+// Tree recorre una sola vez el árbol bajo path, montajes incluidos, y
+// calcula los totales de cada directorio
+func (fs *FileSystem) Tree(path string, opts TreeOptions) (*Tree, error) {
+	path = filepath.Clean("/" + path)
+	nodes := make(map[string]*TreeNode)
+	seen := make(map[treeInode]bool)
+
+	err := fs.Walk(path, func(p string, info FileInfo) error {
+		p = filepath.Clean("/" + p)
+		node := &TreeNode{Path: p, Info: info, Size: info.Size, charge: info.Size}
+		nodes[p] = node
+
+		if st, ok := info.Sys().(*StatT); ok && !info.IsDir && st.Nlink > 1 {
+			m, _ := fs.mountFor(p)
+			key := treeInode{mount: m, ino: st.Ino}
+			if seen[key] {
+				node.charge = 0
+			}
+			seen[key] = true
+		}
+
+		if p == path {
+			return nil
+		}
+		parent, ok := nodes[filepath.Dir(p)]
+		if !ok {
+			return errors.New("recorrido sin el directorio padre: " + p)
+		}
+		parent.Children = append(parent.Children, node)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	root, ok := nodes[path]
+	if !ok {
+		return nil, errors.New("archivo o directorio no encontrado: " + path)
+	}
+	root.total(opts)
+	return &Tree{Root: root, opts: opts}, nil
+}
+
+// total suma los tamaños de abajo hacia arriba y ordena los hijos
+func (n *TreeNode) total(opts TreeOptions) {
+	if !n.Info.IsDir {
+		return
+	}
+
+	n.Size = 0
+	for _, child := range n.Children {
+		child.total(opts)
+		n.Size += child.charge
+		n.Files += child.Files
+		n.Dirs += child.Dirs
+		if child.Info.IsDir {
+			n.Dirs++
+		} else {
+			n.Files++
+		}
+	}
+	n.charge = n.Size
+
+	sort.SliceStable(n.Children, func(i, j int) bool {
+		a, b := n.Children[i], n.Children[j]
+		if opts.Reverse {
+			a, b = b, a
+		}
+		switch {
+		case opts.Sort == SortBySize && a.Size != b.Size:
+			return a.Size > b.Size
+		case opts.Sort == SortByModTime && !a.Info.ModTime.Equal(b.Info.ModTime):
+			return a.Info.ModTime.After(b.Info.ModTime)
+		}
+		return a.Info.Name < b.Info.Name
+	})
+}
+
+// Top devuelve los n subdirectorios más pesados bajo la raíz, a cualquier
+// profundidad y sin contar la raíz, del más pesado al más liviano. Con n
+// cero o negativo los devuelve todos.
+func (t *Tree) Top(n int) []*TreeNode {
+	var dirs []*TreeNode
+	var collect func(node *TreeNode)
+	collect = func(node *TreeNode) {
+		for _, child := range node.Children {
+			if child.Info.IsDir {
+				dirs = append(dirs, child)
+				collect(child)
+			}
+		}
+	}
+	collect(t.Root)
+
+	sort.Slice(dirs, func(i, j int) bool {
+		if dirs[i].Size != dirs[j].Size {
+			return dirs[i].Size > dirs[j].Size
+		}
+		return dirs[i].Path < dirs[j].Path
+	})
+	if n > 0 && len(dirs) > n {
+		dirs = dirs[:n]
+	}
+	return dirs
+}
+
+// FormatSize escribe un tamaño en bytes o, con human, en la unidad binaria
+// más grande que no lo deje por debajo de uno
+func FormatSize(size int64, human bool) string {
+	if !human {
+		return fmt.Sprintf("%d bytes", size)
+	}
+	if size < 1024 {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(sizeUnits)-1 {
+		value /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f %s", value, sizeUnits[unit])
+}
+
+var sizeUnits = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+// treeBranches son las piezas para dibujar: rama, última rama, continuación
+// y espacio
+var treeBranches = map[bool][4]string{
+	false: {"├── ", "└── ", "│   ", "    "},
+	true:  {"|-- ", "`-- ", "|   ", "    "},
+}
+
+// treeWriter cuenta lo escrito y se queda con el primer error
+type treeWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (tw *treeWriter) printf(format string, args ...any) {
+	if tw.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(tw.w, format, args...)
+	tw.n += int64(n)
+	tw.err = err
+}
+
+// WriteTo dibuja el árbol en w, una entrada por línea, empezando por la
+// ruta de la raíz
+func (t *Tree) WriteTo(w io.Writer) (int64, error) {
+	tw := &treeWriter{w: w}
+
+	tw.printf("%s%s\n", t.Root.Path, t.label(t.Root, false))
+	if t.Root.Info.IsDir {
+		t.writeLevel(tw, t.Root, "", 1)
+	}
+	return tw.n, tw.err
+}
+
+func (t *Tree) writeLevel(tw *treeWriter, dir *TreeNode, prefix string, depth int) {
+	if t.opts.MaxDepth > 0 && depth > t.opts.MaxDepth {
+		return
+	}
+
+	pieces := treeBranches[t.opts.ASCII]
+	for i, child := range dir.Children {
+		branch, next := pieces[0], pieces[2]
+		if i == len(dir.Children)-1 {
+			branch, next = pieces[1], pieces[3]
+		}
+
+		tw.printf("%s%s%s%s\n", prefix, branch, child.Info.Name, t.label(child, true))
+		if child.Info.IsDir {
+			t.writeLevel(tw, child, prefix+next, depth+1)
+		}
+	}
+}
+
+// label es lo que sigue al nombre: la barra de los directorios y el tamaño
+func (t *Tree) label(node *TreeNode, slash bool) string {
+	var b strings.Builder
+	if node.Info.IsDir && slash {
+		b.WriteByte('/')
+	}
+	if !node.Info.IsDir || t.opts.DirSizes {
+		fmt.Fprintf(&b, " (%s)", FormatSize(node.Size, t.opts.HumanSizes))
+	}
+	return b.String()
+}
+
+// String devuelve el árbol dibujado como lo escribe WriteTo
+func (t *Tree) String() string {
+	var b strings.Builder
+	t.WriteTo(&b)
+	return b.String()
+}
@@ -0,0 +1,153 @@
+package minifs
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newUsageFS arma un árbol con pesos distintos y un montaje en /mnt
+func newUsageFS(t *testing.T) *FileSystem {
+	t.Helper()
+	fs := newCheckedFS(t)
+	fs.MkdirAll("/src/lib", 0755)
+	fs.MkdirAll("/var/log", 0755)
+	fs.CreateDir("/mnt", 0755)
+	fs.WriteFile("/src/main.go", bytes.Repeat([]byte("a"), 100))
+	fs.WriteFile("/src/lib/util.go", bytes.Repeat([]byte("b"), 300))
+	fs.WriteFile("/var/log/app.log", bytes.Repeat([]byte("c"), 2048))
+	fs.WriteFile("/leeme", []byte("hola"))
+
+	mnt := newCheckedFS(t)
+	mnt.WriteFile("/datos.bin", bytes.Repeat([]byte("d"), 1000))
+	if err := fs.Mount("/mnt", mnt, MountOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	return fs
+}
+
+func TestTree(t *testing.T) {
+	fs := newUsageFS(t)
+
+	tree, err := fs.Tree("/", TreeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `/
+├── leeme (4 bytes)
+├── mnt/
+│   └── datos.bin (1000 bytes)
+├── src/
+│   ├── lib/
+│   │   └── util.go (300 bytes)
+│   └── main.go (100 bytes)
+└── var/
+    └── log/
+        └── app.log (2048 bytes)
+`
+	if got := tree.String(); got != want {
+		t.Errorf("Árbol incorrecto:\n%s", got)
+	}
+	if root := tree.Root; root.Size != 3452 || root.Files != 5 || root.Dirs != 5 {
+		t.Errorf("Totales de la raíz: %d bytes, %d archivos, %d directorios", root.Size, root.Files, root.Dirs)
+	}
+	if size, _ := fs.Size("/"); size != tree.Root.Size {
+		t.Errorf("El total no coincide con Size: %d, %d", tree.Root.Size, size)
+	}
+
+	tree, _ = fs.Tree("/", TreeOptions{Sort: SortBySize, MaxDepth: 1, ASCII: true, HumanSizes: true, DirSizes: true})
+	want = "/ (3.4 KiB)\n" +
+		"|-- var/ (2.0 KiB)\n" +
+		"|-- mnt/ (1000 B)\n" +
+		"|-- src/ (400 B)\n" +
+		"`-- leeme (4 B)\n"
+	if got := tree.String(); got != want {
+		t.Errorf("Árbol por tamaño con un nivel:\n%s", got)
+	}
+
+	fs.SetModTime("/src", time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	fs.SetModTime("/leeme", time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	tree, _ = fs.Tree("/", TreeOptions{Sort: SortByModTime, Reverse: true, MaxDepth: 1})
+	var names []string
+	for _, child := range tree.Root.Children {
+		names = append(names, child.Info.Name)
+	}
+	if got := strings.Join(names, " "); !strings.HasPrefix(got, "leeme ") || !strings.HasSuffix(got, " src") {
+		t.Errorf("Orden por fecha invertido: %s", got)
+	}
+
+	if tree, err := fs.Tree("/src/main.go", TreeOptions{}); err != nil || tree.String() != "/src/main.go (100 bytes)\n" {
+		t.Errorf("Árbol de un archivo: %v", err)
+	}
+	if _, err := fs.Tree("/no/existe", TreeOptions{}); err == nil {
+		t.Error("Tree de una ruta inexistente debería fallar")
+	}
+}
+
+func TestTreeTop(t *testing.T) {
+	fs := newUsageFS(t)
+
+	tree, err := fs.Tree("/", TreeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, node := range tree.Top(4) {
+		got = append(got, node.Path)
+	}
+	want := "/var /var/log /mnt /src"
+	if strings.Join(got, " ") != want {
+		t.Errorf("Top(4) = %v, se esperaba %s", got, want)
+	}
+	if all := tree.Top(0); len(all) != 5 {
+		t.Errorf("Top(0) debería devolver los 5 directorios: %d", len(all))
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	cases := []struct {
+		size  int64
+		human bool
+		want  string
+	}{
+		{18, false, "18 bytes"},
+		{1023, true, "1023 B"},
+		{1536, true, "1.5 KiB"},
+		{5 << 20, true, "5.0 MiB"},
+		{3 << 40, true, "3.0 TiB"},
+	}
+	for _, c := range cases {
+		if got := FormatSize(c.size, c.human); got != c.want {
+			t.Errorf("FormatSize(%d, %v) = %q, se esperaba %q", c.size, c.human, got, c.want)
+		}
+	}
+}
+
+func TestTreeHardLinks(t *testing.T) {
+	fs := newCheckedFS(t)
+	fs.MkdirAll("/a", 0755)
+	fs.MkdirAll("/b", 0755)
+	fs.WriteFile("/a/datos", bytes.Repeat([]byte("x"), 100))
+	fs.Link("/a/datos", "/b/enlace")
+	fs.Link("/a/datos", "/b/otro")
+
+	tree, err := fs.Tree("/", TreeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Como du, el archivo suma una sola vez, donde aparece primero
+	if tree.Root.Size != 100 {
+		t.Errorf("Total con enlaces duros: %d, se esperaba 100", tree.Root.Size)
+	}
+	if top := tree.Top(0); top[0].Path != "/a" || top[0].Size != 100 || top[1].Size != 0 {
+		t.Errorf("Totales por directorio: %s=%d %s=%d", top[0].Path, top[0].Size, top[1].Path, top[1].Size)
+	}
+
+	// Cada enlace se sigue dibujando con su tamaño
+	if !strings.Contains(tree.String(), "enlace (100 bytes)") {
+		t.Errorf("Árbol con enlaces:\n%s", tree)
+	}
+}
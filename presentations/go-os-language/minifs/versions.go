@@ -0,0 +1,447 @@
+package minifs
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TrashDir es el directorio donde Remove y RemoveAll dejan lo eliminado
+// cuando la papelera está activa
+const TrashDir = "/.trash"
+
+// VersioningOptions activa las revisiones y la papelera. El valor cero las
+// desactiva, que es el comportamiento de NewFileSystem.
+type VersioningOptions struct {
+	// MaxVersions es cuántas revisiones anteriores se guardan por archivo
+	MaxVersions int
+
+	// Trash hace que Remove y RemoveAll muevan a TrashDir en lugar de
+	// eliminar. Eliminar algo que ya está en la papelera lo borra de verdad.
+	Trash bool
+
+	// TrashTTL, si no es cero, purga lo que lleve más de ese tiempo en la
+	// papelera cada vez que se elimina algo
+	TrashTTL time.Duration
+}
+
+// Version describe una revisión anterior de un archivo. N es 1 para la
+// más reciente.
+type Version struct {
+	N       int
+	Size    int64
+	ModTime time.Time
+}
+
+// TrashEntry describe algo que está en la papelera
+type TrashEntry struct {
+	Name    string // nombre dentro de TrashDir
+	Path    string // ruta original
+	IsDir   bool
+	Deleted time.Time
+}
+
+// version es el contenido de una revisión. Se guarda el sparseData que
+// reemplazó la escritura, que ya nadie modifica, así que no hace falta
+// copiarlo.
+type version struct {
+	data    sparseData
+	size    int64
+	modTime time.Time
+}
+
+// SetVersioning cambia las opciones de revisiones y papelera. Solo
+// CreateFile, WriteFile y Rename sobre un archivo guardan revisiones;
+// AppendFile, Truncate y las escrituras de un File modifican el contenido
+// actual.
+func (fs *FileSystem) SetVersioning(opts VersioningOptions) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.versioning = opts
+}
+
+// Versioning devuelve las opciones de revisiones y papelera
+func (fs *FileSystem) Versioning() VersioningOptions {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	return fs.versioning
+}
+
+// keepVersion guarda el contenido actual de node como su revisión más
+// reciente y descarta las que sobran. El llamador debe tener node.mu
+// tomado para escritura.
+func (fs *FileSystem) keepVersion(node *Node) {
+	max := fs.versioning.MaxVersions
+	if max <= 0 {
+		return
+	}
+
+	node.versions = append(node.versions, version{data: node.data, size: node.size, modTime: node.modTime})
+	if extra := len(node.versions) - max; extra > 0 {
+		node.versions = append([]version(nil), node.versions[extra:]...)
+	}
+}
+
+// inherit le pasa a node, que Rename va a poner en lugar de target, el
+// historial de target con su contenido actual como revisión más reciente.
+// Se asume que fs.mu está tomado para escritura.
+func (fs *FileSystem) inherit(node, target *Node) error {
+	unpin, err := fs.pin(target, false)
+	if err != nil {
+		return err
+	}
+	defer unpin()
+
+	target.mu.Lock()
+	fs.keepVersion(target)
+	versions := target.versions
+	target.versions = nil
+	target.mu.Unlock()
+
+	node.mu.Lock()
+	node.versions = versions
+	node.mu.Unlock()
+	return nil
+}
+
+// Versions devuelve las revisiones anteriores de un archivo, de la más
+// reciente a la más antigua
+func (fs *FileSystem) Versions(path string) ([]Version, error) {
+	if err := fs.unversioned(path); err != nil {
+		return nil, err
+	}
+	if err := fs.rlock("versions", path); err != nil {
+		return nil, err
+	}
+	defer fs.mu.RUnlock()
+
+	node, err := fs.lookupFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+
+	versions := make([]Version, 0, len(node.versions))
+	for n := 1; n <= len(node.versions); n++ {
+		v := node.versions[len(node.versions)-n]
+		versions = append(versions, Version{N: n, Size: v.size, ModTime: v.modTime})
+	}
+	return versions, nil
+}
+
+// ReadVersion lee la revisión n de un archivo, como la numera Versions
+func (fs *FileSystem) ReadVersion(path string, n int) ([]byte, error) {
+	if err := fs.unversioned(path); err != nil {
+		return nil, err
+	}
+	if err := fs.rlock("read", path); err != nil {
+		return nil, err
+	}
+	defer fs.mu.RUnlock()
+
+	node, err := fs.lookupFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+
+	v, err := node.version(path, n)
+	if err != nil {
+		return nil, err
+	}
+	return v.data.bytes(v.size), nil
+}
+
+// Restore vuelve al contenido de la revisión n. El contenido actual pasa
+// a ser la revisión más reciente, así que Restore(path, 1) deshace un
+// Restore.
+func (fs *FileSystem) Restore(path string, n int) error {
+	if err := fs.unversioned(path); err != nil {
+		return err
+	}
+	if err := fs.lock("restore", path); err != nil {
+		return err
+	}
+	defer fs.mu.Unlock()
+
+	node, err := fs.lookupFile(path)
+	if err != nil {
+		return err
+	}
+
+	unpin, err := fs.pin(node, true)
+	if err != nil {
+		return err
+	}
+	defer unpin()
+
+	node.mu.Lock()
+	defer node.mu.Unlock()
+
+	v, err := node.version(path, n)
+	if err != nil {
+		return err
+	}
+
+	fs.keepVersion(node)
+	node.data = v.data.clone()
+	node.size = v.size
+	node.modTime = time.Now()
+	return nil
+}
+
+// unversioned rechaza las rutas bajo un montaje: las revisiones y la
+// papelera son del árbol propio y ahí sólo se vería el nodo tapado
+func (fs *FileSystem) unversioned(path string) error {
+	if m, _ := fs.mountFor(path); m != nil {
+		return errors.New("el sistema montado no guarda revisiones ni papelera: " + path)
+	}
+	return nil
+}
+
+// version devuelve la revisión n; n es 1 para la más reciente
+func (node *Node) version(path string, n int) (version, error) {
+	if n < 1 || n > len(node.versions) {
+		return version{}, fmt.Errorf("no existe la revisión %d de %s", n, path)
+	}
+	return node.versions[len(node.versions)-n], nil
+}
+
+// lookupFile es lookup pero exige que el nodo sea un archivo
+func (fs *FileSystem) lookupFile(path string) (*Node, error) {
+	node, err := fs.lookup(path)
+	if err != nil {
+		return nil, err
+	}
+	if node.nodeType != FileNode {
+		return nil, errors.New("no es un archivo: " + path)
+	}
+	return node, nil
+}
+
+// trashNode devuelve el directorio de la papelera, o nil si no existe.
+// Se asume que fs.mu está tomado.
+func (fs *FileSystem) trashNode() *Node {
+	node, _, exists := fs.child(fs.root, filepath.Base(TrashDir))
+	if !exists || node.nodeType != DirNode {
+		return nil
+	}
+	return node
+}
+
+// inTrash indica si dir es la papelera o está dentro de ella
+func (fs *FileSystem) inTrash(dir *Node) bool {
+	trash := fs.trashNode()
+	for ; dir != nil && trash != nil; dir = dir.parent {
+		if dir == trash {
+			return true
+		}
+	}
+	return false
+}
+
+// discard decide qué hacer con node, que está en parent con el nombre
+// guardado name: si la papelera está activa lo mueve a ella y si no lo
+// libera. Se asume que fs.mu y parent.mu están tomados para escritura.
+func (fs *FileSystem) discard(node, parent *Node, name, path string) {
+	trash := fs.trashNode()
+	trashed := fs.versioning.Trash && node != trash && !fs.inTrash(parent)
+
+	fs.removeChild(parent, name)
+	parent.modTime = time.Now()
+
+	if !trashed {
+		// Lo que sale de la papelera deja de estar disponible para Undelete
+		if parent == trash {
+			delete(fs.trash, name)
+		}
+		if node == trash {
+			fs.trash = nil
+		}
+		fs.release(node, parent, name)
+		return
+	}
+
+	if trash == nil {
+		trash = fs.newNode(filepath.Base(TrashDir), DirNode, fs.root, 0755)
+		fs.addChild(fs.root, trash.name, trash)
+		fs.root.nlink++
+	}
+
+	fs.trashSeq++
+	entry := TrashEntry{
+		Name:    strconv.FormatUint(fs.trashSeq, 10) + "-" + name,
+		Path:    filepath.Clean("/" + path),
+		IsDir:   node.nodeType == DirNode,
+		Deleted: time.Now(),
+	}
+	if fs.trash == nil {
+		fs.trash = make(map[string]TrashEntry)
+	}
+	fs.trash[entry.Name] = entry
+
+	fs.move(node, parent, name, trash, entry.Name)
+
+	if ttl := fs.versioning.TrashTTL; ttl > 0 {
+		fs.purgeTrash(time.Now().Add(-ttl))
+	}
+}
+
+// move cambia la entrada name de from por la entrada newName de to, que ya
+// se quitó de from. Se asume que fs.mu está tomado para escritura.
+func (fs *FileSystem) move(node, from *Node, name string, to *Node, newName string) {
+	if node.nodeType == DirNode {
+		from.nlink--
+		to.nlink++
+	}
+	// Un archivo con enlaces duros sólo cambia de enlace principal si el
+	// que se movió era el principal
+	if node.nodeType == DirNode || (node.parent == from && node.name == name) {
+		node.parent, node.name = to, newName
+	}
+	fs.addChild(to, newName, node)
+	to.modTime = time.Now()
+}
+
+// Trash devuelve lo que hay en la papelera, de lo más antiguo a lo más
+// reciente
+func (fs *FileSystem) Trash() []TrashEntry {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	trash := fs.trashNode()
+	if trash == nil {
+		return []TrashEntry{}
+	}
+
+	trash.mu.RLock()
+	defer trash.mu.RUnlock()
+
+	entries := make([]TrashEntry, 0, len(fs.trash))
+	for _, entry := range fs.trash {
+		if _, _, exists := fs.child(trash, entry.Name); exists {
+			entries = append(entries, entry)
+		}
+	}
+	sortTrash(entries)
+	return entries
+}
+
+// Undelete devuelve a path lo último que se eliminó de esa ruta. El
+// directorio padre tiene que existir y path no.
+func (fs *FileSystem) Undelete(path string) error {
+	if err := fs.unversioned(path); err != nil {
+		return err
+	}
+	if err := fs.lock("undelete", path); err != nil {
+		return err
+	}
+	defer fs.mu.Unlock()
+
+	original := filepath.Clean("/" + path)
+	var candidates []TrashEntry
+	for _, entry := range fs.trash {
+		if entry.Path == original {
+			candidates = append(candidates, entry)
+		}
+	}
+	if len(candidates) == 0 {
+		return errors.New("no está en la papelera: " + path)
+	}
+	sortTrash(candidates)
+	entry := candidates[len(candidates)-1]
+
+	trash := fs.trashNode()
+	if trash == nil {
+		fs.trash = nil
+		return errors.New("no está en la papelera: " + path)
+	}
+	node, stored, exists := fs.child(trash, entry.Name)
+	if !exists {
+		delete(fs.trash, entry.Name)
+		return errors.New("no está en la papelera: " + path)
+	}
+
+	parent, name, err := fs.resolve(path)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return errors.New("destino ya existe: " + path)
+	}
+
+	parent.mu.Lock()
+	defer parent.mu.Unlock()
+
+	if _, _, exists := fs.child(parent, name); exists {
+		return errors.New("destino ya existe: " + path)
+	}
+
+	fs.removeChild(trash, stored)
+	trash.modTime = time.Now()
+	fs.move(node, trash, stored, parent, name)
+	delete(fs.trash, entry.Name)
+	return nil
+}
+
+// PurgeTrash elimina de verdad lo que se mandó a la papelera antes de
+// before y devuelve cuántas entradas borró
+func (fs *FileSystem) PurgeTrash(before time.Time) int {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.purgeTrash(before)
+}
+
+func (fs *FileSystem) purgeTrash(before time.Time) int {
+	trash := fs.trashNode()
+	if trash == nil {
+		return 0
+	}
+
+	purged := 0
+	for name, entry := range fs.trash {
+		if !entry.Deleted.Before(before) {
+			continue
+		}
+		delete(fs.trash, name)
+
+		node, stored, exists := fs.child(trash, name)
+		if !exists {
+			continue
+		}
+		fs.removeChild(trash, stored)
+		fs.release(node, trash, stored)
+		purged++
+	}
+	if purged > 0 {
+		trash.modTime = time.Now()
+	}
+	return purged
+}
+
+func sortTrash(entries []TrashEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].Deleted.Equal(entries[j].Deleted) {
+			return entries[i].Deleted.Before(entries[j].Deleted)
+		}
+		// El número de secuencia al principio del nombre desempata
+		return trashSeq(entries[i].Name) < trashSeq(entries[j].Name)
+	})
+}
+
+func trashSeq(name string) uint64 {
+	prefix, _, _ := strings.Cut(name, "-")
+	seq, _ := strconv.ParseUint(prefix, 10, 64)
+	return seq
+}
@@ -0,0 +1,202 @@
+package minifs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVersions(t *testing.T) {
+	fs := newCheckedFS(t)
+	fs.SetVersioning(VersioningOptions{MaxVersions: 2})
+
+	for _, content := range []string{"uno", "dos", "tres", "cuatro"} {
+		fs.WriteFile("/notas.txt", []byte(content))
+	}
+
+	versions, err := fs.Versions("/notas.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[0].N != 1 || versions[0].Size != 4 || versions[1].Size != 3 {
+		t.Fatalf("Revisiones inesperadas: %+v", versions)
+	}
+
+	if data, _ := fs.ReadVersion("/notas.txt", 1); string(data) != "tres" {
+		t.Errorf("ReadVersion(1) = %q", data)
+	}
+	if data, _ := fs.ReadVersion("/notas.txt", 2); string(data) != "dos" {
+		t.Errorf("ReadVersion(2) = %q", data)
+	}
+	if _, err := fs.ReadVersion("/notas.txt", 3); err == nil {
+		t.Error("Se leyó una revisión descartada")
+	}
+
+	if err := fs.Restore("/notas.txt", 2); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := fs.ReadFile("/notas.txt"); string(data) != "dos" {
+		t.Errorf("Contenido tras Restore: %q", data)
+	}
+	// Restaurar guarda el contenido anterior, así que se puede deshacer
+	if data, _ := fs.ReadVersion("/notas.txt", 1); string(data) != "cuatro" {
+		t.Errorf("Restore no guardó el contenido actual: %q", data)
+	}
+
+	// Las revisiones no comparten páginas con el contenido actual
+	f, _ := fs.Open("/notas.txt")
+	f.WriteAt([]byte("X"), 0)
+	f.Close()
+	if data, _ := fs.ReadVersion("/notas.txt", 2); string(data) != "tres" {
+		t.Errorf("Escribir modificó una revisión: %q", data)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	fs := newCheckedFS(t)
+	fs.SetVersioning(VersioningOptions{Trash: true})
+	fs.MkdirAll("/docs/viejos", 0755)
+	fs.WriteFile("/docs/viejos/a.txt", []byte("a"))
+	fs.WriteFile("/docs/b.txt", []byte("b1"))
+
+	if err := fs.Remove("/docs/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	fs.WriteFile("/docs/b.txt", []byte("b2"))
+	fs.Remove("/docs/b.txt")
+	if err := fs.RemoveAll("/docs/viejos"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := fs.Trash()
+	if len(entries) != 3 || entries[2].Path != "/docs/viejos" || !entries[2].IsDir {
+		t.Fatalf("Papelera inesperada: %+v", entries)
+	}
+	if !fs.Exists(TrashDir + "/" + entries[2].Name + "/a.txt") {
+		t.Error("El directorio no está en la papelera")
+	}
+
+	// Undelete devuelve lo último que se eliminó de esa ruta
+	if err := fs.Undelete("/docs/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := fs.ReadFile("/docs/b.txt"); string(data) != "b2" {
+		t.Errorf("Undelete devolvió %q", data)
+	}
+	if err := fs.Undelete("/docs/b.txt"); err == nil {
+		t.Error("Undelete sobrescribió un archivo existente")
+	}
+	if err := fs.Undelete("/docs/viejos"); err != nil || !fs.Exists("/docs/viejos/a.txt") {
+		t.Errorf("Undelete de un directorio: %v", err)
+	}
+
+	// Eliminar dentro de la papelera borra de verdad
+	entries = fs.Trash()
+	if len(entries) != 1 {
+		t.Fatalf("Papelera inesperada: %+v", entries)
+	}
+	if err := fs.Remove(TrashDir + "/" + entries[0].Name); err != nil {
+		t.Fatal(err)
+	}
+	if len(fs.Trash()) != 0 || fs.Undelete("/docs/b.txt") == nil {
+		t.Error("Lo eliminado de la papelera sigue disponible")
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	fs := newCheckedFS(t)
+	fs.SetVersioning(VersioningOptions{Trash: true})
+	fs.WriteFile("/viejo.txt", []byte("x"))
+	fs.WriteFile("/nuevo.txt", []byte("y"))
+	fs.Link("/nuevo.txt", "/enlace.txt")
+
+	fs.Remove("/viejo.txt")
+	fs.mu.Lock()
+	for name, entry := range fs.trash {
+		entry.Deleted = entry.Deleted.Add(-48 * time.Hour)
+		fs.trash[name] = entry
+	}
+	fs.mu.Unlock()
+	fs.Remove("/nuevo.txt")
+
+	if n := fs.PurgeTrash(time.Now().Add(-24 * time.Hour)); n != 1 {
+		t.Errorf("PurgeTrash borró %d entradas, se esperaba 1", n)
+	}
+	if entries := fs.Trash(); len(entries) != 1 || entries[0].Path != "/nuevo.txt" {
+		t.Errorf("Papelera tras purgar: %+v", entries)
+	}
+
+	// Purgar un archivo con otro enlace no borra su contenido
+	fs.PurgeTrash(time.Now().Add(time.Hour))
+	if data, _ := fs.ReadFile("/enlace.txt"); string(data) != "y" {
+		t.Errorf("Se perdió el contenido de un enlace: %q", data)
+	}
+	if info := statT(t, fs, "/enlace.txt"); info.Nlink != 1 {
+		t.Errorf("nlink tras purgar = %d", info.Nlink)
+	}
+}
+
+func TestTrashMoved(t *testing.T) {
+	fs := newCheckedFS(t)
+	fs.SetVersioning(VersioningOptions{Trash: true})
+	fs.WriteFile("/a.txt", []byte("a"))
+	fs.WriteFile("/b.txt", []byte("b"))
+	fs.Remove("/a.txt")
+	fs.Remove("/b.txt")
+
+	// Sacar una entrada de la papelera con Rename la quita de Trash
+	name := fs.Trash()[1].Name
+	if err := fs.Rename(TrashDir+"/"+name, "/rescatado.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if entries := fs.Trash(); len(entries) != 1 || entries[0].Path != "/a.txt" {
+		t.Errorf("Papelera tras sacar una entrada: %+v", entries)
+	}
+
+	// Mover la papelera entera la deja como un directorio cualquiera
+	if err := fs.Rename(TrashDir, "/vieja"); err != nil {
+		t.Fatal(err)
+	}
+	if len(fs.Trash()) != 0 {
+		t.Errorf("La papelera movida sigue listada: %+v", fs.Trash())
+	}
+	if err := fs.Undelete("/a.txt"); err == nil {
+		t.Error("Undelete tras mover la papelera debería fallar")
+	}
+
+	// Una papelera nueva con los mismos nombres no revive lo viejo
+	fs.CreateDir(TrashDir, 0755)
+	fs.WriteFile(TrashDir+"/"+name, []byte("otro"))
+	if err := fs.Undelete("/b.txt"); err == nil {
+		t.Error("Undelete devolvió un archivo que no salió de la papelera")
+	}
+}
+
+func TestVersionsUnderMount(t *testing.T) {
+	fs := newCheckedFS(t)
+	fs.SetVersioning(VersioningOptions{MaxVersions: 2, Trash: true})
+	fs.MkdirAll("/mnt", 0755)
+	fs.WriteFile("/mnt/a.txt", []byte("tapado"))
+	fs.WriteFile("/mnt/a.txt", []byte("tapado 2"))
+
+	other := newCheckedFS(t)
+	other.WriteFile("/a.txt", []byte("montado"))
+	if err := fs.Mount("/mnt", other, MountOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fs.Versions("/mnt/a.txt"); err == nil {
+		t.Error("Versions de una ruta montada debería fallar")
+	}
+	if _, err := fs.ReadVersion("/mnt/a.txt", 1); err == nil {
+		t.Error("ReadVersion de una ruta montada debería fallar")
+	}
+	if err := fs.Restore("/mnt/a.txt", 1); err == nil {
+		t.Error("Restore de una ruta montada debería fallar")
+	}
+	if err := fs.Undelete("/mnt/b.txt"); err == nil {
+		t.Error("Undelete hacia una ruta montada debería fallar")
+	}
+	if data, _ := fs.ReadFile("/mnt/a.txt"); string(data) != "montado" {
+		t.Errorf("El archivo montado cambió: %q", data)
+	}
+}
@@ -0,0 +1,198 @@
+package webdav
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lock es un candado exclusivo de escritura sobre un recurso
+type lock struct {
+	token   string
+	path    string
+	owner   string
+	deep    bool
+	expires time.Time
+}
+
+// lockSystem guarda los candados activos indexados por ruta
+type lockSystem struct {
+	mu    sync.Mutex
+	locks map[string]*lock
+	now   func() time.Time
+}
+
+func newLockSystem() *lockSystem {
+	return &lockSystem{locks: make(map[string]*lock), now: time.Now}
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "opaquelocktoken:" + hex.EncodeToString(b)
+}
+
+// covering devuelve el candado que protege path, si existe. Se asume que
+// ls.mu está tomado.
+func (ls *lockSystem) covering(path string) *lock {
+	now := ls.now()
+	for p, l := range ls.locks {
+		if now.After(l.expires) {
+			delete(ls.locks, p)
+			continue
+		}
+		if p == path || l.deep && isAncestor(p, path) {
+			return l
+		}
+	}
+	return nil
+}
+
+// descendant devuelve un candado sobre algún descendiente de path
+func (ls *lockSystem) descendant(path string) *lock {
+	for p, l := range ls.locks {
+		if isAncestor(path, p) {
+			return l
+		}
+	}
+	return nil
+}
+
+// create toma un candado nuevo o devuelve nil si hay un conflicto
+func (ls *lockSystem) create(path, owner string, deep bool, timeout time.Duration) *lock {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.covering(path) != nil {
+		return nil
+	}
+	if deep && ls.descendant(path) != nil {
+		return nil
+	}
+
+	l := &lock{
+		token:   newToken(),
+		path:    path,
+		owner:   owner,
+		deep:    deep,
+		expires: ls.now().Add(timeout),
+	}
+	ls.locks[path] = l
+	return l
+}
+
+// refresh extiende un candado existente identificado por su token
+func (ls *lockSystem) refresh(path, token string, timeout time.Duration) *lock {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	l := ls.covering(path)
+	if l == nil || l.token != token {
+		return nil
+	}
+	l.expires = ls.now().Add(timeout)
+	return l
+}
+
+// unlock libera el candado con el token dado
+func (ls *lockSystem) unlock(path, token string) bool {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	l := ls.covering(path)
+	if l == nil || l.token != token {
+		return false
+	}
+	delete(ls.locks, l.path)
+	return true
+}
+
+// allowed indica si una petición que presenta tokens puede modificar path
+// y, si deep es verdadero, todo lo que está debajo
+func (ls *lockSystem) allowed(path string, deep bool, tokens []string) bool {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	held := func(l *lock) bool {
+		for _, t := range tokens {
+			if t == l.token {
+				return true
+			}
+		}
+		return false
+	}
+
+	if l := ls.covering(path); l != nil && !held(l) {
+		return false
+	}
+	if deep {
+		for p, l := range ls.locks {
+			if isAncestor(path, p) && !held(l) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// forget elimina los candados de path y sus descendientes, por ejemplo
+// cuando el recurso se borra o se mueve
+func (ls *lockSystem) forget(path string) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	for p := range ls.locks {
+		if p == path || isAncestor(path, p) {
+			delete(ls.locks, p)
+		}
+	}
+}
+
+// isAncestor indica si child está estrictamente debajo de parent
+func isAncestor(parent, child string) bool {
+	if parent == "/" {
+		return child != "/"
+	}
+	return strings.HasPrefix(child, parent+"/")
+}
+
+// parseTimeout interpreta la cabecera Timeout ("Second-300", "Infinite")
+func parseTimeout(header string) time.Duration {
+	const def = 10 * time.Minute
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "Infinite" {
+			return 24 * time.Hour
+		}
+		if secs, ok := strings.CutPrefix(part, "Second-"); ok {
+			if n, err := strconv.Atoi(secs); err == nil && n > 0 {
+				return time.Duration(n) * time.Second
+			}
+		}
+	}
+	return def
+}
+
+// ifTokens extrae los tokens de candado de la cabecera If, por ejemplo
+// (<opaquelocktoken:abc>)
+func ifTokens(header string) []string {
+	var tokens []string
+	for {
+		start := strings.Index(header, "<")
+		if start < 0 {
+			return tokens
+		}
+		end := strings.Index(header[start:], ">")
+		if end < 0 {
+			return tokens
+		}
+		token := header[start+1 : start+end]
+		if strings.HasPrefix(token, "opaquelocktoken:") {
+			tokens = append(tokens, token)
+		}
+		header = header[start+end+1:]
+	}
+}
@@ -0,0 +1,522 @@
+// Package webdav expone un minifs.FileSystem por HTTP con los verbos de
+// WebDAV (RFC 4918), de modo que otros procesos puedan montarlo o usarlo
+// con clientes como cadaver, curl o el explorador de archivos del sistema.
+package webdav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hectorip/minifs"
+)
+
+// Handler implementa http.Handler sobre un FileSystem
+type Handler struct {
+	FS *minifs.FileSystem
+	// Prefix es la parte de la URL que se quita antes de resolver rutas,
+	// por ejemplo "/dav" si el handler se monta en http.Handle("/dav/", ...)
+	Prefix string
+
+	locks *lockSystem
+}
+
+// NewHandler crea un handler WebDAV para fs
+func NewHandler(fs *minifs.FileSystem) *Handler {
+	return &Handler{FS: fs, locks: newLockSystem()}
+}
+
+// ETag calcula la etiqueta de entidad de un recurso a partir de su fecha de
+// modificación y su tamaño
+func ETag(info minifs.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.ModTime.UnixNano(), info.Size)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.stripPrefix(r.URL.Path)
+	if !ok {
+		http.Error(w, "fuera del prefijo", http.StatusNotFound)
+		return
+	}
+
+	var status int
+	var err error
+	switch r.Method {
+	case "OPTIONS":
+		status = h.handleOptions(w, r)
+	case "GET", "HEAD":
+		status, err = h.handleGet(w, r, p)
+	case "PUT":
+		status, err = h.handlePut(w, r, p)
+	case "DELETE":
+		status, err = h.handleDelete(w, r, p)
+	case "MKCOL":
+		status, err = h.handleMkcol(w, r, p)
+	case "COPY", "MOVE":
+		status, err = h.handleCopyMove(w, r, p)
+	case "PROPFIND":
+		status, err = h.handlePropfind(w, r, p)
+	case "LOCK":
+		status, err = h.handleLock(w, r, p)
+	case "UNLOCK":
+		status, err = h.handleUnlock(w, r, p)
+	default:
+		status = http.StatusMethodNotAllowed
+	}
+
+	// status 0 significa que el handler ya escribió la respuesta
+	if status == 0 {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.WriteHeader(status)
+}
+
+// stripPrefix quita Prefix de p. El prefijo tiene que terminar en un
+// límite de la ruta: con Prefix "/dav", "/davx" queda afuera.
+func (h *Handler) stripPrefix(p string) (string, bool) {
+	if h.Prefix != "" {
+		rest, ok := strings.CutPrefix(p, strings.TrimSuffix(h.Prefix, "/"))
+		if !ok || (rest != "" && !strings.HasPrefix(rest, "/")) {
+			return "", false
+		}
+		p = rest
+	}
+	return path.Clean("/" + p), true
+}
+
+// href construye la URL pública de un recurso
+func (h *Handler) href(p string, isDir bool) string {
+	u := url.URL{Path: path.Join("/", strings.TrimSuffix(h.Prefix, "/"), p)}
+	s := u.EscapedPath()
+	if isDir && !strings.HasSuffix(s, "/") {
+		s += "/"
+	}
+	return s
+}
+
+// checkLock indica si la petición presenta el token de cualquier candado sobre p
+func (h *Handler) checkLock(r *http.Request, p string, deep bool) bool {
+	return h.locks.allowed(p, deep, ifTokens(r.Header.Get("If")))
+}
+
+// parentExists verifica que el directorio que contendrá p exista
+func (h *Handler) parentExists(p string) bool {
+	info, err := h.FS.Stat(path.Dir(p))
+	return err == nil && info.IsDir
+}
+
+func (h *Handler) handleOptions(w http.ResponseWriter, r *http.Request) int {
+	w.Header().Set("DAV", "1, 2")
+	w.Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, MKCOL, COPY, MOVE, PROPFIND, LOCK, UNLOCK")
+	w.Header().Set("MS-Author-Via", "DAV")
+	return http.StatusOK
+}
+
+func (h *Handler) handleGet(w http.ResponseWriter, r *http.Request, p string) (int, error) {
+	info, err := h.FS.Stat(p)
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+
+	if info.IsDir {
+		entries, err := h.FS.ListDir(p)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, "<pre>\n")
+		for _, e := range entries {
+			name := e.Name
+			if e.IsDir {
+				name += "/"
+			}
+			fmt.Fprintf(w, "<a href=\"%s\">%s</a>\n", h.href(path.Join(p, e.Name), e.IsDir), xmlEscape(name))
+		}
+		fmt.Fprintf(w, "</pre>\n")
+		return 0, nil
+	}
+
+	// Un File se puede servir por rangos sin leer el archivo completo
+	file, err := h.FS.Open(p)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	defer file.Close()
+
+	// ServeContent resuelve Range, If-Range, If-None-Match y HEAD por nosotros
+	w.Header().Set("ETag", ETag(info))
+	if ctype := mime.TypeByExtension(path.Ext(p)); ctype != "" {
+		w.Header().Set("Content-Type", ctype)
+	}
+	http.ServeContent(w, r, info.Name, info.ModTime, file)
+	return 0, nil
+}
+
+func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request, p string) (int, error) {
+	if !h.checkLock(r, p, false) {
+		return http.StatusLocked, nil
+	}
+	if !h.parentExists(p) {
+		return http.StatusConflict, fmt.Errorf("el directorio padre no existe: %s", path.Dir(p))
+	}
+
+	existed := false
+	if info, err := h.FS.Stat(p); err == nil {
+		if info.IsDir {
+			return http.StatusMethodNotAllowed, fmt.Errorf("es un directorio: %s", p)
+		}
+		existed = true
+	}
+
+	content, err := io.ReadAll(r.Body)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	if err := h.FS.WriteFile(p, content); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if info, err := h.FS.Stat(p); err == nil {
+		w.Header().Set("ETag", ETag(info))
+	}
+	if existed {
+		return http.StatusNoContent, nil
+	}
+	return http.StatusCreated, nil
+}
+
+func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request, p string) (int, error) {
+	if p == "/" {
+		return http.StatusForbidden, fmt.Errorf("no se puede eliminar la raíz")
+	}
+	if !h.FS.Exists(p) {
+		return http.StatusNotFound, nil
+	}
+	if !h.checkLock(r, p, true) {
+		return http.StatusLocked, nil
+	}
+
+	if err := h.FS.RemoveAll(p); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	h.locks.forget(p)
+	return http.StatusNoContent, nil
+}
+
+func (h *Handler) handleMkcol(w http.ResponseWriter, r *http.Request, p string) (int, error) {
+	if r.ContentLength > 0 {
+		return http.StatusUnsupportedMediaType, nil
+	}
+	if h.FS.Exists(p) {
+		return http.StatusMethodNotAllowed, nil
+	}
+	if !h.parentExists(p) {
+		return http.StatusConflict, nil
+	}
+	if !h.checkLock(r, p, false) {
+		return http.StatusLocked, nil
+	}
+
+	if err := h.FS.CreateDir(p, 0755); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusCreated, nil
+}
+
+func (h *Handler) handleCopyMove(w http.ResponseWriter, r *http.Request, src string) (int, error) {
+	info, err := h.FS.Stat(src)
+	if err != nil {
+		return http.StatusNotFound, nil
+	}
+
+	u, err := url.Parse(r.Header.Get("Destination"))
+	if err != nil || u.Path == "" {
+		return http.StatusBadRequest, fmt.Errorf("cabecera Destination inválida")
+	}
+	dst, ok := h.stripPrefix(u.Path)
+	if !ok {
+		return http.StatusBadGateway, fmt.Errorf("destino fuera del servidor")
+	}
+	if dst == src {
+		return http.StatusForbidden, fmt.Errorf("origen y destino son el mismo")
+	}
+	if info.IsDir && isAncestor(src, dst) {
+		return http.StatusBadRequest, fmt.Errorf("el destino está dentro del origen")
+	}
+
+	move := r.Method == "MOVE"
+	if move && !h.checkLock(r, src, true) {
+		return http.StatusLocked, nil
+	}
+	if !h.checkLock(r, dst, true) {
+		return http.StatusLocked, nil
+	}
+	if !h.parentExists(dst) {
+		return http.StatusConflict, nil
+	}
+
+	existed := h.FS.Exists(dst)
+	if existed {
+		if r.Header.Get("Overwrite") == "F" {
+			return http.StatusPreconditionFailed, nil
+		}
+		existing, err := h.FS.Stat(dst)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		// Un archivo que reemplaza a otro no hace falta borrarlo antes:
+		// Rename lo reemplaza en un paso y la copia lo sobrescribe, así que
+		// si algo falla el destino queda como estaba. Un directorio se
+		// borra para que el resultado no mezcle los dos árboles.
+		if info.IsDir || existing.IsDir {
+			if err := h.FS.RemoveAll(dst); err != nil {
+				return http.StatusInternalServerError, err
+			}
+		}
+		h.locks.forget(dst)
+	}
+
+	if move {
+		err = h.FS.Rename(src, dst)
+		h.locks.forget(src)
+	} else if info.IsDir && r.Header.Get("Depth") == "0" {
+		err = h.FS.CreateDir(dst, info.Mode)
+	} else {
+		err = h.FS.Copy(src, dst, minifs.CopyOptions{PreserveMode: true, Overwrite: minifs.OverwriteAlways})
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if existed {
+		return http.StatusNoContent, nil
+	}
+	return http.StatusCreated, nil
+}
+
+func (h *Handler) handlePropfind(w http.ResponseWriter, r *http.Request, p string) (int, error) {
+	info, err := h.FS.Stat(p)
+	if err != nil {
+		return http.StatusNotFound, nil
+	}
+
+	depth := r.Header.Get("Depth")
+	if depth == "" {
+		depth = "infinity"
+	}
+
+	ms := multistatus{XmlnsD: "DAV:"}
+	ms.Responses = append(ms.Responses, h.propResponse(p, info))
+
+	if info.IsDir && depth == "1" {
+		// Con Depth 1 alcanza con el directorio, sin recorrer el subárbol
+		infos, err := h.FS.ListDir(p)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		for _, ci := range infos {
+			ms.Responses = append(ms.Responses, h.propResponse(path.Join(p, ci.Name), ci))
+		}
+	} else if info.IsDir && depth != "0" {
+		err := h.FS.Walk(p, func(child string, ci minifs.FileInfo) error {
+			if child != p {
+				ms.Responses = append(ms.Responses, h.propResponse(child, ci))
+			}
+			return nil
+		})
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+	}
+	sort.Slice(ms.Responses[1:], func(i, j int) bool {
+		return ms.Responses[i+1].Href < ms.Responses[j+1].Href
+	})
+
+	return writeXML(w, http.StatusMultiStatus, ms)
+}
+
+func (h *Handler) propResponse(p string, info minifs.FileInfo) response {
+	pr := prop{
+		DisplayName:  info.Name,
+		LastModified: info.ModTime.UTC().Format(http.TimeFormat),
+		ETag:         ETag(info),
+	}
+	if info.IsDir {
+		pr.ResourceType.Collection = &struct{}{}
+	} else {
+		size := info.Size
+		pr.ContentLength = &size
+		pr.ContentType = mime.TypeByExtension(path.Ext(p))
+		if pr.ContentType == "" {
+			pr.ContentType = "application/octet-stream"
+		}
+	}
+
+	return response{
+		Href: h.href(p, info.IsDir),
+		Propstat: propstat{
+			Prop:   pr,
+			Status: "HTTP/1.1 200 OK",
+		},
+	}
+}
+
+func (h *Handler) handleLock(w http.ResponseWriter, r *http.Request, p string) (int, error) {
+	timeout := parseTimeout(r.Header.Get("Timeout"))
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	var l *lock
+	status := http.StatusOK
+	if len(bytes.TrimSpace(body)) == 0 {
+		// Sin cuerpo es una renovación del candado indicado en If
+		tokens := ifTokens(r.Header.Get("If"))
+		if len(tokens) == 0 {
+			return http.StatusBadRequest, fmt.Errorf("falta el token a renovar")
+		}
+		if l = h.locks.refresh(p, tokens[0], timeout); l == nil {
+			return http.StatusPreconditionFailed, nil
+		}
+	} else {
+		var info lockInfo
+		if err := xml.Unmarshal(body, &info); err != nil {
+			return http.StatusBadRequest, err
+		}
+		if info.Exclusive == nil {
+			return http.StatusNotImplemented, fmt.Errorf("sólo se soportan candados exclusivos")
+		}
+		if !h.parentExists(p) {
+			return http.StatusConflict, nil
+		}
+
+		deep := r.Header.Get("Depth") != "0"
+		if l = h.locks.create(p, strings.TrimSpace(info.Owner.Inner), deep, timeout); l == nil {
+			return http.StatusLocked, nil
+		}
+
+		// Bloquear un recurso inexistente crea un archivo vacío
+		if !h.FS.Exists(p) {
+			if err := h.FS.WriteFile(p, nil); err != nil {
+				h.locks.unlock(p, l.token)
+				return http.StatusInternalServerError, err
+			}
+			status = http.StatusCreated
+		}
+		w.Header().Set("Lock-Token", "<"+l.token+">")
+	}
+
+	return writeXML(w, status, lockResponse(h.href(l.path, false), l))
+}
+
+func (h *Handler) handleUnlock(w http.ResponseWriter, r *http.Request, p string) (int, error) {
+	token := strings.Trim(r.Header.Get("Lock-Token"), "<>")
+	if token == "" {
+		return http.StatusBadRequest, fmt.Errorf("falta la cabecera Lock-Token")
+	}
+	if !h.locks.unlock(p, token) {
+		return http.StatusConflict, nil
+	}
+	return http.StatusNoContent, nil
+}
+
+// Estructuras XML de las respuestas. Usamos el prefijo D: para el espacio
+// de nombres "DAV:" como hacen la mayoría de los servidores.
+
+type multistatus struct {
+	XMLName   xml.Name   `xml:"D:multistatus"`
+	XmlnsD    string     `xml:"xmlns:D,attr"`
+	Responses []response `xml:"D:response"`
+}
+
+type response struct {
+	Href     string   `xml:"D:href"`
+	Propstat propstat `xml:"D:propstat"`
+}
+
+type propstat struct {
+	Prop   prop   `xml:"D:prop"`
+	Status string `xml:"D:status"`
+}
+
+type prop struct {
+	DisplayName   string       `xml:"D:displayname"`
+	ResourceType  resourceType `xml:"D:resourcetype"`
+	ContentLength *int64       `xml:"D:getcontentlength,omitempty"`
+	ContentType   string       `xml:"D:getcontenttype,omitempty"`
+	LastModified  string       `xml:"D:getlastmodified"`
+	ETag          string       `xml:"D:getetag"`
+}
+
+type resourceType struct {
+	Collection *struct{} `xml:"D:collection,omitempty"`
+}
+
+type lockInfo struct {
+	XMLName   xml.Name  `xml:"DAV: lockinfo"`
+	Exclusive *struct{} `xml:"DAV: lockscope>exclusive"`
+	Owner     struct {
+		Inner string `xml:",innerxml"`
+	} `xml:"DAV: owner"`
+}
+
+type lockDiscovery struct {
+	XMLName  xml.Name `xml:"D:prop"`
+	XmlnsD   string   `xml:"xmlns:D,attr"`
+	Type     string   `xml:"D:lockdiscovery>D:activelock>D:locktype>D:write"`
+	Scope    string   `xml:"D:lockdiscovery>D:activelock>D:lockscope>D:exclusive"`
+	Depth    string   `xml:"D:lockdiscovery>D:activelock>D:depth"`
+	Timeout  string   `xml:"D:lockdiscovery>D:activelock>D:timeout"`
+	Token    string   `xml:"D:lockdiscovery>D:activelock>D:locktoken>D:href"`
+	LockRoot string   `xml:"D:lockdiscovery>D:activelock>D:lockroot>D:href"`
+}
+
+func lockResponse(root string, l *lock) lockDiscovery {
+	depth := "0"
+	if l.deep {
+		depth = "infinity"
+	}
+	secs := int(time.Until(l.expires).Round(time.Second).Seconds())
+	return lockDiscovery{
+		XmlnsD:   "DAV:",
+		Depth:    depth,
+		Timeout:  fmt.Sprintf("Second-%d", secs),
+		Token:    l.token,
+		LockRoot: root,
+	}
+}
+
+func writeXML(w http.ResponseWriter, status int, v any) (int, error) {
+	out, err := xml.Marshal(v)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(status)
+	io.WriteString(w, xml.Header)
+	w.Write(out)
+	return 0, nil
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
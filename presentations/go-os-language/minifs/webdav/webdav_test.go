@@ -0,0 +1,285 @@
+package webdav
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/hectorip/minifs"
+)
+
+type client struct {
+	t   *testing.T
+	srv *httptest.Server
+}
+
+func (c *client) do(method, path, body string, headers map[string]string) *http.Response {
+	c.t.Helper()
+	req, err := http.NewRequest(method, c.srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.srv.Client().Do(req)
+	if err != nil {
+		c.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	return resp
+}
+
+func (c *client) expect(resp *http.Response, status int) string {
+	c.t.Helper()
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != status {
+		c.t.Fatalf("%s %s: status %d, want %d: %s",
+			resp.Request.Method, resp.Request.URL.Path, resp.StatusCode, status, body)
+	}
+	return string(body)
+}
+
+func newTestServer(t *testing.T) (*client, *minifs.FileSystem) {
+	fs := minifs.NewFileSystem()
+	h := NewHandler(fs)
+	h.Prefix = "/dav"
+
+	mux := http.NewServeMux()
+	mux.Handle("/dav/", h)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return &client{t: t, srv: srv}, fs
+}
+
+func TestWebDAV(t *testing.T) {
+	c, fs := newTestServer(t)
+
+	t.Run("MkcolPutGet", func(t *testing.T) {
+		c.expect(c.do("MKCOL", "/dav/docs", "", nil), http.StatusCreated)
+		c.expect(c.do("MKCOL", "/dav/docs", "", nil), http.StatusMethodNotAllowed)
+		c.expect(c.do("MKCOL", "/dav/a/b", "", nil), http.StatusConflict)
+
+		c.expect(c.do("PUT", "/dav/docs/hola.txt", "hola mundo", nil), http.StatusCreated)
+		c.expect(c.do("PUT", "/dav/docs/hola.txt", "hola, mundo!", nil), http.StatusNoContent)
+		c.expect(c.do("PUT", "/dav/nada/x.txt", "x", nil), http.StatusConflict)
+
+		body := c.expect(c.do("GET", "/dav/docs/hola.txt", "", nil), http.StatusOK)
+		if body != "hola, mundo!" {
+			t.Errorf("Contenido incorrecto: %q", body)
+		}
+
+		if data, _ := fs.ReadFile("/docs/hola.txt"); string(data) != body {
+			t.Error("El archivo no llegó al FileSystem")
+		}
+	})
+
+	t.Run("RangeAndETag", func(t *testing.T) {
+		resp := c.do("GET", "/dav/docs/hola.txt", "", map[string]string{"Range": "bytes=6-10"})
+		if body := c.expect(resp, http.StatusPartialContent); body != "mundo" {
+			t.Errorf("Rango incorrecto: %q", body)
+		}
+
+		info, _ := fs.Stat("/docs/hola.txt")
+		etag := resp.Header.Get("ETag")
+		if etag != ETag(info) {
+			t.Errorf("ETag incorrecto: %s, want %s", etag, ETag(info))
+		}
+
+		resp = c.do("GET", "/dav/docs/hola.txt", "", map[string]string{"If-None-Match": etag})
+		c.expect(resp, http.StatusNotModified)
+
+		// Al cambiar el contenido cambia el ETag
+		fs.WriteFile("/docs/hola.txt", []byte("otro contenido más largo"))
+		c.expect(c.do("GET", "/dav/docs/hola.txt", "", map[string]string{"If-None-Match": etag}), http.StatusOK)
+	})
+
+	t.Run("Propfind", func(t *testing.T) {
+		fs.MkdirAll("/docs/sub/deep", 0755)
+
+		body := c.expect(c.do("PROPFIND", "/dav/docs", "", map[string]string{"Depth": "1"}), http.StatusMultiStatus)
+
+		var ms struct {
+			Responses []struct {
+				Href          string    `xml:"DAV: href"`
+				ContentLength string    `xml:"DAV: propstat>prop>getcontentlength"`
+				Collection    *struct{} `xml:"DAV: propstat>prop>resourcetype>collection"`
+			} `xml:"DAV: response"`
+		}
+		if err := xml.Unmarshal([]byte(body), &ms); err != nil {
+			t.Fatalf("XML inválido: %v\n%s", err, body)
+		}
+
+		if len(ms.Responses) != 3 {
+			t.Fatalf("Se esperaban 3 respuestas con Depth 1, got %d:\n%s", len(ms.Responses), body)
+		}
+		if ms.Responses[0].Href != "/dav/docs/" || ms.Responses[0].Collection == nil {
+			t.Errorf("Primera respuesta incorrecta: %+v", ms.Responses[0])
+		}
+		if ms.Responses[1].Href != "/dav/docs/hola.txt" || ms.Responses[1].ContentLength != "25" {
+			t.Errorf("Respuesta del archivo incorrecta: %+v", ms.Responses[1])
+		}
+
+		body = c.expect(c.do("PROPFIND", "/dav/docs", "", map[string]string{"Depth": "infinity"}), http.StatusMultiStatus)
+		if !strings.Contains(body, "/dav/docs/sub/deep/") {
+			t.Error("Depth infinity no incluyó los descendientes")
+		}
+
+		c.expect(c.do("PROPFIND", "/dav/nada", "", nil), http.StatusNotFound)
+	})
+
+	t.Run("CopyMove", func(t *testing.T) {
+		dest := func(p string) map[string]string {
+			return map[string]string{"Destination": c.srv.URL + p}
+		}
+
+		c.expect(c.do("COPY", "/dav/docs", "", dest("/dav/copia")), http.StatusCreated)
+		if !fs.Exists("/copia/sub/deep") || !fs.Exists("/copia/hola.txt") {
+			t.Error("COPY no copió el árbol")
+		}
+
+		c.expect(c.do("COPY", "/dav/docs", "", dest("/dav/docs/sub/loop")), http.StatusBadRequest)
+
+		headers := dest("/dav/copia")
+		headers["Overwrite"] = "F"
+		c.expect(c.do("MOVE", "/dav/docs/hola.txt", "", headers), http.StatusPreconditionFailed)
+
+		c.expect(c.do("MOVE", "/dav/copia", "", dest("/dav/movido")), http.StatusCreated)
+		if fs.Exists("/copia") || !fs.Exists("/movido/hola.txt") {
+			t.Error("MOVE no movió el directorio")
+		}
+
+		c.expect(c.do("MOVE", "/dav/docs/hola.txt", "", dest("/dav/movido/hola.txt")), http.StatusNoContent)
+
+		// Un destino que solo comparte el comienzo con el prefijo está
+		// fuera del servidor
+		c.expect(c.do("COPY", "/dav/movido/hola.txt", "", dest("/davx/hola.txt")), http.StatusBadGateway)
+		if fs.Exists("/x/hola.txt") || fs.Exists("/hola.txt") {
+			t.Error("COPY resolvió un destino fuera del prefijo")
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		c.expect(c.do("DELETE", "/dav/movido", "", nil), http.StatusNoContent)
+		c.expect(c.do("DELETE", "/dav/movido", "", nil), http.StatusNotFound)
+		if fs.Exists("/movido") {
+			t.Error("DELETE no eliminó el directorio")
+		}
+	})
+
+	t.Run("Options", func(t *testing.T) {
+		resp := c.do("OPTIONS", "/dav/", "", nil)
+		c.expect(resp, http.StatusOK)
+		if resp.Header.Get("DAV") != "1, 2" {
+			t.Errorf("Cabecera DAV incorrecta: %q", resp.Header.Get("DAV"))
+		}
+	})
+}
+
+func TestCopyOverwrite(t *testing.T) {
+	c, fs := newTestServer(t)
+	fs.SetVersioning(minifs.VersioningOptions{MaxVersions: 5, Trash: true})
+	fs.WriteFile("/a.txt", []byte("nuevo"))
+	fs.WriteFile("/b.txt", []byte("viejo"))
+	fs.MkdirAll("/dir/x", 0755)
+	fs.MkdirAll("/otro/y", 0755)
+	dest := func(p string) map[string]string {
+		return map[string]string{"Destination": c.srv.URL + p}
+	}
+
+	// El archivo se sobrescribe en su lugar y guarda la revisión anterior
+	c.expect(c.do("COPY", "/dav/a.txt", "", dest("/dav/b.txt")), http.StatusNoContent)
+	if data, _ := fs.ReadFile("/b.txt"); string(data) != "nuevo" {
+		t.Errorf("COPY sobre un archivo: %q", data)
+	}
+	if data, err := fs.ReadVersion("/b.txt", 1); string(data) != "viejo" {
+		t.Errorf("COPY no guardó la revisión anterior: %q %v", data, err)
+	}
+	if trash := fs.Trash(); len(trash) != 0 {
+		t.Errorf("COPY mandó el destino a la papelera: %v", trash)
+	}
+
+	// Un directorio reemplaza al destino en lugar de mezclarse con él
+	c.expect(c.do("COPY", "/dav/dir", "", dest("/dav/otro")), http.StatusNoContent)
+	if !fs.Exists("/otro/x") || fs.Exists("/otro/y") {
+		t.Error("COPY de un directorio mezcló los árboles")
+	}
+	c.expect(c.do("MOVE", "/dav/a.txt", "", dest("/dav/otro")), http.StatusNoContent)
+	if info, err := fs.Stat("/otro"); err != nil || info.IsDir {
+		t.Errorf("MOVE de un archivo sobre un directorio: %v", err)
+	}
+}
+
+func TestPropfindDepth(t *testing.T) {
+	c, fs := newTestServer(t)
+	fs.MkdirAll("/docs/lento", 0755)
+	fs.WriteFile("/docs/a.txt", []byte("a"))
+
+	// Un subárbol que falla al recorrerlo: Depth 1 no tiene que entrar
+	sub := minifs.NewFileSystem()
+	sub.WriteFile("/b.txt", []byte("b"))
+	faulty := minifs.NewFaultFS(sub, 1)
+	faulty.AddRule(minifs.Rule{Op: "Walk", Err: syscall.EIO})
+	if err := fs.Mount("/docs/lento", faulty, minifs.MountOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	body := c.expect(c.do("PROPFIND", "/dav/docs", "", map[string]string{"Depth": "1"}), http.StatusMultiStatus)
+	for _, href := range []string{"/dav/docs/", "/dav/docs/a.txt", "/dav/docs/lento/"} {
+		if !strings.Contains(body, "<D:href>"+href+"</D:href>") {
+			t.Errorf("Falta %s con Depth 1:\n%s", href, body)
+		}
+	}
+	if strings.Contains(body, "b.txt") {
+		t.Errorf("Depth 1 incluyó un nieto:\n%s", body)
+	}
+
+	c.expect(c.do("PROPFIND", "/dav/docs", "", map[string]string{"Depth": "infinity"}), http.StatusInternalServerError)
+}
+
+func TestWebDAVLocks(t *testing.T) {
+	c, fs := newTestServer(t)
+	fs.MkdirAll("/proj", 0755)
+
+	lockBody := `<?xml version="1.0"?>
+<D:lockinfo xmlns:D="DAV:">
+  <D:lockscope><D:exclusive/></D:lockscope>
+  <D:locktype><D:write/></D:locktype>
+  <D:owner>tester</D:owner>
+</D:lockinfo>`
+
+	resp := c.do("LOCK", "/dav/proj", lockBody, map[string]string{"Timeout": "Second-60"})
+	body := c.expect(resp, http.StatusOK)
+	token := strings.Trim(resp.Header.Get("Lock-Token"), "<>")
+	if !strings.HasPrefix(token, "opaquelocktoken:") || !strings.Contains(body, token) {
+		t.Fatalf("Token inválido %q en:\n%s", token, body)
+	}
+
+	// Un segundo candado sobre un descendiente entra en conflicto
+	c.expect(c.do("LOCK", "/dav/proj/a.txt", lockBody, nil), http.StatusLocked)
+
+	// Sin el token no se puede escribir dentro del directorio bloqueado
+	c.expect(c.do("PUT", "/dav/proj/a.txt", "x", nil), http.StatusLocked)
+	c.expect(c.do("DELETE", "/dav/proj", "", nil), http.StatusLocked)
+
+	withToken := map[string]string{"If": "(<" + token + ">)"}
+	c.expect(c.do("PUT", "/dav/proj/a.txt", "x", withToken), http.StatusCreated)
+
+	// Renovar el candado
+	c.expect(c.do("LOCK", "/dav/proj", "", withToken), http.StatusOK)
+
+	c.expect(c.do("UNLOCK", "/dav/proj", "", map[string]string{"Lock-Token": "<opaquelocktoken:otro>"}), http.StatusConflict)
+	c.expect(c.do("UNLOCK", "/dav/proj", "", map[string]string{"Lock-Token": "<" + token + ">"}), http.StatusNoContent)
+	c.expect(c.do("PUT", "/dav/proj/a.txt", "y", nil), http.StatusNoContent)
+
+	// Bloquear un recurso inexistente lo crea vacío
+	c.expect(c.do("LOCK", "/dav/proj/nuevo.txt", lockBody, map[string]string{"Depth": "0"}), http.StatusCreated)
+	if info, err := fs.Stat("/proj/nuevo.txt"); err != nil || info.Size != 0 {
+		t.Errorf("LOCK no creó el recurso vacío: %v", err)
+	}
+}